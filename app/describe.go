@@ -0,0 +1,73 @@
+package app
+
+import (
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+)
+
+// Description describes a trigger or action instance of the app, it is
+// serializable to JSON
+type Description struct {
+	// Kind is the kind of the instance, "Trigger" or "Action"
+	Kind string `json:"kind"`
+
+	Id  string `json:"id"`
+	Ref string `json:"ref"`
+
+	// Metadata is the resolved metadata of the instance, nil if it has none
+	Metadata interface{} `json:"metadata,omitempty"`
+
+	// NoMetadata is set if the instance doesn't provide metadata
+	NoMetadata bool `json:"noMetadata,omitempty"`
+}
+
+// Describe creates the trigger and action instances in the configuration and
+// returns their descriptions, triggers first, in configuration order.  The
+// instances aren't initialized or started
+func (h *InstanceHelper) Describe() ([]*Description, error) {
+
+	triggers, err := h.CreateTriggers()
+	if err != nil {
+		return nil, err
+	}
+
+	actions, err := h.CreateActions()
+	if err != nil {
+		return nil, err
+	}
+
+	var descriptions []*Description
+
+	for _, tConfig := range h.app.Triggers {
+		if tConfig == nil {
+			continue
+		}
+
+		description := &Description{Kind: "Trigger", Id: tConfig.Id, Ref: tConfig.Ref}
+
+		if md := triggers[tConfig.Id].Interf.Metadata(); md != nil {
+			description.Metadata = md
+		} else {
+			description.NoMetadata = true
+		}
+
+		descriptions = append(descriptions, description)
+	}
+
+	for _, aConfig := range h.app.Actions {
+		if aConfig == nil {
+			continue
+		}
+
+		description := &Description{Kind: "Action", Id: aConfig.Id, Ref: aConfig.Ref}
+
+		if provider, ok := actions[aConfig.Id].(action.MetadataProvider); ok && provider.Metadata() != nil {
+			description.Metadata = provider.Metadata()
+		} else {
+			description.NoMetadata = true
+		}
+
+		descriptions = append(descriptions, description)
+	}
+
+	return descriptions, nil
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestDescribe
+func TestDescribe(t *testing.T) {
+
+	app := getMockApp()
+	app.Triggers = append(app.Triggers, &trigger.Config{Id: "myTrigger2", Ref: "described"})
+	app.Actions = append(app.Actions, &action.Config{Id: "myAction2", Ref: "described"})
+
+	tFactories := map[string]trigger.Factory{"github.com/TIBCOSoftware/flogo-lib/app/mocktrigger": &MockTriggerFactory{}, "described": &describedTriggerFactory{}}
+	aFactories := map[string]action.Factory{"github.com/TIBCOSoftware/flogo-lib/app/mockaction": &MockActionFactory{}, "described": &describedActionFactory{}}
+
+	descriptions, err := NewInstanceHelper(app, tFactories, aFactories).Describe()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(descriptions))
+
+	// the mock trigger has no metadata, it is still described
+	assert.Equal(t, &Description{Kind: "Trigger", Id: "myTrigger1", Ref: "github.com/TIBCOSoftware/flogo-lib/app/mocktrigger", NoMetadata: true}, descriptions[0])
+
+	assert.Equal(t, "myTrigger2", descriptions[1].Id)
+	assert.False(t, descriptions[1].NoMetadata)
+	assert.Equal(t, "described", descriptions[1].Metadata.(*trigger.Metadata).ID)
+
+	assert.Equal(t, &Description{Kind: "Action", Id: "myAction1", Ref: "github.com/TIBCOSoftware/flogo-lib/app/mockaction", NoMetadata: true}, descriptions[2])
+	assert.Equal(t, &Description{Kind: "Action", Id: "myAction2", Ref: "described", Metadata: map[string]string{"version": "1.0"}}, descriptions[3])
+
+	_, err = json.Marshal(descriptions)
+	assert.Nil(t, err)
+}
+
+// describedTriggerFactory creates triggers that provide metadata
+type describedTriggerFactory struct {
+}
+
+func (f *describedTriggerFactory) New(config *trigger.Config) trigger.Trigger {
+	return &describedTrigger{}
+}
+
+type describedTrigger struct {
+	MockTrigger
+}
+
+func (t *describedTrigger) Metadata() *trigger.Metadata {
+	return &trigger.Metadata{ID: "described"}
+}
+
+// describedActionFactory creates actions that provide metadata
+type describedActionFactory struct {
+}
+
+func (f *describedActionFactory) New(config *action.Config) action.Action {
+	return &describedAction{}
+}
+
+type describedAction struct {
+	MockAction
+}
+
+func (a *describedAction) Metadata() interface{} {
+	return map[string]string{"version": "1.0"}
+}
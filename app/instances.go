@@ -2,11 +2,17 @@ package app
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/TIBCOSoftware/flogo-lib/core/action"
 	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
 )
 
+// MaxConcurrentCreates is the maximum number of trigger or action instances
+// created concurrently
+var MaxConcurrentCreates = 8
+
 //InstanceHelper helps to create the instances for a given id
 type InstanceHelper struct {
 	app        *Config
@@ -19,36 +25,104 @@ func NewInstanceHelper(app *Config, tFactories map[string]trigger.Factory, aFact
 	return &InstanceHelper{app: app, tFactories: tFactories, aFactories: aFactories}
 }
 
+// CreateError aggregates the failures to create the trigger or action
+// instances of an app
+type CreateError struct {
+	// Kind is the kind of the instances, "Trigger" or "Action"
+	Kind string
+
+	// Failed are the IDs of the instances that couldn't be created, in
+	// configuration order
+	Failed []string
+
+	// Errors are the causes of the failures by ID
+	Errors map[string]error
+
+	// Succeeded are the IDs of the instances that were created, in
+	// configuration order
+	Succeeded []string
+}
+
+// Error implements error.Error
+func (e *CreateError) Error() string {
+
+	causes := make([]string, len(e.Failed))
+	for i, id := range e.Failed {
+		causes[i] = fmt.Sprintf("'%s': %s", id, e.Errors[id].Error())
+	}
+
+	msg := fmt.Sprintf("%ss %s failed", e.Kind, quoteIDs(e.Failed))
+
+	if len(e.Succeeded) > 0 {
+		msg += fmt.Sprintf("; %ss %s succeeded", e.Kind, quoteIDs(e.Succeeded))
+	}
+
+	return msg + " - " + strings.Join(causes, ", ")
+}
+
+func quoteIDs(ids []string) string {
+
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = "'" + id + "'"
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
 //CreateTriggers creates new instances for triggers in the configuration
 func (h *InstanceHelper) CreateTriggers() (map[string]*trigger.TriggerInstance, error) {
 
-	// Get Trigger instances from configuration
-	tConfigs := h.app.Triggers
+	if err := newValidationError(h.app.validateTriggers(h.tFactories)); err != nil {
+		return nil, err
+	}
 
-	instances := make(map[string]*trigger.TriggerInstance, len(tConfigs))
+	// Get Trigger instances from configuration
+	var tConfigs []*trigger.Config
 
-	for _, tConfig := range tConfigs {
-		if tConfig == nil {
-			continue
+	for _, tConfig := range h.app.Triggers {
+		if tConfig != nil {
+			tConfigs = append(tConfigs, tConfig)
 		}
+	}
 
-		_, ok := instances[tConfig.Id]
-		if ok {
-			return nil, fmt.Errorf("Trigger with id '%s' already registered, trigger ids have to be unique", tConfig.Id)
-		}
+	created := make([]*trigger.TriggerInstance, len(tConfigs))
 
-		factory, ok := h.tFactories[tConfig.Ref]
-		if !ok {
-			return nil, fmt.Errorf("Trigger Factory '%s' not registered", tConfig.Ref)
-		}
+	errs := createConcurrently(len(tConfigs), func(i int) error {
 
-		newInterface := factory.New(tConfig)
+		tConfig := tConfigs[i]
+
+		newInterface := h.tFactories[tConfig.Ref].New(tConfig)
 
 		if newInterface == nil {
-			return nil, fmt.Errorf("Cannot create Trigger nil for id '%s'", tConfig.Id)
+			return fmt.Errorf("Cannot create Trigger nil for id '%s'", tConfig.Id)
+		}
+
+		if tConfig.Restart != nil {
+			supervisor, err := trigger.NewSupervisor(tConfig.Id, newInterface, tConfig.Restart)
+			if err != nil {
+				return err
+			}
+			newInterface = supervisor
 		}
 
-		instances[tConfig.Id] = &trigger.TriggerInstance{Config: tConfig, Interf: newInterface}
+		created[i] = &trigger.TriggerInstance{Config: tConfig, Interf: newInterface}
+		return nil
+	})
+
+	configIDs := make([]string, len(tConfigs))
+	for i, tConfig := range tConfigs {
+		configIDs[i] = tConfig.Id
+	}
+
+	if err := newCreateError("Trigger", configIDs, errs); err != nil {
+		return nil, err
+	}
+
+	instances := make(map[string]*trigger.TriggerInstance, len(tConfigs))
+
+	for _, instance := range created {
+		instances[instance.Config.Id] = instance
 	}
 
 	return instances, nil
@@ -57,34 +131,110 @@ func (h *InstanceHelper) CreateTriggers() (map[string]*trigger.TriggerInstance,
 //CreateActions creates new instances for actions in the configuration
 func (h *InstanceHelper) CreateActions() (map[string]action.Action, error) {
 
-	// Get Action instances from configuration
-	aConfigs := h.app.Actions
+	if err := newValidationError(h.app.validateActions(h.aFactories)); err != nil {
+		return nil, err
+	}
 
-	actions := make(map[string]action.Action, len(aConfigs))
+	// Get Action instances from configuration
+	var aConfigs []*action.Config
 
-	for _, aConfig := range aConfigs {
-		if aConfig == nil {
-			continue
+	for _, aConfig := range h.app.Actions {
+		if aConfig != nil {
+			aConfigs = append(aConfigs, aConfig)
 		}
+	}
 
-		_, ok := actions[aConfig.Id]
-		if ok {
-			return nil, fmt.Errorf("Action with id '%s' already registered, action ids have to be unique", aConfig.Id)
-		}
+	created := make([]action.Action, len(aConfigs))
 
-		factory, ok := h.aFactories[aConfig.Ref]
-		if !ok {
-			return nil, fmt.Errorf("Action Factory '%s' not registered", aConfig.Ref)
-		}
+	errs := createConcurrently(len(aConfigs), func(i int) error {
+
+		aConfig := aConfigs[i]
 
-		newAction := factory.New(aConfig)
+		newAction := h.aFactories[aConfig.Ref].New(aConfig)
 
 		if newAction == nil {
-			return nil, fmt.Errorf("Cannot create Action nil for id '%s'", aConfig.Id)
+			return fmt.Errorf("Cannot create Action nil for id '%s'", aConfig.Id)
 		}
 
-		actions[aConfig.Id] = newAction
+		created[i] = newAction
+		return nil
+	})
+
+	configIDs := make([]string, len(aConfigs))
+	for i, aConfig := range aConfigs {
+		configIDs[i] = aConfig.Id
+	}
+
+	if err := newCreateError("Action", configIDs, errs); err != nil {
+		return nil, err
+	}
+
+	actions := make(map[string]action.Action, len(aConfigs))
+
+	for i, newAction := range created {
+		actions[aConfigs[i].Id] = newAction
 	}
 
 	return actions, nil
 }
+
+// createConcurrently calls create for each of the n instances, using up to
+// MaxConcurrentCreates goroutines, and returns the errors by index.  A
+// create that panics fails with the recovered value
+func createConcurrently(n int, create func(i int) error) []error {
+
+	errs := make([]error, n)
+
+	workers := MaxConcurrentCreates
+	if workers < 1 {
+		workers = 1
+	}
+
+	sem := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("%v", r)
+				}
+			}()
+
+			errs[i] = create(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return errs
+}
+
+// newCreateError aggregates the errors of the instances with the specified
+// ids, returns nil if there are none
+func newCreateError(kind string, ids []string, errs []error) error {
+
+	createErr := &CreateError{Kind: kind, Errors: make(map[string]error)}
+
+	for i, id := range ids {
+		if errs[i] != nil {
+			createErr.Failed = append(createErr.Failed, id)
+			createErr.Errors[id] = errs[i]
+		} else {
+			createErr.Succeeded = append(createErr.Succeeded, id)
+		}
+	}
+
+	if len(createErr.Failed) == 0 {
+		return nil
+	}
+
+	return createErr
+}
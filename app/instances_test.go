@@ -43,6 +43,111 @@ func TestCreateActionsOk(t *testing.T) {
 	assert.Equal(t, 1, len(actions))
 }
 
+//TestCreateTriggersAggregatesErrors
+func TestCreateTriggersAggregatesErrors(t *testing.T) {
+
+	ref := "github.com/TIBCOSoftware/flogo-lib/app/mocktrigger"
+
+	app := &Config{Name: "MyApp", Version: "1.0.0", Triggers: []*trigger.Config{
+		{Id: "A", Ref: "nil"},
+		{Id: "B", Ref: ref},
+		{Id: "C", Ref: "nil"},
+		{Id: "D", Ref: ref},
+	}}
+
+	tFactories := map[string]trigger.Factory{ref: &MockTriggerFactory{}, "nil": &nilTriggerFactory{}}
+
+	helper := NewInstanceHelper(app, tFactories, nil)
+
+	triggers, err := helper.CreateTriggers()
+
+	assert.Nil(t, triggers)
+
+	createErr, ok := err.(*CreateError)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"A", "C"}, createErr.Failed)
+	assert.Equal(t, []string{"B", "D"}, createErr.Succeeded)
+	assert.Contains(t, err.Error(), "Triggers 'A', 'C' failed; Triggers 'B', 'D' succeeded")
+	assert.Contains(t, err.Error(), "'A': Cannot create Trigger nil for id 'A'")
+}
+
+// nilTriggerFactory fails to create triggers
+type nilTriggerFactory struct {
+}
+
+func (f *nilTriggerFactory) New(config *trigger.Config) trigger.Trigger {
+	return nil
+}
+
+//TestValidate
+func TestValidate(t *testing.T) {
+
+	ref := "github.com/TIBCOSoftware/flogo-lib/app/mocktrigger"
+
+	app := getMockApp()
+	app.Triggers = append(app.Triggers,
+		&trigger.Config{Id: "myTrigger1", Ref: ref},
+		&trigger.Config{Ref: "unknown", Handlers: []*trigger.HandlerConfig{{}}})
+	app.Actions = append(app.Actions, &action.Config{Id: "myAction2"})
+
+	tFactories := map[string]trigger.Factory{ref: &MockTriggerFactory{}}
+	aFactories := map[string]action.Factory{"github.com/TIBCOSoftware/flogo-lib/app/mockaction": &MockActionFactory{}}
+
+	err := app.Validate(tFactories, aFactories)
+
+	validationErr, ok := err.(*ValidationError)
+	assert.True(t, ok)
+
+	var problems []string
+	for _, violation := range validationErr.Violations {
+		problems = append(problems, violation.String())
+	}
+
+	assert.Equal(t, []string{
+		"Trigger 'myTrigger1': id is already used, trigger ids have to be unique",
+		"Trigger #2: Trigger Factory 'unknown' not registered",
+		"Action 'myAction2': ref is required",
+	}, problems)
+
+	// the helper fails upfront
+	_, err = NewInstanceHelper(app, tFactories, aFactories).CreateTriggers()
+	assert.IsType(t, &ValidationError{}, err)
+
+	assert.Nil(t, getMockApp().Validate(tFactories, aFactories))
+}
+
+//TestCreateActionsAggregatesErrors
+func TestCreateActionsAggregatesErrors(t *testing.T) {
+
+	ref := "github.com/TIBCOSoftware/flogo-lib/app/mockaction"
+
+	app := &Config{Name: "MyApp", Version: "1.0.0", Actions: []*action.Config{
+		{Id: "A", Ref: ref},
+		{Id: "B", Ref: "panicking"},
+	}}
+
+	aFactories := map[string]action.Factory{ref: &MockActionFactory{}, "panicking": &panickingActionFactory{}}
+
+	helper := NewInstanceHelper(app, nil, aFactories)
+
+	actions, err := helper.CreateActions()
+
+	assert.Nil(t, actions)
+
+	createErr, ok := err.(*CreateError)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"B"}, createErr.Failed)
+	assert.Equal(t, "invalid flow", createErr.Errors["B"].Error())
+}
+
+// panickingActionFactory panics when creating an action
+type panickingActionFactory struct {
+}
+
+func (f *panickingActionFactory) New(config *action.Config) action.Action {
+	panic("invalid flow")
+}
+
 //MockTriggerFactory
 type MockTriggerFactory struct {
 }
@@ -106,3 +211,29 @@ func getMockApp() *Config {
 
 	return &Config{Name: "MyApp", Version: "1.0.0", Triggers: triggers, Actions: actions}
 }
+
+//TestCreateSupervisedTriggers
+func TestCreateSupervisedTriggers(t *testing.T) {
+
+	ref := "github.com/TIBCOSoftware/flogo-lib/app/mocktrigger"
+
+	app := &Config{Name: "MyApp", Version: "1.0.0", Triggers: []*trigger.Config{
+		{Id: "plain", Ref: ref},
+		{Id: "supervised", Ref: ref, Restart: &trigger.RestartPolicy{MaxAttempts: 3, Backoff: "1s"}},
+	}}
+
+	tFactories := map[string]trigger.Factory{ref: &MockTriggerFactory{}}
+
+	triggers, err := NewInstanceHelper(app, tFactories, nil).CreateTriggers()
+	assert.Nil(t, err)
+
+	assert.IsType(t, &MockTrigger{}, triggers["plain"].Interf)
+	assert.IsType(t, &trigger.Supervisor{}, triggers["supervised"].Interf)
+
+	// an invalid policy fails the validation
+	app.Triggers[1].Restart.Backoff = "soon"
+
+	_, err = NewInstanceHelper(app, tFactories, nil).CreateTriggers()
+	assert.IsType(t, &ValidationError{}, err)
+	assert.Contains(t, err.Error(), "Trigger 'supervised': invalid restart backoff 'soon'")
+}
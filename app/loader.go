@@ -0,0 +1,106 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envPlaceholder matches ${ENV_VAR} and ${ENV_VAR:default}
+var envPlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::([^}]*))?\}`)
+
+// UnresolvedEnvError lists the environment variables referenced by an app
+// configuration that are not set and have no default
+type UnresolvedEnvError struct {
+	Vars []string
+}
+
+// Error implements error.Error
+func (e *UnresolvedEnvError) Error() string {
+	return fmt.Sprintf("Unresolved environment variables in app configuration: %s", strings.Join(e.Vars, ", "))
+}
+
+// LoadConfig reads an app configuration in JSON.  The ${ENV_VAR} and
+// ${ENV_VAR:default} placeholders in its strings are replaced by the values of
+// the environment variables, or by the defaults when the variables are not
+// set.  The ids and refs of the triggers and actions are validated, the
+// registration of the refs is checked when the instances are created
+func LoadConfig(r io.Reader) (*Config, error) {
+
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := interpolateEnv(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &Config{}
+
+	if err := json.NewDecoder(bytes.NewReader(resolved)).Decode(app); err != nil {
+		return nil, fmt.Errorf("Invalid app configuration - %s", err.Error())
+	}
+
+	if err := app.Validate(nil, nil); err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// LoadConfigFile reads the app configuration in the JSON file, see LoadConfig
+func LoadConfigFile(path string) (*Config, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadConfig(file)
+}
+
+// interpolateEnv replaces the environment placeholders in the JSON, the
+// values are escaped as they are in JSON strings
+func interpolateEnv(raw []byte) ([]byte, error) {
+
+	var missing []string
+	seen := make(map[string]bool)
+
+	resolved := envPlaceholder.ReplaceAllFunc(raw, func(placeholder []byte) []byte {
+
+		groups := envPlaceholder.FindSubmatch(placeholder)
+		name := string(groups[1])
+
+		value, ok := os.LookupEnv(name)
+
+		if !ok {
+			if !bytes.Contains(placeholder, []byte(":")) {
+				if !seen[name] {
+					seen[name] = true
+					missing = append(missing, name)
+				}
+				return placeholder
+			}
+			value = string(groups[2])
+		}
+
+		escaped, _ := json.Marshal(value)
+
+		// strip the quotes, the placeholder is inside a JSON string
+		return escaped[1 : len(escaped)-1]
+	})
+
+	if len(missing) > 0 {
+		return nil, &UnresolvedEnvError{Vars: missing}
+	}
+
+	return resolved, nil
+}
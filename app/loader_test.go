@@ -0,0 +1,67 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const envConfigJSON = `{
+  "name": "${APP_NAME}",
+  "version": "${APP_VERSION:1.0.0}",
+  "description": "${APP_DESC}",
+  "triggers": [{ "id": "myTrigger1", "ref": "github.com/TIBCOSoftware/flogo-lib/app/mocktrigger" }],
+  "actions": [{ "id": "myAction1", "ref": "github.com/TIBCOSoftware/flogo-lib/app/mockaction" }]
+}`
+
+//TestLoadConfig
+func TestLoadConfig(t *testing.T) {
+
+	os.Setenv("APP_NAME", "MyApp")
+	os.Setenv("APP_DESC", `a "quoted" description`)
+	defer os.Unsetenv("APP_NAME")
+	defer os.Unsetenv("APP_DESC")
+
+	app, err := LoadConfig(strings.NewReader(envConfigJSON))
+	assert.Nil(t, err)
+	assert.Equal(t, "MyApp", app.Name)
+	assert.Equal(t, "1.0.0", app.Version)
+	assert.Equal(t, `a "quoted" description`, app.Description)
+	assert.Equal(t, "myTrigger1", app.Triggers[0].Id)
+
+	// from a file
+	path := filepath.Join(os.TempDir(), "flogo-loadconfig-test.json")
+	err = ioutil.WriteFile(path, []byte(envConfigJSON), 0600)
+	assert.Nil(t, err)
+	defer os.Remove(path)
+
+	app, err = LoadConfigFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "MyApp", app.Name)
+}
+
+//TestLoadConfigUnresolvedEnv
+func TestLoadConfigUnresolvedEnv(t *testing.T) {
+
+	os.Unsetenv("APP_NAME")
+	os.Unsetenv("APP_DESC")
+
+	_, err := LoadConfig(strings.NewReader(envConfigJSON))
+	assert.EqualError(t, err, "Unresolved environment variables in app configuration: APP_NAME, APP_DESC")
+}
+
+//TestLoadConfigInvalid
+func TestLoadConfigInvalid(t *testing.T) {
+
+	_, err := LoadConfig(strings.NewReader(`{"name": "MyApp", "triggers": [{ "id": "t1" }, { "id": "t1", "ref": "r" }]}`))
+	assert.IsType(t, &ValidationError{}, err)
+	assert.Contains(t, err.Error(), "Trigger 't1': ref is required")
+	assert.Contains(t, err.Error(), "Trigger 't1': id is already used")
+
+	_, err = LoadConfig(strings.NewReader(`{"name": `))
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+)
+
+// TriggerStartOrder groups the ids of the triggers in the order they have to
+// be started according to their StartAfter dependencies.  The triggers of a
+// group only depend on the triggers of the previous groups, so they can be
+// started concurrently, the ids of a group are sorted.  An error is returned
+// if a dependency is unknown or the dependencies form a cycle
+func (c *Config) TriggerStartOrder() ([][]string, error) {
+
+	pending := make(map[string][]string, len(c.Triggers))
+
+	for _, tConfig := range c.Triggers {
+		if tConfig != nil {
+			pending[tConfig.Id] = tConfig.StartAfter
+		}
+	}
+
+	for id, after := range pending {
+		for _, dep := range after {
+			if _, ok := pending[dep]; !ok {
+				return nil, fmt.Errorf("Trigger '%s' startAfter references unknown trigger '%s'", id, dep)
+			}
+		}
+	}
+
+	if cycle := startCycle(c.Triggers); len(cycle) > 0 {
+		return nil, fmt.Errorf("Trigger startAfter dependencies form a cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	var order [][]string
+	started := make(map[string]bool, len(pending))
+
+	for len(pending) > 0 {
+
+		var group []string
+
+		for id, after := range pending {
+			ready := true
+			for _, dep := range after {
+				if !started[dep] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				group = append(group, id)
+			}
+		}
+
+		sort.Strings(group)
+
+		for _, id := range group {
+			started[id] = true
+			delete(pending, id)
+		}
+
+		order = append(order, group)
+	}
+
+	return order, nil
+}
+
+// startCycle returns the ids of a cycle in the StartAfter dependencies of the
+// triggers, starting and ending with the same id, nil if there is none
+func startCycle(tConfigs []*trigger.Config) []string {
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	after := make(map[string][]string, len(tConfigs))
+	var ids []string
+
+	for _, tConfig := range tConfigs {
+		if tConfig != nil {
+			after[tConfig.Id] = tConfig.StartAfter
+			ids = append(ids, tConfig.Id)
+		}
+	}
+
+	state := make(map[string]int, len(after))
+	var path []string
+	var cycle []string
+
+	var visit func(id string) bool
+	visit = func(id string) bool {
+
+		state[id] = visiting
+		path = append(path, id)
+
+		for _, dep := range after[id] {
+
+			if _, ok := after[dep]; !ok {
+				continue
+			}
+
+			switch state[dep] {
+			case visiting:
+				for i, pathID := range path {
+					if pathID == dep {
+						cycle = append(append(cycle, path[i:]...), dep)
+						break
+					}
+				}
+				return true
+			case unvisited:
+				if visit(dep) {
+					return true
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[id] = visited
+		return false
+	}
+
+	for _, id := range ids {
+		if state[id] == unvisited && visit(id) {
+			return cycle
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestTriggerStartOrder
+func TestTriggerStartOrder(t *testing.T) {
+
+	ref := "github.com/TIBCOSoftware/flogo-lib/app/mocktrigger"
+
+	app := &Config{Name: "MyApp", Version: "1.0.0", Triggers: []*trigger.Config{
+		{Id: "scheduler", Ref: ref, StartAfter: []string{"consumer", "rest"}},
+		{Id: "rest", Ref: ref},
+		{Id: "consumer", Ref: ref, StartAfter: []string{"queue"}},
+		{Id: "queue", Ref: ref},
+	}}
+
+	order, err := app.TriggerStartOrder()
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"queue", "rest"}, {"consumer"}, {"scheduler"}}, order)
+
+	assert.Nil(t, app.Validate(nil, nil))
+}
+
+//TestTriggerStartOrderCycle
+func TestTriggerStartOrderCycle(t *testing.T) {
+
+	ref := "github.com/TIBCOSoftware/flogo-lib/app/mocktrigger"
+
+	app := &Config{Name: "MyApp", Version: "1.0.0", Triggers: []*trigger.Config{
+		{Id: "a", Ref: ref, StartAfter: []string{"b"}},
+		{Id: "b", Ref: ref, StartAfter: []string{"c"}},
+		{Id: "c", Ref: ref, StartAfter: []string{"a"}},
+		{Id: "d", Ref: ref, StartAfter: []string{"unknown"}},
+	}}
+
+	_, err := app.TriggerStartOrder()
+	assert.EqualError(t, err, "Trigger 'd' startAfter references unknown trigger 'unknown'")
+
+	app.Triggers = app.Triggers[:3]
+
+	_, err = app.TriggerStartOrder()
+	assert.EqualError(t, err, "Trigger startAfter dependencies form a cycle: a -> b -> c -> a")
+
+	// the helper fails upfront
+	app.Triggers = append(app.Triggers, &trigger.Config{Id: "d", Ref: ref, StartAfter: []string{"unknown"}})
+
+	_, err = NewInstanceHelper(app, map[string]trigger.Factory{ref: &MockTriggerFactory{}}, nil).CreateTriggers()
+	assert.EqualError(t, err, "Invalid app configuration, 2 problem(s) found - Trigger 'd': startAfter references unknown trigger 'unknown'; Trigger 'a': startAfter dependencies form a cycle: a -> b -> c -> a")
+}
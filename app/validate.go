@@ -0,0 +1,154 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+)
+
+// Violation is a problem found when validating an app Config
+type Violation struct {
+	// Kind is the kind of the offending element, "Trigger" or "Action"
+	Kind string
+
+	// Index is the position of the element in the configuration
+	Index int
+
+	// ID is the id of the element, if it has one
+	ID string
+
+	// Problem describes the violation
+	Problem string
+}
+
+// String returns a description of the violation
+func (v *Violation) String() string {
+
+	if len(v.ID) > 0 {
+		return fmt.Sprintf("%s '%s': %s", v.Kind, v.ID, v.Problem)
+	}
+
+	return fmt.Sprintf("%s #%d: %s", v.Kind, v.Index, v.Problem)
+}
+
+// ValidationError lists the violations found when validating an app Config
+type ValidationError struct {
+	Violations []*Violation
+}
+
+// Error implements error.Error
+func (e *ValidationError) Error() string {
+
+	problems := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		problems[i] = violation.String()
+	}
+
+	return fmt.Sprintf("Invalid app configuration, %d problem(s) found - %s", len(e.Violations), strings.Join(problems, "; "))
+}
+
+// Validate checks the triggers and actions of the configuration, their ids
+// have to be unique, actions require one, and their refs have to be
+// registered in the factories, the registration isn't checked for nil
+// factories.  All the violations found are returned as a ValidationError
+func (c *Config) Validate(tFactories map[string]trigger.Factory, aFactories map[string]action.Factory) error {
+
+	violations := append(c.validateTriggers(tFactories), c.validateActions(aFactories)...)
+
+	return newValidationError(violations)
+}
+
+func (c *Config) validateTriggers(tFactories map[string]trigger.Factory) []*Violation {
+
+	var violations []*Violation
+	ids := make(map[string]bool, len(c.Triggers))
+
+	for i, tConfig := range c.Triggers {
+		if tConfig == nil {
+			continue
+		}
+
+		violation := func(problem string) {
+			violations = append(violations, &Violation{Kind: "Trigger", Index: i, ID: tConfig.Id, Problem: problem})
+		}
+
+		if len(tConfig.Id) > 0 {
+			if ids[tConfig.Id] {
+				violation("id is already used, trigger ids have to be unique")
+			}
+			ids[tConfig.Id] = true
+		}
+
+		if len(tConfig.Ref) == 0 {
+			violation("ref is required")
+		} else if _, ok := tFactories[tConfig.Ref]; !ok && tFactories != nil {
+			violation(fmt.Sprintf("Trigger Factory '%s' not registered", tConfig.Ref))
+		}
+
+		if tConfig.Restart != nil {
+			if err := tConfig.Restart.Validate(); err != nil {
+				violation(err.Error())
+			}
+		}
+	}
+
+	for i, tConfig := range c.Triggers {
+		if tConfig == nil {
+			continue
+		}
+
+		for _, id := range tConfig.StartAfter {
+			if !ids[id] {
+				violations = append(violations, &Violation{Kind: "Trigger", Index: i, ID: tConfig.Id, Problem: fmt.Sprintf("startAfter references unknown trigger '%s'", id)})
+			}
+		}
+	}
+
+	if cycle := startCycle(c.Triggers); len(cycle) > 0 {
+		violations = append(violations, &Violation{Kind: "Trigger", ID: cycle[0], Problem: "startAfter dependencies form a cycle: " + strings.Join(cycle, " -> ")})
+	}
+
+	return violations
+}
+
+func (c *Config) validateActions(aFactories map[string]action.Factory) []*Violation {
+
+	var violations []*Violation
+	ids := make(map[string]bool, len(c.Actions))
+
+	for i, aConfig := range c.Actions {
+		if aConfig == nil {
+			continue
+		}
+
+		violation := func(problem string) {
+			violations = append(violations, &Violation{Kind: "Action", Index: i, ID: aConfig.Id, Problem: problem})
+		}
+
+		if len(aConfig.Id) == 0 {
+			violation("id is required")
+		} else if ids[aConfig.Id] {
+			violation("id is already used, action ids have to be unique")
+		}
+		ids[aConfig.Id] = true
+
+		if len(aConfig.Ref) == 0 {
+			violation("ref is required")
+		} else if _, ok := aFactories[aConfig.Ref]; !ok && aFactories != nil {
+			violation(fmt.Sprintf("Action Factory '%s' not registered", aConfig.Ref))
+		}
+	}
+
+	return violations
+}
+
+func newValidationError(violations []*Violation) error {
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Violations: violations}
+}
@@ -24,6 +24,41 @@ type Runner interface {
 	Run(context context.Context, action Action, uri string, options interface{}) (code int, data interface{}, err error)
 }
 
+// SaturationReporter is implemented by Runners that can signal when they are
+// saturated, triggers can use it to stop pulling events until there is capacity
+type SaturationReporter interface {
+
+	// Saturated indicates if the runner has reached its concurrency cap
+	Saturated() bool
+}
+
+// IsSaturated indicates if the specified Runner is saturated, Runners that
+// don't report saturation are never considered saturated
+func IsSaturated(runner Runner) bool {
+
+	if reporter, ok := runner.(SaturationReporter); ok {
+		return reporter.Saturated()
+	}
+
+	return false
+}
+
+// ErrorRateReporter is implemented by Actions that track the rate of the runs
+// that fail, it is used to report the health of an app
+type ErrorRateReporter interface {
+
+	// ErrorRate returns the rolling rate, between 0 and 1, of the failed runs
+	ErrorRate() float64
+}
+
+// MetadataProvider is implemented by Actions that can describe themselves,
+// the metadata has to be serializable to JSON
+type MetadataProvider interface {
+
+	// Metadata returns the metadata of the action
+	Metadata() interface{}
+}
+
 // ResultHandler used to handle results from the Action
 type ResultHandler interface {
 	HandleResult(code int, data interface{}, err error)
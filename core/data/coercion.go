@@ -3,6 +3,7 @@ package data
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -286,3 +287,128 @@ func handleComplex(complex *ComplexObject) *ComplexObject {
 	}
 	return complex
 }
+
+// CoercionError is returned when a value can't be coerced, it contains the
+// path of the field that failed (ex. "{T.order}.items[2].price")
+type CoercionError struct {
+	Path string
+	Type Type
+	Err  error
+}
+
+// Error implements error.Error
+func (e *CoercionError) Error() string {
+	return fmt.Sprintf("Unable to coerce field '%s' to %s - %s", e.Path, e.Type.String(), e.Err.Error())
+}
+
+// CoerceToValueAt coerces the value at the specified path to the specified
+// type like CoerceToValue.  A failure is reported as a CoercionError with the
+// path of the nested field that couldn't be coerced when there is one, the
+// path of the value followed by the keys and indexes of the field
+func CoerceToValueAt(path string, value interface{}, dataType Type) (interface{}, error) {
+
+	coerced, err := CoerceToValue(value, dataType)
+
+	if err != nil {
+		return nil, coercionErrorAt(path, value, dataType, err)
+	}
+
+	return coerced, nil
+}
+
+// coercionErrorAt returns the CoercionError of the value at the path, for the
+// nested field that caused the error if there is one
+func coercionErrorAt(path string, value interface{}, dataType Type, err error) *CoercionError {
+
+	switch dataType {
+	case PARAMS:
+		// the values of params are coerced to strings
+		if fieldErr := paramsCoercionError(path, value); fieldErr != nil {
+			return fieldErr
+		}
+	case STRING, COMPLEX_OBJECT:
+		// objects are coerced through their JSON representation
+		if fieldErr := jsonCoercionError(path, value, dataType); fieldErr != nil {
+			return fieldErr
+		}
+	}
+
+	return &CoercionError{Path: path, Type: dataType, Err: err}
+}
+
+// paramsCoercionError returns the CoercionError of the first value of the
+// params that can't be coerced to a string
+func paramsCoercionError(path string, value interface{}) *CoercionError {
+
+	for _, field := range nestedFields(path, value) {
+		if _, err := CoerceToString(field.value); err != nil {
+			return coercionErrorAt(field.path, field.value, STRING, err)
+		}
+	}
+
+	return nil
+}
+
+// jsonCoercionError returns the CoercionError of the first nested field of
+// the value that can't be marshalled to JSON
+func jsonCoercionError(path string, value interface{}, dataType Type) *CoercionError {
+
+	for _, field := range nestedFields(path, value) {
+
+		if _, err := json.Marshal(field.value); err != nil {
+
+			if fieldErr := jsonCoercionError(field.path, field.value, dataType); fieldErr != nil {
+				return fieldErr
+			}
+
+			return &CoercionError{Path: field.path, Type: dataType, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// field is a nested field of a value
+type field struct {
+	path  string
+	value interface{}
+}
+
+// nestedFields returns the direct fields of an object or elements of an
+// array, sorted by key
+func nestedFields(path string, value interface{}) []field {
+
+	var fields []field
+
+	switch t := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for key := range t {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fields = append(fields, field{path: path + "." + key, value: t[key]})
+		}
+	case map[interface{}]interface{}:
+		keys := make([]string, 0, len(t))
+		values := make(map[string]interface{}, len(t))
+		for key, v := range t {
+			keyStr := fmt.Sprintf("%v", key)
+			keys = append(keys, keyStr)
+			values[keyStr] = v
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fields = append(fields, field{path: path + "." + key, value: values[key]})
+		}
+	case []interface{}:
+		for i, v := range t {
+			fields = append(fields, field{path: path + "[" + strconv.Itoa(i) + "]", value: v})
+		}
+	}
+
+	return fields
+}
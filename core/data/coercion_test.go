@@ -107,3 +107,40 @@ func TestUnmarshComplexObject(t *testing.T) {
 	assert.NotEqual(t, "", complexObject.Value)
 }
 
+
+func TestCoerceToValueAt(t *testing.T) {
+
+	order := map[string]interface{}{
+		"id": "order-1",
+		"items": []interface{}{
+			map[string]interface{}{"price": 1.5},
+			map[string]interface{}{"price": 2.5, "tags": []string{"a"}},
+		},
+	}
+
+	// the value of params that can't be coerced to a string
+	_, err := CoerceToValueAt("{T.order}", order, PARAMS)
+	coercionErr, ok := err.(*CoercionError)
+	assert.True(t, ok)
+	assert.Equal(t, "{T.order}.items", coercionErr.Path)
+	assert.Equal(t, STRING, coercionErr.Type)
+
+	// the nested field that can't be marshalled
+	order["items"].([]interface{})[1].(map[string]interface{})["total"] = func() {}
+
+	_, err = CoerceToValueAt("{T.order}", order, STRING)
+	coercionErr, ok = err.(*CoercionError)
+	assert.True(t, ok)
+	assert.Equal(t, "{T.order}.items[1].total", coercionErr.Path)
+	assert.Contains(t, err.Error(), "Unable to coerce field '{T.order}.items[1].total' to string")
+
+	// a value without nested fields
+	_, err = CoerceToValueAt("{T.order}.id", "order-1", NUMBER)
+	coercionErr, ok = err.(*CoercionError)
+	assert.True(t, ok)
+	assert.Equal(t, "{T.order}.id", coercionErr.Path)
+
+	cval, err := CoerceToValueAt("{T.order}.id", "12", INTEGER)
+	assert.Nil(t, err)
+	assert.Equal(t, 12, cval)
+}
@@ -30,3 +30,14 @@ type MappingDef struct {
 type Mapper interface {
 	Apply(inputScope Scope, outputScope Scope)
 }
+
+// CheckedMapper is a Mapper that reports the mappings it couldn't apply, ie.
+// because a value couldn't be coerced to the type of the attribute it is
+// mapped to
+type CheckedMapper interface {
+	Mapper
+
+	// ApplyChecked applies the mappings like Apply, the error of the first
+	// mapping that couldn't be applied is returned
+	ApplyChecked(inputScope Scope, outputScope Scope) error
+}
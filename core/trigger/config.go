@@ -8,6 +8,14 @@ type Config struct {
 	Settings map[string]interface{} `json:"settings"`
 	Handlers []*HandlerConfig       `json:"handlers"`
 
+	// Restart optionally supervises the trigger, restarting it when it fails
+	// to start or exits unexpectedly
+	Restart *RestartPolicy `json:"restart,omitempty"`
+
+	// StartAfter are the ids of the triggers that have to be started before
+	// this one
+	StartAfter []string `json:"startAfter,omitempty"`
+
 	//deprecated
 	//Settings map[string]string `json:"settings"`
 	Endpoints []*EndpointConfig `json:"endpoints"`
@@ -0,0 +1,293 @@
+package trigger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"github.com/TIBCOSoftware/flogo-lib/util"
+)
+
+// DefaultRestartBackoff is the delay before the first restart of a trigger
+// when its RestartPolicy doesn't specify a Backoff
+const DefaultRestartBackoff = time.Second
+
+// RestartPolicy determines how a supervised trigger is restarted when it
+// fails to start or exits unexpectedly
+type RestartPolicy struct {
+	// MaxAttempts is the maximum number of restarts, unlimited if < 1
+	MaxAttempts int `json:"maxAttempts"`
+
+	// Backoff is the delay before the first restart, ie. "1s", it doubles
+	// after each consecutive failure
+	Backoff string `json:"backoff,omitempty"`
+
+	// MaxBackoff caps the delay between restarts, ie. "1m"
+	MaxBackoff string `json:"maxBackoff,omitempty"`
+}
+
+// Validate checks the durations of the policy
+func (p *RestartPolicy) Validate() error {
+	_, _, err := p.backoffs()
+	return err
+}
+
+func (p *RestartPolicy) backoffs() (backoff time.Duration, maxBackoff time.Duration, err error) {
+
+	backoff = DefaultRestartBackoff
+
+	if len(p.Backoff) > 0 {
+		if backoff, err = time.ParseDuration(p.Backoff); err != nil {
+			return 0, 0, fmt.Errorf("invalid restart backoff '%s'", p.Backoff)
+		}
+	}
+
+	if len(p.MaxBackoff) > 0 {
+		if maxBackoff, err = time.ParseDuration(p.MaxBackoff); err != nil {
+			return 0, 0, fmt.Errorf("invalid restart maxBackoff '%s'", p.MaxBackoff)
+		}
+	}
+
+	return backoff, maxBackoff, nil
+}
+
+// ExitNotifier is implemented by triggers that can stop running on their
+// own, ie. when the listener they serve dies
+type ExitNotifier interface {
+
+	// Exited returns a channel that receives the error of the trigger when
+	// it exits unexpectedly, it is called after each Start
+	Exited() <-chan error
+}
+
+// SupervisorState is the state of a supervised trigger
+type SupervisorState string
+
+const (
+	SupervisorRunning    SupervisorState = "Running"
+	SupervisorRestarting SupervisorState = "Restarting"
+	SupervisorFailed     SupervisorState = "Failed"
+	SupervisorStopped    SupervisorState = "Stopped"
+)
+
+// Supervisor is a Trigger that restarts the trigger it wraps according to a
+// RestartPolicy, when it fails to start or exits unexpectedly
+type Supervisor struct {
+	Trigger
+
+	id         string
+	policy     *RestartPolicy
+	backoff    time.Duration
+	maxBackoff time.Duration
+	clock      util.Clock
+
+	mu       sync.Mutex
+	state    SupervisorState
+	restarts int
+	lastErr  error
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSupervisor creates a Supervisor for the trigger with the specified id
+func NewSupervisor(id string, trg Trigger, policy *RestartPolicy) (*Supervisor, error) {
+
+	backoff, maxBackoff, err := policy.backoffs()
+	if err != nil {
+		return nil, fmt.Errorf("Trigger '%s': %s", id, err.Error())
+	}
+
+	return &Supervisor{Trigger: trg, id: id, policy: policy, backoff: backoff, maxBackoff: maxBackoff, clock: util.SystemClock, state: SupervisorStopped}, nil
+}
+
+// State returns the current state of the supervised trigger
+func (s *Supervisor) State() SupervisorState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Restarts returns the number of times the trigger was restarted
+func (s *Supervisor) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// LastError returns the last error the trigger failed with
+func (s *Supervisor) LastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Start implements util.Managed.Start, if the trigger fails to start the
+// error is returned and the trigger is restarted in the background, the
+// failure is reflected by the State until a restart succeeds
+func (s *Supervisor) Start() error {
+
+	s.stop = make(chan struct{})
+
+	err := s.startTrigger()
+
+	s.wg.Add(1)
+	go s.supervise(err == nil)
+
+	return err
+}
+
+// Stop implements util.Managed.Stop
+func (s *Supervisor) Stop() error {
+
+	if s.stop == nil {
+		return nil
+	}
+
+	close(s.stop)
+	s.wg.Wait()
+	s.stop = nil
+
+	running := s.State() == SupervisorRunning
+	s.setState(SupervisorStopped, nil)
+
+	if running {
+		return s.Trigger.Stop()
+	}
+
+	return nil
+}
+
+// supervise watches the trigger and restarts it until it is stopped or the
+// restarts are exhausted
+func (s *Supervisor) supervise(running bool) {
+
+	defer s.wg.Done()
+
+	failures := 0
+
+	for {
+		if running {
+			failures = 0
+
+			var exited <-chan error
+			if notifier, ok := s.Trigger.(ExitNotifier); ok {
+				exited = notifier.Exited()
+			}
+
+			select {
+			case <-s.stop:
+				return
+			case err := <-exited:
+				if err == nil {
+					err = fmt.Errorf("trigger exited")
+				}
+				logger.Warnf("Trigger [%s] exited unexpectedly - %s", s.id, err.Error())
+				s.setState(SupervisorRestarting, err)
+			}
+		} else {
+			failures++
+		}
+
+		if !s.canRestart() {
+			logger.Errorf("Trigger [%s] failed, no restarts left", s.id)
+			s.setState(SupervisorFailed, s.LastError())
+			return
+		}
+
+		restart := make(chan struct{})
+		stopTimer := util.AfterFunc(s.clock, s.delay(failures), func() { close(restart) })
+
+		select {
+		case <-s.stop:
+			stopTimer()
+			return
+		case <-restart:
+		}
+
+		s.mu.Lock()
+		s.restarts++
+		s.mu.Unlock()
+
+		logger.Infof("Trigger [%s] restarting, attempt %d", s.id, s.Restarts())
+
+		running = s.startTrigger() == nil
+	}
+}
+
+// startTrigger starts the trigger, a panic fails the start
+func (s *Supervisor) startTrigger() (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			logger.Warnf("Trigger [%s] failed to start - %s", s.id, err.Error())
+			s.setState(SupervisorRestarting, err)
+		}
+	}()
+
+	if err = s.Trigger.Start(); err != nil {
+		logger.Warnf("Trigger [%s] failed to start - %s", s.id, err.Error())
+		s.setState(SupervisorRestarting, err)
+		return err
+	}
+
+	s.setState(SupervisorRunning, nil)
+	return nil
+}
+
+func (s *Supervisor) canRestart() bool {
+	return s.policy.MaxAttempts < 1 || s.Restarts() < s.policy.MaxAttempts
+}
+
+// delay is the backoff before the next restart, it doubles with each
+// consecutive failure to start
+func (s *Supervisor) delay(failures int) time.Duration {
+
+	delay := s.backoff
+
+	for i := 1; i < failures; i++ {
+		delay *= 2
+
+		if s.maxBackoff > 0 && delay >= s.maxBackoff {
+			return s.maxBackoff
+		}
+	}
+
+	if s.maxBackoff > 0 && delay > s.maxBackoff {
+		return s.maxBackoff
+	}
+
+	return delay
+}
+
+func (s *Supervisor) setState(state SupervisorState, err error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state = state
+
+	if err != nil {
+		s.lastErr = err
+	}
+}
+
+// SupervisorStates returns the state of the registered trigger instances that
+// are supervised, by id
+func SupervisorStates() map[string]SupervisorState {
+
+	triggersMu.Lock()
+	defer triggersMu.Unlock()
+
+	states := make(map[string]SupervisorState)
+
+	for id, instance := range reg.instances {
+		if supervisor, ok := instance.Interf.(*Supervisor); ok {
+			states[id] = supervisor.State()
+		}
+	}
+
+	return states
+}
@@ -0,0 +1,155 @@
+package trigger
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/stretchr/testify/assert"
+)
+
+// crashingTrigger fails to start the first failStarts times and can be made
+// to exit
+type crashingTrigger struct {
+	mu         sync.Mutex
+	failStarts int
+	starts     int
+	stops      int
+	exited     chan error
+	started    chan bool
+}
+
+func newCrashingTrigger(failStarts int) *crashingTrigger {
+	return &crashingTrigger{failStarts: failStarts, started: make(chan bool, 10)}
+}
+
+func (t *crashingTrigger) Metadata() *Metadata {
+	return nil
+}
+
+func (t *crashingTrigger) Init(actionRunner action.Runner) {
+}
+
+func (t *crashingTrigger) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.starts++
+	if t.starts <= t.failStarts {
+		return errors.New("port in use")
+	}
+
+	t.exited = make(chan error, 1)
+	t.started <- true
+	return nil
+}
+
+func (t *crashingTrigger) Stop() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stops++
+	return nil
+}
+
+func (t *crashingTrigger) Exited() <-chan error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.exited
+}
+
+func (t *crashingTrigger) crash(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.exited <- err
+}
+
+func waitForState(t *testing.T, s *Supervisor, state SupervisorState) {
+
+	for i := 0; i < 1000 && s.State() != state; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal(t, state, s.State())
+}
+
+// TestSupervisorRetriesStart tests that a failed start is retried
+func TestSupervisorRetriesStart(t *testing.T) {
+
+	trg := newCrashingTrigger(2)
+
+	s, err := NewSupervisor("flaky", trg, &RestartPolicy{MaxAttempts: 3, Backoff: "1ms"})
+	assert.Nil(t, err)
+
+	// the first failure is reported, the start is retried in the background
+	err = s.Start()
+	assert.EqualError(t, err, "port in use")
+
+	<-trg.started
+	waitForState(t, s, SupervisorRunning)
+	assert.Equal(t, 2, s.Restarts())
+	assert.EqualError(t, s.LastError(), "port in use")
+
+	err = s.Stop()
+	assert.Nil(t, err)
+	assert.Equal(t, SupervisorStopped, s.State())
+	assert.Equal(t, 1, trg.stops)
+}
+
+// TestSupervisorRestartsExited tests that a trigger that exits is restarted
+// until the restarts are exhausted
+func TestSupervisorRestartsExited(t *testing.T) {
+
+	trg := newCrashingTrigger(0)
+
+	s, err := NewSupervisor("crashing", trg, &RestartPolicy{MaxAttempts: 1, Backoff: "1ms"})
+	assert.Nil(t, err)
+
+	err = s.Start()
+	assert.Nil(t, err)
+	<-trg.started
+
+	trg.crash(errors.New("listener closed"))
+	<-trg.started
+
+	waitForState(t, s, SupervisorRunning)
+	assert.Equal(t, 1, s.Restarts())
+
+	// no restarts left
+	trg.crash(errors.New("listener closed"))
+
+	waitForState(t, s, SupervisorFailed)
+	assert.EqualError(t, s.LastError(), "listener closed")
+
+	err = s.Stop()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, trg.stops)
+}
+
+// TestSupervisorInvalidPolicy tests that the durations of the policy are
+// validated
+func TestSupervisorInvalidPolicy(t *testing.T) {
+
+	_, err := NewSupervisor("flaky", newCrashingTrigger(0), &RestartPolicy{Backoff: "soon"})
+	assert.EqualError(t, err, "Trigger 'flaky': invalid restart backoff 'soon'")
+
+	err = (&RestartPolicy{MaxBackoff: "later"}).Validate()
+	assert.EqualError(t, err, "invalid restart maxBackoff 'later'")
+}
+
+// TestSupervisorDelay tests the exponential backoff
+func TestSupervisorDelay(t *testing.T) {
+
+	s, err := NewSupervisor("flaky", newCrashingTrigger(0), &RestartPolicy{Backoff: "1s", MaxBackoff: "5s"})
+	assert.Nil(t, err)
+
+	assert.Equal(t, time.Second, s.delay(0))
+	assert.Equal(t, time.Second, s.delay(1))
+	assert.Equal(t, 2*time.Second, s.delay(2))
+	assert.Equal(t, 4*time.Second, s.delay(3))
+	assert.Equal(t, 5*time.Second, s.delay(4))
+}
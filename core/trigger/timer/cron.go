@@ -0,0 +1,170 @@
+package timer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed standard five field cron expression
+// (minute hour day-of-month month day-of-week)
+type CronSchedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	domAny bool
+	dowAny bool
+}
+
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// ParseCron parses a cron expression, each field supports '*', single values,
+// ranges ('a-b'), steps ('*/n' or 'a-b/n') and comma separated lists
+func ParseCron(expr string) (*CronSchedule, error) {
+
+	fields := strings.Fields(expr)
+
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("Invalid cron expression '%s' - expected %d fields, found %d", expr, len(cronFields), len(fields))
+	}
+
+	bits := make([]uint64, len(fields))
+
+	for i, field := range fields {
+		var err error
+		bits[i], err = parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cron expression '%s' - %s", expr, err.Error())
+		}
+	}
+
+	return &CronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, def cronField) (uint64, error) {
+
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+
+		rangePart := part
+		step := 1
+
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field '%s'", def.name, part)
+			}
+			rangePart = part[:idx]
+		}
+
+		start, end := def.min, def.max
+
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %s field '%s'", def.name, part)
+			}
+
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("invalid value in %s field '%s'", def.name, part)
+				}
+			} else if step > 1 {
+				end = def.max
+			}
+		}
+
+		if start < def.min || end > def.max || start > end {
+			return 0, fmt.Errorf("%s field '%s' out of range [%d-%d]", def.name, part, def.min, def.max)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// Next returns the first time after t that matches the schedule, or the zero
+// time if there is no such time within the next five years
+func (s *CronSchedule) Next(t time.Time) time.Time {
+
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !has(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+
+	domMatch := has(s.dom, t.Day())
+	dowMatch := has(s.dow, int(t.Weekday()))
+
+	// as in standard cron, if both are restricted either one can match
+	if !s.domAny && !s.dowAny {
+		return domMatch || dowMatch
+	}
+
+	return domMatch && dowMatch
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
@@ -0,0 +1,265 @@
+package timer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"github.com/TIBCOSoftware/flogo-lib/util"
+)
+
+const (
+	// Ref is the ref of the built-in timer trigger
+	Ref = "github.com/TIBCOSoftware/flogo-lib/core/trigger/timer"
+
+	settingInterval    = "interval"
+	settingCron        = "cron"
+	settingStartDelay  = "startDelay"
+	settingRepeatCount = "repeatCount"
+	settingActionURI   = "actionURI"
+)
+
+var metadata = &trigger.Metadata{ID: Ref, Handler: &trigger.HandlerMetadata{}}
+
+// Factory is the trigger.Factory for the timer Trigger
+type Factory struct {
+}
+
+// New implements trigger.Factory.New
+func (f *Factory) New(config *trigger.Config) trigger.Trigger {
+	return NewTrigger(config)
+}
+
+// Trigger is a trigger.Trigger that periodically runs the actions of its
+// handlers, either on a fixed interval or on a cron schedule.
+//
+// Settings (on the trigger, can be overridden per handler):
+//
+//	interval    - duration between runs, ie. "30s"
+//	cron        - cron expression, used instead of interval
+//	startDelay  - duration to wait before the first run
+//	repeatCount - number of times to run, 0 means run until stopped
+//	actionURI   - uri passed to the action (handler only)
+type Trigger struct {
+	config *trigger.Config
+	runner action.Runner
+	clock  util.Clock
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewTrigger creates a new timer Trigger
+func NewTrigger(config *trigger.Config) *Trigger {
+	return &Trigger{config: config, clock: util.SystemClock}
+}
+
+// Metadata implements trigger.Trigger.Metadata
+func (t *Trigger) Metadata() *trigger.Metadata {
+	return metadata
+}
+
+// Init implements trigger.Trigger.Init
+func (t *Trigger) Init(actionRunner action.Runner) {
+	t.runner = actionRunner
+}
+
+// Start implements util.Managed.Start, starting a started Trigger is a no-op
+func (t *Trigger) Start() error {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cancel != nil {
+		return nil
+	}
+
+	var schedules []*timerSchedule
+
+	for _, handler := range t.config.Handlers {
+
+		act := action.Get(handler.ActionId)
+		if act == nil {
+			return fmt.Errorf("Timer trigger '%s': action '%s' not found", t.config.Id, handler.ActionId)
+		}
+
+		schedule, err := newTimerSchedule(t.config.Settings, handler.Settings)
+		if err != nil {
+			return fmt.Errorf("Timer trigger '%s': %s", t.config.Id, err.Error())
+		}
+
+		schedule.action = act
+		schedules = append(schedules, schedule)
+	}
+
+	var ctx context.Context
+	ctx, t.cancel = context.WithCancel(context.Background())
+
+	for _, schedule := range schedules {
+		t.wg.Add(1)
+		go t.run(ctx, schedule)
+	}
+
+	return nil
+}
+
+// Stop implements util.Managed.Stop, the context of the actions that are
+// running is cancelled and Stop waits for them to return
+func (t *Trigger) Stop() error {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cancel != nil {
+		t.cancel()
+		t.wg.Wait()
+		t.cancel = nil
+	}
+
+	return nil
+}
+
+// run runs the action of the schedule until the schedule ends or ctx is done,
+// the runs missed while the action was running are skipped
+func (t *Trigger) run(ctx context.Context, schedule *timerSchedule) {
+
+	defer t.wg.Done()
+
+	next := t.clock.Now().Add(schedule.startDelay)
+
+	if schedule.cron != nil {
+		next = schedule.cron.Next(next)
+	}
+
+	for count := 0; schedule.repeatCount <= 0 || count < schedule.repeatCount; count++ {
+
+		if next.IsZero() {
+			logger.Warnf("Timer trigger '%s': cron schedule has no upcoming run", t.config.Id)
+			return
+		}
+
+		due := make(chan struct{})
+		stopTimer := util.AfterFunc(t.clock, next.Sub(t.clock.Now()), func() { close(due) })
+
+		select {
+		case <-ctx.Done():
+			stopTimer()
+			return
+		case <-due:
+		}
+
+		_, _, err := t.runner.Run(ctx, schedule.action, schedule.actionURI, nil)
+		if err != nil {
+			logger.Errorf("Timer trigger '%s': error running action - %s", t.config.Id, err.Error())
+		}
+
+		next = schedule.next(next, t.clock.Now())
+	}
+}
+
+type timerSchedule struct {
+	action      action.Action
+	actionURI   string
+	interval    time.Duration
+	cron        *CronSchedule
+	startDelay  time.Duration
+	repeatCount int
+}
+
+// next returns the time of the run following the one due at prev, the runs
+// that were due before now are skipped rather than run back to back
+func (s *timerSchedule) next(prev time.Time, now time.Time) time.Time {
+
+	if s.cron != nil {
+		next := s.cron.Next(prev)
+		if !next.IsZero() && next.Before(now) {
+			logger.Debugf("Timer schedule skipped the runs due since %v", next)
+			next = s.cron.Next(now)
+		}
+		return next
+	}
+
+	next := prev.Add(s.interval)
+
+	if next.Before(now) {
+		missed := (now.Sub(next) + s.interval - 1) / s.interval
+		logger.Debugf("Timer schedule skipped %d runs", missed)
+		next = next.Add(missed * s.interval)
+	}
+
+	return next
+}
+
+func newTimerSchedule(triggerSettings, handlerSettings map[string]interface{}) (*timerSchedule, error) {
+
+	setting := func(name string) (interface{}, bool) {
+		if v, ok := handlerSettings[name]; ok {
+			return v, true
+		}
+		v, ok := triggerSettings[name]
+		return v, ok
+	}
+
+	durationSetting := func(name string) (time.Duration, error) {
+		v, ok := setting(name)
+		if !ok {
+			return 0, nil
+		}
+		str, err := data.CoerceToString(v)
+		if err != nil {
+			return 0, err
+		}
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s '%s'", name, str)
+		}
+		return d, nil
+	}
+
+	schedule := &timerSchedule{}
+
+	if v, ok := setting(settingCron); ok {
+		expr, err := data.CoerceToString(v)
+		if err != nil {
+			return nil, err
+		}
+		schedule.cron, err = ParseCron(expr)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		schedule.interval, err = durationSetting(settingInterval)
+		if err != nil {
+			return nil, err
+		}
+		if schedule.interval <= 0 {
+			return nil, fmt.Errorf("either a positive '%s' or a '%s' setting is required", settingInterval, settingCron)
+		}
+	}
+
+	var err error
+	schedule.startDelay, err = durationSetting(settingStartDelay)
+	if err != nil {
+		return nil, err
+	}
+
+	if v, ok := setting(settingRepeatCount); ok {
+		schedule.repeatCount, err = data.CoerceToInteger(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%v'", settingRepeatCount, v)
+		}
+	}
+
+	if v, ok := handlerSettings[settingActionURI]; ok {
+		schedule.actionURI, _ = data.CoerceToString(v)
+	}
+
+	return schedule, nil
+}
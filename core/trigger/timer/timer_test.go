@@ -0,0 +1,314 @@
+package timer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	added   chan bool
+}
+
+type fakeWaiter struct {
+	at time.Time
+	f  func()
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, added: make(chan bool, 100)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) (stop func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &fakeWaiter{at: c.now.Add(d), f: f}
+	c.waiters = append(c.waiters, w)
+	c.added <- true
+
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for i, waiter := range c.waiters {
+			if waiter == w {
+				c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// advance waits for the trigger to be waiting, then moves the time forward
+func (c *fakeClock) advance(d time.Duration) {
+	<-c.added
+
+	c.mu.Lock()
+
+	c.now = c.now.Add(d)
+
+	var due, pending []*fakeWaiter
+	for _, w := range c.waiters {
+		if !w.at.After(c.now) {
+			due = append(due, w)
+		} else {
+			pending = append(pending, w)
+		}
+	}
+	c.waiters = pending
+
+	c.mu.Unlock()
+
+	for _, w := range due {
+		w.f()
+	}
+}
+
+type countingRunner struct {
+	mu    sync.Mutex
+	times []time.Time
+	clock *fakeClock
+}
+
+func (r *countingRunner) Run(ctx context.Context, act action.Action, uri string, options interface{}) (code int, data interface{}, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.times = append(r.times, r.clock.Now())
+	return 0, nil, nil
+}
+
+type noopAction struct {
+}
+
+func (a *noopAction) Run(ctx context.Context, uri string, options interface{}, handler action.ResultHandler) error {
+	return nil
+}
+
+func init() {
+	action.Register("timer-test", &noopAction{})
+}
+
+//TestTimerTriggerInterval
+func TestTimerTriggerInterval(t *testing.T) {
+
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	runner := &countingRunner{clock: clock}
+
+	config := &trigger.Config{
+		Id:       "timer",
+		Settings: map[string]interface{}{"interval": "10s", "startDelay": "5s", "repeatCount": 3},
+		Handlers: []*trigger.HandlerConfig{{ActionId: "timer-test"}},
+	}
+
+	timer := NewTrigger(config)
+	timer.clock = clock
+	timer.Init(runner)
+
+	err := timer.Start()
+	assert.Nil(t, err)
+
+	clock.advance(5 * time.Second)
+	clock.advance(10 * time.Second)
+	clock.advance(10 * time.Second)
+
+	// after the last repeat the schedule ends on its own
+	timer.wg.Wait()
+	timer.Stop()
+
+	expected := []time.Time{start.Add(5 * time.Second), start.Add(15 * time.Second), start.Add(25 * time.Second)}
+	assert.Equal(t, expected, runner.times)
+}
+
+//TestTimerTriggerCron
+func TestTimerTriggerCron(t *testing.T) {
+
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	runner := &countingRunner{clock: clock}
+
+	config := &trigger.Config{
+		Id:       "timer",
+		Settings: map[string]interface{}{"cron": "*/15 * * * *"},
+		Handlers: []*trigger.HandlerConfig{{ActionId: "timer-test", Settings: map[string]interface{}{"repeatCount": "2"}}},
+	}
+
+	timer := NewTrigger(config)
+	timer.clock = clock
+	timer.Init(runner)
+
+	err := timer.Start()
+	assert.Nil(t, err)
+
+	clock.advance(15 * time.Minute)
+	clock.advance(15 * time.Minute)
+
+	timer.wg.Wait()
+	timer.Stop()
+
+	assert.Equal(t, []time.Time{start.Add(15 * time.Minute), start.Add(30 * time.Minute)}, runner.times)
+}
+
+//TestTimerTriggerStartTwice
+func TestTimerTriggerStartTwice(t *testing.T) {
+
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	runner := &countingRunner{clock: clock}
+
+	config := &trigger.Config{
+		Id:       "timer",
+		Settings: map[string]interface{}{"interval": "10s", "repeatCount": 1},
+		Handlers: []*trigger.HandlerConfig{{ActionId: "timer-test"}},
+	}
+
+	timer := NewTrigger(config)
+	timer.clock = clock
+	timer.Init(runner)
+
+	assert.Nil(t, timer.Start())
+	assert.Nil(t, timer.Start())
+
+	clock.advance(10 * time.Second)
+
+	timer.wg.Wait()
+	timer.Stop()
+
+	// the second Start didn't schedule the handler again
+	assert.Equal(t, []time.Time{start.Add(10 * time.Second)}, runner.times)
+}
+
+// slowRunner runs actions that take longer than the interval of the timer
+type slowRunner struct {
+	countingRunner
+	duration time.Duration
+}
+
+func (r *slowRunner) Run(ctx context.Context, act action.Action, uri string, options interface{}) (code int, data interface{}, err error) {
+	r.countingRunner.Run(ctx, act, uri, options)
+
+	r.clock.mu.Lock()
+	r.clock.now = r.clock.now.Add(r.duration)
+	r.clock.mu.Unlock()
+
+	return 0, nil, nil
+}
+
+//TestTimerTriggerSkipsMissedRuns
+func TestTimerTriggerSkipsMissedRuns(t *testing.T) {
+
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	runner := &slowRunner{countingRunner: countingRunner{clock: clock}, duration: 25 * time.Second}
+
+	config := &trigger.Config{
+		Id:       "timer",
+		Settings: map[string]interface{}{"interval": "10s", "repeatCount": 2},
+		Handlers: []*trigger.HandlerConfig{{ActionId: "timer-test"}},
+	}
+
+	timer := NewTrigger(config)
+	timer.clock = clock
+	timer.Init(runner)
+
+	assert.Nil(t, timer.Start())
+
+	// the first run ends at 35s, the runs due at 20s and 30s are skipped
+	clock.advance(10 * time.Second)
+	clock.advance(5 * time.Second)
+
+	timer.wg.Wait()
+	timer.Stop()
+
+	assert.Equal(t, []time.Time{start.Add(10 * time.Second), start.Add(40 * time.Second)}, runner.times)
+
+	// the same goes for a cron schedule
+	schedule := &timerSchedule{}
+	schedule.cron, _ = ParseCron("*/15 * * * *")
+	assert.Equal(t, start.Add(45*time.Minute), schedule.next(start.Add(15*time.Minute), start.Add(40*time.Minute)))
+	assert.Equal(t, start.Add(30*time.Minute), schedule.next(start.Add(15*time.Minute), start.Add(20*time.Minute)))
+}
+
+// blockingRunner runs actions until their context is done
+type blockingRunner struct {
+	entered chan bool
+	err     error
+}
+
+func (r *blockingRunner) Run(ctx context.Context, act action.Action, uri string, options interface{}) (code int, data interface{}, err error) {
+	r.entered <- true
+	<-ctx.Done()
+	r.err = ctx.Err()
+	return 0, nil, r.err
+}
+
+//TestTimerTriggerStopCancelsRun
+func TestTimerTriggerStopCancelsRun(t *testing.T) {
+
+	clock := newFakeClock(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+	runner := &blockingRunner{entered: make(chan bool, 1)}
+
+	config := &trigger.Config{
+		Id:       "timer",
+		Settings: map[string]interface{}{"interval": "10s"},
+		Handlers: []*trigger.HandlerConfig{{ActionId: "timer-test"}},
+	}
+
+	timer := NewTrigger(config)
+	timer.clock = clock
+	timer.Init(runner)
+
+	assert.Nil(t, timer.Start())
+
+	clock.advance(10 * time.Second)
+	<-runner.entered
+
+	// Stop doesn't wait for the action to end on its own
+	assert.Nil(t, timer.Stop())
+	assert.Equal(t, context.Canceled, runner.err)
+}
+
+//TestTimerTriggerInvalidSettings
+func TestTimerTriggerInvalidSettings(t *testing.T) {
+
+	config := &trigger.Config{Id: "timer", Handlers: []*trigger.HandlerConfig{{ActionId: "timer-test"}}}
+
+	timer := NewTrigger(config)
+	timer.Init(&countingRunner{})
+
+	err := timer.Start()
+	assert.NotNil(t, err)
+}
+
+//TestParseCron
+func TestParseCron(t *testing.T) {
+
+	schedule, err := ParseCron("30 9 * * 1-5")
+	assert.Nil(t, err)
+
+	// Saturday 2017-01-07 -> Monday 09:30
+	next := schedule.Next(time.Date(2017, 1, 7, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2017, 1, 9, 9, 30, 0, 0, time.UTC), next)
+
+	_, err = ParseCron("61 * * * *")
+	assert.NotNil(t, err)
+
+	_, err = ParseCron("* * *")
+	assert.NotNil(t, err)
+}
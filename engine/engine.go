@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"runtime/debug"
+	"sync"
 
 	"github.com/TIBCOSoftware/flogo-lib/app"
 	"github.com/TIBCOSoftware/flogo-lib/config"
@@ -23,6 +24,15 @@ type IEngine interface {
 	Stop()
 }
 
+// HealthReporter is implemented by engines that report the health of their
+// app, the engines that can signal when they are saturated implement
+// action.SaturationReporter
+type HealthReporter interface {
+
+	// Health reports the health of the app and of its components
+	Health() *Health
+}
+
 // Engine creates and executes FlowInstances.
 type Engine struct {
 	generator      *util.Generator
@@ -38,6 +48,12 @@ type EngineConfig struct {
 	LogLevel       string
 	runner         action.Runner
 	serviceManager *util.ServiceManager
+
+	healthMu         sync.RWMutex
+	started          bool
+	triggers         map[string]*trigger.TriggerInstance
+	actions          map[string]action.Action
+	registrationErrs []string
 }
 
 // New creates a new Engine
@@ -71,6 +87,11 @@ func New(app *app.Config) (IEngine, error) {
 	return &EngineConfig{App: app, LogLevel: logLevel, runner: r, serviceManager: util.GetDefaultServiceManager()}, nil
 }
 
+// Saturated implements action.SaturationReporter.Saturated
+func (e *EngineConfig) Saturated() bool {
+	return action.IsSaturated(e.runner)
+}
+
 //Start initializes and starts the Triggers and initializes the Actions
 func (e *EngineConfig) Start() {
 	logger.Info("Engine: Starting...")
@@ -85,6 +106,8 @@ func (e *EngineConfig) Start() {
 		panic(errorMsg)
 	}
 
+	var registrationErrs []string
+
 	// Initialize and register the triggers
 	for key, value := range tInstances {
 		triggerInterface := value.Interf
@@ -92,7 +115,9 @@ func (e *EngineConfig) Start() {
 		//Init
 		triggerInterface.Init(e.runner)
 		//Register
-		trigger.RegisterInstance(key, value)
+		if err := trigger.RegisterInstance(key, value); err != nil {
+			registrationErrs = append(registrationErrs, err.Error())
+		}
 	}
 
 	// Create the action instances
@@ -106,7 +131,9 @@ func (e *EngineConfig) Start() {
 	// Initialize and register the actions,
 	for key, value := range actions {
 
-		action.Register(key, value)
+		if err := action.Register(key, value); err != nil {
+			registrationErrs = append(registrationErrs, err.Error())
+		}
 		//do we need an init? or start
 	}
 
@@ -127,31 +154,119 @@ func (e *EngineConfig) Start() {
 		logger.Info("Engine: Started Services")
 	}
 
-	// Start the triggers
-	for key, value := range tInstances {
-		err := util.StartManaged(fmt.Sprintf("Trigger [ '%s' ]", key), value.Interf)
-		if err != nil {
-			logger.Infof("Trigger [%s] failed to start due to error [%s]", key, err.Error())
-			value.Status = trigger.Failed
-			value.Error = err
-			logger.Debugf("StackTrace: %s", debug.Stack())
-			if config.StopEngineOnError() {
-				logger.Debugf("{%s=true}. Stopping engine", config.STOP_ENGINE_ON_ERROR_KEY)
-				logger.Info("Engine: Stopped")
-				os.Exit(1)
+	// Start the triggers, after the triggers they depend on
+	startOrder, err := e.App.TriggerStartOrder()
+	if err != nil {
+		errorMsg := fmt.Sprintf("Engine: Error Ordering trigger instances - %s", err.Error())
+		logger.Error(errorMsg)
+		panic(errorMsg)
+	}
+
+	startTriggers(startOrder, tInstances)
+
+	e.healthMu.Lock()
+	e.started = true
+	e.triggers = tInstances
+	e.actions = actions
+	e.registrationErrs = registrationErrs
+	e.healthMu.Unlock()
+
+	logger.Info("Engine: Started")
+}
+
+// startTrigger starts the trigger instance and updates its status
+func startTrigger(key string, value *trigger.TriggerInstance) {
+
+	err := util.StartManaged(fmt.Sprintf("Trigger [ '%s' ]", key), value.Interf)
+	if err != nil {
+		logger.Infof("Trigger [%s] failed to start due to error [%s]", key, err.Error())
+		value.Status = trigger.Failed
+		value.Error = err
+		logger.Debugf("StackTrace: %s", debug.Stack())
+		if config.StopEngineOnError() {
+			logger.Debugf("{%s=true}. Stopping engine", config.STOP_ENGINE_ON_ERROR_KEY)
+			logger.Info("Engine: Stopped")
+			os.Exit(1)
+		}
+	} else {
+		logger.Infof("Trigger [%s] started", key)
+		value.Status = trigger.Started
+	}
+}
+
+// startTriggers starts the trigger instances concurrently, a trigger is
+// started as soon as the triggers it starts after are up, it isn't started if
+// one of them failed to start
+func startTriggers(startOrder [][]string, tInstances map[string]*trigger.TriggerInstance) {
+
+	done := make(map[string]chan struct{}, len(tInstances))
+
+	for _, group := range startOrder {
+		for _, key := range group {
+			done[key] = make(chan struct{})
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	for _, group := range startOrder {
+		for _, key := range group {
+			wg.Add(1)
+			go func(key string, value *trigger.TriggerInstance) {
+				defer wg.Done()
+				defer close(done[key])
+
+				for _, id := range value.Config.StartAfter {
+					if prerequisite, ok := done[id]; ok {
+						<-prerequisite
+					}
+				}
+
+				if failed := failedPrerequisite(value.Config, tInstances); len(failed) > 0 {
+					logger.Infof("Trigger [%s] not started, trigger [%s] it starts after failed to start", key, failed)
+					value.Status = trigger.Failed
+					value.Error = fmt.Errorf("trigger '%s' it starts after failed to start", failed)
+					return
+				}
+
+				startTrigger(key, value)
+			}(key, tInstances[key])
+		}
+	}
+
+	wg.Wait()
+}
+
+// failedPrerequisite returns the id of a trigger the configured trigger
+// starts after that isn't up, if any.  A supervised trigger is only up while
+// its supervisor is running it
+func failedPrerequisite(tConfig *trigger.Config, tInstances map[string]*trigger.TriggerInstance) string {
+
+	for _, id := range tConfig.StartAfter {
+		prerequisite, ok := tInstances[id]
+		if !ok {
+			continue
+		}
+
+		if supervisor, ok := prerequisite.Interf.(*trigger.Supervisor); ok {
+			if supervisor.State() != trigger.SupervisorRunning {
+				return id
 			}
-		} else {
-			logger.Infof("Trigger [%s] started", key)
-			value.Status = trigger.Started
+		} else if prerequisite.Status != trigger.Started {
+			return id
 		}
 	}
 
-	logger.Info("Engine: Started")
+	return ""
 }
 
 func (e *EngineConfig) Stop() {
 	logger.Info("Engine: Stopping...")
 
+	e.healthMu.Lock()
+	e.started = false
+	e.healthMu.Unlock()
+
 	// Stop Triggers
 	tConfigs := e.App.Triggers
 
@@ -195,7 +310,7 @@ func (e *EngineConfig) Stop() {
 func NewEngine(engineConfig *Config, triggersConfig *TriggersConfig) *Engine {
 
 	var engine Engine
-	engine.generator, _ = util.NewGenerator()
+	engine.generator = util.MustNewGenerator()
 	engine.engineConfig = engineConfig
 
 	engine.triggersConfig = triggersConfig
@@ -218,6 +333,11 @@ func NewEngine(engineConfig *Config, triggersConfig *TriggersConfig) *Engine {
 	return &engine
 }
 
+// Saturated indicates if the engine has reached its concurrency cap
+func (e *Engine) Saturated() bool {
+	return action.IsSaturated(e.runner)
+}
+
 // RegisterService register a service with the engine
 func (e *Engine) RegisterService(service util.Service) {
 	e.serviceManager.RegisterService(service)
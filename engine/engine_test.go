@@ -1,9 +1,15 @@
 package engine
 
 import (
-	"github.com/stretchr/testify/assert"
+	"context"
+	"encoding/json"
+	"errors"
 	"testing"
+
 	"github.com/TIBCOSoftware/flogo-lib/app"
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/stretchr/testify/assert"
 )
 
 //TestNewEngineErrorNoApp
@@ -33,3 +39,140 @@ func TestNewEngineErrorNoAppVersion(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, "Error: No App version provided", err.Error())
 }
+
+// errorRateAction is an action that reports a fixed error rate
+type errorRateAction struct {
+	rate float64
+}
+
+func (a *errorRateAction) Run(context context.Context, uri string, options interface{}, handler action.ResultHandler) error {
+	return nil
+}
+
+func (a *errorRateAction) ErrorRate() float64 {
+	return a.rate
+}
+
+//TestHealth
+func TestHealth(t *testing.T) {
+
+	e := &EngineConfig{}
+
+	// the health is reported through an optional interface
+	var engine IEngine = e
+	reporter, ok := engine.(HealthReporter)
+	assert.True(t, ok)
+
+	health := reporter.Health()
+	assert.Equal(t, HealthDown, health.Status)
+	assert.Equal(t, "not started", health.Components[0].Detail)
+
+	e.started = true
+	e.triggers = map[string]*trigger.TriggerInstance{
+		"rest":  {Status: trigger.Started},
+		"timer": {Status: trigger.Failed, Error: errors.New("invalid interval")},
+	}
+	e.actions = map[string]action.Action{"flow": &errorRateAction{rate: 0.2}}
+
+	health = e.Health()
+	assert.Equal(t, HealthDegraded, health.Status)
+
+	b, err := json.Marshal(health)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"status":"degraded","components":[`+
+		`{"name":"registration","status":"ok"},`+
+		`{"name":"trigger:rest","status":"ok"},`+
+		`{"name":"trigger:timer","status":"down","detail":"invalid interval"},`+
+		`{"name":"action:flow","status":"degraded","detail":"error rate 0.20"}]}`, string(b))
+
+	// all the triggers are down
+	e.triggers["rest"].Status = trigger.Failed
+	assert.Equal(t, HealthDown, e.Health().Status)
+
+	e.triggers["rest"].Status = trigger.Started
+	e.triggers["timer"].Status = trigger.Started
+	e.actions["flow"] = &errorRateAction{}
+	assert.Equal(t, HealthOK, e.Health().Status)
+
+	e.registrationErrs = []string{"action already registered for id 'flow'"}
+	assert.Equal(t, HealthDown, e.Health().Status)
+}
+
+// blockingTrigger fails to start or blocks its start until released
+type blockingTrigger struct {
+	err     error
+	release chan bool
+	started chan bool
+}
+
+func (t *blockingTrigger) Metadata() *trigger.Metadata {
+	return nil
+}
+
+func (t *blockingTrigger) Init(actionRunner action.Runner) {
+}
+
+func (t *blockingTrigger) Start() error {
+	if t.release != nil {
+		<-t.release
+	}
+	if t.started != nil {
+		t.started <- true
+	}
+	return t.err
+}
+
+func (t *blockingTrigger) Stop() error {
+	return nil
+}
+
+//TestStartTriggers
+func TestStartTriggers(t *testing.T) {
+
+	slow := &blockingTrigger{release: make(chan bool)}
+	second := &blockingTrigger{started: make(chan bool, 1)}
+
+	tInstances := map[string]*trigger.TriggerInstance{
+		"slow":   {Config: &trigger.Config{Id: "slow"}, Interf: slow},
+		"first":  {Config: &trigger.Config{Id: "first"}, Interf: &blockingTrigger{}},
+		"second": {Config: &trigger.Config{Id: "second", StartAfter: []string{"first"}}, Interf: second},
+	}
+
+	done := make(chan bool)
+	go func() {
+		startTriggers([][]string{{"first", "slow"}, {"second"}}, tInstances)
+		done <- true
+	}()
+
+	// 'second' doesn't wait for 'slow' that it doesn't start after
+	<-second.started
+	slow.release <- true
+	<-done
+
+	for _, instance := range tInstances {
+		assert.Equal(t, trigger.Started, instance.Status)
+	}
+}
+
+//TestFailedPrerequisite
+func TestFailedPrerequisite(t *testing.T) {
+
+	supervisor, err := trigger.NewSupervisor("rest", &blockingTrigger{err: errors.New("port in use")}, &trigger.RestartPolicy{Backoff: "1h"})
+	assert.Nil(t, err)
+
+	assert.NotNil(t, supervisor.Start())
+	defer supervisor.Stop()
+
+	tInstances := map[string]*trigger.TriggerInstance{
+		"rest":  {Config: &trigger.Config{Id: "rest"}, Interf: supervisor, Status: trigger.Started},
+		"timer": {Config: &trigger.Config{Id: "timer"}, Interf: &blockingTrigger{}, Status: trigger.Started},
+	}
+
+	assert.Equal(t, "", failedPrerequisite(&trigger.Config{StartAfter: []string{"timer"}}, tInstances))
+
+	// the supervisor is restarting the trigger
+	assert.Equal(t, "rest", failedPrerequisite(&trigger.Config{StartAfter: []string{"timer", "rest"}}, tInstances))
+
+	tInstances["timer"].Status = trigger.Failed
+	assert.Equal(t, "timer", failedPrerequisite(&trigger.Config{StartAfter: []string{"timer"}}, tInstances))
+}
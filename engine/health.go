@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+)
+
+// HealthStatus is the health of the app or of one of its components
+type HealthStatus string
+
+const (
+	HealthOK       HealthStatus = "ok"
+	HealthDegraded HealthStatus = "degraded"
+	HealthDown     HealthStatus = "down"
+)
+
+var (
+	// HealthErrorRateDegraded is the error rate of an action from which it is
+	// reported as degraded
+	HealthErrorRateDegraded = 0.1
+
+	// HealthErrorRateDown is the error rate of an action from which it is
+	// reported as down
+	HealthErrorRateDown = 0.5
+)
+
+// ComponentHealth is the health of a component of the app
+type ComponentHealth struct {
+	Name   string       `json:"name"`
+	Status HealthStatus `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+}
+
+// Health is the health of the app, it can be serialized for a health or
+// readiness endpoint
+type Health struct {
+	Status     HealthStatus       `json:"status"`
+	Components []*ComponentHealth `json:"components"`
+}
+
+// Health implements HealthReporter.Health, the app is down until it is started, if
+// its trigger or action instances couldn't all be registered or if none of
+// its triggers is running.  It is degraded if any of its components isn't ok
+func (e *EngineConfig) Health() *Health {
+
+	e.healthMu.RLock()
+	defer e.healthMu.RUnlock()
+
+	health := &Health{Status: HealthOK}
+
+	if !e.started {
+		health.Status = HealthDown
+		health.Components = append(health.Components, &ComponentHealth{Name: "engine", Status: HealthDown, Detail: "not started"})
+		return health
+	}
+
+	registration := &ComponentHealth{Name: "registration", Status: HealthOK}
+	if len(e.registrationErrs) > 0 {
+		registration.Status = HealthDown
+		registration.Detail = strings.Join(e.registrationErrs, "; ")
+		health.Status = HealthDown
+	}
+	health.Components = append(health.Components, registration)
+
+	triggersDown := 0
+	for _, id := range sortedIDs(e.triggers) {
+		component := triggerHealth(id, e.triggers[id])
+		if component.Status == HealthDown {
+			triggersDown++
+		}
+		health.Components = append(health.Components, component)
+	}
+
+	if len(e.triggers) > 0 && triggersDown == len(e.triggers) {
+		health.Status = HealthDown
+	}
+
+	for _, id := range sortedIDs(e.actions) {
+		if reporter, ok := e.actions[id].(action.ErrorRateReporter); ok {
+			health.Components = append(health.Components, actionHealth(id, reporter.ErrorRate()))
+		}
+	}
+
+	if health.Status == HealthOK {
+		for _, component := range health.Components {
+			if component.Status != HealthOK {
+				health.Status = HealthDegraded
+				break
+			}
+		}
+	}
+
+	return health
+}
+
+// triggerHealth reports the state of the supervisor of a supervised trigger,
+// otherwise the status it was started with
+func triggerHealth(id string, instance *trigger.TriggerInstance) *ComponentHealth {
+
+	component := &ComponentHealth{Name: "trigger:" + id, Status: HealthOK}
+
+	if supervisor, ok := instance.Interf.(*trigger.Supervisor); ok {
+		state := supervisor.State()
+
+		switch state {
+		case trigger.SupervisorRunning:
+			return component
+		case trigger.SupervisorRestarting:
+			component.Status = HealthDegraded
+		default:
+			component.Status = HealthDown
+		}
+
+		component.Detail = string(state)
+		if err := supervisor.LastError(); err != nil {
+			component.Detail += " - " + err.Error()
+		}
+
+		return component
+	}
+
+	if instance.Status != trigger.Started {
+		component.Status = HealthDown
+		component.Detail = "not started"
+
+		if instance.Error != nil {
+			component.Detail = instance.Error.Error()
+		}
+	}
+
+	return component
+}
+
+// actionHealth reports the error rate of an action
+func actionHealth(id string, errorRate float64) *ComponentHealth {
+
+	component := &ComponentHealth{Name: "action:" + id, Status: HealthOK, Detail: fmt.Sprintf("error rate %.2f", errorRate)}
+
+	if errorRate >= HealthErrorRateDown {
+		component.Status = HealthDown
+	} else if errorRate >= HealthErrorRateDegraded {
+		component.Status = HealthDegraded
+	}
+
+	return component
+}
+
+func sortedIDs(m interface{}) []string {
+
+	var ids []string
+
+	switch instances := m.(type) {
+	case map[string]*trigger.TriggerInstance:
+		for id := range instances {
+			ids = append(ids, id)
+		}
+	case map[string]action.Action:
+		for id := range instances {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
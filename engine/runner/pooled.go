@@ -3,6 +3,7 @@ package runner
 import (
 	"context"
 	"errors"
+	"sync/atomic"
 
 	"github.com/TIBCOSoftware/flogo-lib/core/action"
 	"github.com/TIBCOSoftware/flogo-lib/logger"
@@ -15,6 +16,9 @@ type PooledRunner struct {
 	numWorkers  int
 	workers     []*ActionWorker
 	active      bool
+	busy        int32
+	queued      int64
+	dispatched  int64
 
 	directRunner *DirectRunner
 }
@@ -66,7 +70,13 @@ func (runner *PooledRunner) Start() error {
 						worker := <-runner.workerQueue
 
 						logger.Debug("Dispatching work request")
+						atomic.AddInt32(&runner.busy, 1)
 						worker <- work
+
+						atomic.AddInt64(&runner.dispatched, 1)
+						if ticket := work.actionData.ticket; ticket != nil {
+							ticket.dispatch()
+						}
 					}()
 				}
 			}
@@ -106,10 +116,18 @@ func (runner *PooledRunner) Run(context context.Context, action action.Action, u
 		data := &ActionData{context: context, action: action, uri: uri, options: options, rc: make(chan *ActionResult, 1)}
 		work := ActionWorkRequest{ReqType: RtRun, actionData: data}
 
+		// every run takes a position in the queue, so the tickets can be estimated
+		if data.ticket = queueTicketFromContext(context); data.ticket != nil {
+			data.ticket.enqueue(runner)
+		} else {
+			atomic.AddInt64(&runner.queued, 1)
+		}
+
 		runner.workQueue <- work
 		logger.Debugf("Run Action '%s' queued", uri)
 
 		reply := <-data.rc
+		atomic.AddInt32(&runner.busy, -1)
 		logger.Debugf("Run Action '%s' complete", uri)
 
 		return reply.code, reply.data, reply.err
@@ -118,3 +136,9 @@ func (runner *PooledRunner) Run(context context.Context, action action.Action, u
 	//Run rejected
 	return 0, nil, errors.New("Runner not active")
 }
+
+// Saturated implements action.SaturationReporter.Saturated, the runner is
+// saturated when all of its workers are busy, the queued runs aren't counted
+func (runner *PooledRunner) Saturated() bool {
+	return int(atomic.LoadInt32(&runner.busy)) >= runner.numWorkers
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/TIBCOSoftware/flogo-lib/core/action"
 	"github.com/stretchr/testify/assert"
@@ -117,3 +118,107 @@ func TestStopOk(t *testing.T) {
 	assert.False(t, runner.active)
 
 }
+
+// BlockingAction blocks until it is released
+type BlockingAction struct {
+	started chan bool
+	release chan bool
+}
+
+func (a *BlockingAction) Run(context context.Context, uri string, options interface{}, handler action.ResultHandler) error {
+	a.started <- true
+	<-a.release
+	handler.Done()
+	return nil
+}
+
+// TestSaturated test that the runner signals saturation when all workers are busy
+func TestSaturated(t *testing.T) {
+	config := &PooledConfig{NumWorkers: 2, WorkQueueSize: 2}
+	runner := NewPooled(config)
+	err := runner.Start()
+	assert.Nil(t, err)
+	defer runner.Stop()
+
+	a := &BlockingAction{started: make(chan bool, 2), release: make(chan bool)}
+
+	assert.False(t, action.IsSaturated(runner))
+
+	done := make(chan bool, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			runner.Run(nil, a, "", nil)
+			done <- true
+		}()
+	}
+
+	<-a.started
+	<-a.started
+
+	assert.True(t, action.IsSaturated(runner))
+
+	close(a.release)
+	<-done
+	<-done
+
+	assert.False(t, action.IsSaturated(runner))
+}
+
+// waitFor waits until the condition is met, failing the test if it isn't
+// met within a few seconds
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before the deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestQueuePosition test that a queued run reports its position as the queue drains
+func TestQueuePosition(t *testing.T) {
+	config := &PooledConfig{NumWorkers: 1, WorkQueueSize: 3}
+	runner := NewPooled(config)
+	err := runner.Start()
+	assert.Nil(t, err)
+	defer runner.Stop()
+
+	a := &BlockingAction{started: make(chan bool, 3), release: make(chan bool)}
+
+	done := make(chan bool, 3)
+	run := func(ctx context.Context) {
+		runner.Run(ctx, a, "", nil)
+		done <- true
+	}
+
+	go run(nil)
+	<-a.started
+
+	second, third := NewQueueTicket(), NewQueueTicket()
+	assert.Equal(t, -1, second.Position())
+
+	go run(WithQueueTicket(context.Background(), second))
+	waitFor(t, second.Queued)
+	go run(WithQueueTicket(context.Background(), third))
+	waitFor(t, third.Queued)
+
+	assert.Equal(t, 0, second.Position())
+	assert.Equal(t, 1, third.Position())
+
+	// the first run completes, one of the queued runs takes its place
+	a.release <- true
+	waitFor(t, func() bool { return second.Dispatched() || third.Dispatched() })
+
+	assert.Equal(t, 0, third.Position())
+
+	a.release <- true
+	a.release <- true
+
+	waitFor(t, func() bool { return second.Dispatched() && third.Dispatched() })
+
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+}
@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// QueueTicket tracks the position of a run in the work queue of a
+// PooledRunner, pass it to the run using WithQueueTicket
+type QueueTicket struct {
+	runner     *PooledRunner
+	seq        int64
+	dispatched int32
+}
+
+// NewQueueTicket creates a new QueueTicket
+func NewQueueTicket() *QueueTicket {
+	return &QueueTicket{}
+}
+
+type ticketKey struct{}
+
+// WithQueueTicket returns a new Context that carries the ticket
+func WithQueueTicket(ctx context.Context, ticket *QueueTicket) context.Context {
+	return context.WithValue(ctx, ticketKey{}, ticket)
+}
+
+// queueTicketFromContext returns the ticket stored in ctx, if any
+func queueTicketFromContext(ctx context.Context) *QueueTicket {
+
+	if ctx == nil {
+		return nil
+	}
+
+	ticket, _ := ctx.Value(ticketKey{}).(*QueueTicket)
+	return ticket
+}
+
+// Queued returns true once the run has been queued
+func (t *QueueTicket) Queued() bool {
+	return atomic.LoadInt64(&t.seq) > 0
+}
+
+// Dispatched returns true once the run has been handed to a worker
+func (t *QueueTicket) Dispatched() bool {
+	return atomic.LoadInt32(&t.dispatched) == 1
+}
+
+// Position returns an estimate of the number of queued runs ahead of the
+// run, it is 0 once the run has been dispatched and -1 if it isn't queued yet
+func (t *QueueTicket) Position() int {
+
+	seq := atomic.LoadInt64(&t.seq)
+
+	if seq == 0 {
+		return -1
+	}
+
+	if t.Dispatched() {
+		return 0
+	}
+
+	ahead := seq - atomic.LoadInt64(&t.runner.dispatched) - 1
+	if ahead < 0 {
+		// runs queued after this one were dispatched first
+		ahead = 0
+	}
+
+	return int(ahead)
+}
+
+// enqueue assigns the next position in the queue to the ticket
+func (t *QueueTicket) enqueue(runner *PooledRunner) {
+	t.runner = runner
+	atomic.StoreInt64(&t.seq, atomic.AddInt64(&runner.queued, 1))
+}
+
+// dispatch marks the ticket as handed to a worker
+func (t *QueueTicket) dispatch() {
+	atomic.StoreInt32(&t.dispatched, 1)
+}
@@ -32,6 +32,7 @@ type ActionData struct {
 	uri     string
 	options interface{}
 	rc      chan (*ActionResult)
+	ticket  *QueueTicket
 }
 
 // ActionResult is a simple struct to hold the results for an Action
@@ -1,6 +1,10 @@
 package activity
 
-import "github.com/TIBCOSoftware/flogo-lib/flow/support"
+import (
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/support"
+)
 
 // Context describes the execution context for an Activity.
 // It provides access to attributes, task and Flow information.
@@ -21,6 +25,44 @@ type Context interface {
 	SetOutput(name string, value interface{})
 }
 
+// WorkUnitCounter is implemented by contexts that budget the work of an
+// instance in work units
+type WorkUnitCounter interface {
+
+	// AddWorkUnits adds the specified units to the work done by the instance
+	AddWorkUnits(units int)
+}
+
+// AddWorkUnits reports the cost of the work done by an activity, it is a no-op
+// if the context doesn't track work units
+func AddWorkUnits(context Context, units int) {
+
+	if counter, ok := context.(WorkUnitCounter); ok {
+		counter.AddWorkUnits(units)
+	}
+}
+
+// SubflowRunner is implemented by contexts that can run another flow as part
+// of the execution of an activity
+type SubflowRunner interface {
+
+	// RunSubflow runs the flow with the specified URI and inputs, it blocks
+	// until the subflow is done and returns its output
+	RunSubflow(uri string, inputs map[string]interface{}) (map[string]interface{}, error)
+}
+
+// RunSubflow runs the flow with the specified URI as a subflow of the flow
+// executing the activity, it fails if the context doesn't support subflows
+func RunSubflow(context Context, uri string, inputs map[string]interface{}) (map[string]interface{}, error) {
+
+	runner, ok := context.(SubflowRunner)
+	if !ok {
+		return nil, fmt.Errorf("Unable to run subflow [%s], subflows are not supported by the context", uri)
+	}
+
+	return runner.RunSubflow(uri, inputs)
+}
+
 // FlowDetails details of the flow that is being executed
 type FlowDetails interface {
 
@@ -25,3 +25,30 @@ func (e *Error) Data() interface{} {
 func (e *Error) Code() string {
 	return e.errorCode
 }
+
+// RetryableError marks the error of an activity as transient, the task that
+// produced it can be retried, see flowinst.ActionOptions.StepRetry
+type RetryableError struct {
+	err error
+}
+
+// NewRetryableError marks the specified error as retryable
+func NewRetryableError(err error) *RetryableError {
+	return &RetryableError{err: err}
+}
+
+// Error implements error.Error()
+func (e *RetryableError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns the error that was marked as retryable
+func (e *RetryableError) Cause() error {
+	return e.err
+}
+
+// IsRetryable indicates if the error was marked as retryable
+func IsRetryable(err error) bool {
+	_, ok := err.(*RetryableError)
+	return ok
+}
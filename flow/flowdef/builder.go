@@ -0,0 +1,158 @@
+package flowdef
+
+import (
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+)
+
+// Builder is used to construct a flow Definition programmatically
+//
+//	def, err := flowdef.NewBuilder().Name("myflow").Model("simple").
+//		AddTask(2, 1, "log", "tibco-log").
+//		AddTask(3, 1, "reply", "tibco-reply").
+//		AddLink(2, 3).
+//		Build()
+type Builder struct {
+	rep     *DefinitionRep
+	taskIDs map[int]bool
+	linkID  int
+	err     error
+}
+
+// NewBuilder creates a new Builder, the root task of the flow has ID 1
+func NewBuilder() *Builder {
+
+	root := &TaskRep{ID: 1, TypeID: 1, Name: "root"}
+
+	return &Builder{
+		rep:     &DefinitionRep{RootTask: root},
+		taskIDs: map[int]bool{root.ID: true},
+	}
+}
+
+// Name sets the name of the flow
+func (b *Builder) Name(name string) *Builder {
+	b.rep.Name = name
+	return b
+}
+
+// Version sets the version of the flow
+func (b *Builder) Version(version string) *Builder {
+	b.rep.Version = version
+	return b
+}
+
+// Model sets the ID of the flow model of the flow
+func (b *Builder) Model(modelID string) *Builder {
+	b.rep.ModelID = modelID
+	return b
+}
+
+// ExplicitReply sets whether the flow replies explicitly
+func (b *Builder) ExplicitReply(explicitReply bool) *Builder {
+	b.rep.ExplicitReply = explicitReply
+	return b
+}
+
+// Resources sets the resource requirements of the flow
+func (b *Builder) Resources(resources ResourceTags) *Builder {
+	b.rep.Resources = &resources
+	return b
+}
+
+// AddAttr adds an attribute to the flow
+func (b *Builder) AddAttr(name string, attrType data.Type, value interface{}) *Builder {
+	b.rep.Attributes = append(b.rep.Attributes, data.NewAttribute(name, attrType, value))
+	return b
+}
+
+// AddInput declares an input attribute of the flow, it is required if it has
+// no default value
+func (b *Builder) AddInput(name string, attrType data.Type, defaultValue interface{}) *Builder {
+	b.rep.Inputs = append(b.rep.Inputs, data.NewAttribute(name, attrType, defaultValue))
+	return b
+}
+
+// AddOutput declares an output attribute of the flow
+func (b *Builder) AddOutput(name string, attrType data.Type) *Builder {
+	b.rep.Outputs = append(b.rep.Outputs, data.NewAttribute(name, attrType, nil))
+	return b
+}
+
+// AddTask adds a task to the root task of the flow
+func (b *Builder) AddTask(id int, typeID int, name string, activityType string) *Builder {
+	return b.AddTaskRep(&TaskRep{ID: id, TypeID: typeID, Name: name, ActivityType: activityType, ActivityRef: activityType})
+}
+
+// AddTaskRep adds a task, described by its serializable representation, to
+// the root task of the flow, it allows setting the mappings and attributes of
+// the task
+func (b *Builder) AddTaskRep(task *TaskRep) *Builder {
+
+	if b.taskIDs[task.ID] {
+		b.setErr(fmt.Errorf("duplicate task id %d", task.ID))
+		return b
+	}
+
+	b.taskIDs[task.ID] = true
+	b.rep.RootTask.Tasks = append(b.rep.RootTask.Tasks, task)
+
+	return b
+}
+
+// AddLink adds a dependency link between two tasks of the flow
+func (b *Builder) AddLink(fromID int, toID int) *Builder {
+	return b.addLink(LtDependency, fromID, toID, "")
+}
+
+// AddExprLink adds a link between two tasks of the flow, that is only
+// followed if the expression evaluates to true
+func (b *Builder) AddExprLink(fromID int, toID int, expr string) *Builder {
+	return b.addLink(LtExpression, fromID, toID, expr)
+}
+
+func (b *Builder) addLink(linkType LinkType, fromID int, toID int, value string) *Builder {
+
+	if !b.taskIDs[fromID] {
+		b.setErr(fmt.Errorf("link from unknown task %d", fromID))
+		return b
+	}
+
+	if !b.taskIDs[toID] {
+		b.setErr(fmt.Errorf("link to unknown task %d", toID))
+		return b
+	}
+
+	b.linkID++
+	link := &LinkRep{ID: b.linkID, Type: int(linkType), FromID: fromID, ToID: toID, Value: value}
+	b.rep.RootTask.Links = append(b.rep.RootTask.Links, link)
+
+	return b
+}
+
+// Rep returns the serializable representation of the flow being built
+func (b *Builder) Rep() *DefinitionRep {
+	return b.rep
+}
+
+// Build validates and creates the flow Definition
+func (b *Builder) Build() (*Definition, error) {
+
+	if b.err != nil {
+		return nil, fmt.Errorf("Invalid flow '%s' - %s", b.rep.Name, b.err.Error())
+	}
+
+	if len(b.rep.ModelID) == 0 {
+		return nil, fmt.Errorf("Invalid flow '%s' - model not specified", b.rep.Name)
+	}
+
+	return NewDefinition(b.rep)
+}
+
+// setErr keeps the first error encountered while building
+func (b *Builder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
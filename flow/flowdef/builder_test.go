@@ -0,0 +1,51 @@
+package flowdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//TestBuilder
+func TestBuilder(t *testing.T) {
+
+	def, err := NewBuilder().Name("built").Model("simple").
+		AddTask(2, 1, "a", "").
+		AddTask(3, 1, "b", "").
+		AddLink(2, 3).
+		Build()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "built", def.Name())
+	assert.Equal(t, "simple", def.ModelID())
+	assert.Equal(t, 2, len(def.RootTask().ChildTasks()))
+	assert.Equal(t, 3, def.GetTask(2).ToLinks()[0].ToTask().ID())
+}
+
+//TestBuilderInvalid
+func TestBuilderInvalid(t *testing.T) {
+
+	_, err := NewBuilder().Name("built").Model("simple").
+		AddTask(2, 1, "a", "").
+		AddTask(2, 1, "b", "").
+		Build()
+	assert.Equal(t, "Invalid flow 'built' - duplicate task id 2", err.Error())
+
+	_, err = NewBuilder().Name("built").Model("simple").
+		AddTask(2, 1, "a", "").
+		AddLink(2, 5).
+		Build()
+	assert.Equal(t, "Invalid flow 'built' - link to unknown task 5", err.Error())
+
+	_, err = NewBuilder().Name("built").Build()
+	assert.Equal(t, "Invalid flow 'built' - model not specified", err.Error())
+
+	// validation of the definition still applies
+	_, err = NewBuilder().Name("built").Model("simple").
+		AddTask(2, 1, "a", "").
+		AddTask(3, 1, "b", "").
+		AddLink(2, 3).
+		AddLink(3, 2).
+		Build()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,122 @@
+package flowdef
+
+import (
+	"sync"
+	"time"
+)
+
+// CachingProvider is a Provider that caches the definitions of the wrapped
+// Provider by URI.  Concurrent requests for a definition that isn't cached
+// share a single load
+type CachingProvider struct {
+	provider Provider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	loads   map[string]*cacheLoad
+}
+
+type cacheEntry struct {
+	def    *Definition
+	loaded time.Time
+}
+
+// cacheLoad is a load of a definition in progress
+type cacheLoad struct {
+	wg    sync.WaitGroup
+	def   *Definition
+	err   error
+	stale bool
+}
+
+// NewCachingProvider creates a new CachingProvider, the cached definitions
+// expire after the ttl, zero means they never expire
+func NewCachingProvider(provider Provider, ttl time.Duration) *CachingProvider {
+	return &CachingProvider{
+		provider: provider,
+		ttl:      ttl,
+		entries:  make(map[string]*cacheEntry),
+		loads:    make(map[string]*cacheLoad),
+	}
+}
+
+// GetFlow implements Provider.GetFlow
+func (cp *CachingProvider) GetFlow(flowURI string) (*Definition, error) {
+
+	cp.mu.Lock()
+
+	if entry, ok := cp.entries[flowURI]; ok {
+		if cp.ttl <= 0 || time.Since(entry.loaded) < cp.ttl {
+			cp.mu.Unlock()
+			return entry.def, nil
+		}
+
+		delete(cp.entries, flowURI)
+	}
+
+	if load, ok := cp.loads[flowURI]; ok {
+		cp.mu.Unlock()
+		load.wg.Wait()
+		return load.def, load.err
+	}
+
+	load := &cacheLoad{}
+	load.wg.Add(1)
+	cp.loads[flowURI] = load
+
+	cp.mu.Unlock()
+
+	load.def, load.err = cp.provider.GetFlow(flowURI)
+
+	cp.mu.Lock()
+	if cp.loads[flowURI] == load {
+		delete(cp.loads, flowURI)
+	}
+	if load.err == nil && load.def != nil && !load.stale {
+		cp.entries[flowURI] = &cacheEntry{def: load.def, loaded: time.Now()}
+	}
+	cp.mu.Unlock()
+
+	load.wg.Done()
+
+	return load.def, load.err
+}
+
+// Invalidate removes the definition from the cache, it is loaded again the
+// next time it is requested.  A load in progress isn't cached
+func (cp *CachingProvider) Invalidate(flowURI string) {
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	delete(cp.entries, flowURI)
+
+	if load, ok := cp.loads[flowURI]; ok {
+		load.stale = true
+		delete(cp.loads, flowURI)
+	}
+}
+
+// Reload loads the definition again and replaces the cached one, ie. to pick
+// up a new version of a flow at runtime
+func (cp *CachingProvider) Reload(flowURI string) (*Definition, error) {
+
+	cp.Invalidate(flowURI)
+
+	return cp.GetFlow(flowURI)
+}
+
+// GetFlowVersion implements VersionedProvider.GetFlowVersion, the cached
+// definition is returned if it has the requested version, other versions are
+// looked up in the wrapped Provider without being cached
+func (cp *CachingProvider) GetFlowVersion(flowURI string, version string) (*Definition, error) {
+
+	def, err := cp.GetFlow(flowURI)
+
+	if len(version) == 0 || (err == nil && def != nil && def.Version() == version) {
+		return def, err
+	}
+
+	return GetFlowVersion(cp.provider, flowURI, version)
+}
@@ -0,0 +1,102 @@
+package flowdef
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingProvider builds a new definition each time a flow is requested
+type countingProvider struct {
+	mu      sync.Mutex
+	loads   int
+	release chan bool
+}
+
+func (p *countingProvider) GetFlow(flowURI string) (*Definition, error) {
+
+	if p.release != nil {
+		<-p.release
+	}
+
+	p.mu.Lock()
+	p.loads++
+	p.mu.Unlock()
+
+	return NewBuilder().Name(flowURI).Model("simple").AddTask(2, 1, "a", "").Build()
+}
+
+//TestCachingProvider
+func TestCachingProvider(t *testing.T) {
+
+	provider := &countingProvider{}
+	cp := NewCachingProvider(provider, 0)
+
+	def, err := cp.GetFlow("flow")
+	assert.Nil(t, err)
+
+	cached, err := cp.GetFlow("flow")
+	assert.Nil(t, err)
+	assert.True(t, def == cached)
+	assert.Equal(t, 1, provider.loads)
+
+	// a reload replaces the cached definition
+	reloaded, err := cp.Reload("flow")
+	assert.Nil(t, err)
+	assert.False(t, def == reloaded)
+
+	cached, _ = cp.GetFlow("flow")
+	assert.True(t, reloaded == cached)
+	assert.Equal(t, 2, provider.loads)
+
+	cp.Invalidate("flow")
+	cp.GetFlow("flow")
+	assert.Equal(t, 3, provider.loads)
+}
+
+//TestCachingProviderTTL
+func TestCachingProviderTTL(t *testing.T) {
+
+	provider := &countingProvider{}
+	cp := NewCachingProvider(provider, 10*time.Millisecond)
+
+	cp.GetFlow("flow")
+	cp.GetFlow("flow")
+	assert.Equal(t, 1, provider.loads)
+
+	time.Sleep(20 * time.Millisecond)
+
+	cp.GetFlow("flow")
+	assert.Equal(t, 2, provider.loads)
+}
+
+//TestCachingProviderCoalesce
+func TestCachingProviderCoalesce(t *testing.T) {
+
+	provider := &countingProvider{release: make(chan bool)}
+	cp := NewCachingProvider(provider, 0)
+
+	var wg sync.WaitGroup
+	defs := make([]*Definition, 5)
+
+	for i := range defs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defs[i], _ = cp.GetFlow("flow")
+		}(i)
+	}
+
+	// let the callers pile up on the load
+	time.Sleep(10 * time.Millisecond)
+	close(provider.release)
+	wg.Wait()
+
+	assert.Equal(t, 1, provider.loads)
+
+	for _, def := range defs {
+		assert.True(t, defs[0] == def)
+	}
+}
@@ -2,6 +2,7 @@ package flowdef
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/TIBCOSoftware/flogo-lib/core/data"
 )
@@ -11,18 +12,23 @@ import (
 // structure (tasks & links).
 type Definition struct {
 	name          string
+	version       string
 	modelID       string
 	explicitReply bool
 	rootTask      *Task
 	ehTask        *Task
 
-	attrs map[string]*data.Attribute
+	attrs   map[string]*data.Attribute
+	inputs  []*data.Attribute
+	outputs []*data.Attribute
 
 	inputMapper data.Mapper
 	links       map[int]*Link
 	tasks       map[int]*Task
 
 	linkExprMgr LinkExprManager
+
+	resources ResourceTags
 }
 
 // Name returns the name of the definition
@@ -30,6 +36,12 @@ func (pd *Definition) Name() string {
 	return pd.name
 }
 
+// Version returns the version of the definition, it is empty for
+// definitions that aren't versioned
+func (pd *Definition) Version() string {
+	return pd.version
+}
+
 // ModelID returns the ID of the model the definition uses
 func (pd *Definition) ModelID() string {
 	return pd.modelID
@@ -62,12 +74,39 @@ func (pd *Definition) GetAttr(attrName string) (attr *data.Attribute, exists boo
 	return nil, false
 }
 
+// Inputs returns the input attributes declared by the definition, an input
+// without a default value is required
+func (pd *Definition) Inputs() []*data.Attribute {
+	return pd.inputs
+}
+
+// Outputs returns the output attributes declared by the definition
+func (pd *Definition) Outputs() []*data.Attribute {
+	return pd.outputs
+}
+
 // GetTask returns the task with the specified ID
 func (pd *Definition) GetTask(taskID int) *Task {
 	task := pd.tasks[taskID]
 	return task
 }
 
+// GetTasksByName returns the tasks with the specified name, ordered by ID
+func (pd *Definition) GetTasksByName(name string) []*Task {
+
+	var tasks []*Task
+
+	for _, task := range pd.tasks {
+		if task.name == name {
+			tasks = append(tasks, task)
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].id < tasks[j].id })
+
+	return tasks
+}
+
 // GetLink returns the link with the specified ID
 func (pd *Definition) GetLink(linkID int) *Link {
 	task := pd.links[linkID]
@@ -1,6 +1,11 @@
 package flowdef
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/TIBCOSoftware/flogo-lib/core/data"
 	"github.com/TIBCOSoftware/flogo-lib/util"
 )
@@ -9,11 +14,15 @@ import (
 type DefinitionRep struct {
 	ExplicitReply    bool               `json:"explicitReply"`
 	Name             string             `json:"name"`
+	Version          string             `json:"version,omitempty"`
 	ModelID          string             `json:"model"`
 	Attributes       []*data.Attribute  `json:"attributes,omitempty"`
+	Inputs           []*data.Attribute  `json:"inputs,omitempty"`
+	Outputs          []*data.Attribute  `json:"outputs,omitempty"`
 	InputMappings    []*data.MappingDef `json:"inputMappings,omitempty"`
 	RootTask         *TaskRep           `json:"rootTask"`
 	ErrorHandlerTask *TaskRep           `json:"errorHandlerTask"`
+	Resources        *ResourceTags      `json:"resources,omitempty"`
 }
 
 // TaskRep is a serializable representation of a flow Task
@@ -48,9 +57,14 @@ func NewDefinition(rep *DefinitionRep) (def *Definition, err error) {
 
 	def = &Definition{}
 	def.name = rep.Name
+	def.version = rep.Version
 	def.modelID = rep.ModelID
 	def.explicitReply = rep.ExplicitReply
 
+	if rep.Resources != nil {
+		def.resources = *rep.Resources
+	}
+
 	//todo is this used or needed?
 	if rep.InputMappings != nil {
 		def.inputMapper = GetMapperFactory().NewMapper(&MapperDef{Mappings: rep.InputMappings})
@@ -64,6 +78,9 @@ func NewDefinition(rep *DefinitionRep) (def *Definition, err error) {
 		}
 	}
 
+	def.inputs = rep.Inputs
+	def.outputs = rep.Outputs
+
 	def.rootTask = &Task{}
 
 	def.tasks = make(map[int]*Task)
@@ -79,6 +96,10 @@ func NewDefinition(rep *DefinitionRep) (def *Definition, err error) {
 		addLinks(def, def.ehTask, rep.ErrorHandlerTask)
 	}
 
+	if err := checkCycles(def); err != nil {
+		return nil, err
+	}
+
 	return def, nil
 }
 
@@ -156,3 +177,75 @@ func addLinks(def *Definition, task *Task, rep *TaskRep) {
 		}
 	}
 }
+
+// checkCycles verifies that the link graph of the definition has no unguarded
+// cycles. A cycle that passes through an expression link is an intentional loop,
+// since its condition can end it, a cycle made only of dependency or label links
+// would step forever.
+func checkCycles(def *Definition) error {
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[int]int, len(def.tasks))
+	var path []int
+	var cycle []int
+
+	var visit func(task *Task) bool
+	visit = func(task *Task) bool {
+
+		state[task.id] = visiting
+		path = append(path, task.id)
+
+		for _, link := range task.toLinks {
+
+			if link.linkType == LtExpression {
+				continue
+			}
+
+			next := link.toTask
+
+			switch state[next.id] {
+			case visiting:
+				for i, id := range path {
+					if id == next.id {
+						cycle = append(append(cycle, path[i:]...), next.id)
+						break
+					}
+				}
+				return true
+			case unvisited:
+				if visit(next) {
+					return true
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[task.id] = visited
+		return false
+	}
+
+	ids := make([]int, 0, len(def.tasks))
+	for id := range def.tasks {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		if state[id] == unvisited && visit(def.tasks[id]) {
+
+			steps := make([]string, len(cycle))
+			for i, id := range cycle {
+				steps[i] = strconv.Itoa(id)
+			}
+
+			return fmt.Errorf("Flow '%s' has an unguarded cycle in its links: %s", def.name, strings.Join(steps, " -> "))
+		}
+	}
+
+	return nil
+}
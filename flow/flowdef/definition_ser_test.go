@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 const defJSON = `
@@ -80,3 +82,70 @@ func TestRestartWithFlowData(t *testing.T) {
 
 	fmt.Printf("Definition: %v", def)
 }
+
+const cycleDefJSON = `
+{
+    "type": 1,
+    "name": "Cycle Flow",
+    "model": "simple",
+    "rootTask": {
+      "id": 1,
+      "type": 1,
+      "name": "root",
+      "tasks": [
+        { "id": 2, "type": 1, "name": "a" },
+        { "id": 3, "type": 1, "name": "b" },
+        { "id": 4, "type": 1, "name": "c" }
+      ],
+      "links": [
+        { "id": 1, "type": 0, "to": 3, "from": 2 },
+        { "id": 2, "type": 0, "to": 4, "from": 3 },
+        { "id": 3, "type": %d, "to": 2, "from": 4, "value": "$.retry == true" }
+      ]
+    }
+  }
+`
+
+//TestUnguardedCycle
+func TestUnguardedCycle(t *testing.T) {
+
+	defRep := &DefinitionRep{}
+	err := json.Unmarshal([]byte(fmt.Sprintf(cycleDefJSON, LtDependency)), defRep)
+	assert.Nil(t, err)
+
+	def, err := NewDefinition(defRep)
+	assert.Nil(t, def)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Flow 'Cycle Flow' has an unguarded cycle in its links: 2 -> 3 -> 4 -> 2", err.Error())
+}
+
+//TestGuardedCycle
+func TestGuardedCycle(t *testing.T) {
+
+	defRep := &DefinitionRep{}
+	err := json.Unmarshal([]byte(fmt.Sprintf(cycleDefJSON, LtExpression)), defRep)
+	assert.Nil(t, err)
+
+	// the expression link makes this an intentional loop
+	def, err := NewDefinition(defRep)
+	assert.Nil(t, err)
+	assert.NotNil(t, def)
+}
+
+//TestResourceTags
+func TestResourceTags(t *testing.T) {
+
+	defJSON := `{"name": "res", "model": "simple", "resources": {"cpu": 0.5, "memory": 256, "tags": {"gpu": "none"}}, "rootTask": {"id": 1, "type": 1}}`
+
+	defRep := &DefinitionRep{}
+	err := json.Unmarshal([]byte(defJSON), defRep)
+	assert.Nil(t, err)
+
+	def, err := NewDefinition(defRep)
+	assert.Nil(t, err)
+
+	res := def.Resources()
+	assert.Equal(t, 0.5, res.CPU)
+	assert.Equal(t, 256, res.Memory)
+	assert.Equal(t, "none", res.Tags["gpu"])
+}
@@ -93,10 +93,33 @@ func (m *BasicMapper) Mappings() []*data.MappingDef {
 
 // Apply executes the mappings using the values from the input scope
 // and puts the results in the output scope
-//
-// return error
 func (m *BasicMapper) Apply(inputScope data.Scope, outputScope data.Scope) {
 
+	m.apply(inputScope, outputScope)
+}
+
+// ApplyChecked implements data.CheckedMapper.ApplyChecked, a value that can't
+// be coerced is reported as a data.CoercionError with the path of the field
+// it was mapped from, down to the nested field that failed (ex.
+// "{T.order}.items[2].price"), or the path it was mapped to when it can't be
+// assigned to an element of an array or a key of an object
+func (m *BasicMapper) ApplyChecked(inputScope data.Scope, outputScope data.Scope) error {
+
+	errs := m.apply(inputScope, outputScope)
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// apply executes every mapping, the values that couldn't be coerced to the
+// type of the attribute they are mapped to are returned as errors
+func (m *BasicMapper) apply(inputScope data.Scope, outputScope data.Scope) []error {
+
+	var errs []error
+
 	//todo validate types
 	for _, mapping := range m.mappings {
 
@@ -135,30 +158,26 @@ func (m *BasicMapper) Apply(inputScope data.Scope, outputScope data.Scope) {
 				if !oe {
 					//todo handle attr dne
 					fmt.Printf("Attr %s not found in output scope\n", attrName)
-					return
+					return errs
 				}
 
 				switch pathType {
 				case data.PT_SIMPLE:
+					if _, err := data.CoerceToValueAt(mapping.Value, attrValue, toAttr.Type); err != nil {
+						errs = append(errs, err)
+					}
 					outputScope.SetAttrValue(mapping.MapTo, attrValue)
 				case data.PT_ARRAY:
-					if toAttr.Type == data.ARRAY {
-						var valArray []interface{}
-						if toAttr.Value == nil {
-							//what should we do in this case, construct the array?
-							//valArray = make(map[string]string)
-						} else {
-							valArray = toAttr.Value.([]interface{})
-						}
+					if err := checkElement(mapping.MapTo, toAttr, attrPath); err != nil {
+						errs = append(errs, err)
+						continue
+					}
 
-						idx, _ := strconv.Atoi(attrPath)
-						//todo handle err
-						valArray[idx] = attrValue
+					valArray := toAttr.Value.([]interface{})
+					idx, _ := strconv.Atoi(attrPath)
+					valArray[idx] = attrValue
 
-						outputScope.SetAttrValue(attrName, valArray)
-					} else {
-						//todo throw error.. not an ARRAY
-					}
+					outputScope.SetAttrValue(attrName, valArray)
 				case data.PT_MAP:
 
 					if toAttr.Type == data.PARAMS {
@@ -168,8 +187,12 @@ func (m *BasicMapper) Apply(inputScope data.Scope, outputScope data.Scope) {
 						} else {
 							valMap = toAttr.Value.(map[string]string)
 						}
-						strVal, _ := data.CoerceToString(attrValue)
-						valMap[attrPath] = strVal
+						strVal, err := data.CoerceToValueAt(mapping.Value, attrValue, data.STRING)
+						if err != nil {
+							errs = append(errs, err)
+							continue
+						}
+						valMap[attrPath] = strVal.(string)
 
 						outputScope.SetAttrValue(attrName, valMap)
 					} else if toAttr.Type == data.OBJECT {
@@ -183,17 +206,46 @@ func (m *BasicMapper) Apply(inputScope data.Scope, outputScope data.Scope) {
 
 						outputScope.SetAttrValue(attrName, valMap)
 					} else {
-						//todo throw error.. not a OBJECT or PARAMS
+						errs = append(errs, &data.CoercionError{Path: mapping.MapTo, Type: data.OBJECT, Err: fmt.Errorf("'%s' is not an object", attrName)})
 					}
 				}
 			}
 		//todo: should we ignore if DNE - if we have to add dynamically what type do we use
 		case data.MtLiteral:
+			if toAttr, exists := outputScope.GetAttr(mapping.MapTo); exists {
+				if _, err := data.CoerceToValueAt(mapping.MapTo, mapping.Value, toAttr.Type); err != nil {
+					errs = append(errs, err)
+				}
+			}
 			outputScope.SetAttrValue(mapping.MapTo, mapping.Value)
 		case data.MtExpression:
 		//todo implement script mapping
 		}
 	}
+
+	return errs
+}
+
+// checkElement returns a data.CoercionError with the specified path if the
+// element at the index can't be assigned in the attribute
+func checkElement(path string, toAttr *data.Attribute, index string) error {
+
+	if toAttr.Type != data.ARRAY {
+		return &data.CoercionError{Path: path, Type: data.ARRAY, Err: fmt.Errorf("'%s' is not an array", toAttr.Name)}
+	}
+
+	valArray, _ := toAttr.Value.([]interface{})
+
+	idx, err := strconv.Atoi(index)
+	if err != nil {
+		return &data.CoercionError{Path: path, Type: data.ARRAY, Err: fmt.Errorf("invalid index '%s'", index)}
+	}
+
+	if idx < 0 || idx >= len(valArray) {
+		return &data.CoercionError{Path: path, Type: data.ARRAY, Err: fmt.Errorf("index %d out of range", idx)}
+	}
+
+	return nil
 }
 
 // BasicMapper is a simple object holding and executing mappings
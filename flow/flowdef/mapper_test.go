@@ -0,0 +1,90 @@
+package flowdef
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBasicMapperApplyChecked tests that every mapping is applied and the
+// value that can't be coerced is reported with its nested path
+func TestBasicMapperApplyChecked(t *testing.T) {
+
+	order := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"price": 1.5},
+			map[string]interface{}{"price": "abc"},
+		},
+	}
+
+	inputScope := data.NewSimpleScope([]*data.Attribute{data.NewAttribute("{T.order}", data.OBJECT, order)}, nil)
+	outputScope := data.NewSimpleScope([]*data.Attribute{
+		data.NewAttribute("price", data.NUMBER, nil),
+		data.NewAttribute("currency", data.STRING, nil),
+	}, nil)
+
+	mapper := NewBasicMapper(&MapperDef{Mappings: []*data.MappingDef{
+		{Type: data.MtAssign, Value: "{T.order}.items[1].price", MapTo: "price"},
+		{Type: data.MtLiteral, Value: "EUR", MapTo: "currency"},
+	}})
+
+	err := mapper.(data.CheckedMapper).ApplyChecked(inputScope, outputScope)
+	assert.NotNil(t, err)
+
+	coercionErr, ok := err.(*data.CoercionError)
+	assert.True(t, ok)
+	assert.Equal(t, "{T.order}.items[1].price", coercionErr.Path)
+	assert.Contains(t, err.Error(), "Unable to coerce field '{T.order}.items[1].price' to number")
+
+	// the mapping after the failed one is still applied
+	currency, _ := outputScope.GetAttr("currency")
+	assert.Equal(t, "EUR", currency.Value)
+}
+
+// TestBasicMapperApplyCheckedPaths tests that the mappings to an element of an
+// array or to a key of params or an object are checked
+func TestBasicMapperApplyCheckedPaths(t *testing.T) {
+
+	inputScope := data.NewSimpleScope([]*data.Attribute{
+		data.NewAttribute("{T.tags}", data.ARRAY, []interface{}{"a", "b"}),
+		data.NewAttribute("{T.code}", data.STRING, "x"),
+	}, nil)
+
+	outputScope := data.NewSimpleScope([]*data.Attribute{
+		data.NewAttribute("{A.items}", data.ARRAY, []interface{}{nil}),
+		data.NewAttribute("{A.headers}", data.PARAMS, nil),
+		data.NewAttribute("{A.code}", data.STRING, nil),
+	}, nil)
+
+	for mapTo, path := range map[string]string{
+		"{A.items}[0]":      "",
+		"{A.items}[3]":      "{A.items}[3]",
+		"{A.headers}.tags":  "{T.tags}",
+		"{A.headers}.code":  "",
+		"{A.code}.property": "{A.code}.property",
+	} {
+		value := "{T.tags}"
+		if mapTo == "{A.headers}.code" {
+			value = "{T.code}"
+		}
+
+		mapper := NewBasicMapper(&MapperDef{Mappings: []*data.MappingDef{{Type: data.MtAssign, Value: value, MapTo: mapTo}}})
+		err := mapper.(data.CheckedMapper).ApplyChecked(inputScope, outputScope)
+
+		if len(path) == 0 {
+			assert.Nil(t, err, mapTo)
+			continue
+		}
+
+		coercionErr, ok := err.(*data.CoercionError)
+		assert.True(t, ok, mapTo)
+		assert.Equal(t, path, coercionErr.Path, mapTo)
+	}
+
+	items, _ := outputScope.GetAttr("{A.items}")
+	assert.Equal(t, []interface{}{[]interface{}{"a", "b"}}, items.Value)
+
+	headers, _ := outputScope.GetAttr("{A.headers}")
+	assert.Equal(t, map[string]string{"code": "x"}, headers.Value)
+}
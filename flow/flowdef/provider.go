@@ -1,5 +1,9 @@
 package flowdef
 
+import (
+	"fmt"
+)
+
 // Provider is the interface that describes an object
 // that can provide flow definitions from a URI
 type Provider interface {
@@ -7,3 +11,52 @@ type Provider interface {
 	// GetFlow retrieves the flow definition for the specified URI
 	GetFlow(flowURI string) (*Definition, error)
 }
+
+// VersionedProvider is a Provider that keeps the versions of the flow
+// definitions, so that instances can be resumed with the version they were
+// started with
+type VersionedProvider interface {
+	Provider
+
+	// GetFlowVersion retrieves the specified version of the flow definition
+	// for the specified URI
+	GetFlowVersion(flowURI string, version string) (*Definition, error)
+}
+
+// VersionNotFoundError is the error of a lookup of a version of a flow
+// definition that doesn't exist
+type VersionNotFoundError struct {
+	URI     string
+	Version string
+}
+
+// Error implements error.Error
+func (e *VersionNotFoundError) Error() string {
+	return fmt.Sprintf("Version '%s' of Flow [%s] not found", e.Version, e.URI)
+}
+
+// GetFlowVersion retrieves the specified version of the flow definition from
+// the provider, the current definition is returned if no version is
+// specified.  Providers that aren't versioned can only provide their current
+// definition, a VersionNotFoundError is returned if it has another version
+func GetFlowVersion(provider Provider, flowURI string, version string) (*Definition, error) {
+
+	if len(version) == 0 {
+		return provider.GetFlow(flowURI)
+	}
+
+	if versioned, ok := provider.(VersionedProvider); ok {
+		return versioned.GetFlowVersion(flowURI, version)
+	}
+
+	def, err := provider.GetFlow(flowURI)
+	if err != nil || def == nil {
+		return def, err
+	}
+
+	if def.Version() != version {
+		return nil, &VersionNotFoundError{URI: flowURI, Version: version}
+	}
+
+	return def, nil
+}
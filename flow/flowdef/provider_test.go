@@ -0,0 +1,83 @@
+package flowdef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// versionedProvider keeps all the versions of its flows, the last added is
+// the current one
+type versionedProvider struct {
+	current  map[string]*Definition
+	versions map[string]*Definition
+}
+
+func newVersionedProvider() *versionedProvider {
+	return &versionedProvider{current: make(map[string]*Definition), versions: make(map[string]*Definition)}
+}
+
+func (p *versionedProvider) add(t *testing.T, flowURI string, version string) *Definition {
+
+	def, err := NewBuilder().Name(flowURI).Version(version).Model("simple").Build()
+	assert.Nil(t, err)
+
+	p.current[flowURI] = def
+	p.versions[flowURI+"@"+version] = def
+
+	return def
+}
+
+func (p *versionedProvider) GetFlow(flowURI string) (*Definition, error) {
+	return p.current[flowURI], nil
+}
+
+func (p *versionedProvider) GetFlowVersion(flowURI string, version string) (*Definition, error) {
+
+	def, ok := p.versions[flowURI+"@"+version]
+	if !ok {
+		return nil, &VersionNotFoundError{URI: flowURI, Version: version}
+	}
+
+	return def, nil
+}
+
+//TestGetFlowVersion
+func TestGetFlowVersion(t *testing.T) {
+
+	provider := newVersionedProvider()
+	v1 := provider.add(t, "flow", "1")
+	v2 := provider.add(t, "flow", "2")
+
+	def, err := GetFlowVersion(provider, "flow", "1")
+	assert.Nil(t, err)
+	assert.True(t, v1 == def)
+
+	def, err = GetFlowVersion(provider, "flow", "")
+	assert.Nil(t, err)
+	assert.True(t, v2 == def)
+
+	// the cache keeps the current version
+	cp := NewCachingProvider(provider, 0)
+
+	def, err = GetFlowVersion(cp, "flow", "2")
+	assert.Nil(t, err)
+	assert.True(t, v2 == def)
+
+	def, err = GetFlowVersion(cp, "flow", "1")
+	assert.Nil(t, err)
+	assert.True(t, v1 == def)
+
+	_, err = GetFlowVersion(cp, "flow", "3")
+	assert.EqualError(t, err, "Version '3' of Flow [flow] not found")
+
+	// a provider that isn't versioned only has its current definition
+	unversioned := &countingProvider{}
+
+	_, err = GetFlowVersion(unversioned, "flow", "1")
+	assert.IsType(t, &VersionNotFoundError{}, err)
+
+	def, err = GetFlowVersion(unversioned, "flow", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "", def.Version())
+}
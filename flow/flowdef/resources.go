@@ -0,0 +1,19 @@
+package flowdef
+
+// ResourceTags are the resource requirements a flow declares, they are hints
+// for schedulers and admission controllers and aren't enforced by the engine
+type ResourceTags struct {
+	// CPU is the number of cores the flow expects to use, ie. 0.5
+	CPU float64 `json:"cpu,omitempty"`
+
+	// Memory is the amount of memory, in MB, the flow expects to use
+	Memory int `json:"memory,omitempty"`
+
+	// Tags are additional scheduler specific requirements
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// Resources returns the resource requirements declared by the definition
+func (pd *Definition) Resources() ResourceTags {
+	return pd.resources
+}
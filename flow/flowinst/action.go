@@ -2,14 +2,44 @@ package flowinst
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
 	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
 	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
-	"github.com/TIBCOSoftware/flogo-lib/util"
+	"github.com/TIBCOSoftware/flogo-lib/flow/support"
 	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"github.com/TIBCOSoftware/flogo-lib/util"
+)
+
+const (
+	// CodeCancelled is the result code of a run cancelled by its caller
+	CodeCancelled = 499
+
+	// CodeDeadlineExceeded is the result code of a run cancelled because it
+	// exceeded its deadline
+	CodeDeadlineExceeded = 504
+
+	// CodeMaxStepCountExceeded is the result code of a run aborted because it
+	// exceeded the max step count
+	CodeMaxStepCountExceeded = 508
+
+	// CodeReplyTimeout is the result code reported to the caller when the
+	// instance didn't reply within the ReplyTimeout, see ReplyTimeoutError
+	CodeReplyTimeout = 504
+
+	// CodeStepTimeout is the result code reported to the caller when a step
+	// of the instance didn't complete within the StepTimeout, see
+	// StepTimeoutError
+	CodeStepTimeout = 504
+
+	// CodeEvicted is the result code reported to the caller when the
+	// instance was evicted, see EvictedError
+	CodeEvicted = 503
 )
 
 const (
@@ -24,15 +54,267 @@ const ActionType = "flow"
 // ActionOptions are the options for the FlowAction
 type ActionOptions struct {
 	MaxStepCount int
-	Record       bool
+
+	// Record enables recording the state of instances
+	//
+	// Deprecated: set a CheckpointStrategy instead, recording is enabled
+	// whenever a CheckpointStrategy is specified
+	Record bool
+
+	// SuppressDeprecationWarnings disables the warnings logged when deprecated
+	// options are used
+	SuppressDeprecationWarnings bool
+
+	// MaxWorkUnits is the budget of work units activities of an instance can
+	// report, the instance is aborted when it is exceeded, zero means no budget
+	MaxWorkUnits int
+
+	// DeadlineForPriority computes the deadline of a run from its priority, it
+	// is only consulted when the run doesn't specify an explicit Timeout
+	DeadlineForPriority func(priority int) time.Duration
+
+	// ExecutionTimeout is the deadline of the runs that don't have one of
+	// their own, zero means no deadline.  The deadline is checked between
+	// steps, an instance that exceeds it times out and is reported with
+	// CodeDeadlineExceeded.  When the context passed to Run carries a
+	// deadline, ie. the one of an HTTP request, the stricter of the two
+	// applies, so the instance stops once its caller would have given up
+	ExecutionTimeout time.Duration
+
+	// PauseExecutionTimeout stops the clock of the deadline of an instance
+	// while it is paused, see PauseInstance.  By default a paused instance
+	// still times out once its deadline is exceeded.  The deadline of the
+	// context passed to Run can't be paused
+	PauseExecutionTimeout bool
+
+	// CheckpointStrategy determines which steps are recorded, defaults to
+	// recording every step
+	CheckpointStrategy CheckpointStrategy
+
+	// TaskScheduler determines the order in which the ready tasks of an
+	// instance are executed, defaults to LIFOTaskScheduler
+	TaskScheduler TaskScheduler
+
+	// StepWarningThreshold is the fraction of MaxStepCount (ex. 0.9) at which
+	// a warning is emitted for an instance, zero disables the warning
+	StepWarningThreshold float64
+
+	// OnStepWarning is called when an instance crosses the StepWarningThreshold
+	OnStepWarning func(instance *Instance, stepCount int)
+
+	// FlushTimeout bounds the time given to a FlushableStateRecorder to flush
+	// the records of an instance once it is done executing, zero disables the
+	// flush
+	FlushTimeout time.Duration
+
+	// MaxSnapshotAge forces a snapshot after a step when the last recorded
+	// snapshot of the instance is older, regardless of the CheckpointStrategy
+	MaxSnapshotAge time.Duration
+
+	// Clock is the source of the current time, by default the system time.
+	// The rate limiters refill on it, and the timeouts, retry backoffs and
+	// rate limit waits elapse on it if it is a TimerClock
+	Clock Clock
+
+	// IDGenerator generates the IDs of the started and restarted instances,
+	// by default random UUIDs are generated
+	IDGenerator IDGenerator
+
+	// DeadLetterSink receives the runs that fail permanently, time out or
+	// exceed the max step count
+	DeadLetterSink DeadLetterSink
+
+	// StepTimeout bounds the time a step can take, independently of the
+	// ExecutionTimeout, zero means no timeout.  The caller of a run whose
+	// step times out receives a StepTimeoutError with CodeStepTimeout right
+	// away, the step is abandoned and its activity keeps running in the
+	// background.  Once the step returns the instance fails with the
+	// StepTimeoutError and is recorded and sent to the DeadLetterSink
+	StepTimeout time.Duration
+
+	// LoopDetection fails instances that keep re-entering the same task, so
+	// pathological cycles are caught long before the MaxStepCount
+	LoopDetection *LoopDetection
+
+	// ValidateInputs rejects the starts whose trigger attributes are missing a
+	// required input of the flow or have a value that doesn't match the type
+	// of the input, see RunOptions.ValidateInputs
+	ValidateInputs bool
+
+	// ValidateOutputs fails instances that complete without setting all the
+	// outputs declared by their flow
+	ValidateOutputs bool
+
+	// ParallelStepWorkers enables the concurrent evaluation of the activities
+	// of independent tasks, see Instance.DoParallelStep, using up to the
+	// specified number of workers per instance
+	ParallelStepWorkers int
+
+	// StepDecorators wrap the execution of each step, they are applied in
+	// order, the first decorator being the outermost.  The StepTimeout
+	// applies to the decorated step
+	StepDecorators []StepDecorator
+
+	// StepExecutor executes the steps of the instances instead of
+	// Instance.DoStep, ParallelStepWorkers is ignored when it is set
+	StepExecutor StepExecutor
+
+	// RecordTimeout bounds the time a synchronous recorder call can take, so a
+	// hung recorder can't stall the instance, zero means no timeout.  A call
+	// that timed out is left running in the background
+	RecordTimeout time.Duration
+
+	// OnRecordError determines what happens to an instance when recording its
+	// state fails, by default the instance continues executing
+	OnRecordError RecordErrorPolicy
+
+	// ErrorClassifier determines if an error produced by a task is retryable,
+	// by default no error is retryable
+	ErrorClassifier ErrorClassifier
+
+	// MaxTaskRetries is the maximum number of times a task that failed with a
+	// retryable error is retried, defaults to DefaultMaxTaskRetries
+	MaxTaskRetries int
+
+	// StepRetry enables the retry, with backoff, of the tasks that failed with
+	// an error marked using activity.NewRetryableError, in addition to the
+	// ones classified as retryable by the ErrorClassifier.  The steps that
+	// failed and are retried don't count towards the MaxStepCount
+	StepRetry *StepRetry
+
+	// MaxConcurrentInstances limits the number of instances executing at the
+	// same time, zero means no limit
+	MaxConcurrentInstances int
+
+	// ConcurrencyPolicy determines what happens to the runs started while the
+	// MaxConcurrentInstances are executing, by default they block
+	ConcurrencyPolicy ConcurrencyPolicy
+
+	// MaxBatchInFlight limits the number of instances of a batch executing at
+	// the same time, see RunBatch, defaults to MaxConcurrentInstances or
+	// DefaultMaxBatchInFlight
+	MaxBatchInFlight int
+
+	// AdmitRun is consulted before starting an instance of a flow with the
+	// resources the flow declares, if it returns an error the run is rejected.
+	// The subflows started by the instances aren't submitted to it
+	AdmitRun func(uri string, res flowdef.ResourceTags) error
+
+	// RegistryTTL enables the eviction of the in-memory registry entries of
+	// finished instances older than the TTL: the runs coalesced by
+	// IdempotencyKey, which are kept for the TTL once completed, the live runs
+	// and the StateRecorder and DeadLetterSink if they are Expirable.  The
+	// entries are evicted by a background goroutine, stopped by
+	// FlowAction.Close
+	RegistryTTL time.Duration
+
+	// RepanicOnPanic re-raises a panic while executing an instance once it
+	// is reported, which is useful for debugging.  By default the panic
+	// doesn't crash the process, the instance fails and the panic is reported
+	// to the caller as a PanicError
+	RepanicOnPanic bool
+
+	// PanicFormatter controls how panics recovered while executing instances,
+	// their tasks or activities, are converted to errors, by default they are
+	// converted to PanicErrors
+	PanicFormatter PanicFormatter
+
+	// RecordLinkDecisions includes the link decisions of the instances, see
+	// Instance.LinkDecisions, in the recorded snapshots
+	RecordLinkDecisions bool
+
+	// RecordFinalOnly only records a snapshot of the instances that reach a
+	// terminal status, the steps and the snapshots of the instances that are
+	// still executing, ie. when evicted, are not recorded
+	RecordFinalOnly bool
+
+	// RecordRateLimiter optionally limits the rate of the recorder writes, it
+	// can be shared by FlowActions to limit the writes globally
+	RecordRateLimiter *RecordRateLimiter
+
+	// StartRateLimiter optionally limits the rate at which instances are
+	// started, independently of MaxConcurrentInstances.  It can be shared by
+	// FlowActions, ie. to limit the starts of a trigger.  The subflows started
+	// by the instances aren't limited
+	StartRateLimiter *StartRateLimiter
+
+	// RecordMappedInputs includes the inputs produced by the input mapper of
+	// each task in the recorded steps, it is verbose and meant for debugging
+	RecordMappedInputs bool
+
+	// MetricsCollector optionally collects metrics about the executed instances
+	MetricsCollector *MetricsCollector
+
+	// Tracer optionally traces the execution of the instances, a span is
+	// created for each instance and each of its steps.  The span of an
+	// instance is a child of the span in the context it is run with and the
+	// parent of the spans of its subflows
+	Tracer Tracer
+
+	// ContextKeys are the keys of the context values, ie. a request or tenant
+	// ID, that are lifted out of the context an instance is started with.
+	// They are exposed to the flow as read-only attributes named after the
+	// key names, see ContextAttrName, and are part of the snapshots
+	ContextKeys map[string]interface{}
+
+	// IDResponseKey is the JSON key of the ID in an IDResponse, defaults to "id"
+	IDResponseKey string
+
+	// IDResponseWrapper optionally wraps the ID of an IDResponse in an object
+	// with this key, ex. {"data": {"id": ...}}
+	IDResponseWrapper string
+
+	// AsyncRecord writes the recorded state of instances on a dedicated
+	// goroutine instead of on the step path, the records of an instance are
+	// written before its handler is done.  The goroutine is stopped by
+	// FlowAction.Close
+	AsyncRecord bool
+
+	// RecordBufferSize is the number of records AsyncRecord buffers before
+	// the instances block, defaults to DefaultRecordBufferSize
+	RecordBufferSize int
+
+	// RecordOverflowPolicy determines what happens when the AsyncRecord
+	// buffer is full, by default the instances block until there is room
+	RecordOverflowPolicy RecordOverflowPolicy
+
+	// OnAsyncRecordError is called when an asynchronous write fails, the
+	// failure is logged regardless
+	OnAsyncRecordError func(instanceID string, err error)
 }
 
 // FlowAction is a Action that executes a flow
 type FlowAction struct {
 	stateRecorder StateRecorder
 	flowProvider  flowdef.Provider
-	idGenerator   *util.Generator
+	idGenerator   IDGenerator
 	actionOptions *ActionOptions
+
+	inflightMu sync.Mutex
+	inflight   map[string]*coalescedRun
+
+	stopJanitor chan struct{}
+	janitorMu   sync.Mutex
+	cancelSweep func() bool
+	closeOnce   sync.Once
+
+	liveMu sync.Mutex
+	live   map[string]*liveRun
+
+	step StepFunc
+
+	asyncRecorder *asyncRecorder
+
+	abandonedSteps int32
+
+	observerMu sync.Mutex
+	observers  []InstanceObserver
+
+	slots *slots
+
+	runningMu    sync.Mutex
+	running      sync.WaitGroup
+	shuttingDown bool
 }
 
 // NewFlowAction creates a new FlowAction
@@ -40,20 +322,109 @@ func NewFlowAction(flowProvider flowdef.Provider, stateRecorder StateRecorder, o
 	var action FlowAction
 	action.flowProvider = flowProvider
 	action.stateRecorder = stateRecorder
-	action.idGenerator, _ = util.NewGenerator()
+	action.inflight = make(map[string]*coalescedRun)
+	action.live = make(map[string]*liveRun)
 	// fix up run options
 
 	if options == nil {
 		options = &ActionOptions{Record: true}
+	} else {
+		warnDeprecatedOptions(options)
 	}
 
 	if options.MaxStepCount < 1 {
 		options.MaxStepCount = int(^uint16(0))
 	}
 
-	options.Record = (stateRecorder != nil) && options.Record
+	options.Record = (stateRecorder != nil) && (options.Record || options.CheckpointStrategy != nil || options.RecordFinalOnly)
+
+	if options.CheckpointStrategy == nil {
+		options.CheckpointStrategy = &EveryStepCheckpoint{}
+	}
+
+	if options.Clock == nil {
+		options.Clock = util.SystemClock
+	} else {
+		// the limiters refill on the clock of the action
+		if options.StartRateLimiter != nil {
+			options.StartRateLimiter.bucket.useClock(options.Clock)
+		}
+		if options.RecordRateLimiter != nil {
+			options.RecordRateLimiter.bucket.useClock(options.Clock)
+		}
+	}
+
+	if options.IDGenerator == nil {
+		options.IDGenerator = newUUIDGenerator()
+	}
+
+	if options.StepRetry != nil {
+		if classifier := options.ErrorClassifier; classifier != nil {
+			options.ErrorClassifier = func(err error) bool {
+				return RetryableErrorClassifier(err) || classifier(err)
+			}
+		} else {
+			options.ErrorClassifier = RetryableErrorClassifier
+		}
+
+		if options.StepRetry.MaxRetries > 0 {
+			options.MaxTaskRetries = options.StepRetry.MaxRetries
+		}
+	}
+
+	if options.ErrorClassifier == nil {
+		options.ErrorClassifier = NoRetryClassifier
+	}
+
+	if options.MaxTaskRetries < 1 {
+		options.MaxTaskRetries = DefaultMaxTaskRetries
+	}
 
 	action.actionOptions = options
+	action.idGenerator = options.IDGenerator
+
+	decorators := make([]StepDecorator, 0, len(options.StepDecorators)+5)
+
+	// the whole step is abandoned when it times out, so none of the other
+	// decorators touch the instance while the abandoned step owns it
+	if options.StepTimeout > 0 {
+		decorators = append(decorators, action.stepTimeout)
+	}
+
+	decorators = append(decorators, options.StepDecorators...)
+	decorators = append(decorators, action.workUnitBudget)
+
+	if options.ValidateOutputs {
+		decorators = append(decorators, validateOutputs)
+	}
+
+	if options.LoopDetection != nil {
+		decorators = append(decorators, action.loopDetection)
+	}
+
+	step := doStep
+
+	if options.StepExecutor != nil {
+		step = executorStep(options.StepExecutor)
+	} else if workers := options.ParallelStepWorkers; workers > 0 {
+		step = func(instance *Instance) bool {
+			return instance.DoParallelStep(workers)
+		}
+	}
+
+	action.step = chainStepDecorators(step, decorators...)
+
+	if options.MaxConcurrentInstances > 0 {
+		action.slots = newSlots(options.MaxConcurrentInstances)
+	}
+
+	if options.RegistryTTL > 0 {
+		action.startJanitor(options.RegistryTTL)
+	}
+
+	if options.AsyncRecord && options.Record {
+		action.startAsyncRecorder(options.RecordBufferSize)
+	}
 
 	return &action
 }
@@ -64,67 +435,259 @@ type RunOptions struct {
 	ReturnID     bool
 	InitialState *Instance
 	ExecOptions  *ExecOptions
+	Timeout      time.Duration
+
+	// Priority of the run, when the MaxConcurrentInstances are executing the
+	// blocked runs are admitted by descending priority, see also
+	// ActionOptions.DeadlineForPriority
+	Priority int
+
+	// IdempotencyKey coalesces concurrent starts with the same key, only one
+	// instance is run and its results are shared with all callers, see also
+	// ActionOptions.RegistryTTL
+	IdempotencyKey string
+
+	// CorrelationID correlates the run with an existing logical run, by
+	// default the ID of the started instance is used
+	CorrelationID string
+
+	// Shadow runs the instance without side effects, see Instance.SetShadow,
+	// shadow instances are not recorded
+	Shadow bool
+
+	// SensitiveAttrs are the attributes of the run whose values should be
+	// encrypted by an EncryptingStateRecorder
+	SensitiveAttrs []string
+
+	// CompensationURI is the flow started when the instance fails, it is
+	// seeded with the state of the failed instance and its results are
+	// reported to the caller as CompensationResults
+	CompensationURI string
+
+	// IDNamespace is prepended to the ID generated for the instance, using
+	// the IDNamespaceSeparator, ie. to keep the instances of tenants apart
+	IDNamespace string
+
+	// ReplyHandlers also receive the replies of the instance, ie. for auditing,
+	// after the caller
+	ReplyHandlers []support.ReplyHandler
+
+	// ReplyTimeout bounds the time the caller waits for the reply of a flow
+	// with an explicit reply, zero means no timeout.  If the instance didn't
+	// reply or complete in time the caller receives a ReplyTimeoutError and
+	// the later reply is skipped, the instance keeps executing
+	ReplyTimeout time.Duration
+
+	// ReturnResult reports a FlowResult with the final status and attributes
+	// of the instance once it is done executing, instead of an IDResponse
+	ReturnResult bool
+
+	// ValidateInputs validates the trigger attributes of the start against the
+	// inputs of the flow, even if ActionOptions.ValidateInputs isn't set
+	ValidateInputs bool
+
+	// PreserveID restarts the instance of an AoRestart under its existing ID
+	// instead of a new one.  The snapshots of the restarted instance, which
+	// are keyed by ID, then overwrite the ones of the original instance in
+	// the StateRecorder instead of starting a new lineage
+	PreserveID bool
+
+	// parent is the instance that started the run as a subflow
+	parent *Instance
+
+	// instanceID is the ID of the started instance, generated by the caller,
+	// see RunBatch
+	instanceID string
 }
 
 // Run implements action.Action.Run
-func (fa *FlowAction) Run(context context.Context, uri string, options interface{}, handler action.ResultHandler) error {
+func (fa *FlowAction) Run(ctx context.Context, uri string, options interface{}, handler action.ResultHandler) error {
 
-	//todo: catch panic
 	//todo: consider switch to URI to dictate flow operation (ex. flow://blah/resume)
 
 	op := AoStart
-	retID := false
 
 	ro, ok := options.(*RunOptions)
 
 	if ok {
 		op = ro.Op
-		retID = ro.ReturnID
 	}
 
 	var instance *Instance
 
 	switch op {
 	case AoStart:
-		flow, _ := fa.flowProvider.GetFlow(uri)
+		flow, err := fa.flowProvider.GetFlow(uri)
 
 		if flow == nil {
-			err := fmt.Errorf("Flow [%s] not found", uri)
-			return err
+			return &FlowNotFoundError{URI: uri, Err: err}
+		}
+
+		// a subflow starts as a step of its parent, only the starts requested
+		// by the triggers are admitted, validated and rate limited
+		subflow := ok && ro.parent != nil
+
+		if fa.actionOptions.AdmitRun != nil && !subflow {
+			if err := fa.actionOptions.AdmitRun(uri, flow.Resources()); err != nil {
+				logger.Warnf("Flow [%s] not admitted - %s", uri, err.Error())
+				return err
+			}
+		}
+
+		if !subflow && (fa.actionOptions.ValidateInputs || (ok && ro.ValidateInputs)) {
+			var triggerAttrs []*data.Attribute
+			if ctx != nil {
+				triggerAttrs, _ = trigger.FromContext(ctx)
+			}
+
+			if err := validateInputs(uri, flow, triggerAttrs); err != nil {
+				logger.Warnf("Flow [%s] not started - %s", uri, err.Error())
+				return err
+			}
+		}
+
+		// the duplicates join the run before it takes a token and an ID, only
+		// the run that executes does
+		if ok && len(ro.IdempotencyKey) > 0 {
+
+			coalesced, inflight := fa.coalesce(ro.IdempotencyKey, handler)
+
+			if inflight {
+				logger.Debugf("Coalescing start of flow [%s] with key '%s'", uri, ro.IdempotencyKey)
+				return nil
+			}
+
+			handler = coalesced
+		}
+
+		if limiter := fa.actionOptions.StartRateLimiter; limiter != nil && !subflow {
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			if err := limiter.acquire(ctx); err != nil {
+				logger.Warnf("Flow [%s] not started - %s", uri, err.Error())
+				return failRun(handler, err)
+			}
+		}
+
+		instanceID := ""
+		if ok {
+			instanceID = ro.instanceID
+		}
+
+		if len(instanceID) == 0 {
+			if instanceID, err = fa.newInstanceID(ro); err != nil {
+				return failRun(handler, err)
+			}
 		}
 
-		instanceID := fa.idGenerator.NextAsString()
 		logger.Debug("Creating Instance: ", instanceID)
 
 		instance = NewFlowInstance(instanceID, uri, flow)
+
+		if ok && ro.parent != nil {
+			instance.parentID = ro.parent.ID()
+			instance.ancestry = append(append([]string(nil), ro.parent.ancestry...), ro.parent.FlowURI)
+		}
 	case AoResume:
-		if ok {
+		if ok && ro.InitialState != nil {
 			instance = ro.InitialState
 			logger.Debug("Resuming Instance: ", instance.ID())
+
+			if instance.Flow == nil {
+				// deserialized state, ie. of an evicted instance
+				if err := instance.Restart(instance.ID(), fa.flowProvider); err != nil {
+					return err
+				}
+			}
 		} else {
-			return errors.New("Unable to resume instance, resume options not provided")
+			return &RunOptionsError{Op: AoResume, URI: uri}
 		}
 	case AoRestart:
-		if ok {
+		if ok && ro.InitialState != nil {
 			instance = ro.InitialState
-			instanceID := fa.idGenerator.NextAsString()
-			instance.Restart(instanceID, fa.flowProvider)
+			instanceID := instance.ID()
+
+			if !ro.PreserveID {
+				var err error
+				if instanceID, err = fa.newInstanceID(ro); err != nil {
+					return err
+				}
+			}
+
+			if err := instance.Restart(instanceID, fa.flowProvider); err != nil {
+				return err
+			}
 
 			logger.Debug("Restarting Instance: ", instanceID)
 		} else {
-			return errors.New("Unable to restart instance, restart options not provided")
+			return &RunOptionsError{Op: AoRestart, URI: uri}
 		}
 	}
 
-	if ok && ro.ExecOptions != nil {
-		logger.Debugf("Applying Exec Options to instance: %s\n", instance.ID())
-		ApplyExecOptions(instance, ro.ExecOptions)
+	if err := fa.execute(ctx, op, instance, ro, handler); err != nil {
+		return failRun(handler, err)
+	}
+
+	return nil
+}
+
+// failRun releases the callers that joined a coalesced run that couldn't be
+// executed, returns the error
+func failRun(handler action.ResultHandler, err error) error {
+
+	if run, coalesced := handler.(*coalescedRun); coalesced {
+		run.HandleResult(500, nil, err)
+		run.Done()
+	}
+
+	return err
+}
+
+// execute executes the instance asynchronously, the handler is notified of the
+// results and when the instance is done executing
+func (fa *FlowAction) execute(ctx context.Context, op int, instance *Instance, ro *RunOptions, handler action.ResultHandler) error {
+
+	retID := ro != nil && ro.ReturnID
+	retResult := ro != nil && ro.ReturnResult
+
+	record := fa.configure(instance, ro)
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := fa.trackRun(ro != nil && ro.parent != nil); err != nil {
+		logger.Warnf("Flow [%s] not executed - %s", instance.ID(), err.Error())
+		return err
 	}
 
-	triggerAttrs, ok := trigger.FromContext(context)
+	var release func()
+	var err error
+
+	if ro != nil && ro.parent != nil {
+		// a subflow runs in the execution slot of its parent
+		release = func() {}
+	} else {
+		priority := 0
+		if ro != nil {
+			priority = ro.Priority
+		}
+
+		release, err = fa.acquireSlot(ctx, priority)
+	}
+
+	if err != nil {
+		fa.running.Done()
+		logger.Warnf("Flow [%s] not executed - %s", instance.ID(), err.Error())
+		return err
+	}
+
+	triggerAttrs, ok := trigger.FromContext(ctx)
 
 	if ok {
-		if len(triggerAttrs) > 0 {
+		if len(triggerAttrs) > 0 && logger.IsEnabledFor(logger.DebugLevel) {
 			logger.Debug("Run Attributes:")
 			for _, attr := range triggerAttrs {
 				logger.Debugf(" Attr:%s, Type:%s, Value:%v", attr.Name, attr.Type.String(), attr.Value)
@@ -133,63 +696,694 @@ func (fa *FlowAction) Run(context context.Context, uri string, options interface
 	}
 
 	if op == AoStart {
+		fa.liftContextValues(ctx, instance)
 		instance.Start(triggerAttrs)
 	} else {
 		instance.UpdateAttrs(triggerAttrs)
 	}
 
-	logger.Debugf("Executing instance: %s\n", instance.ID())
+	logger.Debugf("Executing instance: %s [correlation: %s]\n", instance.ID(), instance.CorrelationID())
 
 	stepCount := 0
 	hasWork := true
+	pending := false
+	cancelled := false
+	evicted := false
+	stepWarned := false
+	lastSnapshot := fa.actionOptions.Clock.Now()
+
+	simpleReplyHandler := &SimpleReplyHandler{resultHandler: handler, ctx: ctx}
+	var replyHandler support.ReplyHandler = simpleReplyHandler
+
+	if ro != nil && len(ro.ReplyHandlers) > 0 {
+		replyHandler = support.NewMultiReplyHandler(append([]support.ReplyHandler{replyHandler}, ro.ReplyHandlers...)...)
+	}
+
+	instance.SetReplyHandler(replyHandler)
+
+	if ro != nil && ro.ReplyTimeout > 0 && instance.Flow.ExplicitReply() {
+		simpleReplyHandler.startTimeout(fa.actionOptions.Clock, instance.ID(), ro.ReplyTimeout)
+	}
+
+	var runCtx context.Context
+	var cancel context.CancelFunc
+	var pausable *pausableDeadline
+
+	if deadline := fa.deadline(ctx, ro); deadline > 0 {
+		logger.Debugf("Instance [%s] has deadline of %v", instance.ID(), deadline)
 
-	instance.SetReplyHandler(&SimpleReplyHandler{resultHandler: handler})
+		if fa.actionOptions.PauseExecutionTimeout {
+			pausable = newPausableDeadline(fa.actionOptions.Clock, ctx, deadline)
+			runCtx, cancel = pausable, pausable.stop
+		} else {
+			runCtx, cancel = withTimeout(fa.actionOptions.Clock, ctx, deadline)
+		}
+	} else {
+		runCtx, cancel = context.WithCancel(ctx)
+	}
+
+	run := fa.register(instance, cancel, pausable)
+
+	// the context of the steps, it carries the span of the instance when
+	// tracing
+	stepCtx := runCtx
+
+	instance.subflows = func(uri string, inputs map[string]interface{}) (map[string]interface{}, error) {
+		return fa.runSubflow(stepCtx, instance, uri, inputs)
+	}
+	observers := fa.instanceObservers()
+	started := false
 
 	go func() {
 
-		defer handler.Done()
+		defer fa.running.Done()
+		defer fa.flushRecorder(instance)
+		defer func() { handler.Done() }()
+		defer fa.drainRecords(instance)
+		defer fa.unregister(run)
+		defer release()
+		defer cancel()
+		defer simpleReplyHandler.stopTimeout()
+
+		defer func() {
+			if started {
+				notifyObservers(observers, func(observer InstanceObserver) { observer.OnComplete(instance.ID(), instance.Status()) })
+			}
+		}()
+
+		defer func() {
+			if r := recover(); r != nil {
+				fa.recoverRun(instance, handler, record, r)
+
+				if fa.actionOptions.RepanicOnPanic {
+					panic(r)
+				}
+			}
+		}()
+
+		// a run cancelled before it got to execute shouldn't do any work
+		if runCtx.Err() != nil {
+			logger.Infof("Flow [%s] Cancelled before starting [correlation: %s] - %s", instance.ID(), instance.CorrelationID(), runCtx.Err().Error())
+			fa.cancelRun(instance, handler, record, cancelCause(ctx, instance, runCtx.Err()))
+			return
+		}
+
+		if metrics := fa.actionOptions.MetricsCollector; metrics != nil {
+			start := time.Now()
+			metrics.instanceStarted(instance)
+			defer func() { metrics.instanceFinished(instance, stepCount, time.Since(start)) }()
+		}
+
+		if fa.actionOptions.Tracer != nil {
+			var span Span
+			stepCtx, span = fa.startInstanceSpan(runCtx, instance)
+			defer finishInstanceSpan(runCtx, span, instance)
+		}
+
+		started = true
+		notifyObservers(observers, func(observer InstanceObserver) { observer.OnStart(instance.ID(), instance.FlowURI) })
 
 		if !instance.Flow.ExplicitReply() {
-			handler.HandleResult(200, &IDResponse{ID: instance.ID()}, nil)
+			handler.HandleResult(200, fa.newIDResponse(instance.ID()), nil)
 		}
 
-		for hasWork && instance.Status() < StatusCompleted && stepCount < fa.actionOptions.MaxStepCount {
+		for hasWork && !instance.Status().IsTerminal() && stepCount < fa.actionOptions.MaxStepCount {
+			// a paused run blocks between steps until it is resumed
+			run.waitWhilePaused(runCtx)
+
+			if run.evictRequested() {
+				logger.Debugf("Flow [%s] stopped for eviction", instance.ID())
+				evicted = true
+				break
+			}
+
+			if runCtx.Err() != nil {
+				logger.Infof("Flow [%s] Cancelled [correlation: %s] - %s", instance.ID(), instance.CorrelationID(), runCtx.Err().Error())
+				fa.cancelRun(instance, handler, record, cancelCause(ctx, instance, runCtx.Err()))
+				pending = false
+				cancelled = true
+				break
+			}
+
 			stepCount++
 			logger.Debugf("Step: %d\n", stepCount)
-			hasWork = instance.DoStep()
 
-			if fa.actionOptions.Record {
-				fa.stateRecorder.RecordSnapshot(instance)
-				fa.stateRecorder.RecordStep(instance)
+			if !stepWarned && fa.nearsMaxStepCount(stepCount) {
+				stepWarned = true
+				logger.Warnf("Flow [%s] is nearing the max step count (%d of %d)", instance.ID(), stepCount, fa.actionOptions.MaxStepCount)
+
+				if fa.actionOptions.OnStepWarning != nil {
+					fa.actionOptions.OnStepWarning(instance, stepCount)
+				}
+			}
+
+			var stepSpan Span
+			if fa.actionOptions.Tracer != nil {
+				stepSpan = fa.startStepSpan(stepCtx, instance, stepCount)
+			}
+
+			prevStatus := instance.Status()
+			hasWork = fa.step(instance)
+
+			if abandoned := instance.takeAbandonedStep(); abandoned != nil {
+				// the caller doesn't wait for the abandoned step, which owns
+				// the instance until it returns and fails it
+				simpleReplyHandler.release(CodeStepTimeout, abandoned.err)
+				handler = &noopResultHandler{}
+
+				<-abandoned.returned
+			}
+
+			if stepSpan != nil {
+				finishStepSpan(stepSpan, instance)
+			}
+			statusChanged := prevStatus != instance.Status()
+			run.setStatus(instance.Status())
+			run.setProgress(fa.actionOptions.Clock.Now())
+
+			notifyObservers(observers, func(observer InstanceObserver) { observer.OnStep(instance.ID(), stepCount) })
+
+			if record && !fa.actionOptions.RecordFinalOnly && (fa.actionOptions.CheckpointStrategy.ShouldCheckpoint(instance, stepCount, statusChanged) || fa.snapshotTooOld(lastSnapshot)) {
+				pending = fa.record(instance, pending)
+
+				if !pending {
+					lastSnapshot = fa.actionOptions.Clock.Now()
+				}
+			}
+
+			if retry := fa.actionOptions.StepRetry; retry != nil && instance.StepRetries() > 0 {
+				// the retry redoes the work of the failed step
+				stepCount--
+
+				logger.Debugf("Flow [%s] backing off before retry %d", instance.ID(), instance.StepRetries())
+				retry.backoff(fa.actionOptions.Clock, runCtx, instance.StepRetries())
 			}
 		}
 
-		if retID {
-			handler.HandleResult(200, &IDResponse{ID: instance.ID()}, nil)
+		if !cancelled && runCtx.Err() != nil && instance.Status() == StatusFailed && subflowCancelled(instance) {
+			// the instance didn't fail, its subflow was cancelled with it
+			logger.Infof("Flow [%s] Cancelled [correlation: %s] - %s", instance.ID(), instance.CorrelationID(), runCtx.Err().Error())
+			fa.cancelRun(instance, handler, record, cancelCause(ctx, instance, runCtx.Err()))
+			pending = false
+			cancelled = true
 		}
 
-		logger.Debugf("Done Executing A.instance [%s] - Status: %d\n", instance.ID(), instance.Status())
+		// the completion results are reported instead
+		simpleReplyHandler.stopTimeout()
+
+		if record && fa.actionOptions.RecordFinalOnly && instance.Status().IsTerminal() && !cancelled {
+			pending = true
+		}
+
+		if hasWork && !instance.Status().IsTerminal() && stepCount >= fa.actionOptions.MaxStepCount {
+			logger.Warnf("Flow [%s] Aborted, max step count of %d exceeded [correlation: %s]", instance.ID(), fa.actionOptions.MaxStepCount, instance.CorrelationID())
+			fa.abortRun(instance, handler)
+			pending = pending || record
+		}
+
+		if pending {
+			// make sure the latest state of the instance is recorded
+			fa.waitForRecordLimit()
+
+			err := fa.recordState(instance, true, false)
+			if err != nil {
+				fa.handleRecordError(instance, err)
+			}
+		}
+
+		if evicted {
+			handler.HandleResult(CodeEvicted, nil, &EvictedError{InstanceID: instance.ID()})
+		} else if retResult {
+			handler.HandleResult(200, newFlowResult(instance), nil)
+		} else if retID {
+			handler.HandleResult(200, fa.newIDResponse(instance.ID()), nil)
+		}
+
+		logger.Debugf("Done Executing A.instance [%s] - Status: %s\n", instance.ID(), instance.Status())
 
 		if instance.Status() == StatusCompleted {
-			logger.Infof("Flow [%s] Completed", instance.ID())
+			logger.Infof("Flow [%s] Completed [correlation: %s]", instance.ID(), instance.CorrelationID())
+		}
+
+		if reason := deadLetterReason(instance.Status()); len(reason) > 0 && fa.actionOptions.DeadLetterSink != nil {
+			logger.Infof("Flow [%s] sent to the dead-letter sink, %s [correlation: %s]", instance.ID(), reason, instance.CorrelationID())
+			fa.actionOptions.DeadLetterSink.Send(newDeadLetter(instance, reason, triggerAttrs, runCtx.Err()))
+		}
+
+		if instance.Status() == StatusFailed && ro != nil && len(ro.CompensationURI) > 0 {
+			// the compensation waits for a slot of its own, like any run
+			release()
+			fa.compensate(ro.CompensationURI, instance, ro, handler)
 		}
 	}()
 
 	return nil
 }
 
+// configure applies the action and run options to the instance, returns true
+// if the state of the instance should be recorded
+func (fa *FlowAction) configure(instance *Instance, ro *RunOptions) (record bool) {
+
+	if fa.actionOptions.TaskScheduler != nil {
+		instance.SetTaskScheduler(fa.actionOptions.TaskScheduler)
+	}
+
+	instance.SetErrorClassifier(fa.actionOptions.ErrorClassifier, fa.actionOptions.MaxTaskRetries)
+
+	if fa.actionOptions.RecordMappedInputs {
+		instance.SetRecordMappedInputs(true)
+	}
+
+	if fa.actionOptions.PanicFormatter != nil {
+		instance.SetPanicFormatter(fa.actionOptions.PanicFormatter)
+	}
+
+	if fa.actionOptions.RecordLinkDecisions {
+		instance.SetRecordLinkDecisions(true)
+	}
+
+	if ro != nil && len(ro.SensitiveAttrs) > 0 {
+		instance.sensitiveAttrs = make(map[string]bool, len(ro.SensitiveAttrs))
+
+		for _, name := range ro.SensitiveAttrs {
+			instance.sensitiveAttrs[name] = true
+		}
+	}
+
+	record = fa.actionOptions.Record
+
+	if ro != nil && ro.Shadow {
+		instance.SetShadow(true)
+		record = false
+	}
+
+	if ro != nil && len(ro.CorrelationID) > 0 {
+		instance.SetCorrelationID(ro.CorrelationID)
+	}
+
+	if ro != nil && ro.ExecOptions != nil {
+		logger.Debugf("Applying Exec Options to instance: %s\n", instance.ID())
+		ApplyExecOptions(instance, ro.ExecOptions)
+	}
+
+	return record
+}
+
+// record records the snapshot and step of the instance, subject to the
+// RecordRateLimiter. Returns true if the write was skipped and the state of the
+// instance is pending.
+func (fa *FlowAction) record(instance *Instance, pending bool) bool {
+
+	limiter := fa.actionOptions.RecordRateLimiter
+
+	if limiter != nil && limiter.Policy == RateLimitBuffer {
+
+		if !limiter.TryAcquire() {
+			if metrics := fa.actionOptions.MetricsCollector; metrics != nil {
+				metrics.recorderDropped()
+			}
+			return true
+		}
+	} else {
+		fa.waitForRecordLimit()
+	}
+
+	err := fa.recordState(instance, true, true)
+
+	if err != nil {
+		fa.handleRecordError(instance, err)
+	}
+
+	return false
+}
+
+// snapshotTooOld determines if a snapshot has to be forced because of the
+// MaxSnapshotAge
+func (fa *FlowAction) snapshotTooOld(lastSnapshot time.Time) bool {
+
+	maxAge := fa.actionOptions.MaxSnapshotAge
+
+	return maxAge > 0 && fa.actionOptions.Clock.Now().Sub(lastSnapshot) >= maxAge
+}
+
+// callRecorder makes the specified recorder call, giving up once the
+// RecordTimeout expires
+func (fa *FlowAction) callRecorder(instance *Instance, call func()) error {
+
+	timeout := fa.actionOptions.RecordTimeout
+
+	if timeout <= 0 {
+		call()
+		return nil
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		call()
+		close(done)
+	}()
+
+	timedOut := make(chan struct{})
+
+	stop := util.AfterFunc(fa.actionOptions.Clock, timeout, func() {
+		close(timedOut)
+	})
+	defer stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timedOut:
+		return fmt.Errorf("Recording the state of Flow [%s] timed out after %v", instance.ID(), timeout)
+	}
+}
+
+// cancelRun cancels the instance, or times it out if it exceeded its deadline,
+// records its final state if record is set and reports the cancellation to
+// the caller.  A subflow cancelled with its parent, see ParentCancelledError,
+// is cancelled rather than timed out, so it isn't sent to the DeadLetterSink
+// on its own
+func (fa *FlowAction) cancelRun(instance *Instance, handler action.ResultHandler, record bool, err error) {
+
+	if err == context.DeadlineExceeded {
+		instance.setStatus(StatusTimedOut)
+	} else {
+		instance.setStatus(StatusCancelled)
+	}
+
+	if _, ok := err.(*ParentCancelledError); ok {
+		// the records show that the instance was cancelled with its parent
+		instance.setLastError(err)
+	}
+
+	if record {
+		fa.waitForRecordLimit()
+
+		if recordErr := fa.recordState(instance, true, false); recordErr != nil {
+			logger.Warn(recordErr.Error())
+		}
+	}
+
+	code := CodeCancelled
+	if err == context.DeadlineExceeded {
+		code = CodeDeadlineExceeded
+	}
+
+	handler.HandleResult(code, nil, err)
+}
+
+// MaxStepCountError is the error an instance is aborted with when it exceeds
+// the max step count
+type MaxStepCountError struct {
+	InstanceID string
+	Limit      int
+}
+
+// Error implements error.Error
+func (e *MaxStepCountError) Error() string {
+	return fmt.Sprintf("Flow [%s] exceeded the max step count of %d", e.InstanceID, e.Limit)
+}
+
+// abortRun aborts the instance that exceeded the max step count and reports
+// the abort to the caller
+func (fa *FlowAction) abortRun(instance *Instance, handler action.ResultHandler) {
+
+	err := &MaxStepCountError{InstanceID: instance.ID(), Limit: fa.actionOptions.MaxStepCount}
+
+	instance.setLastError(err)
+	instance.setStatus(StatusAborted)
+
+	handler.HandleResult(CodeMaxStepCountExceeded, nil, err)
+}
+
+// handleRecordError applies the OnRecordError policy
+func (fa *FlowAction) handleRecordError(instance *Instance, err error) {
+
+	logger.Warn(err.Error())
+
+	_, overflow := err.(*RecordOverflowError)
+
+	if (overflow || fa.actionOptions.OnRecordError == RecordErrorAbort) && !instance.Status().IsTerminal() {
+		instance.setLastError(err)
+		instance.setStatus(StatusFailed)
+	}
+}
+
+// waitForRecordLimit blocks until the RecordRateLimiter allows a write
+func (fa *FlowAction) waitForRecordLimit() {
+
+	limiter := fa.actionOptions.RecordRateLimiter
+
+	if limiter == nil {
+		return
+	}
+
+	if wait := limiter.Wait(); wait > 0 {
+		if metrics := fa.actionOptions.MetricsCollector; metrics != nil {
+			metrics.recorderWaited(wait)
+		}
+	}
+}
+
+// flushRecorder gives a FlushableStateRecorder the configured grace period to
+// flush the records of the instance
+func (fa *FlowAction) flushRecorder(instance *Instance) {
+
+	timeout := fa.actionOptions.FlushTimeout
+
+	if !fa.actionOptions.Record || timeout <= 0 {
+		return
+	}
+
+	recorder, ok := fa.stateRecorder.(FlushableStateRecorder)
+
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := recorder.Flush(ctx); err != nil {
+		logger.Warnf("Unable to flush records of Flow [%s] - %s", instance.ID(), err.Error())
+	}
+}
+
+// nearsMaxStepCount indicates if the step count crossed the StepWarningThreshold
+func (fa *FlowAction) nearsMaxStepCount(stepCount int) bool {
+
+	threshold := fa.actionOptions.StepWarningThreshold
+
+	if threshold <= 0 {
+		return false
+	}
+
+	return float64(stepCount) >= threshold*float64(fa.actionOptions.MaxStepCount)
+}
+
+// newIDResponse creates an IDResponse that is serialized according to the
+// configured ActionOptions
+func (fa *FlowAction) newIDResponse(id string) *IDResponse {
+	return &IDResponse{ID: id, key: fa.actionOptions.IDResponseKey, wrapper: fa.actionOptions.IDResponseWrapper}
+}
+
+// exceedsWorkUnits indicates if the instance went over the MaxWorkUnits budget
+func (fa *FlowAction) exceedsWorkUnits(instance *Instance) bool {
+
+	max := fa.actionOptions.MaxWorkUnits
+	return max > 0 && instance.WorkUnits() > max
+}
+
+// deadline determines the deadline for a run, the remaining time of the
+// deadline of the context of the run is used if it is stricter than the
+// timeout of the run
+func (fa *FlowAction) deadline(ctx context.Context, ro *RunOptions) time.Duration {
+
+	timeout := fa.timeout(ro)
+
+	if ctxDeadline, ok := ctx.Deadline(); ok {
+		if remaining := ctxDeadline.Sub(fa.actionOptions.Clock.Now()); remaining > 0 && (timeout <= 0 || remaining < timeout) {
+			return remaining
+		}
+	}
+
+	return timeout
+}
+
+// timeout determines the timeout for a run, an explicit timeout takes
+// precedence over the one computed from the priority
+func (fa *FlowAction) timeout(ro *RunOptions) time.Duration {
+
+	if ro != nil {
+		if ro.Timeout > 0 {
+			return ro.Timeout
+		}
+
+		if fa.actionOptions.DeadlineForPriority != nil {
+			if deadline := fa.actionOptions.DeadlineForPriority(ro.Priority); deadline > 0 {
+				return deadline
+			}
+		}
+	}
+
+	return fa.actionOptions.ExecutionTimeout
+}
+
 // SimpleReplyHandler is a simple ReplyHandler that is pass-thru to the action ResultHandler
 type SimpleReplyHandler struct {
 	resultHandler action.ResultHandler
+	ctx           context.Context
+
+	mu        sync.Mutex
+	stopTimer func() bool
+	replied   bool
+	stopped   bool
+	timedOut  bool
+	released  bool
 }
 
-// Reply implements ReplyHandler.Reply
+// Reply implements ReplyHandler.Reply, the reply is skipped if the context
+// of the caller has already been cancelled or the ReplyTimeout expired
 func (rh *SimpleReplyHandler) Reply(replyCode int, replyData interface{}, err error) {
 
+	if rh.ctx != nil && rh.ctx.Err() != nil {
+		logger.Infof("Skipping reply, caller is gone - %s", rh.ctx.Err().Error())
+		return
+	}
+
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	if rh.timedOut {
+		logger.Infof("Skipping reply, the reply timed out")
+		return
+	}
+
+	if rh.released {
+		logger.Infof("Skipping reply, the caller was released")
+		return
+	}
+
+	rh.replied = true
+
+	if rh.stopTimer != nil {
+		rh.stopTimer()
+	}
+
 	rh.resultHandler.HandleResult(replyCode, replyData, err)
 }
 
+// startTimeout reports a ReplyTimeoutError to the caller if the instance
+// doesn't reply within the timeout
+func (rh *SimpleReplyHandler) startTimeout(clock Clock, instanceID string, timeout time.Duration) {
+
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	rh.stopTimer = util.AfterFunc(clock, timeout, func() {
+
+		rh.mu.Lock()
+		defer rh.mu.Unlock()
+
+		if rh.replied || rh.stopped || rh.timedOut {
+			return
+		}
+
+		rh.timedOut = true
+
+		err := &ReplyTimeoutError{InstanceID: instanceID, Timeout: timeout}
+		logger.Warn(err.Error())
+
+		rh.resultHandler.HandleResult(CodeReplyTimeout, nil, err)
+	})
+}
+
+// stopTimeout stops the ReplyTimeout, ie. once the instance is done
+func (rh *SimpleReplyHandler) stopTimeout() {
+
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	if rh.stopTimer != nil {
+		rh.stopTimer()
+	}
+
+	rh.stopped = true
+}
+
+// release reports the result to the caller and notifies it that the run is
+// done, ie. when its step is abandoned, the later replies are skipped
+func (rh *SimpleReplyHandler) release(code int, err error) {
+
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	if rh.stopTimer != nil {
+		rh.stopTimer()
+	}
+
+	rh.stopped = true
+	rh.released = true
+
+	rh.resultHandler.HandleResult(code, nil, err)
+	rh.resultHandler.Done()
+}
+
+// noopResultHandler is a ResultHandler for runs whose results are discarded
+type noopResultHandler struct {
+}
+
+// HandleResult implements action.ResultHandler.HandleResult
+func (rh *noopResultHandler) HandleResult(code int, data interface{}, err error) {
+}
+
+// Done implements action.ResultHandler.Done
+func (rh *noopResultHandler) Done() {
+}
+
 // IDResponse is a response object consists of an ID
 type IDResponse struct {
 	ID string `json:"id"`
+
+	key     string
+	wrapper string
+}
+
+// FlowResult is the response of a run with RunOptions.ReturnResult, it
+// contains the final status and attributes of the instance
+type FlowResult struct {
+	ID     string                     `json:"id"`
+	Status int                        `json:"status"`
+	Attrs  map[string]*data.Attribute `json:"attrs,omitempty"`
+}
+
+// newFlowResult creates a FlowResult from the current state of the instance
+func newFlowResult(instance *Instance) *FlowResult {
+
+	result := &FlowResult{ID: instance.ID(), Status: int(instance.Status())}
+
+	if attrs := instance.CopyAttrs(); len(attrs) > 0 {
+		result.Attrs = attrs
+	}
+
+	return result
+}
+
+// MarshalJSON overrides the default MarshalJSON for IDResponse, so that the
+// key of the ID and an optional wrapping object can be configured
+func (r *IDResponse) MarshalJSON() ([]byte, error) {
+
+	key := r.key
+	if len(key) == 0 {
+		key = "id"
+	}
+
+	var v interface{} = map[string]string{key: r.ID}
+
+	if len(r.wrapper) > 0 {
+		v = map[string]interface{}{r.wrapper: v}
+	}
+
+	return json.Marshal(v)
 }
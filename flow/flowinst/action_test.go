@@ -0,0 +1,906 @@
+package flowinst
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/flow/activity"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/TIBCOSoftware/flogo-lib/flow/model"
+	"github.com/TIBCOSoftware/flogo-lib/flow/support"
+	"github.com/TIBCOSoftware/flogo-lib/flow/test"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestDeadlineForPriority
+func TestDeadlineForPriority(t *testing.T) {
+
+	options := &ActionOptions{DeadlineForPriority: func(priority int) time.Duration {
+		if priority < 5 {
+			return time.Second
+		}
+		return time.Minute
+	}}
+
+	fa := NewFlowAction(nil, nil, options)
+
+	// low priority run gets the shorter deadline
+	assert.Equal(t, time.Second, fa.timeout(&RunOptions{Priority: 1}))
+	assert.Equal(t, time.Minute, fa.timeout(&RunOptions{Priority: 10}))
+
+	// an explicit timeout takes precedence
+	assert.Equal(t, 5*time.Second, fa.timeout(&RunOptions{Priority: 1, Timeout: 5 * time.Second}))
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fa = NewFlowAction(provider, nil, &ActionOptions{Clock: clock, TaskScheduler: &FIFOTaskScheduler{}, DeadlineForPriority: func(priority int) time.Duration {
+		if priority < 5 {
+			return 20 * time.Millisecond
+		}
+		return time.Minute
+	}})
+
+	run := func(priority int) *errorResultHandler {
+		handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+		err := fa.Run(nil, "gated", &RunOptions{Priority: priority}, handler)
+		assert.Nil(t, err)
+
+		// 'a' takes longer than the deadline of the low priority run
+		<-gate.entered
+		clock.advance(50 * time.Millisecond)
+		gate.release <- true
+		<-handler.done
+
+		return handler
+	}
+
+	handler := run(1)
+	assert.Equal(t, []int{200, CodeDeadlineExceeded}, handler.codes)
+	assert.Equal(t, context.DeadlineExceeded, handler.errors[1])
+
+	handler = run(10)
+	assert.Equal(t, []int{200}, handler.codes)
+}
+
+const simpleDefJSON = `
+{
+    "type": 1,
+    "name": "simple",
+    "model": "test",
+    "rootTask": {
+      "id": 1,
+      "type": 1,
+      "activityType": "",
+      "name": "root",
+      "tasks": [
+        {
+          "id": 2,
+          "type": 1,
+          "name": "a"
+        }
+      ]
+    }
+  }
+`
+
+type testFlowProvider struct {
+	flows map[string]*flowdef.Definition
+}
+
+func (p *testFlowProvider) GetFlow(flowURI string) (*flowdef.Definition, error) {
+	return p.flows[flowURI], nil
+}
+
+func newTestFlowProvider(t *testing.T) *testFlowProvider {
+
+	defRep := &flowdef.DefinitionRep{}
+	err := json.Unmarshal([]byte(simpleDefJSON), defRep)
+	assert.Nil(t, err)
+
+	def, err := flowdef.NewDefinition(defRep)
+	assert.Nil(t, err)
+
+	return &testFlowProvider{flows: map[string]*flowdef.Definition{"test": def}}
+}
+
+type testResultHandler struct {
+	done    chan bool
+	results []interface{}
+}
+
+func newTestResultHandler() *testResultHandler {
+	return &testResultHandler{done: make(chan bool, 1)}
+}
+
+func (rh *testResultHandler) HandleResult(code int, data interface{}, err error) {
+	rh.results = append(rh.results, data)
+}
+
+func (rh *testResultHandler) Done() {
+	rh.done <- true
+}
+
+type testStateRecorder struct {
+	snapshots []Status
+	steps     int
+	instance  *Instance
+}
+
+func (sr *testStateRecorder) RecordSnapshot(instance *Instance) {
+	sr.snapshots = append(sr.snapshots, instance.Status())
+	sr.instance = instance
+}
+
+func (sr *testStateRecorder) RecordStep(instance *Instance) {
+	sr.steps++
+}
+
+//TestRecordFinalOnly
+func TestRecordFinalOnly(t *testing.T) {
+
+	recorder := &testStateRecorder{}
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{RecordFinalOnly: true})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, []Status{StatusCompleted}, recorder.snapshots)
+	assert.Equal(t, 0, recorder.steps)
+
+	// an aborted instance is recorded once too
+	recorder = &testStateRecorder{}
+	fa = NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{RecordFinalOnly: true, MaxStepCount: 1})
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, []Status{StatusAborted}, recorder.snapshots)
+	assert.Equal(t, 0, recorder.steps)
+}
+
+type blockingStateRecorder struct {
+	release chan bool
+}
+
+func (sr *blockingStateRecorder) RecordSnapshot(instance *Instance) {
+	<-sr.release
+}
+
+func (sr *blockingStateRecorder) RecordStep(instance *Instance) {
+}
+
+//TestReplySkippedWhenCallerGone
+func TestReplySkippedWhenCallerGone(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	handler := newTestResultHandler()
+	replyHandler := &SimpleReplyHandler{resultHandler: handler, ctx: ctx}
+
+	replyHandler.Reply(200, "first", nil)
+	cancel()
+	replyHandler.Reply(200, "second", nil)
+
+	assert.Equal(t, []interface{}{"first"}, handler.results)
+}
+
+//TestStepWarning
+func TestStepWarning(t *testing.T) {
+
+	var warnings []int
+
+	options := &ActionOptions{MaxStepCount: 4, StepWarningThreshold: 0.5}
+	options.OnStepWarning = func(instance *Instance, stepCount int) {
+		warnings = append(warnings, stepCount)
+	}
+
+	fa := NewFlowAction(newTestFlowProvider(t), nil, options)
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// fires once, when crossing the threshold
+	assert.Equal(t, []int{2}, warnings)
+}
+
+//TestIDResponseKey
+func TestIDResponseKey(t *testing.T) {
+
+	b, err := json.Marshal(&IDResponse{ID: "1234"})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":"1234"}`, string(b))
+
+	fa := NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{IDResponseKey: "instanceId"})
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	idResponse := handler.results[0].(*IDResponse)
+
+	b, err = json.Marshal(idResponse)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"instanceId":"`+idResponse.ID+`"}`, string(b))
+
+	fa = NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{IDResponseKey: "instanceId", IDResponseWrapper: "data"})
+
+	b, err = json.Marshal(fa.newIDResponse("1234"))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"data":{"instanceId":"1234"}}`, string(b))
+}
+
+//TestReturnResult
+func TestReturnResult(t *testing.T) {
+
+	fa := NewFlowAction(newTestFlowProvider(t), nil, nil)
+
+	ctx := trigger.NewContext(context.Background(), []*data.Attribute{data.NewAttribute("orderId", data.STRING, "order-1")})
+
+	handler := newTestResultHandler()
+	err := fa.Run(ctx, "test", &RunOptions{ReturnID: true, ReturnResult: true}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	id := handler.results[0].(*IDResponse).ID
+
+	result, ok := handler.results[len(handler.results)-1].(*FlowResult)
+	assert.True(t, ok)
+	assert.Equal(t, id, result.ID)
+	assert.Equal(t, int(StatusCompleted), result.Status)
+	assert.Equal(t, "order-1", result.Attrs["{T.orderId}"].Value)
+
+	// ReturnID alone keeps replying with an IDResponse
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "test", &RunOptions{ReturnID: true}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.IsType(t, &IDResponse{}, handler.results[len(handler.results)-1])
+}
+
+const budgetDefJSON = `
+{
+    "type": 1,
+    "name": "budget",
+    "model": "budget",
+    "rootTask": {
+      "id": 1,
+      "type": 1,
+      "name": "root",
+      "tasks": [
+        { "id": 2, "type": 2, "activityType": "workunits", "name": "a", "ouputMappings": [] },
+        { "id": 3, "type": 2, "activityType": "workunits", "name": "b", "ouputMappings": [] },
+        { "id": 4, "type": 2, "activityType": "workunits", "name": "c", "ouputMappings": [] }
+      ]
+    }
+  }
+`
+
+// registerActivity registers the activity unless an activity with its ID is
+// already registered, so the tests can run more than once
+func registerActivity(act activity.Activity) {
+
+	if activity.Get(act.Metadata().ID) == nil {
+		activity.Register(act)
+	}
+}
+
+// registerModel registers the model unless a model with its name is already
+// registered, so the tests can run more than once
+func registerModel(m *model.FlowModel) {
+
+	if model.Get(m.Name()) == nil {
+		model.Register(m)
+	}
+}
+
+func init() {
+	registerActivity(&workUnitsActivity{metadata: &activity.Metadata{ID: "workunits"}})
+
+	m := model.New("budget")
+	m.RegisterFlowBehavior(&test.SimpleFlowBehavior{})
+	m.RegisterTaskBehavior(1, &allChildrenTaskBehavior{SimpleTaskBehavior: &test.SimpleTaskBehavior{}})
+	m.RegisterTaskBehavior(2, &test.SimpleTaskBehavior{})
+	model.Register(m)
+}
+
+// workUnitsActivity reports 3 work units each time it is evaluated
+type workUnitsActivity struct {
+	metadata *activity.Metadata
+}
+
+func (a *workUnitsActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+func (a *workUnitsActivity) Eval(context activity.Context) (done bool, err error) {
+	activity.AddWorkUnits(context, 3)
+	return true, nil
+}
+
+// allChildrenTaskBehavior is only done once all its children are done, the
+// children done are counted in the state of the task so the count survives the
+// serialization of the instance
+type allChildrenTaskBehavior struct {
+	*test.SimpleTaskBehavior
+}
+
+func (b *allChildrenTaskBehavior) ChildDone(context model.TaskContext, childTask *flowdef.Task, childDoneCode int) (done bool, doneCode int) {
+	childrenDone := context.State() - test.STATE_WAITING + 1
+	context.SetState(test.STATE_WAITING + childrenDone)
+
+	return childrenDone == len(context.Task().ChildTasks()), 0
+}
+
+const mappedDefJSON = `
+{
+    "type": 1,
+    "name": "mapped",
+    "model": "test",
+    "rootTask": {
+      "id": 1,
+      "type": 1,
+      "name": "root",
+      "tasks": [
+        {
+          "id": 2,
+          "type": 1,
+          "activityType": "echo",
+          "name": "echo",
+          "inputMappings": [
+            { "type": 2, "value": "hello", "mapTo": "message" }
+          ],
+          "ouputMappings": []
+        }
+      ]
+    }
+  }
+`
+
+func init() {
+	md := &activity.Metadata{ID: "echo", Inputs: map[string]*data.Attribute{
+		"message": data.NewAttribute("message", data.STRING, nil),
+		"level":   data.NewAttribute("level", data.STRING, "INFO"),
+	}}
+	registerActivity(&workUnitsActivity{metadata: md})
+}
+
+//TestRunBuiltFlow
+func TestRunBuiltFlow(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("built").Model("budget").
+		AddTask(2, 2, "a", "").
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	recorder := &testStateRecorder{}
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"built": def}}
+	fa := NewFlowAction(provider, recorder, &ActionOptions{Record: true})
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "built", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// root, a and b
+	assert.Equal(t, 3, recorder.steps)
+	assert.Equal(t, StatusCompleted, recorder.instance.Status())
+}
+
+// gatedResultHandler blocks the first result until released
+type gatedResultHandler struct {
+	*testResultHandler
+	release chan bool
+	once    sync.Once
+}
+
+func (rh *gatedResultHandler) HandleResult(code int, data interface{}, err error) {
+	rh.once.Do(func() { <-rh.release })
+	rh.testResultHandler.HandleResult(code, data, err)
+}
+
+//TestCancelBeforeStart
+func TestCancelBeforeStart(t *testing.T) {
+
+	recorder := &testStateRecorder{}
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true})
+
+	// cancelled before the run was even submitted
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err := fa.Run(ctx, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// only the cancellation is reported
+	assert.Equal(t, 0, recorder.steps)
+	assert.Equal(t, []int{CodeCancelled}, handler.codes)
+
+	// cancelled right after Run returns, while the run is held up replying
+	ctx, cancel = context.WithCancel(context.Background())
+
+	gated := &gatedResultHandler{testResultHandler: newTestResultHandler(), release: make(chan bool)}
+	err = fa.Run(ctx, "test", nil, gated)
+	assert.Nil(t, err)
+	cancel()
+	close(gated.release)
+	<-gated.done
+
+	assert.Equal(t, 0, recorder.steps)
+}
+
+//TestAdmitRun
+func TestAdmitRun(t *testing.T) {
+
+	small, err := flowdef.NewBuilder().Name("small").Model("budget").
+		Resources(flowdef.ResourceTags{CPU: 0.5, Memory: 128}).
+		AddTask(2, 2, "a", "").
+		Build()
+	assert.Nil(t, err)
+
+	large, err := flowdef.NewBuilder().Name("large").Model("budget").
+		Resources(flowdef.ResourceTags{CPU: 4, Memory: 8192}).
+		AddTask(2, 2, "a", "").
+		Build()
+	assert.Nil(t, err)
+
+	var admitted []string
+
+	admit := func(uri string, res flowdef.ResourceTags) error {
+		if res.CPU > 1 || res.Memory > 1024 {
+			return fmt.Errorf("flow '%s' exceeds the resource budget", uri)
+		}
+		admitted = append(admitted, uri)
+		return nil
+	}
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"small": small, "large": large}}
+	fa := NewFlowAction(provider, &testStateRecorder{}, &ActionOptions{AdmitRun: admit})
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "large", nil, handler)
+	assert.NotNil(t, err)
+	assert.Equal(t, "flow 'large' exceeds the resource budget", err.Error())
+
+	err = fa.Run(nil, "small", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, []string{"small"}, admitted)
+}
+
+// hangingStateRecorder never returns from RecordStep
+type hangingStateRecorder struct {
+	mu       sync.Mutex
+	steps    int
+	instance *Instance
+	entered  chan bool
+	release  chan bool
+}
+
+func (sr *hangingStateRecorder) RecordSnapshot(instance *Instance) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.instance = instance
+}
+
+func (sr *hangingStateRecorder) RecordStep(instance *Instance) {
+	sr.mu.Lock()
+	sr.steps++
+	sr.mu.Unlock()
+	sr.entered <- true
+	<-sr.release
+}
+
+// timeOutRecord times out the call of the recorder hung in RecordStep
+func (sr *hangingStateRecorder) timeOutRecord(t *testing.T, clock *testClock) {
+	<-sr.entered
+	waitFor(t, func() bool { return clock.pending() > 0 })
+	clock.advance(time.Hour)
+}
+
+//TestRecordTimeout
+func TestRecordTimeout(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("built").Model("budget").
+		AddTask(2, 2, "a", "").
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"built": def}}
+
+	// the calls time out on the clock of the action
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	// by default the instance keeps on executing
+	recorder := &hangingStateRecorder{entered: make(chan bool), release: make(chan bool)}
+	defer close(recorder.release)
+
+	fa := NewFlowAction(provider, recorder, &ActionOptions{Record: true, RecordTimeout: time.Hour, Clock: clock})
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "built", nil, handler)
+	assert.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		recorder.timeOutRecord(t, clock)
+	}
+	<-handler.done
+
+	recorder.mu.Lock()
+	assert.Equal(t, 3, recorder.steps)
+	assert.Equal(t, StatusCompleted, recorder.instance.Status())
+	recorder.mu.Unlock()
+
+	// the instance is failed after the first hung step
+	recorder = &hangingStateRecorder{entered: make(chan bool), release: make(chan bool)}
+	defer close(recorder.release)
+
+	fa = NewFlowAction(provider, recorder, &ActionOptions{Record: true, RecordTimeout: time.Hour, Clock: clock, OnRecordError: RecordErrorAbort})
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "built", nil, handler)
+	assert.Nil(t, err)
+
+	recorder.timeOutRecord(t, clock)
+	<-handler.done
+
+	recorder.mu.Lock()
+	assert.Equal(t, 1, recorder.steps)
+	assert.Equal(t, StatusFailed, recorder.instance.Status())
+	recorder.mu.Unlock()
+}
+
+// waitFor waits until the condition is met, failing the test if it isn't
+// met within a few seconds
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before the deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// tickActivity advances the clock of the test using it
+type tickActivity struct {
+	metadata *activity.Metadata
+	clock    *testClock
+}
+
+func (a *tickActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+func (a *tickActivity) Eval(context activity.Context) (done bool, err error) {
+	if a.clock != nil {
+		a.clock.advance(2 * time.Minute)
+	}
+	return true, nil
+}
+
+var tickClock = &tickActivity{metadata: &activity.Metadata{ID: "tick"}}
+
+func init() {
+	registerActivity(tickClock)
+}
+
+//TestMaxSnapshotAge
+func TestMaxSnapshotAge(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("tick").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "tick", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"tick": def}}
+
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tickClock.clock = clock
+	defer func() { tickClock.clock = nil }()
+
+	// without a max age only the status change is recorded
+	recorder := &testStateRecorder{}
+	fa := NewFlowAction(provider, recorder, &ActionOptions{CheckpointStrategy: &StatusChangeCheckpoint{}, Clock: clock})
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "tick", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, []Status{StatusCompleted}, recorder.snapshots)
+
+	// the time spent in 'a' makes the snapshot stale, 'b' is executed after 'a'
+	recorder = &testStateRecorder{}
+	fa = NewFlowAction(provider, recorder, &ActionOptions{CheckpointStrategy: &StatusChangeCheckpoint{}, Clock: clock, MaxSnapshotAge: time.Minute, TaskScheduler: &FIFOTaskScheduler{}})
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "tick", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, []Status{StatusActive, StatusCompleted}, recorder.snapshots)
+}
+
+// gateActivity blocks until released
+type gateActivity struct {
+	metadata *activity.Metadata
+	entered  chan bool
+	release  chan bool
+}
+
+func (a *gateActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+func (a *gateActivity) Eval(context activity.Context) (done bool, err error) {
+	a.entered <- true
+	<-a.release
+	return true, nil
+}
+
+var gate = &gateActivity{metadata: &activity.Metadata{ID: "gate"}, entered: make(chan bool, 1), release: make(chan bool)}
+
+func init() {
+	registerActivity(gate)
+}
+
+// panickingStateRecorder panics when recording a step
+type panickingStateRecorder struct {
+	testStateRecorder
+}
+
+func (sr *panickingStateRecorder) RecordStep(instance *Instance) {
+	panic("disk on fire")
+}
+
+// errorResultHandler also keeps the errors of the results
+type errorResultHandler struct {
+	*testResultHandler
+	codes  []int
+	errors []error
+}
+
+func (rh *errorResultHandler) HandleResult(code int, data interface{}, err error) {
+	rh.testResultHandler.HandleResult(code, data, err)
+	rh.codes = append(rh.codes, code)
+	rh.errors = append(rh.errors, err)
+}
+
+//TestMaxStepCountExceeded
+func TestMaxStepCountExceeded(t *testing.T) {
+
+	recorder := &testStateRecorder{}
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true, MaxStepCount: 1})
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// the IDResponse followed by the abort
+	assert.Equal(t, []int{200, CodeMaxStepCountExceeded}, handler.codes)
+
+	stepErr, ok := handler.errors[1].(*MaxStepCountError)
+	assert.True(t, ok)
+	assert.Equal(t, 1, stepErr.Limit)
+	assert.Equal(t, recorder.instance.ID(), stepErr.InstanceID)
+
+	// the abort is recorded
+	assert.Equal(t, StatusAborted, recorder.instance.Status())
+	assert.Equal(t, StatusAborted, recorder.snapshots[len(recorder.snapshots)-1])
+}
+
+// replyActivity replies "done" to the caller of the flow
+type replyActivity struct {
+	metadata *activity.Metadata
+}
+
+func (a *replyActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+func (a *replyActivity) Eval(context activity.Context) (done bool, err error) {
+	context.FlowDetails().ReplyHandler().Reply(200, "done", nil)
+	return true, nil
+}
+
+func init() {
+	registerActivity(&replyActivity{metadata: &activity.Metadata{ID: "reply"}})
+}
+
+// testReplyHandler keeps the replies, panicking after it did if panics is set
+type testReplyHandler struct {
+	replies []interface{}
+	panics  bool
+}
+
+func (rh *testReplyHandler) Reply(replyCode int, replyData interface{}, err error) {
+	rh.replies = append(rh.replies, replyData)
+
+	if rh.panics {
+		panic("audit log unavailable")
+	}
+}
+
+//TestReplyHandlers
+func TestReplyHandlers(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("replying").Model("budget").ExplicitReply(true).
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "reply", OutputMappings: []*data.MappingDef{}}).
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"replying": def}}
+	fa := NewFlowAction(provider, nil, nil)
+
+	audit := &testReplyHandler{panics: true}
+	metrics := &testReplyHandler{}
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "replying", &RunOptions{ReplyHandlers: []support.ReplyHandler{audit, metrics}}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// the caller gets the reply first, the panic of audit doesn't affect metrics
+	assert.Equal(t, []interface{}{"done"}, handler.results)
+	assert.Equal(t, []interface{}{"done"}, audit.replies)
+	assert.Equal(t, []interface{}{"done"}, metrics.replies)
+}
+
+//TestReplyTimeout
+func TestReplyTimeout(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("slow").Model("budget").ExplicitReply(true).
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTaskRep(&flowdef.TaskRep{ID: 3, TypeID: 2, Name: "b", ActivityType: "reply", OutputMappings: []*data.MappingDef{}}).
+		AddLink(2, 3).
+		Build()
+	assert.Nil(t, err)
+
+	replying, err := flowdef.NewBuilder().Name("replying").Model("budget").ExplicitReply(true).
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "reply", OutputMappings: []*data.MappingDef{}}).
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"slow": def, "replying": replying}}
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fa := NewFlowAction(provider, nil, &ActionOptions{Clock: clock})
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(nil, "slow", &RunOptions{ReplyTimeout: 10 * time.Millisecond}, handler)
+	assert.Nil(t, err)
+
+	<-gate.entered
+	clock.advance(100 * time.Millisecond)
+	gate.release <- true
+	<-handler.done
+
+	// the late reply is skipped
+	assert.Equal(t, []int{CodeReplyTimeout}, handler.codes)
+	assert.IsType(t, &ReplyTimeoutError{}, handler.errors[0])
+
+	// the timeout doesn't fire once the flow replied
+	handler = &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(nil, "replying", &RunOptions{ReplyTimeout: 10 * time.Millisecond}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	clock.advance(30 * time.Millisecond)
+	assert.Equal(t, []int{200}, handler.codes)
+	assert.Equal(t, []interface{}{"done"}, handler.results)
+}
+
+//TestContextDeadline
+func TestContextDeadline(t *testing.T) {
+
+	fa := NewFlowAction(nil, nil, &ActionOptions{ExecutionTimeout: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// the deadline of the context is stricter
+	deadline := fa.deadline(ctx, nil)
+	assert.True(t, deadline > 0 && deadline <= time.Second)
+
+	// the explicit timeout is stricter
+	assert.Equal(t, 10*time.Millisecond, fa.deadline(ctx, &RunOptions{Timeout: 10 * time.Millisecond}))
+
+	// no deadline on the context
+	assert.Equal(t, time.Minute, fa.deadline(context.Background(), nil))
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	clock := &testClock{now: time.Now()}
+	fa = NewFlowAction(provider, nil, &ActionOptions{Clock: clock, ExecutionTimeout: 24 * time.Hour, TaskScheduler: &FIFOTaskScheduler{}})
+
+	// the deadline is reached on the clock of the FlowAction, long before
+	// the context itself is done
+	ctx, cancel = context.WithDeadline(context.Background(), clock.Now().Add(time.Hour))
+	defer cancel()
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(ctx, "gated", nil, handler)
+	assert.Nil(t, err)
+
+	// 'a' takes longer than the deadline of the context
+	<-gate.entered
+	clock.advance(2 * time.Hour)
+	gate.release <- true
+	<-handler.done
+
+	assert.Equal(t, []int{200, CodeDeadlineExceeded}, handler.codes)
+	assert.Equal(t, context.DeadlineExceeded, handler.errors[1])
+}
+
+//TestExecutionTimeout
+func TestExecutionTimeout(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	recorder := &testStateRecorder{}
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fa := NewFlowAction(provider, recorder, &ActionOptions{Clock: clock, CheckpointStrategy: &StatusChangeCheckpoint{}, ExecutionTimeout: 20 * time.Millisecond, TaskScheduler: &FIFOTaskScheduler{}})
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(nil, "gated", nil, handler)
+	assert.Nil(t, err)
+
+	// 'a' takes longer than the timeout
+	<-gate.entered
+	clock.advance(50 * time.Millisecond)
+	gate.release <- true
+	<-handler.done
+
+	assert.Equal(t, []int{200, CodeDeadlineExceeded}, handler.codes)
+	assert.Equal(t, context.DeadlineExceeded, handler.errors[1])
+	assert.Equal(t, []Status{StatusTimedOut}, recorder.snapshots)
+
+	// a run that completes in time isn't affected
+	recorder = &testStateRecorder{}
+	fa = NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true, ExecutionTimeout: time.Minute})
+
+	handler = &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, []int{200}, handler.codes)
+	assert.Equal(t, StatusCompleted, recorder.instance.Status())
+}
@@ -0,0 +1,100 @@
+package flowinst
+
+import (
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+)
+
+// ReplyAggregator combines a reply with the aggregate of the previous replies,
+// the aggregate is nil for the first reply
+type ReplyAggregator func(aggregate interface{}, reply interface{}) (interface{}, error)
+
+// SumReplies is a ReplyAggregator that sums numeric replies
+func SumReplies(aggregate interface{}, reply interface{}) (interface{}, error) {
+
+	value, err := data.CoerceToNumber(reply)
+	if err != nil {
+		return aggregate, err
+	}
+
+	if aggregate == nil {
+		return value, nil
+	}
+
+	return aggregate.(float64) + value, nil
+}
+
+// ConcatReplies is a ReplyAggregator that collects the replies in an array
+func ConcatReplies(aggregate interface{}, reply interface{}) (interface{}, error) {
+
+	if aggregate == nil {
+		return []interface{}{reply}, nil
+	}
+
+	return append(aggregate.([]interface{}), reply), nil
+}
+
+// AggregatingReplyHandler is an action.ResultHandler that combines the
+// intermediate results of a run and passes a single result on to the wrapped
+// handler once the run is done.  It is meant for flows that reply explicitly,
+// otherwise the IDResponse is aggregated as well
+type AggregatingReplyHandler struct {
+	handler   action.ResultHandler
+	aggregate ReplyAggregator
+
+	mu      sync.Mutex
+	code    int
+	result  interface{}
+	err     error
+	replies int
+}
+
+// NewAggregatingReplyHandler creates a new AggregatingReplyHandler that
+// combines the results using the specified aggregator
+func NewAggregatingReplyHandler(handler action.ResultHandler, aggregate ReplyAggregator) *AggregatingReplyHandler {
+	return &AggregatingReplyHandler{handler: handler, aggregate: aggregate}
+}
+
+// HandleResult implements action.ResultHandler.HandleResult, the first error
+// encountered is kept and reported with the final result
+func (rh *AggregatingReplyHandler) HandleResult(code int, data interface{}, err error) {
+
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	rh.replies++
+	rh.code = code
+
+	if err != nil {
+		if rh.err == nil {
+			rh.err = err
+		}
+		return
+	}
+
+	result, aggErr := rh.aggregate(rh.result, data)
+	if aggErr != nil {
+		if rh.err == nil {
+			rh.err = aggErr
+		}
+		return
+	}
+
+	rh.result = result
+}
+
+// Done implements action.ResultHandler.Done
+func (rh *AggregatingReplyHandler) Done() {
+
+	rh.mu.Lock()
+	replies, code, result, err := rh.replies, rh.code, rh.result, rh.err
+	rh.mu.Unlock()
+
+	if replies > 0 {
+		rh.handler.HandleResult(code, result, err)
+	}
+
+	rh.handler.Done()
+}
@@ -0,0 +1,27 @@
+package flowinst
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//TestAggregatingReplyHandler
+func TestAggregatingReplyHandler(t *testing.T) {
+
+	handler := newTestResultHandler()
+	aggregating := NewAggregatingReplyHandler(handler, SumReplies)
+
+	rh := &SimpleReplyHandler{resultHandler: aggregating}
+	rh.Reply(200, 1, nil)
+	rh.Reply(200, "2.5", nil)
+	rh.Reply(200, 3, nil)
+
+	// nothing is passed on until done
+	assert.Empty(t, handler.results)
+
+	aggregating.Done()
+	<-handler.done
+
+	assert.Equal(t, []interface{}{6.5}, handler.results)
+}
@@ -0,0 +1,339 @@
+package flowinst
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// DefaultRecordBufferSize is the size of the buffer of the asynchronous
+// recorder when no RecordBufferSize is specified
+const DefaultRecordBufferSize = 100
+
+// RecordOverflowPolicy determines what happens to a record when the buffer of
+// the asynchronous recorder is full
+type RecordOverflowPolicy int
+
+const (
+	// RecordOverflowBlock pauses the stepping of the instance until the
+	// recorder catches up
+	RecordOverflowBlock RecordOverflowPolicy = iota
+
+	// RecordOverflowDropOldest drops the oldest buffered record to make room
+	// for the new one, an instance is still only done once its remaining
+	// records are written
+	RecordOverflowDropOldest
+
+	// RecordOverflowFail drops the new record and fails the instance
+	RecordOverflowFail
+)
+
+func (p RecordOverflowPolicy) String() string {
+
+	switch p {
+	case RecordOverflowBlock:
+		return "block"
+	case RecordOverflowDropOldest:
+		return "dropOldest"
+	case RecordOverflowFail:
+		return "fail"
+	}
+
+	return "unknown"
+}
+
+// RecordOverflowError is the error of a record that was dropped because the
+// buffer of the asynchronous recorder was full
+type RecordOverflowError struct {
+	InstanceID string
+}
+
+// Error implements error.Error
+func (e *RecordOverflowError) Error() string {
+	return fmt.Sprintf("Unable to record the state of Flow [%s], the record buffer is full", e.InstanceID)
+}
+
+// recordRequest is a write queued for the asynchronous recorder, a request
+// without an instance is a flush barrier
+type recordRequest struct {
+	instance *Instance
+	snapshot bool
+	step     bool
+
+	flushed chan struct{}
+}
+
+// asyncRecorder writes the state of instances on a dedicated goroutine
+type asyncRecorder struct {
+	mu       sync.RWMutex
+	closed   bool
+	requests chan *recordRequest
+	stopped  chan struct{}
+}
+
+// startAsyncRecorder starts the goroutine that drains the recorder buffer
+func (fa *FlowAction) startAsyncRecorder(size int) {
+
+	if size < 1 {
+		size = DefaultRecordBufferSize
+	}
+
+	ar := &asyncRecorder{requests: make(chan *recordRequest, size), stopped: make(chan struct{})}
+	fa.asyncRecorder = ar
+
+	go func() {
+		defer close(ar.stopped)
+
+		for req := range ar.requests {
+			if req.instance == nil {
+				close(req.flushed)
+				continue
+			}
+
+			fa.writeRecord(req)
+		}
+	}()
+}
+
+// stopAsyncRecorder writes the buffered records and stops the asynchronous
+// recorder, later records are written synchronously
+func (fa *FlowAction) stopAsyncRecorder() {
+
+	ar := fa.asyncRecorder
+
+	if ar == nil {
+		return
+	}
+
+	ar.mu.Lock()
+	if !ar.closed {
+		ar.closed = true
+		close(ar.requests)
+	}
+	ar.mu.Unlock()
+
+	<-ar.stopped
+}
+
+// recordState records the snapshot and/or step of the instance, when
+// AsyncRecord is enabled a copy of the instance is queued and the error of the
+// write is reported to OnAsyncRecordError instead of being returned
+func (fa *FlowAction) recordState(instance *Instance, snapshot bool, step bool) error {
+
+	if ar := fa.asyncRecorder; ar != nil {
+
+		ar.mu.RLock()
+		defer ar.mu.RUnlock()
+
+		if !ar.closed {
+			instCopy, err := instance.recordCopy()
+			if err != nil {
+				return fmt.Errorf("Unable to copy the state of Flow [%s] for recording - %s", instance.ID(), err.Error())
+			}
+
+			return fa.queueRecord(ar, &recordRequest{instance: instCopy, snapshot: snapshot, step: step})
+		}
+	}
+
+	return fa.callRecorder(instance, func() {
+		if snapshot {
+			fa.stateRecorder.RecordSnapshot(instance)
+		}
+		if step {
+			fa.stateRecorder.RecordStep(instance)
+		}
+	})
+}
+
+// queueRecord queues the request for the asynchronous recorder, applying the
+// RecordOverflowPolicy when the buffer is full
+func (fa *FlowAction) queueRecord(ar *asyncRecorder, req *recordRequest) error {
+
+	select {
+	case ar.requests <- req:
+		return nil
+	default:
+	}
+
+	policy := fa.actionOptions.RecordOverflowPolicy
+
+	if metrics := fa.actionOptions.MetricsCollector; metrics != nil {
+		metrics.recorderOverflowed(policy)
+	}
+
+	switch policy {
+	case RecordOverflowDropOldest:
+		fa.dropOldestRecord(ar)
+	case RecordOverflowFail:
+		return &RecordOverflowError{InstanceID: req.instance.ID()}
+	}
+
+	// blocks while the buffer is full
+	ar.requests <- req
+	return nil
+}
+
+// dropOldestRecord drops the oldest queued record to make room for a new one.
+// Flush barriers are never dropped, the barriers ahead of the dropped record
+// are queued again so they are still released after the records that were
+// queued before them.  Nothing is dropped if only barriers are queued
+func (fa *FlowAction) dropOldestRecord(ar *asyncRecorder) {
+
+	var barriers []*recordRequest
+
+	defer func() {
+		for _, barrier := range barriers {
+			ar.requests <- barrier
+		}
+	}()
+
+	for i := 0; i < cap(ar.requests); i++ {
+		select {
+		case oldest := <-ar.requests:
+			if oldest.instance == nil {
+				barriers = append(barriers, oldest)
+				continue
+			}
+
+			logger.Warnf("Dropped a record of Flow [%s], the record buffer is full", oldest.instance.ID())
+			return
+		default:
+			return
+		}
+	}
+}
+
+// drainRecords waits until the records queued for the instance are written
+func (fa *FlowAction) drainRecords(instance *Instance) {
+
+	ar := fa.asyncRecorder
+
+	if ar == nil {
+		return
+	}
+
+	flushed := make(chan struct{})
+
+	ar.mu.RLock()
+	if ar.closed {
+		ar.mu.RUnlock()
+		return
+	}
+	ar.requests <- &recordRequest{flushed: flushed}
+	ar.mu.RUnlock()
+
+	<-flushed
+
+	logger.Debugf("Drained the records of Flow [%s]", instance.ID())
+}
+
+// writeRecord writes the queued record, failures are logged and reported to
+// OnAsyncRecordError
+func (fa *FlowAction) writeRecord(req *recordRequest) {
+
+	instance := req.instance
+
+	defer func() {
+		if r := recover(); r != nil {
+			fa.asyncRecordError(instance, fmt.Errorf("Recording the state of Flow [%s] panicked: %v", instance.ID(), r))
+		}
+	}()
+
+	err := fa.callRecorder(instance, func() {
+		if req.snapshot {
+			fa.stateRecorder.RecordSnapshot(instance)
+		}
+		if req.step {
+			fa.stateRecorder.RecordStep(instance)
+		}
+	})
+
+	if err != nil {
+		fa.asyncRecordError(instance, err)
+	}
+}
+
+func (fa *FlowAction) asyncRecordError(instance *Instance, err error) {
+
+	logger.Warn(err.Error())
+
+	if fa.actionOptions.OnAsyncRecordError != nil {
+		fa.actionOptions.OnAsyncRecordError(instance.ID(), err)
+	}
+}
+
+// recordCopy creates a copy of the instance and its changes for the current
+// step that can be recorded while the instance continues to execute
+func (pi *Instance) recordCopy() (*Instance, error) {
+
+	state, err := json.Marshal(pi)
+	if err != nil {
+		return nil, err
+	}
+
+	instCopy := &Instance{}
+
+	if err := json.Unmarshal(state, instCopy); err != nil {
+		return nil, err
+	}
+
+	instCopy.stepID = pi.stepID
+	instCopy.Flow = pi.Flow
+	instCopy.FlowModel = pi.FlowModel
+	instCopy.flowProvider = pi.flowProvider
+	instCopy.sensitiveAttrs = pi.sensitiveAttrs
+	instCopy.recordLinkDecisions = pi.recordLinkDecisions
+
+	if instCopy.RootTaskEnv != nil && instCopy.Flow != nil {
+		instCopy.RootTaskEnv.init(instCopy)
+	}
+
+	if pi.ChangeTracker != nil {
+		instCopy.ChangeTracker = pi.ChangeTracker.copyFor(instCopy.RootTaskEnv)
+	}
+
+	return instCopy, nil
+}
+
+// copyFor copies the tracked changes, the changed TaskDatas and LinkDatas are
+// looked up in the specified TaskEnv, the ones it doesn't contain are shared
+func (ict *InstanceChangeTracker) copyFor(env *TaskEnv) *InstanceChangeTracker {
+
+	chgCopy := NewInstanceChangeTracker()
+
+	*chgCopy.instChange = *ict.instChange
+	chgCopy.instChange.AttrChanges = make([]*AttributeChange, 0, len(ict.instChange.AttrChanges))
+
+	for _, change := range ict.instChange.AttrChanges {
+		attr := *change.Attribute
+		chgCopy.instChange.AttrChanges = append(chgCopy.instChange.AttrChanges, &AttributeChange{ChgType: change.ChgType, Attribute: &attr})
+	}
+
+	for _, change := range ict.wiqChanges {
+		chgCopy.trackWorkItem(change)
+	}
+
+	for _, change := range ict.tdChanges {
+		if env != nil {
+			if taskData, ok := env.TaskDatas[change.ID]; ok {
+				change = &TaskDataChange{ChgType: change.ChgType, ID: change.ID, TaskData: taskData}
+			}
+		}
+		chgCopy.trackTaskData(change)
+	}
+
+	for _, change := range ict.ldChanges {
+		if env != nil {
+			if linkData, ok := env.LinkDatas[change.ID]; ok {
+				change = &LinkDataChange{ChgType: change.ChgType, ID: change.ID, LinkData: linkData}
+			}
+		}
+		chgCopy.trackLinkData(change)
+	}
+
+	chgCopy.miChanges = append(chgCopy.miChanges, ict.miChanges...)
+
+	return chgCopy
+}
@@ -0,0 +1,161 @@
+package flowinst
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestAsyncRecord
+func TestAsyncRecord(t *testing.T) {
+
+	syncRecorder := &testStateRecorder{}
+	fa := NewFlowAction(newTestFlowProvider(t), syncRecorder, &ActionOptions{Record: true})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	recorder := &testStateRecorder{}
+	fa = NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true, AsyncRecord: true, RecordBufferSize: 1})
+	defer fa.Close()
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// all the records are written by the time the handler is done
+	assert.Equal(t, syncRecorder.snapshots, recorder.snapshots)
+	assert.Equal(t, syncRecorder.steps, recorder.steps)
+
+	// a copy of the instance is recorded
+	id := handler.results[0].(*IDResponse).ID
+	assert.Equal(t, id, recorder.instance.ID())
+	assert.NotNil(t, recorder.instance.ChangeTracker)
+}
+
+//TestAsyncRecordError
+func TestAsyncRecordError(t *testing.T) {
+
+	var failures []error
+
+	options := &ActionOptions{Record: true, AsyncRecord: true}
+	options.OnAsyncRecordError = func(instanceID string, err error) {
+		failures = append(failures, err)
+	}
+
+	fa := NewFlowAction(newTestFlowProvider(t), &panickingStateRecorder{}, options)
+	defer fa.Close()
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// the failed writes don't affect the instance
+	assert.Equal(t, []int{200}, handler.codes)
+	assert.NotEmpty(t, failures)
+	assert.Contains(t, failures[0].Error(), "disk on fire")
+}
+
+//TestRecordOverflowPolicy
+func TestRecordOverflowPolicy(t *testing.T) {
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"budget": newTestDefinition(t, budgetDefJSON)}}
+
+	for policy, expected := range map[RecordOverflowPolicy]Status{RecordOverflowDropOldest: StatusCompleted, RecordOverflowFail: StatusFailed} {
+
+		recorder := &blockingStateRecorder{release: make(chan bool)}
+		metrics := NewMetricsCollector()
+
+		options := &ActionOptions{Record: true, AsyncRecord: true, RecordBufferSize: 1, RecordOverflowPolicy: policy, MetricsCollector: metrics}
+		fa := NewFlowAction(provider, recorder, options)
+
+		handler := newTestResultHandler()
+		err := fa.Run(nil, "budget", &RunOptions{ReturnResult: true}, handler)
+		assert.Nil(t, err)
+
+		// the stalled recorder lets the buffer overflow
+		overflowed := "flogo_recorder_overflows_total{policy=\"" + policy.String() + "\"}"
+		waitFor(t, func() bool { return strings.Contains(metrics.MetricsText(), overflowed) })
+		close(recorder.release)
+
+		<-handler.done
+		fa.Close()
+
+		assert.Contains(t, metrics.MetricsText(), overflowed)
+		result := handler.results[len(handler.results)-1].(*FlowResult)
+		assert.Equal(t, expected, Status(result.Status), policy.String())
+	}
+}
+
+// gatedStateRecorder records the IDs of the snapshots, the first write waits
+// for the release
+type gatedStateRecorder struct {
+	entered chan bool
+	release chan bool
+
+	mu  sync.Mutex
+	ids []string
+}
+
+func (sr *gatedStateRecorder) RecordSnapshot(instance *Instance) {
+
+	sr.mu.Lock()
+	first := len(sr.ids) == 0
+	sr.ids = append(sr.ids, instance.ID())
+	sr.mu.Unlock()
+
+	if first {
+		sr.entered <- true
+		<-sr.release
+	}
+}
+
+func (sr *gatedStateRecorder) RecordStep(instance *Instance) {
+}
+
+//TestRecordOverflowKeepsBarriers
+func TestRecordOverflowKeepsBarriers(t *testing.T) {
+
+	recorder := &gatedStateRecorder{entered: make(chan bool, 1), release: make(chan bool)}
+
+	options := &ActionOptions{Record: true, AsyncRecord: true, RecordBufferSize: 2, RecordOverflowPolicy: RecordOverflowDropOldest}
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, options)
+	defer fa.Close()
+
+	ar := fa.asyncRecorder
+
+	// the writer is stuck on the first record
+	assert.Nil(t, fa.queueRecord(ar, &recordRequest{instance: &Instance{id: "first"}, snapshot: true}))
+	<-recorder.entered
+
+	flushed := make(chan struct{})
+	ar.requests <- &recordRequest{flushed: flushed}
+	assert.Nil(t, fa.queueRecord(ar, &recordRequest{instance: &Instance{id: "dropped"}, snapshot: true}))
+
+	// the overflow drops the record behind the barrier, not the barrier
+	assert.Nil(t, fa.queueRecord(ar, &recordRequest{instance: &Instance{id: "last"}, snapshot: true}))
+
+	select {
+	case <-flushed:
+		t.Fatal("the barrier was released before the record ahead of it was written")
+	default:
+	}
+
+	close(recorder.release)
+	<-flushed
+
+	waitFor(t, func() bool {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		return len(recorder.ids) == 2
+	})
+
+	assert.Equal(t, []string{"first", "last"}, recorder.ids)
+}
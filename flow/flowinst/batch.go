@@ -0,0 +1,165 @@
+package flowinst
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// DefaultMaxBatchInFlight is the number of instances of a batch executing at
+// the same time when neither MaxBatchInFlight nor MaxConcurrentInstances are
+// set
+const DefaultMaxBatchInFlight = 64
+
+// BatchError aggregates the failures to start the instances of a batch
+type BatchError struct {
+	URI string
+
+	// Errors are the causes of the failures by index in the batch
+	Errors map[int]error
+}
+
+// Error implements error.Error
+func (e *BatchError) Error() string {
+
+	indexes := make([]int, 0, len(e.Errors))
+	for i := range e.Errors {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	causes := make([]string, len(indexes))
+	for i, index := range indexes {
+		causes[i] = fmt.Sprintf("#%d: %s", index, e.Errors[index].Error())
+	}
+
+	return fmt.Sprintf("Unable to start instances of Flow [%s] - %s", e.URI, strings.Join(causes, ", "))
+}
+
+// batchResultHandler frees the place of an instance of the batch once it is
+// done executing, or couldn't be started
+type batchResultHandler struct {
+	inFlight chan struct{}
+	once     sync.Once
+}
+
+// HandleResult implements action.ResultHandler.HandleResult
+func (rh *batchResultHandler) HandleResult(code int, data interface{}, err error) {
+}
+
+// Done implements action.ResultHandler.Done
+func (rh *batchResultHandler) Done() {
+	rh.release()
+}
+
+// release frees the place of the instance, only once whether the run failed
+// to start, was done or both
+func (rh *batchResultHandler) release() {
+	rh.once.Do(func() {
+		<-rh.inFlight
+	})
+}
+
+// RunBatch starts an instance of the flow for each set of inputs, the inputs
+// are the trigger attributes of the instance.  The IDs of the instances are
+// returned in the order of the inputs, the ID of an instance that couldn't be
+// started is empty and its error is collected in a BatchError.
+//
+// The instances are started as executing ones complete, so that at most
+// MaxBatchInFlight instances of the batch execute at the same time, each
+// start goes through the MaxConcurrentInstances slots and StartRateLimiter
+// like a Run.  The inputs not started when the context is done fail with the
+// error of the context, the started instances are cancelled with it.  The
+// results of the instances are discarded.  The IdempotencyKey of the
+// RunOptions is ignored, each set of inputs starts its own instance
+func (fa *FlowAction) RunBatch(ctx context.Context, uri string, inputsList [][]*data.Attribute, ro *RunOptions) ([]string, error) {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	maxInFlight := fa.actionOptions.MaxBatchInFlight
+	if maxInFlight < 1 {
+		maxInFlight = fa.actionOptions.MaxConcurrentInstances
+	}
+	if maxInFlight < 1 {
+		maxInFlight = DefaultMaxBatchInFlight
+	}
+
+	inFlight := make(chan struct{}, maxInFlight)
+
+	ids := make([]string, len(inputsList))
+	batchErr := &BatchError{URI: uri, Errors: make(map[int]error)}
+
+	for i, inputs := range inputsList {
+
+		select {
+		case inFlight <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			for j := i; j < len(inputsList); j++ {
+				batchErr.Errors[j] = ctx.Err()
+			}
+			break
+		}
+
+		var runOptions RunOptions
+		if ro != nil {
+			runOptions = *ro
+		}
+		runOptions.Op = AoStart
+
+		// a shared key would coalesce the items of the batch into one run
+		runOptions.IdempotencyKey = ""
+
+		handler := &batchResultHandler{inFlight: inFlight}
+
+		id, err := fa.newInstanceID(&runOptions)
+
+		if err == nil {
+			runOptions.instanceID = id
+			err = fa.Run(trigger.NewContext(ctx, inputs), uri, &runOptions, handler)
+		}
+
+		if err != nil {
+			handler.release()
+			batchErr.Errors[i] = err
+			continue
+		}
+
+		ids[i] = id
+	}
+
+	if len(batchErr.Errors) > 0 {
+		logger.Warn(batchErr.Error())
+		return ids, batchErr
+	}
+
+	return ids, nil
+}
+
+// StartBatch starts an instance of the specified flow for each of the input
+// attribute sets like RunBatch, returns the IDs of the instances and the
+// errors for the items that couldn't be started
+func (fa *FlowAction) StartBatch(ctx context.Context, uri string, inputs [][]*data.Attribute) ([]string, []error) {
+
+	errs := make([]error, len(inputs))
+
+	ids, err := fa.RunBatch(ctx, uri, inputs, nil)
+
+	if batchErr, ok := err.(*BatchError); ok {
+		for i, cause := range batchErr.Errors {
+			errs[i] = cause
+		}
+	}
+
+	return ids, errs
+}
@@ -0,0 +1,125 @@
+package flowinst
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestStartBatch
+func TestStartBatch(t *testing.T) {
+
+	fa := NewFlowAction(newTestFlowProvider(t), nil, nil)
+
+	inputs := [][]*data.Attribute{
+		{data.NewAttribute("id", data.INTEGER, 1)},
+		{data.NewAttribute("id", data.INTEGER, 2)},
+		{data.NewAttribute("id", data.INTEGER, 3)},
+	}
+
+	ids, errs := fa.StartBatch(nil, "test", inputs)
+
+	assert.Equal(t, 3, len(ids))
+	assert.Equal(t, []error{nil, nil, nil}, errs)
+
+	distinct := map[string]bool{ids[0]: true, ids[1]: true, ids[2]: true}
+	assert.Equal(t, 3, len(distinct))
+
+	ids, errs = fa.StartBatch(nil, "unknown", inputs)
+	assert.NotNil(t, errs[0])
+	assert.Equal(t, "", ids[0])
+
+	// the items are admitted like a Run
+	admitErr := errors.New("not admitted")
+	fa = NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{AdmitRun: func(uri string, res flowdef.ResourceTags) error {
+		return admitErr
+	}})
+
+	ids, errs = fa.StartBatch(nil, "test", inputs)
+	assert.Equal(t, []error{admitErr, admitErr, admitErr}, errs)
+	assert.Equal(t, []string{"", "", ""}, ids)
+}
+
+//TestRunBatch
+func TestRunBatch(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddInput("orderId", data.STRING, nil).
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	fa := NewFlowAction(provider, nil, &ActionOptions{MaxBatchInFlight: 1, ValidateInputs: true})
+
+	inputsList := [][]*data.Attribute{
+		{data.NewAttribute("orderId", data.STRING, "order-1")},
+		{},
+		{data.NewAttribute("orderId", data.STRING, "order-3")},
+	}
+
+	type batchResult struct {
+		ids []string
+		err error
+	}
+
+	done := make(chan batchResult)
+
+	go func() {
+		ids, err := fa.RunBatch(nil, "gated", inputsList, nil)
+		done <- batchResult{ids, err}
+	}()
+
+	// the instances of the batch execute one at a time
+	<-gate.entered
+	assert.Len(t, fa.ActiveInstances(), 1)
+	first := fa.ActiveInstances()[0]
+	gate.release <- true
+
+	<-gate.entered
+	assert.Len(t, fa.ActiveInstances(), 1)
+	last := fa.ActiveInstances()[0]
+	gate.release <- true
+
+	result := <-done
+	assert.Equal(t, []string{first, "", last}, result.ids)
+
+	batchErr, ok := result.err.(*BatchError)
+	assert.True(t, ok)
+	assert.Len(t, batchErr.Errors, 1)
+	assert.True(t, errors.Is(batchErr.Errors[1], ErrInvalidInputs))
+	assert.Equal(t, "Unable to start instances of Flow [gated] - #1: Flow [gated] has invalid inputs: missing required input 'orderId'", batchErr.Error())
+}
+
+//TestRunBatchIdempotencyKey
+func TestRunBatchIdempotencyKey(t *testing.T) {
+
+	recorder := &parentRecorder{parents: make(map[string]string)}
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true, MaxBatchInFlight: 1})
+
+	inputs := [][]*data.Attribute{
+		{data.NewAttribute("id", data.INTEGER, 1)},
+		{data.NewAttribute("id", data.INTEGER, 2)},
+		{data.NewAttribute("id", data.INTEGER, 3)},
+	}
+
+	// each item starts its own instance, whatever the key
+	ids, err := fa.RunBatch(nil, "test", inputs, &RunOptions{IdempotencyKey: "batch"})
+	assert.Nil(t, err)
+
+	waitFor(t, func() bool {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		return len(recorder.parents) == 3
+	})
+
+	for _, id := range ids {
+		recorder.mu.Lock()
+		_, recorded := recorder.parents[id]
+		recorder.mu.Unlock()
+		assert.True(t, recorded)
+	}
+}
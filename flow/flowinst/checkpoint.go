@@ -0,0 +1,54 @@
+package flowinst
+
+// CheckpointStrategy is used to determine if the state of an instance
+// should be recorded after a step
+type CheckpointStrategy interface {
+
+	// ShouldCheckpoint indicates if the instance should be recorded for the
+	// specified step
+	ShouldCheckpoint(instance *Instance, step int, statusChanged bool) bool
+}
+
+// EveryStepCheckpoint is a CheckpointStrategy that records every step
+type EveryStepCheckpoint struct {
+}
+
+// ShouldCheckpoint implements CheckpointStrategy.ShouldCheckpoint
+func (cs *EveryStepCheckpoint) ShouldCheckpoint(instance *Instance, step int, statusChanged bool) bool {
+	return true
+}
+
+// EveryNStepsCheckpoint is a CheckpointStrategy that records every N steps
+type EveryNStepsCheckpoint struct {
+	N int
+}
+
+// ShouldCheckpoint implements CheckpointStrategy.ShouldCheckpoint
+func (cs *EveryNStepsCheckpoint) ShouldCheckpoint(instance *Instance, step int, statusChanged bool) bool {
+
+	if cs.N < 2 {
+		return true
+	}
+
+	return step%cs.N == 0
+}
+
+// StatusChangeCheckpoint is a CheckpointStrategy that only records a step
+// if the status of the instance changed
+type StatusChangeCheckpoint struct {
+}
+
+// ShouldCheckpoint implements CheckpointStrategy.ShouldCheckpoint
+func (cs *StatusChangeCheckpoint) ShouldCheckpoint(instance *Instance, step int, statusChanged bool) bool {
+	return statusChanged
+}
+
+// IdleCheckpoint is a CheckpointStrategy that records a step when the
+// instance has no more work queued
+type IdleCheckpoint struct {
+}
+
+// ShouldCheckpoint implements CheckpointStrategy.ShouldCheckpoint
+func (cs *IdleCheckpoint) ShouldCheckpoint(instance *Instance, step int, statusChanged bool) bool {
+	return instance.WorkItemQueue.IsEmpty()
+}
@@ -0,0 +1,25 @@
+package flowinst
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//TestStatusChangeCheckpoint
+func TestStatusChangeCheckpoint(t *testing.T) {
+
+	recorder := &testStateRecorder{}
+	options := &ActionOptions{Record: true, CheckpointStrategy: &StatusChangeCheckpoint{}}
+
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, options)
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// only the transition to completed is recorded
+	assert.Equal(t, []Status{StatusCompleted}, recorder.snapshots)
+	assert.Equal(t, 1, recorder.steps)
+}
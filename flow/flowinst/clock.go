@@ -0,0 +1,29 @@
+package flowinst
+
+import (
+	"context"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/util"
+)
+
+// Clock is the source of the current time used by a FlowAction, it can be
+// replaced to control the passing of time in tests
+type Clock = util.Clock
+
+// TimerClock is implemented by Clocks that also schedule the timeouts of the
+// instances, ie. their deadline, StepTimeout and ReplyTimeout.  The system
+// timers are used if the Clock doesn't implement it
+type TimerClock = util.TimerClock
+
+// withTimeout is context.WithTimeout, the timeout elapses on the clock
+func withTimeout(clock Clock, parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+
+	if _, ok := clock.(TimerClock); !ok {
+		return context.WithTimeout(parent, timeout)
+	}
+
+	deadline := newPausableDeadline(clock, parent, timeout)
+
+	return deadline, deadline.stop
+}
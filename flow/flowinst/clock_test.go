@@ -0,0 +1,107 @@
+package flowinst
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// testClock is a TimerClock that only moves when advanced
+type testClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*testTimer
+}
+
+// testTimer is a call scheduled on a testClock
+type testTimer struct {
+	at time.Time
+	f  func()
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timer := &testTimer{at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for i, pending := range c.timers {
+			if pending == timer {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// pending returns the number of timers that didn't expire
+func (c *testClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// advance moves the clock, the timers that expire are called before it
+// returns
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+
+	var expired, pending []*testTimer
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+		} else {
+			expired = append(expired, timer)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	for _, timer := range expired {
+		timer.f()
+	}
+}
+
+//TestWithTimeout
+func TestWithTimeout(t *testing.T) {
+
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	ctx, cancel := withTimeout(clock, context.Background(), time.Second)
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, clock.Now().Add(time.Second), deadline)
+
+	// the timeout elapses on the clock
+	clock.advance(time.Second - time.Millisecond)
+	assert.Nil(t, ctx.Err())
+
+	clock.advance(time.Millisecond)
+	<-ctx.Done()
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+
+	// the system timers are used for other clocks
+	ctx, cancel = withTimeout(util.SystemClock, context.Background(), time.Hour)
+	cancel()
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
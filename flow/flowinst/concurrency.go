@@ -0,0 +1,196 @@
+package flowinst
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+)
+
+// ConcurrencyPolicy determines what happens to a run when the
+// MaxConcurrentInstances are already executing
+type ConcurrencyPolicy int
+
+const (
+	// ConcurrencyBlock blocks the run until an instance finishes executing,
+	// the blocked runs are admitted by priority, see RunOptions.Priority
+	ConcurrencyBlock ConcurrencyPolicy = iota
+
+	// ConcurrencyReject rejects the run with ErrTooManyInstances
+	ConcurrencyReject
+)
+
+// ErrTooManyInstances is the error a run is rejected with when the
+// MaxConcurrentInstances are already executing
+var ErrTooManyInstances = errors.New("too many flow instances executing")
+
+// slotWaiter is a run blocked until a slot is released
+type slotWaiter struct {
+	priority int
+	seq      uint64
+
+	// granted is closed when the slot is handed to the waiter
+	granted chan struct{}
+
+	// index in the queue, -1 once the waiter left the queue
+	index int
+}
+
+// waiterQueue orders the waiters by descending priority, then by arrival
+type waiterQueue []*slotWaiter
+
+func (q waiterQueue) Len() int { return len(q) }
+
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waiterQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waiterQueue) Push(x interface{}) {
+	waiter := x.(*slotWaiter)
+	waiter.index = len(*q)
+	*q = append(*q, waiter)
+}
+
+func (q *waiterQueue) Pop() interface{} {
+	old := *q
+	waiter := old[len(old)-1]
+	old[len(old)-1] = nil
+	waiter.index = -1
+	*q = old[:len(old)-1]
+	return waiter
+}
+
+// slots are the MaxConcurrentInstances execution slots, a released slot is
+// handed to the waiting run with the highest priority, the runs with the same
+// priority are admitted in FIFO order
+type slots struct {
+	mu      sync.Mutex
+	free    int
+	seq     uint64
+	waiters waiterQueue
+
+	// closed is closed when the waiters are cancelled
+	closed     chan struct{}
+	closedOnce sync.Once
+}
+
+func newSlots(n int) *slots {
+	return &slots{free: n, closed: make(chan struct{})}
+}
+
+// tryAcquire takes a free slot, if no run is waiting for one
+func (s *slots) tryAcquire() bool {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.free > 0 && len(s.waiters) == 0 {
+		s.free--
+		return true
+	}
+
+	return false
+}
+
+// acquire takes a slot, waiting for one to be released if necessary
+func (s *slots) acquire(ctx context.Context, priority int) error {
+
+	s.mu.Lock()
+
+	if s.free > 0 && len(s.waiters) == 0 {
+		s.free--
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.seq++
+	waiter := &slotWaiter{priority: priority, seq: s.seq, granted: make(chan struct{})}
+	heap.Push(&s.waiters, waiter)
+
+	s.mu.Unlock()
+
+	var err error
+
+	select {
+	case <-waiter.granted:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-s.closed:
+		err = ErrShuttingDown
+	}
+
+	s.mu.Lock()
+
+	if waiter.index >= 0 {
+		heap.Remove(&s.waiters, waiter.index)
+		s.mu.Unlock()
+		return err
+	}
+
+	s.mu.Unlock()
+
+	// the slot was handed over as the wait was aborted
+	s.release()
+
+	return err
+}
+
+// cancelWaiters fails the runs waiting for a slot, and the ones that will
+// wait for one, with ErrShuttingDown, returns the number of runs that were
+// waiting
+func (s *slots) cancelWaiters() int {
+
+	s.mu.Lock()
+	waiting := len(s.waiters)
+	s.mu.Unlock()
+
+	s.closedOnce.Do(func() { close(s.closed) })
+
+	return waiting
+}
+
+// release hands the slot to the next waiting run, or frees it
+func (s *slots) release() {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.waiters) > 0 {
+		waiter := heap.Pop(&s.waiters).(*slotWaiter)
+		close(waiter.granted)
+		return
+	}
+
+	s.free++
+}
+
+// acquireSlot takes one of the MaxConcurrentInstances slots, the returned
+// function releases it and can safely be called more than once
+func (fa *FlowAction) acquireSlot(ctx context.Context, priority int) (release func(), err error) {
+
+	if fa.slots == nil {
+		return func() {}, nil
+	}
+
+	if fa.actionOptions.ConcurrencyPolicy == ConcurrencyReject {
+		if !fa.slots.tryAcquire() {
+			return nil, ErrTooManyInstances
+		}
+	} else if err := fa.slots.acquire(ctx, priority); err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+
+	return func() { once.Do(fa.slots.release) }, nil
+}
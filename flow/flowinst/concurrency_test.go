@@ -0,0 +1,123 @@
+package flowinst
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestMaxConcurrentInstances
+func TestMaxConcurrentInstances(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	fa := NewFlowAction(provider, nil, &ActionOptions{MaxConcurrentInstances: 1, ConcurrencyPolicy: ConcurrencyReject})
+
+	first := newTestResultHandler()
+	err = fa.Run(nil, "gated", nil, first)
+	assert.Nil(t, err)
+	<-gate.entered
+
+	// the only slot is taken
+	err = fa.Run(nil, "gated", nil, newTestResultHandler())
+	assert.Equal(t, ErrTooManyInstances, err)
+
+	gate.release <- true
+	<-first.done
+
+	// the slot is free again
+	second := newTestResultHandler()
+	err = fa.Run(nil, "gated", nil, second)
+	assert.Nil(t, err)
+	<-gate.entered
+	gate.release <- true
+	<-second.done
+
+	// blocked runs wait for a slot, or until their context is done
+	fa = NewFlowAction(provider, nil, &ActionOptions{MaxConcurrentInstances: 1})
+
+	first = newTestResultHandler()
+	err = fa.Run(nil, "gated", nil, first)
+	assert.Nil(t, err)
+	<-gate.entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = fa.Run(ctx, "gated", nil, newTestResultHandler())
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	blocked := make(chan error)
+	second = newTestResultHandler()
+
+	go func() {
+		blocked <- fa.Run(nil, "gated", nil, second)
+	}()
+
+	gate.release <- true
+	<-first.done
+
+	assert.Nil(t, <-blocked)
+	<-gate.entered
+	gate.release <- true
+	<-second.done
+}
+
+//TestPriorityAdmission
+func TestPriorityAdmission(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	fa := NewFlowAction(provider, nil, &ActionOptions{MaxConcurrentInstances: 1})
+
+	first := newTestResultHandler()
+	err = fa.Run(nil, "gated", nil, first)
+	assert.Nil(t, err)
+	<-gate.entered
+
+	admitted := make(chan string, 3)
+	handlers := make(map[string]*testResultHandler)
+
+	// the runs block in order, the default priority is 0
+	for i, name := range []string{"low", "high", "default"} {
+		priority := map[string]int{"low": -1, "high": 5}[name]
+		handler := newTestResultHandler()
+		handlers[name] = handler
+
+		go func(name string, priority int) {
+			fa.Run(nil, "gated", &RunOptions{Priority: priority}, handler)
+			admitted <- name
+		}(name, priority)
+
+		waitFor(t, func() bool { return waiting(fa) == i+1 })
+	}
+
+	gate.release <- true
+	<-first.done
+
+	for _, name := range []string{"high", "default", "low"} {
+		assert.Equal(t, name, <-admitted)
+		<-gate.entered
+		gate.release <- true
+		<-handlers[name].done
+	}
+}
+
+// waiting returns the number of runs waiting for a slot
+func waiting(fa *FlowAction) int {
+	fa.slots.mu.Lock()
+	defer fa.slots.mu.Unlock()
+	return len(fa.slots.waiters)
+}
@@ -0,0 +1,54 @@
+package flowinst
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// contextAttrPrefix is the prefix of the attributes lifted from the context
+// of the run, they are read-only
+const contextAttrPrefix = "{C."
+
+// ContextAttrName returns the name of the attribute the value of the context
+// key registered with the specified name is lifted to, see
+// ActionOptions.ContextKeys
+func ContextAttrName(name string) string {
+	return contextAttrPrefix + name + "}"
+}
+
+func isContextAttr(attrName string) bool {
+	return strings.HasPrefix(attrName, contextAttrPrefix)
+}
+
+// liftContextValues adds the values of the ContextKeys found in the context to
+// the attributes of the instance
+func (fa *FlowAction) liftContextValues(ctx context.Context, instance *Instance) {
+
+	keys := fa.actionOptions.ContextKeys
+
+	if len(keys) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+
+		value := ctx.Value(keys[name])
+
+		if value == nil {
+			continue
+		}
+
+		logger.Debugf("Flow [%s] lifted context value '%s'", instance.ID(), name)
+		instance.AddAttr(ContextAttrName(name), data.ANY, value)
+	}
+}
@@ -0,0 +1,46 @@
+package flowinst
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantKey struct{}
+
+//TestContextKeys
+func TestContextKeys(t *testing.T) {
+
+	fa := NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{ContextKeys: map[string]interface{}{"tenant": tenantKey{}, "requestId": "requestId"}})
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+
+	code, result, err := fa.RunSync(ctx, "test", &RunOptions{ReturnResult: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 200, code)
+
+	attrs := result.(*FlowResult).Attrs
+	assert.Equal(t, "acme", attrs[ContextAttrName("tenant")].Value)
+	assert.NotContains(t, attrs, ContextAttrName("requestId"))
+
+	// the lifted values are read-only and survive a snapshot
+	instance := NewFlowInstance("1", "test", newTestFlowProvider(t).flows["test"])
+	instance.AddAttr(ContextAttrName("tenant"), data.ANY, "acme")
+
+	err = instance.SetAttrValue(ContextAttrName("tenant"), "other")
+	assert.EqualError(t, err, "Attr [{C.tenant}] is read-only")
+
+	state, err := json.Marshal(instance)
+	assert.Nil(t, err)
+
+	restored := &Instance{}
+	err = json.Unmarshal(state, restored)
+	assert.Nil(t, err)
+
+	attr, exists := restored.Attrs[ContextAttrName("tenant")]
+	assert.True(t, exists)
+	assert.Equal(t, "acme", attr.Value)
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/TIBCOSoftware/flogo-lib/logger"
 )
 
-func applyInputMapper(pi *Instance, taskData *TaskData) {
+func applyInputMapper(pi *Instance, taskData *TaskData) error {
 
 	// get the input mapper
 	inputMapper := taskData.task.InputMapper()
@@ -24,8 +24,69 @@ func applyInputMapper(pi *Instance, taskData *TaskData) {
 
 	if inputMapper != nil {
 		logger.Debug("Applying InputMapper")
-		inputMapper.Apply(pi, taskData.InputScope())
+
+		if !pi.recordMappedInputs {
+			return applyMapper(inputMapper, pi, taskData.InputScope())
+		}
+
+		scope := &recordingScope{Scope: taskData.InputScope()}
+		err := applyMapper(inputMapper, pi, scope)
+
+		if pi.ChangeTracker != nil {
+			pi.ChangeTracker.trackMappedInputs(taskData.task.ID(), scope.setAttrs())
+		}
+
+		return err
 	}
+
+	return nil
+}
+
+// applyMapper applies the mapper, the mappings that couldn't be applied are
+// reported if the mapper is a data.CheckedMapper
+func applyMapper(mapper data.Mapper, inputScope data.Scope, outputScope data.Scope) error {
+
+	if checked, ok := mapper.(data.CheckedMapper); ok {
+		return checked.ApplyChecked(inputScope, outputScope)
+	}
+
+	mapper.Apply(inputScope, outputScope)
+
+	return nil
+}
+
+// recordingScope is a data.Scope that keeps track of the attributes that are
+// set on the wrapped scope
+type recordingScope struct {
+	data.Scope
+	names []string
+}
+
+// SetAttrValue implements data.Scope.SetAttrValue
+func (s *recordingScope) SetAttrValue(name string, value interface{}) error {
+	s.names = append(s.names, name)
+	return s.Scope.SetAttrValue(name, value)
+}
+
+// setAttrs returns a copy of the attributes that were set
+func (s *recordingScope) setAttrs() []*data.Attribute {
+
+	attrs := make([]*data.Attribute, 0, len(s.names))
+	seen := make(map[string]bool, len(s.names))
+
+	for _, name := range s.names {
+
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if attr, ok := s.Scope.GetAttr(name); ok {
+			attrs = append(attrs, data.NewAttribute(attr.Name, attr.Type, attr.Value))
+		}
+	}
+
+	return attrs
 }
 
 func applyInputInterceptor(pi *Instance, taskData *TaskData) bool {
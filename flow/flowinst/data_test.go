@@ -0,0 +1,106 @@
+package flowinst
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/flow/activity"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+// mappedInputsRecorder keeps the mapped inputs of the recorded steps
+type mappedInputsRecorder struct {
+	mappedInputs []*MappedInputsChange
+}
+
+func (sr *mappedInputsRecorder) RecordSnapshot(instance *Instance) {
+}
+
+func (sr *mappedInputsRecorder) RecordStep(instance *Instance) {
+	sr.mappedInputs = append(sr.mappedInputs, instance.ChangeTracker.MappedInputs()...)
+}
+
+//TestRecordMappedInputs
+func TestRecordMappedInputs(t *testing.T) {
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"mapped": newTestDefinition(t, mappedDefJSON)}}
+	recorder := &mappedInputsRecorder{}
+
+	fa := NewFlowAction(provider, recorder, &ActionOptions{Record: true, RecordMappedInputs: true})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "mapped", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// only the mapped input is captured, not the other activity inputs
+	assert.Equal(t, 1, len(recorder.mappedInputs))
+	assert.Equal(t, 2, recorder.mappedInputs[0].TaskID)
+	assert.Equal(t, []*data.Attribute{data.NewAttribute("message", data.STRING, "hello")}, recorder.mappedInputs[0].Inputs)
+}
+
+const priceDefJSON = `
+{
+    "type": 1,
+    "name": "price",
+    "model": "test",
+    "rootTask": {
+      "id": 1,
+      "type": 1,
+      "name": "root",
+      "tasks": [
+        {
+          "id": 2,
+          "type": 1,
+          "activityType": "price",
+          "name": "price",
+          "inputMappings": [
+            { "type": 1, "value": "{T.order}.items[2].price", "mapTo": "price" }
+          ],
+          "ouputMappings": []
+        }
+      ]
+    }
+  }
+`
+
+//TestInputMappingCoercionError
+func TestInputMappingCoercionError(t *testing.T) {
+
+	md := &activity.Metadata{ID: "price", Inputs: map[string]*data.Attribute{
+		"price": data.NewAttribute("price", data.NUMBER, nil),
+	}}
+	registerActivity(&workUnitsActivity{metadata: md})
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"price": newTestDefinition(t, priceDefJSON)}}
+	sink := NewInMemoryDeadLetterSink()
+	fa := NewFlowAction(provider, nil, &ActionOptions{DeadLetterSink: sink})
+
+	order := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"price": 1.5},
+			map[string]interface{}{"price": "2"},
+			map[string]interface{}{"price": "abc"},
+		},
+	}
+	ctx := trigger.NewContext(context.Background(), []*data.Attribute{data.NewAttribute("order", data.OBJECT, order)})
+
+	handler := newTestResultHandler()
+	err := fa.Run(ctx, "price", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// the task fails with the path of the field that couldn't be coerced
+	letters := sink.Letters()
+	assert.Len(t, letters, 1)
+
+	var coercionErr *data.CoercionError
+	assert.True(t, errors.As(letters[0].Err, &coercionErr))
+	assert.Equal(t, "{T.order}.items[2].price", coercionErr.Path)
+	assert.Equal(t, data.NUMBER, coercionErr.Type)
+	assert.Contains(t, letters[0].Err.Error(), "Unable to coerce field '{T.order}.items[2].price' to number")
+}
@@ -0,0 +1,139 @@
+package flowinst
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// Reasons of the runs sent to the DeadLetterSink
+const (
+	DeadLetterFailed            = "failed"
+	DeadLetterTimedOut          = "timedOut"
+	DeadLetterStepCountExceeded = "stepCountExceeded"
+)
+
+// DeadLetter describes a run that failed permanently
+type DeadLetter struct {
+	InstanceID string
+	FlowURI    string
+	Reason     string
+	Inputs     []*data.Attribute
+	Outputs    map[string]interface{}
+	Err        error
+
+	// Snapshot is the serialized instance, it can be unmarshalled into an
+	// Instance and used as the InitialState of a resume
+	Snapshot []byte
+}
+
+// DeadLetterSink receives the runs that failed permanently, once the retries
+// of their tasks were exhausted, that timed out or that exceeded the max step
+// count, for later inspection or reprocessing
+type DeadLetterSink interface {
+
+	// Send hands the failed run to the sink
+	Send(letter *DeadLetter)
+}
+
+// InMemoryDeadLetterSink is a DeadLetterSink that keeps the failed runs in memory
+type InMemoryDeadLetterSink struct {
+	mu       sync.Mutex
+	letters  []*DeadLetter
+	received []time.Time
+
+	storeClock
+}
+
+// NewInMemoryDeadLetterSink creates a new InMemoryDeadLetterSink
+func NewInMemoryDeadLetterSink() *InMemoryDeadLetterSink {
+	return &InMemoryDeadLetterSink{}
+}
+
+// Send implements DeadLetterSink.Send
+func (s *InMemoryDeadLetterSink) Send(letter *DeadLetter) {
+	received := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.letters = append(s.letters, letter)
+	s.received = append(s.received, received)
+}
+
+// EvictExpired implements Expirable.EvictExpired, the letters received before
+// the cutoff are evicted
+func (s *InMemoryDeadLetterSink) EvictExpired(cutoff time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the letters are in the order they were received
+	expired := 0
+	for expired < len(s.letters) && !s.received[expired].After(cutoff) {
+		expired++
+	}
+
+	s.letters = append([]*DeadLetter(nil), s.letters[expired:]...)
+	s.received = append([]time.Time(nil), s.received[expired:]...)
+
+	return expired
+}
+
+// Letters returns the failed runs received by the sink
+func (s *InMemoryDeadLetterSink) Letters() []*DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters := make([]*DeadLetter, len(s.letters))
+	copy(letters, s.letters)
+
+	return letters
+}
+
+// deadLetterReason returns the reason to send the instance to the
+// DeadLetterSink, it is empty if the instance didn't fail permanently
+func deadLetterReason(status Status) string {
+
+	switch status {
+	case StatusFailed:
+		return DeadLetterFailed
+	case StatusTimedOut:
+		return DeadLetterTimedOut
+	case StatusAborted:
+		return DeadLetterStepCountExceeded
+	}
+
+	return ""
+}
+
+// newDeadLetter creates the DeadLetter for the failed instance
+func newDeadLetter(instance *Instance, reason string, inputs []*data.Attribute, err error) *DeadLetter {
+
+	outputs := make(map[string]interface{}, len(instance.Attrs))
+
+	for name, attr := range instance.Attrs {
+		outputs[name] = attr.Value
+	}
+
+	if instErr := instance.LastError(); instErr != nil {
+		err = instErr
+	}
+
+	snapshot, snapshotErr := json.Marshal(instance)
+	if snapshotErr != nil {
+		logger.Warnf("Unable to snapshot Flow [%s] for the dead-letter sink - %s", instance.ID(), snapshotErr.Error())
+	}
+
+	return &DeadLetter{
+		InstanceID: instance.ID(),
+		FlowURI:    instance.FlowURI,
+		Reason:     reason,
+		Inputs:     inputs,
+		Outputs:    outputs,
+		Err:        err,
+		Snapshot:   snapshot,
+	}
+}
@@ -0,0 +1,95 @@
+package flowinst
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/TIBCOSoftware/flogo-lib/flow/model"
+	"github.com/TIBCOSoftware/flogo-lib/flow/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// alwaysFailingTaskBehavior fails every evaluation of a task
+type alwaysFailingTaskBehavior struct {
+	*test.SimpleTaskBehavior
+}
+
+func (b *alwaysFailingTaskBehavior) Eval(context model.TaskContext, evalCode int) (done bool, doneCode int, err error) {
+	return false, 0, errTooManyRequests
+}
+
+//TestDeadLetterSink
+func TestDeadLetterSink(t *testing.T) {
+
+	m := model.New("deadletter")
+	m.RegisterFlowBehavior(&test.SimpleFlowBehavior{})
+	m.RegisterTaskBehavior(1, &test.SimpleTaskBehavior{})
+	m.RegisterTaskBehavior(2, &alwaysFailingTaskBehavior{SimpleTaskBehavior: &test.SimpleTaskBehavior{}})
+	registerModel(m)
+
+	def, err := flowdef.NewBuilder().Name("failing").Model("deadletter").AddTask(2, 2, "a", "").Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"failing": def}}
+	sink := NewInMemoryDeadLetterSink()
+
+	classifier := func(err error) bool {
+		return err == errTooManyRequests
+	}
+
+	fa := NewFlowAction(provider, nil, &ActionOptions{DeadLetterSink: sink, ErrorClassifier: classifier, MaxTaskRetries: 2})
+
+	inputs := []*data.Attribute{data.NewAttribute("orderId", data.STRING, "order-1")}
+	ctx := trigger.NewContext(context.Background(), inputs)
+
+	handler := newTestResultHandler()
+	err = fa.Run(ctx, "failing", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	letters := sink.Letters()
+	assert.Len(t, letters, 1)
+
+	letter := letters[0]
+	assert.Equal(t, handler.results[0].(*IDResponse).ID, letter.InstanceID)
+	assert.Equal(t, "failing", letter.FlowURI)
+	assert.Equal(t, inputs, letter.Inputs)
+	assert.Equal(t, "order-1", letter.Outputs["{T.orderId}"])
+	assert.Equal(t, errTooManyRequests, letter.Err)
+	assert.Equal(t, DeadLetterFailed, letter.Reason)
+}
+
+//TestDeadLetterStepCountExceeded
+func TestDeadLetterStepCountExceeded(t *testing.T) {
+
+	sink := NewInMemoryDeadLetterSink()
+	fa := NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{DeadLetterSink: sink, MaxStepCount: 1})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	letters := sink.Letters()
+	assert.Len(t, letters, 1)
+
+	letter := letters[0]
+	assert.Equal(t, DeadLetterStepCountExceeded, letter.Reason)
+	assert.IsType(t, &MaxStepCountError{}, letter.Err)
+
+	// the snapshot can be fed back to resume the instance
+	instance := &Instance{}
+	err = json.Unmarshal(letter.Snapshot, instance)
+	assert.Nil(t, err)
+	assert.Equal(t, letter.InstanceID, instance.ID())
+	assert.Equal(t, StatusAborted, instance.Status())
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "test", &RunOptions{Op: AoResume, InitialState: instance}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+}
@@ -0,0 +1,125 @@
+package flowinst
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// Breakpoints are the points at which StepToBreakpoint pauses an instance
+type Breakpoints struct {
+	// Steps are the IDs of the steps to pause before, see Instance.StepID
+	Steps []int
+
+	// Tasks are the names or activity refs of the tasks to pause before
+	Tasks []string
+}
+
+// matches returns true if the instance should pause before executing the
+// work item as its next step
+func (bp *Breakpoints) matches(stepID int, workItem *WorkItem) bool {
+
+	for _, step := range bp.Steps {
+		if step == stepID {
+			return true
+		}
+	}
+
+	if workItem.TaskData == nil || workItem.TaskData.Task() == nil {
+		return false
+	}
+
+	task := workItem.TaskData.Task()
+
+	for _, name := range bp.Tasks {
+		if name == task.Name() || name == task.ActivityRef() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StartPaused creates and starts an instance of the flow without executing
+// it, for debugging.  The caller drives the steps of the instance with
+// StepOnce or StepToBreakpoint and can resume its execution at full speed by
+// running it with AoResume.  The inputs of the instance are the trigger
+// attributes of the context.
+//
+// The steps of a paused instance are not recorded and don't count towards the
+// MaxStepCount
+func (fa *FlowAction) StartPaused(ctx context.Context, uri string, ro *RunOptions) (*Instance, error) {
+
+	flow, err := fa.flowProvider.GetFlow(uri)
+
+	if flow == nil {
+		return nil, &FlowNotFoundError{URI: uri, Err: err}
+	}
+
+	instanceID, err := fa.newInstanceID(ro)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debugf("Creating paused Instance: %s", instanceID)
+
+	instance := NewFlowInstance(instanceID, uri, flow)
+	fa.configure(instance, ro)
+
+	var triggerAttrs []*data.Attribute
+
+	if ctx != nil {
+		triggerAttrs, _ = trigger.FromContext(ctx)
+	}
+
+	instance.Start(triggerAttrs)
+
+	return instance, nil
+}
+
+// StepOnce executes the next step of the paused instance, the same way the
+// steps of a running instance are executed.  It returns true if the instance
+// could have more work, the error is the one that failed the instance, if any
+func (fa *FlowAction) StepOnce(instance *Instance) (hasWork bool, err error) {
+
+	if instance.Status().IsTerminal() {
+		return false, fmt.Errorf("Flow [%s] is done, status '%s'", instance.ID(), instance.Status())
+	}
+
+	hasWork = fa.step(instance)
+
+	logger.Debugf("Flow [%s] stepped to %d, status '%s'", instance.ID(), instance.StepID(), instance.Status())
+
+	if instance.Status() == StatusFailed {
+		if err = instance.LastError(); err == nil {
+			err = fmt.Errorf("Flow [%s] failed", instance.ID())
+		}
+	}
+
+	return hasWork, err
+}
+
+// StepToBreakpoint executes the steps of the paused instance until the next
+// step matches one of the breakpoints or the instance is done.  The first step
+// is executed even if it matches, so that the instance can move past the
+// breakpoint it is paused at.  It returns true if the instance could have more
+// work
+func (fa *FlowAction) StepToBreakpoint(instance *Instance, breakpoints *Breakpoints) (hasWork bool, err error) {
+
+	hasWork, err = fa.StepOnce(instance)
+
+	for hasWork && err == nil && !instance.Status().IsTerminal() {
+
+		if workItem, ok := instance.peekWorkItem(); ok && breakpoints != nil && breakpoints.matches(instance.StepID()+1, workItem) {
+			logger.Debugf("Flow [%s] paused at breakpoint before step %d", instance.ID(), instance.StepID()+1)
+			return hasWork, nil
+		}
+
+		hasWork, err = fa.StepOnce(instance)
+	}
+
+	return hasWork, err
+}
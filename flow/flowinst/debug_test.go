@@ -0,0 +1,53 @@
+package flowinst
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestStepToBreakpoint
+func TestStepToBreakpoint(t *testing.T) {
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"budget": newTestDefinition(t, budgetDefJSON)}}
+	fa := NewFlowAction(provider, nil, nil)
+
+	instance, err := fa.StartPaused(nil, "budget", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusActive, instance.Status())
+	assert.Equal(t, 0, instance.StepID())
+
+	// the root task schedules a, b and c
+	hasWork, err := fa.StepOnce(instance)
+	assert.True(t, hasWork)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, instance.WorkUnits())
+
+	hasWork, err = fa.StepToBreakpoint(instance, &Breakpoints{Tasks: []string{"b"}})
+	assert.True(t, hasWork)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, instance.StepID())
+	assert.Equal(t, 3, instance.WorkUnits())
+
+	next, _ := instance.peekWorkItem()
+	assert.Equal(t, "b", next.TaskData.Task().Name())
+
+	hasWork, err = fa.StepToBreakpoint(instance, &Breakpoints{Steps: []int{4}})
+	assert.True(t, hasWork)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, instance.StepID())
+	assert.Equal(t, 6, instance.WorkUnits())
+
+	// resume at full speed
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "budget", &RunOptions{Op: AoResume, InitialState: instance}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, StatusCompleted, instance.Status())
+	assert.Equal(t, 9, instance.WorkUnits())
+
+	_, err = fa.StepOnce(instance)
+	assert.EqualError(t, err, "Flow ["+instance.ID()+"] is done, status 'completed'")
+}
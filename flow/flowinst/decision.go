@@ -0,0 +1,62 @@
+package flowinst
+
+import (
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+)
+
+// LinkDecision describes the evaluation of a link, and so why a branch of the
+// flow was or wasn't taken
+type LinkDecision struct {
+	StepID     int              `json:"stepId"`
+	LinkID     int              `json:"linkId"`
+	LinkType   flowdef.LinkType `json:"linkType"`
+	FromTaskID int              `json:"from"`
+	ToTaskID   int              `json:"to"`
+	Condition  string           `json:"condition,omitempty"`
+	Followed   bool             `json:"followed"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// LinkDecisions returns the decisions made evaluating the links of the
+// instance, in the order they were made
+func (pi *Instance) LinkDecisions() []LinkDecision {
+
+	pi.decisionLock.Lock()
+	defer pi.decisionLock.Unlock()
+
+	decisions := make([]LinkDecision, len(pi.linkDecisions))
+	for i, decision := range pi.linkDecisions {
+		decisions[i] = *decision
+	}
+
+	return decisions
+}
+
+// SetRecordLinkDecisions enables including the link decisions in the snapshots
+// of the instance
+func (pi *Instance) SetRecordLinkDecisions(record bool) {
+	pi.recordLinkDecisions = record
+}
+
+// addLinkDecision captures the result of the evaluation of a link
+func (pi *Instance) addLinkDecision(link *flowdef.Link, followed bool, err error) {
+
+	decision := &LinkDecision{
+		StepID:     pi.stepID,
+		LinkID:     link.ID(),
+		LinkType:   link.Type(),
+		FromTaskID: link.FromTask().ID(),
+		ToTaskID:   link.ToTask().ID(),
+		Condition:  link.Value(),
+		Followed:   followed,
+	}
+
+	if err != nil {
+		decision.Error = err.Error()
+	}
+
+	pi.decisionLock.Lock()
+	defer pi.decisionLock.Unlock()
+
+	pi.linkDecisions = append(pi.linkDecisions, decision)
+}
@@ -0,0 +1,194 @@
+package flowinst
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"github.com/TIBCOSoftware/flogo-lib/util"
+)
+
+// StepFunc executes a step of an instance, returns true if the instance
+// could have more work
+type StepFunc func(instance *Instance) bool
+
+// StepDecorator wraps the execution of a step, ie. to instrument it, the
+// decorator has to call next to execute the step
+type StepDecorator func(next StepFunc) StepFunc
+
+// doStep is the StepFunc that executes the step
+func doStep(instance *Instance) bool {
+	return instance.DoStep()
+}
+
+// StepExecutor executes the steps of the instances, ie. to inject faults in
+// tests.  The StepDecorators wrap the executor
+type StepExecutor interface {
+
+	// Execute executes the next step of the instance, returns true if the
+	// instance could have more work.  An error fails the instance
+	Execute(instance *Instance) (hasWork bool, err error)
+}
+
+// DefaultStepExecutor is the StepExecutor that executes the steps with
+// Instance.DoStep
+type DefaultStepExecutor struct {
+}
+
+// Execute implements StepExecutor.Execute
+func (e *DefaultStepExecutor) Execute(instance *Instance) (hasWork bool, err error) {
+	return instance.DoStep(), nil
+}
+
+// executorStep adapts the executor to a StepFunc
+func executorStep(executor StepExecutor) StepFunc {
+	return func(instance *Instance) bool {
+
+		hasWork, err := executor.Execute(instance)
+
+		if err != nil {
+			logger.Warnf("Flow [%s] step %d failed - %s", instance.ID(), instance.StepID(), err.Error())
+			instance.setLastError(err)
+			instance.setStatus(StatusFailed)
+			return false
+		}
+
+		return hasWork
+	}
+}
+
+// chainStepDecorators applies the decorators to the step, the first decorator
+// is the outermost
+func chainStepDecorators(step StepFunc, decorators ...StepDecorator) StepFunc {
+
+	for i := len(decorators) - 1; i >= 0; i-- {
+		step = decorators[i](step)
+	}
+
+	return step
+}
+
+// LoggingStepDecorator is a StepDecorator that logs the execution time of
+// each step at debug level
+func LoggingStepDecorator(next StepFunc) StepFunc {
+	return func(instance *Instance) bool {
+		start := time.Now()
+		hasWork := next(instance)
+		logger.Debugf("Flow [%s] step %d took %v", instance.ID(), instance.stepID, time.Since(start))
+		return hasWork
+	}
+}
+
+// workUnitBudget is the StepDecorator that fails instances that go over the
+// MaxWorkUnits budget
+func (fa *FlowAction) workUnitBudget(next StepFunc) StepFunc {
+	return func(instance *Instance) bool {
+
+		hasWork := next(instance)
+
+		if fa.exceedsWorkUnits(instance) {
+			err := fmt.Errorf("Flow [%s] exceeded its budget of %d work units", instance.ID(), fa.actionOptions.MaxWorkUnits)
+			logger.Warn(err.Error())
+			instance.setLastError(err)
+			instance.setStatus(StatusFailed)
+		}
+
+		return hasWork
+	}
+}
+
+// abandonedStep is a step that didn't complete within the StepTimeout, it
+// owns the instance until it returns
+type abandonedStep struct {
+	err      *StepTimeoutError
+	returned chan struct{}
+}
+
+// stepTimeout is the StepDecorator that fails instances whose step doesn't
+// complete within the StepTimeout.  The step is abandoned, as its activity
+// can't be interrupted it keeps running in the background until it returns,
+// see FlowAction.AbandonedSteps.  The abandoned step keeps owning the
+// instance, it fails the instance once it returns, see takeAbandonedStep
+func (fa *FlowAction) stepTimeout(next StepFunc) StepFunc {
+	return func(instance *Instance) bool {
+
+		timeout := fa.actionOptions.StepTimeout
+
+		err := &StepTimeoutError{InstanceID: instance.ID(), Timeout: timeout}
+		if workItem, ok := instance.peekWorkItem(); ok && workItem.TaskData != nil {
+			err.Task = workItem.TaskData.Task().Name()
+			err.Activity = workItem.TaskData.Task().ActivityRef()
+		}
+
+		done := make(chan bool, 1)
+
+		go func() {
+			done <- next(instance)
+		}()
+
+		expired := make(chan struct{})
+
+		stop := util.AfterFunc(fa.actionOptions.Clock, timeout, func() { close(expired) })
+		defer stop()
+
+		select {
+		case hasWork := <-done:
+			return hasWork
+		case <-expired:
+		}
+
+		atomic.AddInt32(&fa.abandonedSteps, 1)
+		logger.Warn(err.Error())
+
+		step := &abandonedStep{err: err, returned: make(chan struct{})}
+		instance.setAbandonedStep(step)
+
+		go func() {
+			<-done
+
+			instance.setLastError(err)
+			instance.setStatus(StatusFailed)
+
+			atomic.AddInt32(&fa.abandonedSteps, -1)
+			logger.Infof("Abandoned step of Flow [%s] returned", err.InstanceID)
+
+			close(step.returned)
+		}()
+
+		return false
+	}
+}
+
+// AbandonedSteps returns the number of steps that timed out and are still
+// running in the background
+func (fa *FlowAction) AbandonedSteps() int {
+	return int(atomic.LoadInt32(&fa.abandonedSteps))
+}
+
+// validateOutputs is the StepDecorator that fails instances that complete
+// without setting all their outputs
+func validateOutputs(next StepFunc) StepFunc {
+	return func(instance *Instance) bool {
+
+		hasWork := next(instance)
+
+		if instance.Status() != StatusCompleted {
+			return hasWork
+		}
+
+		if unset := instance.unsetOutputs(); len(unset) > 0 {
+			err := fmt.Errorf("Flow [%s] completed without setting outputs: %s", instance.ID(), strings.Join(unset, ", "))
+			logger.Warn(err.Error())
+			instance.setLastError(err)
+			instance.setStatus(StatusFailed)
+
+			if replyHandler := instance.ReplyHandler(); replyHandler != nil {
+				replyHandler.Reply(500, nil, err)
+			}
+		}
+
+		return hasWork
+	}
+}
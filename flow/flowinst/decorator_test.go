@@ -0,0 +1,195 @@
+package flowinst
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestMaxWorkUnits
+func TestMaxWorkUnits(t *testing.T) {
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"budget": newTestDefinition(t, budgetDefJSON)}}
+	recorder := &testStateRecorder{}
+
+	fa := NewFlowAction(provider, recorder, &ActionOptions{Record: true, MaxWorkUnits: 5, CheckpointStrategy: &StatusChangeCheckpoint{}})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "budget", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// aborted after the second activity, the third never ran
+	assert.Equal(t, []Status{StatusFailed}, recorder.snapshots)
+	assert.Equal(t, 6, recorder.instance.WorkUnits())
+	assert.NotNil(t, recorder.instance.LastError())
+}
+
+//TestStepDecorators
+func TestStepDecorators(t *testing.T) {
+
+	var calls []string
+
+	decorator := func(name string) StepDecorator {
+		return func(next StepFunc) StepFunc {
+			return func(instance *Instance) bool {
+				calls = append(calls, name+" before")
+				hasWork := next(instance)
+				calls = append(calls, name+" after")
+				return hasWork
+			}
+		}
+	}
+
+	options := &ActionOptions{StepDecorators: []StepDecorator{decorator("outer"), LoggingStepDecorator, decorator("inner")}}
+	fa := NewFlowAction(newTestFlowProvider(t), nil, options)
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// the test flow takes two steps
+	step := []string{"outer before", "inner before", "inner after", "outer after"}
+	assert.Equal(t, append(step, step...), calls)
+}
+
+//TestValidateOutputs
+func TestValidateOutputs(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("outputs").Model("budget").
+		AddOutput("{T.total}", data.INTEGER).
+		AddOutput("{T.status}", data.STRING).
+		AddTask(2, 2, "a", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"outputs": def}}
+	ctx := trigger.NewContext(context.Background(), []*data.Attribute{data.NewAttribute("total", data.INTEGER, 10)})
+
+	// lenient by default
+	recorder := &testStateRecorder{}
+	fa := NewFlowAction(provider, recorder, &ActionOptions{Record: true})
+
+	handler := newTestResultHandler()
+	err = fa.Run(ctx, "outputs", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, StatusCompleted, recorder.instance.Status())
+
+	recorder = &testStateRecorder{}
+	fa = NewFlowAction(provider, recorder, &ActionOptions{Record: true, ValidateOutputs: true})
+
+	handler = newTestResultHandler()
+	err = fa.Run(ctx, "outputs", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, StatusFailed, recorder.instance.Status())
+	assert.Contains(t, recorder.instance.LastError().Error(), "completed without setting outputs: {T.status}")
+
+	// the IDResponse followed by the failure
+	assert.Len(t, handler.results, 2)
+	assert.Nil(t, handler.results[1])
+}
+
+//TestStepTimeout
+func TestStepTimeout(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "wait", ActivityType: "gate", ActivityRef: "gate", OutputMappings: []*data.MappingDef{}}).
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	sink := NewInMemoryDeadLetterSink()
+	recorder := &testStateRecorder{}
+	fa := NewFlowAction(provider, recorder, &ActionOptions{StepTimeout: 20 * time.Millisecond, DeadLetterSink: sink, CheckpointStrategy: &StatusChangeCheckpoint{}})
+
+	future, err := fa.RunAsync(nil, "gated", &RunOptions{ReturnResult: true})
+	assert.Nil(t, err)
+
+	// the caller is released once the step times out
+	<-gate.entered
+	<-future.Done()
+
+	code, result, err := future.Wait(nil)
+	assert.Equal(t, CodeStepTimeout, code)
+	assert.Nil(t, result)
+
+	timeoutErr, ok := err.(*StepTimeoutError)
+	assert.True(t, ok)
+	assert.Equal(t, "wait", timeoutErr.Task)
+	assert.Equal(t, 1, fa.AbandonedSteps())
+
+	// the abandoned step still owns the instance
+	status, exists := fa.InstanceStatus(timeoutErr.InstanceID)
+	assert.True(t, exists)
+	assert.Equal(t, StatusActive, status)
+	assert.Empty(t, sink.Letters())
+
+	// the instance fails once the abandoned step returns
+	gate.release <- true
+	waitFor(t, func() bool { return len(fa.ActiveInstances()) == 0 })
+	assert.Equal(t, 0, fa.AbandonedSteps())
+	assert.Empty(t, fa.ActiveInstances())
+
+	letters := sink.Letters()
+	assert.Len(t, letters, 1)
+	assert.Equal(t, DeadLetterFailed, letters[0].Reason)
+	assert.EqualError(t, letters[0].Err, "Flow ["+timeoutErr.InstanceID+"] step timed out after 20ms executing task 'wait' (activity 'gate')")
+	assert.Equal(t, []Status{StatusFailed}, recorder.snapshots)
+}
+
+// faultyStepExecutor fails the specified step, the other steps are executed
+type faultyStepExecutor struct {
+	DefaultStepExecutor
+	failStep int
+	executed int
+}
+
+func (e *faultyStepExecutor) Execute(instance *Instance) (hasWork bool, err error) {
+
+	if instance.StepID()+1 == e.failStep {
+		return false, errors.New("injected fault")
+	}
+
+	e.executed++
+	return e.DefaultStepExecutor.Execute(instance)
+}
+
+//TestStepExecutor
+func TestStepExecutor(t *testing.T) {
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"budget": newTestDefinition(t, budgetDefJSON)}}
+	sink := NewInMemoryDeadLetterSink()
+
+	executor := &faultyStepExecutor{failStep: 3}
+	fa := NewFlowAction(provider, nil, &ActionOptions{StepExecutor: executor, DeadLetterSink: sink})
+
+	_, result, err := fa.RunSync(nil, "budget", &RunOptions{ReturnResult: true})
+	assert.Nil(t, err)
+
+	assert.Equal(t, int(StatusFailed), result.(*FlowResult).Status)
+	assert.Equal(t, 2, executor.executed)
+
+	letters := sink.Letters()
+	assert.Len(t, letters, 1)
+	assert.Equal(t, DeadLetterFailed, letters[0].Reason)
+	assert.EqualError(t, letters[0].Err, "injected fault")
+
+	// without a fault the instance completes
+	executor = &faultyStepExecutor{}
+	fa = NewFlowAction(provider, nil, &ActionOptions{StepExecutor: executor})
+
+	_, result, err = fa.RunSync(nil, "budget", &RunOptions{ReturnResult: true})
+	assert.Nil(t, err)
+	assert.Equal(t, int(StatusCompleted), result.(*FlowResult).Status)
+}
@@ -0,0 +1,49 @@
+package flowinst
+
+import (
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// deprecatedOption describes a deprecated field of ActionOptions
+type deprecatedOption struct {
+	name        string
+	replacement string
+	isSet       func(options *ActionOptions) bool
+}
+
+var deprecatedOptions = []*deprecatedOption{
+	{
+		name:        "Record",
+		replacement: "CheckpointStrategy",
+		isSet:       func(options *ActionOptions) bool { return options.Record },
+	},
+}
+
+var (
+	deprecationMu     sync.Mutex
+	deprecationWarned = make(map[string]bool)
+)
+
+// warnDeprecatedOptions logs a warning for each deprecated option that is set,
+// the warning for an option is only logged once
+func warnDeprecatedOptions(options *ActionOptions) {
+
+	if options.SuppressDeprecationWarnings {
+		return
+	}
+
+	deprecationMu.Lock()
+	defer deprecationMu.Unlock()
+
+	for _, option := range deprecatedOptions {
+
+		if !option.isSet(options) || deprecationWarned[option.name] {
+			continue
+		}
+
+		deprecationWarned[option.name] = true
+		logger.Warnf("ActionOptions.%s is deprecated and will be removed in a future release, use ActionOptions.%s instead", option.name, option.replacement)
+	}
+}
@@ -0,0 +1,51 @@
+package flowinst
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// capturingLoggerFactory captures the warnings logged
+type capturingLoggerFactory struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (f *capturingLoggerFactory) GetLogger(name string) logger.Logger {
+	return &capturingLogger{Logger: (&logger.DefaultLoggerFactory{}).GetLogger(name), factory: f}
+}
+
+type capturingLogger struct {
+	logger.Logger
+	factory *capturingLoggerFactory
+}
+
+func (l *capturingLogger) Warnf(format string, args ...interface{}) {
+	l.factory.mu.Lock()
+	l.factory.warnings = append(l.factory.warnings, fmt.Sprintf(format, args...))
+	l.factory.mu.Unlock()
+}
+
+//TestDeprecationWarning
+func TestDeprecationWarning(t *testing.T) {
+
+	factory := &capturingLoggerFactory{}
+	logger.RegisterLoggerFactory(factory)
+	defer logger.RegisterLoggerFactory(&logger.DefaultLoggerFactory{})
+
+	deprecationMu.Lock()
+	deprecationWarned = make(map[string]bool)
+	deprecationMu.Unlock()
+
+	NewFlowAction(nil, nil, &ActionOptions{Record: true, SuppressDeprecationWarnings: true})
+	assert.Equal(t, 0, len(factory.warnings))
+
+	NewFlowAction(nil, nil, &ActionOptions{Record: true})
+	NewFlowAction(nil, nil, &ActionOptions{Record: true})
+
+	assert.Equal(t, []string{"ActionOptions.Record is deprecated and will be removed in a future release, use ActionOptions.CheckpointStrategy instead"}, factory.warnings)
+}
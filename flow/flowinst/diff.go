@@ -0,0 +1,115 @@
+package flowinst
+
+import (
+	"reflect"
+	"sort"
+)
+
+// InstanceDiff describes the differences between two states of an instance
+type InstanceDiff struct {
+	StatusChanged bool
+	StatusBefore  Status
+	StatusAfter   Status
+
+	Attrs []*AttrDiff
+	Tasks []*TaskStateDiff
+}
+
+// AttrDiff describes the change of an attribute
+type AttrDiff struct {
+	ChgType ChgType
+	Name    string
+	Before  interface{}
+	After   interface{}
+}
+
+// TaskStateDiff describes the change of the state of a task
+type TaskStateDiff struct {
+	ChgType ChgType
+	EnvID   int
+	TaskID  int
+	Before  int
+	After   int
+}
+
+// HasChanges indicates if there are any differences
+func (d InstanceDiff) HasChanges() bool {
+	return d.StatusChanged || len(d.Attrs) > 0 || len(d.Tasks) > 0
+}
+
+// DiffInstances determines the differences between two states of an instance,
+// typically two snapshots recorded for the instance
+func DiffInstances(a, b *Instance) InstanceDiff {
+
+	diff := InstanceDiff{StatusBefore: a.status, StatusAfter: b.status}
+	diff.StatusChanged = a.status != b.status
+
+	for name, attrA := range a.Attrs {
+
+		attrB, exists := b.Attrs[name]
+
+		if !exists {
+			diff.Attrs = append(diff.Attrs, &AttrDiff{ChgType: CtDel, Name: name, Before: attrA.Value})
+		} else if !reflect.DeepEqual(attrA.Value, attrB.Value) {
+			diff.Attrs = append(diff.Attrs, &AttrDiff{ChgType: CtUpd, Name: name, Before: attrA.Value, After: attrB.Value})
+		}
+	}
+
+	for name, attrB := range b.Attrs {
+
+		if _, exists := a.Attrs[name]; !exists {
+			diff.Attrs = append(diff.Attrs, &AttrDiff{ChgType: CtAdd, Name: name, After: attrB.Value})
+		}
+	}
+
+	sort.Slice(diff.Attrs, func(i, j int) bool {
+		return diff.Attrs[i].Name < diff.Attrs[j].Name
+	})
+
+	diff.Tasks = append(diff.Tasks, diffTaskEnvs(a.RootTaskEnv, b.RootTaskEnv)...)
+	diff.Tasks = append(diff.Tasks, diffTaskEnvs(a.EhTaskEnv, b.EhTaskEnv)...)
+
+	return diff
+}
+
+func diffTaskEnvs(a, b *TaskEnv) []*TaskStateDiff {
+
+	var taskDatasA, taskDatasB map[int]*TaskData
+	envID := 0
+
+	if a != nil {
+		taskDatasA = a.TaskDatas
+		envID = a.ID
+	}
+
+	if b != nil {
+		taskDatasB = b.TaskDatas
+		envID = b.ID
+	}
+
+	var diffs []*TaskStateDiff
+
+	for id, tdA := range taskDatasA {
+
+		tdB, exists := taskDatasB[id]
+
+		if !exists {
+			diffs = append(diffs, &TaskStateDiff{ChgType: CtDel, EnvID: envID, TaskID: id, Before: tdA.state})
+		} else if tdA.state != tdB.state {
+			diffs = append(diffs, &TaskStateDiff{ChgType: CtUpd, EnvID: envID, TaskID: id, Before: tdA.state, After: tdB.state})
+		}
+	}
+
+	for id, tdB := range taskDatasB {
+
+		if _, exists := taskDatasA[id]; !exists {
+			diffs = append(diffs, &TaskStateDiff{ChgType: CtAdd, EnvID: envID, TaskID: id, After: tdB.state})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].TaskID < diffs[j].TaskID
+	})
+
+	return diffs
+}
@@ -0,0 +1,107 @@
+package flowinst
+
+import (
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/activity"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// DryRun resolves the flow with the specified URI and checks it without
+// creating an instance or executing any activity.  The attributes are
+// validated against the inputs declared by the flow, the links of the flow
+// have to connect tasks of the same scope, every task has to be reachable and
+// its activity registered.  A flow that can't be resolved fails with a
+// FlowNotFoundError, the other problems are consolidated in a DryRunError
+func (fa *FlowAction) DryRun(uri string, attrs []*data.Attribute) error {
+
+	flow, err := fa.flowProvider.GetFlow(uri)
+
+	if flow == nil {
+		return &FlowNotFoundError{URI: uri, Err: err}
+	}
+
+	var problems []string
+
+	if err := validateInputs(uri, flow, attrs); err != nil {
+		problems = append(problems, err.(*InputValidationError).Problems...)
+	}
+
+	problems = append(problems, checkScope(flow.RootTask())...)
+
+	if ehTask := flow.ErrorHandlerTask(); ehTask != nil {
+		problems = append(problems, checkScope(ehTask)...)
+	}
+
+	if len(problems) > 0 {
+		return &DryRunError{URI: uri, Problems: problems}
+	}
+
+	logger.Debugf("Flow [%s] passed the dry run", uri)
+
+	return nil
+}
+
+// checkScope checks the links and child tasks of the scope task and of its
+// nested scopes.  The tasks without incoming links are the entry points of
+// the scope, the other tasks have to be reachable from them
+func checkScope(scope *flowdef.Task) []string {
+
+	var problems []string
+
+	inScope := make(map[*flowdef.Task]bool, len(scope.ChildTasks()))
+	for _, task := range scope.ChildTasks() {
+		inScope[task] = true
+	}
+
+	for _, link := range scope.ChildLinks() {
+
+		if link.FromTask() == nil || link.ToTask() == nil {
+			problems = append(problems, fmt.Sprintf("link %d is dangling", link.ID()))
+		} else if !inScope[link.FromTask()] || !inScope[link.ToTask()] {
+			problems = append(problems, fmt.Sprintf("link %d connects tasks outside of %s", link.ID(), scope))
+		}
+	}
+
+	reached := make(map[*flowdef.Task]bool, len(inScope))
+
+	var reach func(task *flowdef.Task)
+	reach = func(task *flowdef.Task) {
+
+		if reached[task] {
+			return
+		}
+		reached[task] = true
+
+		for _, link := range task.ToLinks() {
+			if link.ToTask() != nil && inScope[link.ToTask()] {
+				reach(link.ToTask())
+			}
+		}
+	}
+
+	for _, task := range scope.ChildTasks() {
+		if len(task.FromLinks()) == 0 {
+			reach(task)
+		}
+	}
+
+	for _, task := range scope.ChildTasks() {
+
+		if !reached[task] {
+			problems = append(problems, fmt.Sprintf("%s is unreachable", task))
+		}
+
+		if len(task.ActivityType()) > 0 && activity.Get(task.ActivityType()) == nil {
+			problems = append(problems, fmt.Sprintf("%s has unregistered activity '%s'", task, task.ActivityType()))
+		}
+
+		if len(task.ChildTasks()) > 0 {
+			problems = append(problems, checkScope(task)...)
+		}
+	}
+
+	return problems
+}
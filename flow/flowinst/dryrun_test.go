@@ -0,0 +1,50 @@
+package flowinst
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestDryRun
+func TestDryRun(t *testing.T) {
+
+	valid, err := flowdef.NewBuilder().Name("valid").Model("budget").
+		AddInput("orderId", data.STRING, nil).
+		AddTask(2, 2, "a", "workunits").
+		AddTask(3, 2, "b", "workunits").
+		AddLink(2, 3).
+		Build()
+	assert.Nil(t, err)
+
+	// b and c only link to each other, so they are never entered
+	invalid, err := flowdef.NewBuilder().Name("invalid").Model("budget").
+		AddInput("orderId", data.STRING, nil).
+		AddTask(2, 2, "a", "unknown").
+		AddTask(3, 2, "b", "workunits").
+		AddTask(4, 2, "c", "workunits").
+		AddExprLink(3, 4, "true").
+		AddExprLink(4, 3, "true").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"valid": valid, "invalid": invalid}}
+	recorder := &testStateRecorder{}
+	fa := NewFlowAction(provider, recorder, &ActionOptions{Record: true})
+
+	assert.Nil(t, fa.DryRun("valid", []*data.Attribute{data.NewAttribute("orderId", data.STRING, "order-1")}))
+
+	err = fa.DryRun("invalid", nil)
+	assert.EqualError(t, err, "Flow [invalid] failed the dry run: missing required input 'orderId', Task[2]:'a' has unregistered activity 'unknown', Task[3]:'b' is unreachable, Task[4]:'c' is unreachable")
+	assert.True(t, errors.Is(err, ErrInvalidFlow))
+
+	err = fa.DryRun("missing", nil)
+	assert.True(t, errors.Is(err, ErrFlowNotFound))
+
+	// nothing was executed
+	assert.Equal(t, 0, len(recorder.snapshots))
+	assert.Equal(t, 0, recorder.steps)
+}
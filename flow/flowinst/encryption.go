@@ -0,0 +1,660 @@
+package flowinst
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+const encValuePrefix = "{enc}"
+
+// KeyProvider provides the master keys used to encrypt the data keys of
+// encrypted attribute values
+type KeyProvider interface {
+
+	// CurrentKey returns the key that should be used to encrypt new values
+	CurrentKey() (keyID string, key []byte, err error)
+
+	// GetKey returns the key with the specified ID
+	GetKey(keyID string) ([]byte, error)
+}
+
+// EncryptingStateRecorder is a StateRecorder that encrypts the values of
+// sensitive attributes before they are handed to the wrapped StateRecorder.
+// Each value is encrypted with its own data key, which in turn is encrypted
+// with the current master key of the KeyProvider.  The instances read back
+// through it, as a StateReader, SnapshotLoader or StepLoader, are decrypted
+type EncryptingStateRecorder struct {
+	recorder    StateRecorder
+	keyProvider KeyProvider
+	attrs       map[string]bool
+}
+
+// NewEncryptingStateRecorder creates a new EncryptingStateRecorder, the values of
+// the specified attributes are always encrypted, in addition to the ones flagged
+// on a specific run
+func NewEncryptingStateRecorder(recorder StateRecorder, keyProvider KeyProvider, attrNames ...string) *EncryptingStateRecorder {
+
+	attrs := make(map[string]bool, len(attrNames))
+
+	for _, name := range attrNames {
+		attrs[name] = true
+	}
+
+	return &EncryptingStateRecorder{recorder: recorder, keyProvider: keyProvider, attrs: attrs}
+}
+
+// RecordSnapshot implements flowinst.StateRecorder.RecordSnapshot, the wrapped
+// StateRecorder records a copy of the instance with the encrypted values
+func (sr *EncryptingStateRecorder) RecordSnapshot(instance *Instance) {
+
+	instCopy, err := sr.encryptedCopy(instance)
+	if err != nil {
+		// never record a sensitive value in plaintext
+		logger.Errorf("Unable to encrypt the snapshot of Flow [%s], not recorded - %s", instance.ID(), err.Error())
+		return
+	}
+
+	sr.recorder.RecordSnapshot(instCopy)
+}
+
+// RecordStep implements flowinst.StateRecorder.RecordStep, the wrapped
+// StateRecorder records a copy of the instance with the encrypted values
+func (sr *EncryptingStateRecorder) RecordStep(instance *Instance) {
+
+	instCopy, err := sr.encryptedCopy(instance)
+	if err != nil {
+		// never record a sensitive value in plaintext
+		logger.Errorf("Unable to encrypt the step of Flow [%s], not recorded - %s", instance.ID(), err.Error())
+		return
+	}
+
+	sr.recorder.RecordStep(instCopy)
+}
+
+// Flush implements flowinst.FlushableStateRecorder.Flush, it flushes the wrapped
+// StateRecorder if it supports flushing
+func (sr *EncryptingStateRecorder) Flush(ctx context.Context) error {
+
+	if recorder, ok := sr.recorder.(FlushableStateRecorder); ok {
+		return recorder.Flush(ctx)
+	}
+
+	return nil
+}
+
+// Decrypt decrypts the sensitive values of an instance that was read back from
+// storage: the attributes of the instance and of its tasks, its errors and the
+// ones of the changes it tracks
+func (sr *EncryptingStateRecorder) Decrypt(instance *Instance) error {
+
+	if err := sr.decryptAttrs(instance.Attrs); err != nil {
+		return err
+	}
+
+	if instance.RootTaskEnv != nil {
+		for _, taskData := range instance.RootTaskEnv.TaskDatas {
+			if err := sr.decryptAttrs(taskData.attrs); err != nil {
+				return err
+			}
+		}
+	}
+
+	if instance.lastError != nil {
+		msg, err := sr.decryptMessage(instance.lastError.Error())
+		if err != nil {
+			return err
+		}
+		instance.lastError = errors.New(msg)
+	}
+
+	for _, decision := range instance.linkDecisions {
+		msg, err := sr.decryptMessage(decision.Error)
+		if err != nil {
+			return err
+		}
+		decision.Error = msg
+	}
+
+	if instance.ChangeTracker == nil {
+		return nil
+	}
+
+	for _, attrChange := range instance.ChangeTracker.instChange.AttrChanges {
+		if err := sr.decrypt(attrChange.Attribute); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range instance.ChangeTracker.tdChanges {
+		if change.TaskData != nil {
+			if err := sr.decryptAttrs(change.TaskData.attrs); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, change := range instance.ChangeTracker.miChanges {
+		for _, attr := range change.Inputs {
+			if err := sr.decrypt(attr); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ListInstances implements StateReader.ListInstances, the summaries are the
+// ones of the wrapped StateRecorder
+func (sr *EncryptingStateRecorder) ListInstances(filter *InstanceFilter) ([]*InstanceSummary, error) {
+
+	reader, ok := sr.recorder.(StateReader)
+	if !ok {
+		return nil, errors.New("the wrapped StateRecorder is not a StateReader")
+	}
+
+	return reader.ListInstances(filter)
+}
+
+// GetSnapshot implements StateReader.GetSnapshot, the snapshot of the wrapped
+// StateRecorder is returned with its sensitive values decrypted
+func (sr *EncryptingStateRecorder) GetSnapshot(instanceID string) (*Instance, error) {
+
+	reader, ok := sr.recorder.(StateReader)
+	if !ok {
+		return nil, errors.New("the wrapped StateRecorder is not a StateReader")
+	}
+
+	instance, err := reader.GetSnapshot(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sr.Decrypt(instance); err != nil {
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+// LoadSnapshot implements SnapshotLoader.LoadSnapshot, the snapshot of the
+// wrapped StateRecorder is returned with its sensitive values decrypted
+func (sr *EncryptingStateRecorder) LoadSnapshot(instanceID string) ([]byte, error) {
+
+	loader, ok := sr.recorder.(SnapshotLoader)
+	if !ok {
+		return nil, errors.New("the wrapped StateRecorder is not a SnapshotLoader")
+	}
+
+	snapshot, err := loader.LoadSnapshot(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sr.decryptSnapshot(snapshot)
+}
+
+// LoadStep implements StepLoader.LoadStep, the state of the step recorded by
+// the wrapped StateRecorder is returned with its sensitive values decrypted
+func (sr *EncryptingStateRecorder) LoadStep(instanceID string, step int) ([]byte, error) {
+
+	loader, ok := sr.recorder.(StepLoader)
+	if !ok {
+		return nil, errors.New("the wrapped StateRecorder is not a StepLoader")
+	}
+
+	state, err := loader.LoadStep(instanceID, step)
+	if err != nil {
+		return nil, err
+	}
+
+	return sr.decryptSnapshot(state)
+}
+
+// RecordedSteps implements StepLoader.RecordedSteps, no steps are returned if
+// the wrapped StateRecorder isn't a StepLoader
+func (sr *EncryptingStateRecorder) RecordedSteps(instanceID string) []int {
+
+	if loader, ok := sr.recorder.(StepLoader); ok {
+		return loader.RecordedSteps(instanceID)
+	}
+
+	return nil
+}
+
+// decryptSnapshot decrypts the sensitive values of a serialized instance, the
+// rest of the snapshot is left as is
+func (sr *EncryptingStateRecorder) decryptSnapshot(snapshot []byte) ([]byte, error) {
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(snapshot, &fields); err != nil {
+		return nil, err
+	}
+
+	if err := sr.decryptField(fields, "attrs", sr.decryptAttrsJSON); err != nil {
+		return nil, err
+	}
+
+	if err := sr.decryptField(fields, "lastError", sr.decryptMessageJSON); err != nil {
+		return nil, err
+	}
+
+	err := sr.decryptField(fields, "rootTaskEnv", func(rawEnv json.RawMessage) (json.RawMessage, error) {
+
+		var env map[string]json.RawMessage
+		if err := json.Unmarshal(rawEnv, &env); err != nil || env == nil {
+			return rawEnv, err
+		}
+
+		err := sr.decryptField(env, "taskDatas", func(rawTaskDatas json.RawMessage) (json.RawMessage, error) {
+			return sr.decryptEach(rawTaskDatas, "attrs", sr.decryptAttrsJSON)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(env)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = sr.decryptField(fields, "linkDecisions", func(rawDecisions json.RawMessage) (json.RawMessage, error) {
+		return sr.decryptEach(rawDecisions, "error", sr.decryptMessageJSON)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(fields)
+}
+
+// decryptField replaces the value of the field, if present, with the one
+// returned by decrypt
+func (sr *EncryptingStateRecorder) decryptField(fields map[string]json.RawMessage, name string, decrypt func(json.RawMessage) (json.RawMessage, error)) error {
+
+	raw, ok := fields[name]
+	if !ok {
+		return nil
+	}
+
+	decrypted, err := decrypt(raw)
+	if err != nil {
+		return err
+	}
+
+	fields[name] = decrypted
+	return nil
+}
+
+// decryptEach decrypts the field of each object of a serialized array
+func (sr *EncryptingStateRecorder) decryptEach(raw json.RawMessage, name string, decrypt func(json.RawMessage) (json.RawMessage, error)) (json.RawMessage, error) {
+
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &objects); err != nil || objects == nil {
+		return raw, err
+	}
+
+	for _, fields := range objects {
+		if err := sr.decryptField(fields, name, decrypt); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(objects)
+}
+
+// decryptAttrsJSON decrypts the values of serialized attributes
+func (sr *EncryptingStateRecorder) decryptAttrsJSON(raw json.RawMessage) (json.RawMessage, error) {
+
+	var attrs []*data.Attribute
+	if err := json.Unmarshal(raw, &attrs); err != nil {
+		return nil, err
+	}
+
+	for _, attr := range attrs {
+		if err := sr.decrypt(attr); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(attrs)
+}
+
+// decryptMessageJSON decrypts a serialized error message
+func (sr *EncryptingStateRecorder) decryptMessageJSON(raw json.RawMessage) (json.RawMessage, error) {
+
+	var msg string
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, err
+	}
+
+	msg, err := sr.decryptMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(msg)
+}
+
+// decryptAttrs decrypts the values of the attributes
+func (sr *EncryptingStateRecorder) decryptAttrs(attrs map[string]*data.Attribute) error {
+
+	for _, attr := range attrs {
+		if err := sr.decrypt(attr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decryptMessage returns the plaintext of an encrypted error message
+func (sr *EncryptingStateRecorder) decryptMessage(msg string) (string, error) {
+
+	if !strings.HasPrefix(msg, encValuePrefix) {
+		return msg, nil
+	}
+
+	value, err := decryptValue(sr.keyProvider, msg)
+	if err != nil {
+		return "", fmt.Errorf("Unable to decrypt error message - %s", err.Error())
+	}
+
+	plaintext, _ := value.(string)
+	return plaintext, nil
+}
+
+// decrypt replaces the encrypted value of the attribute with its plaintext
+func (sr *EncryptingStateRecorder) decrypt(attr *data.Attribute) error {
+
+	encValue, ok := attr.Value.(string)
+
+	if !ok || !strings.HasPrefix(encValue, encValuePrefix) {
+		return nil
+	}
+
+	value, err := decryptValue(sr.keyProvider, encValue)
+	if err != nil {
+		return fmt.Errorf("Unable to decrypt attribute '%s' - %s", attr.Name, err.Error())
+	}
+
+	attr.Value, err = data.CoerceToValue(value, attr.Type)
+	if err != nil {
+		attr.Value = value
+	}
+
+	return nil
+}
+
+// encryptedCopy returns a copy of the instance and its changes in which the
+// values of the sensitive attributes, of the instance and of its tasks, are
+// encrypted, the instance itself is left untouched.  As they can quote those
+// values, the error messages are encrypted too.  Returns an error if the
+// instance couldn't be copied or a value couldn't be encrypted
+func (sr *EncryptingStateRecorder) encryptedCopy(instance *Instance) (*Instance, error) {
+
+	instCopy, err := instance.recordCopy()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sr.encryptAttrs(instance, instCopy.Attrs); err != nil {
+		return nil, err
+	}
+
+	// the TaskDatas of the copy are copies too
+	if instCopy.RootTaskEnv != nil {
+		for _, taskData := range instCopy.RootTaskEnv.TaskDatas {
+			if err := sr.encryptAttrs(instance, taskData.attrs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(sr.attrs) > 0 || len(instance.sensitiveAttrs) > 0 {
+		if err := sr.encryptErrors(instCopy); err != nil {
+			return nil, err
+		}
+	}
+
+	if instCopy.ChangeTracker == nil {
+		return instCopy, nil
+	}
+
+	changes := instCopy.ChangeTracker
+
+	for _, attrChange := range changes.instChange.AttrChanges {
+		if err := sr.encrypt(instance, attrChange.Attribute); err != nil {
+			return nil, err
+		}
+	}
+
+	// the changes of the TaskDatas that are no longer in the environment, and
+	// of the mapped inputs, are shared with the instance
+	for id, change := range changes.tdChanges {
+		if change.TaskData == nil || (instCopy.RootTaskEnv != nil && instCopy.RootTaskEnv.TaskDatas[id] == change.TaskData) {
+			continue
+		}
+
+		taskData := *change.TaskData
+		taskData.attrs = copyAttrs(taskData.attrs)
+
+		if err := sr.encryptAttrs(instance, taskData.attrs); err != nil {
+			return nil, err
+		}
+
+		changes.tdChanges[id] = &TaskDataChange{ChgType: change.ChgType, ID: change.ID, TaskData: &taskData}
+	}
+
+	for i, change := range changes.miChanges {
+		inputs := make([]*data.Attribute, len(change.Inputs))
+
+		for j, input := range change.Inputs {
+			attr := *input
+			if err := sr.encrypt(instance, &attr); err != nil {
+				return nil, err
+			}
+			inputs[j] = &attr
+		}
+
+		changes.miChanges[i] = &MappedInputsChange{TaskID: change.TaskID, Inputs: inputs}
+	}
+
+	return instCopy, nil
+}
+
+// encryptAttrs encrypts the values of the copied attributes that are sensitive
+func (sr *EncryptingStateRecorder) encryptAttrs(instance *Instance, attrs map[string]*data.Attribute) error {
+
+	for _, attr := range attrs {
+		if err := sr.encrypt(instance, attr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encryptErrors encrypts the last error and the errors of the link decisions
+// of the copied instance
+func (sr *EncryptingStateRecorder) encryptErrors(instCopy *Instance) error {
+
+	if instCopy.lastError != nil {
+		encMsg, err := encryptValue(sr.keyProvider, instCopy.lastError.Error())
+		if err != nil {
+			return fmt.Errorf("Unable to encrypt the last error - %s", err.Error())
+		}
+		instCopy.lastError = errors.New(encMsg)
+	}
+
+	for _, decision := range instCopy.linkDecisions {
+		if decision.Error == "" {
+			continue
+		}
+
+		encMsg, err := encryptValue(sr.keyProvider, decision.Error)
+		if err != nil {
+			return fmt.Errorf("Unable to encrypt the error of link '%d' - %s", decision.LinkID, err.Error())
+		}
+		decision.Error = encMsg
+	}
+
+	return nil
+}
+
+// encrypt replaces the value of the copied attribute with its encrypted form
+// if it is sensitive
+func (sr *EncryptingStateRecorder) encrypt(instance *Instance, attr *data.Attribute) error {
+
+	if !sr.attrs[attr.Name] && !instance.sensitiveAttrs[attr.Name] {
+		return nil
+	}
+
+	encValue, err := encryptValue(sr.keyProvider, attr.Value)
+	if err != nil {
+		return fmt.Errorf("Unable to encrypt attribute '%s' - %s", attr.Name, err.Error())
+	}
+
+	attr.Value = encValue
+	return nil
+}
+
+// copyAttrs copies the attributes so their values can be replaced
+func copyAttrs(attrs map[string]*data.Attribute) map[string]*data.Attribute {
+
+	if attrs == nil {
+		return nil
+	}
+
+	attrsCopy := make(map[string]*data.Attribute, len(attrs))
+
+	for name, attr := range attrs {
+		attrCopy := *attr
+		attrsCopy[name] = &attrCopy
+	}
+
+	return attrsCopy
+}
+
+func encryptValue(keyProvider KeyProvider, value interface{}) (string, error) {
+
+	keyID, masterKey, err := keyProvider.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(dataKey, plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	encDataKey, err := seal(masterKey, dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.StdEncoding
+	return encValuePrefix + keyID + ":" + enc.EncodeToString(encDataKey) + ":" + enc.EncodeToString(ciphertext), nil
+}
+
+func decryptValue(keyProvider KeyProvider, encValue string) (interface{}, error) {
+
+	parts := strings.Split(strings.TrimPrefix(encValue, encValuePrefix), ":")
+
+	if len(parts) != 3 {
+		return nil, errors.New("invalid encrypted value")
+	}
+
+	masterKey, err := keyProvider.GetKey(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	enc := base64.StdEncoding
+
+	encDataKey, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := open(masterKey, encDataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	err = json.Unmarshal(plaintext, &value)
+
+	return value, err
+}
+
+func seal(key []byte, plaintext []byte) ([]byte, error) {
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key []byte, ciphertext []byte) ([]byte, error) {
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce := ciphertext[:gcm.NonceSize()]
+	return gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,259 @@
+package flowinst
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+type testKeyProvider struct {
+	keys map[string][]byte
+}
+
+func (kp *testKeyProvider) CurrentKey() (string, []byte, error) {
+	return "k1", kp.keys["k1"], nil
+}
+
+func (kp *testKeyProvider) GetKey(keyID string) ([]byte, error) {
+	key, ok := kp.keys[keyID]
+	if !ok {
+		return nil, errors.New("unknown key")
+	}
+	return key, nil
+}
+
+type storingStateRecorder struct {
+	snapshot []byte
+	changes  []byte
+}
+
+func (sr *storingStateRecorder) RecordSnapshot(instance *Instance) {
+	sr.snapshot, _ = json.Marshal(instance)
+}
+
+func (sr *storingStateRecorder) RecordStep(instance *Instance) {
+	sr.changes, _ = json.Marshal(instance.ChangeTracker)
+}
+
+type failingKeyProvider struct {
+	testKeyProvider
+}
+
+func (kp *failingKeyProvider) CurrentKey() (string, []byte, error) {
+	return "", nil, errors.New("key unavailable")
+}
+
+//TestEncryptSensitiveAttrs
+func TestEncryptSensitiveAttrs(t *testing.T) {
+
+	provider := newTestFlowProvider(t)
+	def, _ := provider.GetFlow("test")
+
+	instance := NewFlowInstance("1", "test", def)
+	instance.AddAttr("password", data.STRING, "secret")
+	instance.AddAttr("user", data.STRING, "jdoe")
+
+	storage := &storingStateRecorder{}
+	keyProvider := &testKeyProvider{keys: map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")}}
+	recorder := NewEncryptingStateRecorder(storage, keyProvider, "password")
+
+	recorder.RecordSnapshot(instance)
+
+	// stored value is ciphertext, the running instance still has the plaintext
+	assert.False(t, strings.Contains(string(storage.snapshot), "secret"))
+	assert.True(t, strings.Contains(string(storage.snapshot), encValuePrefix+"k1:"))
+	assert.True(t, strings.Contains(string(storage.snapshot), "jdoe"))
+	assert.Equal(t, "secret", instance.Attrs["password"].Value)
+
+	restored := &Instance{}
+	err := json.Unmarshal(storage.snapshot, restored)
+	assert.Nil(t, err)
+	assert.NotEqual(t, "secret", restored.Attrs["password"].Value)
+
+	err = recorder.Decrypt(restored)
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", restored.Attrs["password"].Value)
+	assert.Equal(t, "jdoe", restored.Attrs["user"].Value)
+}
+
+// liveStateRecorder checks the attributes of the live instance while recording
+type liveStateRecorder struct {
+	live    *Instance
+	values  []interface{}
+	changes []byte
+}
+
+func (sr *liveStateRecorder) RecordSnapshot(instance *Instance) {
+	attr, _ := sr.live.GetAttr("password")
+	sr.values = append(sr.values, attr.Value)
+}
+
+func (sr *liveStateRecorder) RecordStep(instance *Instance) {
+	attr, _ := sr.live.GetAttr("password")
+	sr.values = append(sr.values, attr.Value)
+	sr.changes, _ = json.Marshal(instance.ChangeTracker)
+}
+
+//TestEncryptLeavesInstance
+func TestEncryptLeavesInstance(t *testing.T) {
+
+	provider := newTestFlowProvider(t)
+	def, _ := provider.GetFlow("test")
+
+	instance := NewFlowInstance("1", "test", def)
+	instance.AddAttr("password", data.STRING, "secret")
+
+	storage := &liveStateRecorder{live: instance}
+	keyProvider := &testKeyProvider{keys: map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")}}
+	recorder := NewEncryptingStateRecorder(storage, keyProvider, "password")
+
+	recorder.RecordSnapshot(instance)
+	recorder.RecordStep(instance)
+
+	// the live instance never sees the ciphertext
+	assert.Equal(t, []interface{}{"secret", "secret"}, storage.values)
+
+	// the changes of the step are encrypted
+	assert.NotEmpty(t, storage.changes)
+	assert.False(t, strings.Contains(string(storage.changes), "secret"))
+	assert.True(t, strings.Contains(string(storage.changes), encValuePrefix+"k1:"))
+}
+
+//TestDecryptOnRead
+func TestDecryptOnRead(t *testing.T) {
+
+	provider := newTestFlowProvider(t)
+	def, _ := provider.GetFlow("test")
+
+	instance := NewFlowInstance("1", "test", def)
+	instance.AddAttr("password", data.STRING, "secret")
+
+	storage := NewInMemoryStateRecorder()
+	keyProvider := &testKeyProvider{keys: map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")}}
+	recorder := NewEncryptingStateRecorder(storage, keyProvider, "password")
+
+	recorder.RecordSnapshot(instance)
+	recorder.RecordStep(instance)
+
+	// the storage only has the ciphertext
+	stored, err := storage.LoadSnapshot("1")
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(string(stored), "secret"))
+
+	snapshot, err := recorder.GetSnapshot("1")
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", snapshot.Attrs["password"].Value)
+
+	summaries, err := recorder.ListInstances(nil)
+	assert.Nil(t, err)
+	assert.Len(t, summaries, 1)
+
+	loaded, err := recorder.LoadSnapshot("1")
+	assert.Nil(t, err)
+	restored := &Instance{}
+	assert.Nil(t, json.Unmarshal(loaded, restored))
+	assert.Equal(t, "secret", restored.Attrs["password"].Value)
+
+	assert.Equal(t, []int{instance.StepID()}, recorder.RecordedSteps("1"))
+	step, err := recorder.LoadStep("1", instance.StepID())
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(step), "secret"))
+
+	// the attribute changes of a step are decrypted too
+	instance.ChangeTracker.AttrChange(CtUpd, instance.Attrs["password"])
+	encrypted, err := recorder.encryptedCopy(instance)
+	assert.Nil(t, err)
+	assert.NotEqual(t, "secret", encrypted.ChangeTracker.instChange.AttrChanges[0].Attribute.Value)
+
+	assert.Nil(t, recorder.Decrypt(encrypted))
+	assert.Equal(t, "secret", encrypted.ChangeTracker.instChange.AttrChanges[0].Attribute.Value)
+
+	// reading requires a readable storage
+	_, err = NewEncryptingStateRecorder(&storingStateRecorder{}, keyProvider).GetSnapshot("1")
+	assert.NotNil(t, err)
+}
+
+//TestEncryptTaskDataAndErrors
+func TestEncryptTaskDataAndErrors(t *testing.T) {
+
+	provider := newTestFlowProvider(t)
+	def, _ := provider.GetFlow("test")
+
+	instance := NewFlowInstance("1", "test", def)
+	instance.SetRecordLinkDecisions(true)
+
+	taskData := instance.RootTaskEnv.NewTaskData(def.GetTask(2))
+	taskData.attrs = map[string]*data.Attribute{"password": data.NewAttribute("password", data.STRING, "secret")}
+
+	// the TaskData of a task that is no longer in the environment
+	removed := NewTaskData(instance.RootTaskEnv, def.RootTask())
+	removed.attrs = map[string]*data.Attribute{"password": data.NewAttribute("password", data.STRING, "secret")}
+	instance.ChangeTracker.trackTaskData(&TaskDataChange{ChgType: CtDel, ID: def.RootTask().ID(), TaskData: removed})
+
+	instance.ChangeTracker.trackMappedInputs(2, []*data.Attribute{data.NewAttribute("password", data.STRING, "secret")})
+	instance.setLastError(errors.New("invalid password 'secret'"))
+	instance.linkDecisions = append(instance.linkDecisions, &LinkDecision{LinkID: 1, Error: "unexpected 'secret'"})
+
+	storage := &storingStateRecorder{}
+	keyProvider := &testKeyProvider{keys: map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")}}
+	recorder := NewEncryptingStateRecorder(storage, keyProvider, "password")
+
+	recorder.RecordSnapshot(instance)
+	recorder.RecordStep(instance)
+
+	assert.NotEmpty(t, storage.snapshot)
+	assert.False(t, strings.Contains(string(storage.snapshot), "secret"))
+	assert.NotEmpty(t, storage.changes)
+	assert.False(t, strings.Contains(string(storage.changes), "secret"))
+
+	// the instance keeps its plaintext
+	assert.Equal(t, "secret", taskData.attrs["password"].Value)
+	assert.Equal(t, "secret", removed.attrs["password"].Value)
+	assert.Equal(t, "secret", instance.ChangeTracker.MappedInputs()[0].Inputs[0].Value)
+	assert.Equal(t, "invalid password 'secret'", instance.LastError().Error())
+	assert.Equal(t, "unexpected 'secret'", instance.LinkDecisions()[0].Error)
+
+	encrypted, err := recorder.encryptedCopy(instance)
+	assert.Nil(t, err)
+	assert.Nil(t, recorder.Decrypt(encrypted))
+	assert.Equal(t, "secret", encrypted.RootTaskEnv.TaskDatas[2].attrs["password"].Value)
+	assert.Equal(t, "secret", encrypted.ChangeTracker.tdChanges[def.RootTask().ID()].TaskData.attrs["password"].Value)
+	assert.Equal(t, "secret", encrypted.ChangeTracker.miChanges[0].Inputs[0].Value)
+	assert.Equal(t, "invalid password 'secret'", encrypted.lastError.Error())
+	assert.Equal(t, "unexpected 'secret'", encrypted.linkDecisions[0].Error)
+
+	// the serialized snapshots are decrypted too
+	decrypted, err := recorder.decryptSnapshot(storage.snapshot)
+	assert.Nil(t, err)
+	restored := &Instance{}
+	assert.Nil(t, json.Unmarshal(decrypted, restored))
+	assert.Equal(t, "secret", restored.RootTaskEnv.TaskDatas[2].attrs["password"].Value)
+	assert.Equal(t, "invalid password 'secret'", restored.lastError.Error())
+	assert.Equal(t, "unexpected 'secret'", restored.linkDecisions[0].Error)
+}
+
+//TestEncryptionFailureNotRecorded
+func TestEncryptionFailureNotRecorded(t *testing.T) {
+
+	provider := newTestFlowProvider(t)
+	def, _ := provider.GetFlow("test")
+
+	instance := NewFlowInstance("1", "test", def)
+	instance.AddAttr("password", data.STRING, "secret")
+
+	storage := &storingStateRecorder{}
+	recorder := NewEncryptingStateRecorder(storage, &failingKeyProvider{}, "password")
+
+	recorder.RecordSnapshot(instance)
+	recorder.RecordStep(instance)
+
+	assert.Nil(t, storage.snapshot)
+	assert.Nil(t, storage.changes)
+
+	_, err := recorder.encryptedCopy(instance)
+	assert.NotNil(t, err)
+}
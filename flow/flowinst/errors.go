@@ -0,0 +1,178 @@
+package flowinst
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrFlowNotFound is matched by the errors of runs of flows that the
+	// flow provider doesn't know, see FlowNotFoundError
+	ErrFlowNotFound = errors.New("flow not found")
+
+	// ErrResumeOptionsMissing is matched by the errors of AoResume runs
+	// without an InitialState, see RunOptionsError
+	ErrResumeOptionsMissing = errors.New("resume options not provided")
+
+	// ErrRestartOptionsMissing is matched by the errors of AoRestart runs
+	// without an InitialState, see RunOptionsError
+	ErrRestartOptionsMissing = errors.New("restart options not provided")
+)
+
+// FlowNotFoundError is the error of a run of a flow that the flow provider
+// doesn't know, it matches ErrFlowNotFound
+type FlowNotFoundError struct {
+	URI string
+
+	// Err is the error of the flow provider, if any
+	Err error
+}
+
+// Error implements error.Error
+func (e *FlowNotFoundError) Error() string {
+	return fmt.Sprintf("Flow [%s] not found", e.URI)
+}
+
+// Is matches ErrFlowNotFound
+func (e *FlowNotFoundError) Is(target error) bool {
+	return target == ErrFlowNotFound
+}
+
+// Unwrap returns the error of the flow provider
+func (e *FlowNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// RunOptionsError is the error of a resume or restart that wasn't provided
+// the state of the instance, it matches ErrResumeOptionsMissing or
+// ErrRestartOptionsMissing depending on the Op
+type RunOptionsError struct {
+	Op  int
+	URI string
+}
+
+// Error implements error.Error
+func (e *RunOptionsError) Error() string {
+
+	if e.Op == AoRestart {
+		return "Unable to restart instance, restart options not provided"
+	}
+
+	return "Unable to resume instance, resume options not provided"
+}
+
+// Is matches the sentinel error of the Op
+func (e *RunOptionsError) Is(target error) bool {
+
+	if e.Op == AoRestart {
+		return target == ErrRestartOptionsMissing
+	}
+
+	return target == ErrResumeOptionsMissing
+}
+
+// StepTimeoutError is the error of an instance whose step didn't complete
+// within the StepTimeout
+type StepTimeoutError struct {
+	InstanceID string
+	Task       string
+	Activity   string
+	Timeout    time.Duration
+}
+
+// Error implements error.Error
+func (e *StepTimeoutError) Error() string {
+	return fmt.Sprintf("Flow [%s] step timed out after %v executing task '%s' (activity '%s')", e.InstanceID, e.Timeout, e.Task, e.Activity)
+}
+
+// ErrInvalidInputs is matched by the errors of starts whose trigger
+// attributes don't match the inputs declared by the flow, see
+// InputValidationError
+var ErrInvalidInputs = errors.New("invalid flow inputs")
+
+// InputValidationError is the error of a start whose trigger attributes don't
+// match the inputs declared by the flow, it matches ErrInvalidInputs
+type InputValidationError struct {
+	URI string
+
+	// Problems describe the missing and mistyped inputs
+	Problems []string
+}
+
+// Error implements error.Error
+func (e *InputValidationError) Error() string {
+	return fmt.Sprintf("Flow [%s] has invalid inputs: %s", e.URI, strings.Join(e.Problems, ", "))
+}
+
+// Is matches ErrInvalidInputs
+func (e *InputValidationError) Is(target error) bool {
+	return target == ErrInvalidInputs
+}
+
+// ErrInvalidFlow is matched by the errors of dry runs that found problems
+// with the flow, see DryRunError
+var ErrInvalidFlow = errors.New("invalid flow")
+
+// DryRunError is the error of a dry run of a flow, it consolidates all the
+// problems found and matches ErrInvalidFlow
+type DryRunError struct {
+	URI string
+
+	// Problems describe the invalid inputs, links and tasks of the flow
+	Problems []string
+}
+
+// Error implements error.Error
+func (e *DryRunError) Error() string {
+	return fmt.Sprintf("Flow [%s] failed the dry run: %s", e.URI, strings.Join(e.Problems, ", "))
+}
+
+// Is matches ErrInvalidFlow
+func (e *DryRunError) Is(target error) bool {
+	return target == ErrInvalidFlow
+}
+
+// EvictedError is the error reported to the caller of a run whose instance
+// was evicted, see FlowAction.Evict
+type EvictedError struct {
+	InstanceID string
+}
+
+// Error implements error.Error
+func (e *EvictedError) Error() string {
+	return fmt.Sprintf("Flow [%s] evicted before it completed", e.InstanceID)
+}
+
+// ReplyTimeoutError is the error reported to the caller of a run whose
+// instance didn't reply within the ReplyTimeout
+type ReplyTimeoutError struct {
+	InstanceID string
+	Timeout    time.Duration
+}
+
+// Error implements error.Error
+func (e *ReplyTimeoutError) Error() string {
+	return fmt.Sprintf("Flow [%s] didn't reply within %v", e.InstanceID, e.Timeout)
+}
+
+// ParentCancelledError is the error of a subflow cancelled because its parent
+// was cancelled or timed out, as opposed to cancelled on its own
+type ParentCancelledError struct {
+	InstanceID string
+	ParentID   string
+
+	// Err is the error of the context of the parent
+	Err error
+}
+
+// Error implements error.Error
+func (e *ParentCancelledError) Error() string {
+	return fmt.Sprintf("Flow [%s] cancelled with its parent [%s] - %s", e.InstanceID, e.ParentID, e.Err.Error())
+}
+
+// Unwrap returns the error of the context of the parent
+func (e *ParentCancelledError) Unwrap() error {
+	return e.Err
+}
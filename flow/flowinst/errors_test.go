@@ -0,0 +1,39 @@
+package flowinst
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestRunErrors
+func TestRunErrors(t *testing.T) {
+
+	fa := NewFlowAction(newTestFlowProvider(t), nil, nil)
+
+	err := fa.Run(nil, "missing", nil, newTestResultHandler())
+	assert.True(t, errors.Is(err, ErrFlowNotFound))
+	assert.Equal(t, "Flow [missing] not found", err.Error())
+
+	var notFound *FlowNotFoundError
+	assert.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "missing", notFound.URI)
+
+	_, errs := fa.StartBatch(nil, "missing", [][]*data.Attribute{nil})
+	assert.True(t, errors.Is(errs[0], ErrFlowNotFound))
+
+	err = fa.Run(nil, "test", &RunOptions{Op: AoResume}, newTestResultHandler())
+	assert.True(t, errors.Is(err, ErrResumeOptionsMissing))
+	assert.False(t, errors.Is(err, ErrRestartOptionsMissing))
+	assert.Equal(t, "Unable to resume instance, resume options not provided", err.Error())
+
+	err = fa.Run(nil, "test", &RunOptions{Op: AoRestart}, newTestResultHandler())
+	assert.True(t, errors.Is(err, ErrRestartOptionsMissing))
+
+	var optionsErr *RunOptionsError
+	assert.True(t, errors.As(err, &optionsErr))
+	assert.Equal(t, AoRestart, optionsErr.Op)
+	assert.Equal(t, "test", optionsErr.URI)
+}
@@ -0,0 +1,129 @@
+package flowinst
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// liveRun is an instance that is being executed by the FlowAction
+type liveRun struct {
+	instance *Instance
+	cancel   context.CancelFunc
+
+	// status of the instance as of its last step, safe to read while the
+	// instance is executing
+	status int32
+
+	// lastProgress is the time, in unix nanoseconds, the last step of the
+	// instance returned or the instance was registered
+	lastProgress int64
+
+	evict     chan struct{}
+	evictOnce sync.Once
+
+	// stopped is closed once the run is done with the instance
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	// resumed is closed when the paused run is resumed, nil when the run
+	// isn't paused
+	pauseMu sync.Mutex
+	resumed chan struct{}
+
+	// deadline of the run if its clock stops while the run is paused
+	deadline *pausableDeadline
+}
+
+func (lr *liveRun) requestEvict() {
+	lr.evictOnce.Do(func() { close(lr.evict) })
+}
+
+func (lr *liveRun) evictRequested() bool {
+	select {
+	case <-lr.evict:
+		return true
+	default:
+		return false
+	}
+}
+
+func (lr *liveRun) setStatus(status Status) {
+	atomic.StoreInt32(&lr.status, int32(status))
+}
+
+func (lr *liveRun) lastStatus() Status {
+	return Status(atomic.LoadInt32(&lr.status))
+}
+
+func (lr *liveRun) setProgress(now time.Time) {
+	atomic.StoreInt64(&lr.lastProgress, now.UnixNano())
+}
+
+func (lr *liveRun) progressedAt() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&lr.lastProgress))
+}
+
+func (lr *liveRun) stop() {
+	lr.stopOnce.Do(func() { close(lr.stopped) })
+}
+
+// register adds the instance to the live runs of the FlowAction
+func (fa *FlowAction) register(instance *Instance, cancel context.CancelFunc, deadline *pausableDeadline) *liveRun {
+
+	run := &liveRun{instance: instance, cancel: cancel, evict: make(chan struct{}), stopped: make(chan struct{}), deadline: deadline}
+
+	run.setStatus(instance.Status())
+	run.setProgress(fa.actionOptions.Clock.Now())
+
+	fa.liveMu.Lock()
+	fa.live[instance.ID()] = run
+	fa.liveMu.Unlock()
+
+	return run
+}
+
+// unregister removes the instance from the live runs of the FlowAction
+func (fa *FlowAction) unregister(run *liveRun) {
+
+	fa.liveMu.Lock()
+	if fa.live[run.instance.ID()] == run {
+		delete(fa.live, run.instance.ID())
+	}
+	fa.liveMu.Unlock()
+
+	run.stop()
+}
+
+// Evict stops the specified in-flight instance after its current step and
+// returns its serialized state, the instance can later be resumed from the
+// state using AoResume.  The caller of the run gets an EvictedError with
+// CodeEvicted
+func (fa *FlowAction) Evict(id string) (state []byte, err error) {
+
+	fa.liveMu.Lock()
+	run, exists := fa.live[id]
+	fa.liveMu.Unlock()
+
+	if !exists {
+		return nil, fmt.Errorf("Flow instance [%s] is not running", id)
+	}
+
+	run.requestEvict()
+
+	// the state is serialized once the run is done with the instance
+	<-run.stopped
+
+	if run.instance.Status().IsTerminal() {
+		return nil, fmt.Errorf("Flow instance [%s] finished before it could be evicted", id)
+	}
+
+	logger.Infof("Flow [%s] Evicted [correlation: %s]", id, run.instance.CorrelationID())
+
+	return json.Marshal(run.instance)
+}
@@ -0,0 +1,84 @@
+package flowinst
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestEvict
+func TestEvict(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		AddTask(4, 2, "c", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+
+	// 'b' and 'c' are executed after 'a'
+	fa := NewFlowAction(provider, nil, &ActionOptions{TaskScheduler: &FIFOTaskScheduler{}})
+
+	errHandler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(nil, "gated", nil, errHandler)
+	assert.Nil(t, err)
+
+	// the instance is in the middle of executing 'a'
+	<-gate.entered
+
+	fa.liveMu.Lock()
+	assert.Len(t, fa.live, 1)
+	var id string
+	var run *liveRun
+	for id, run = range fa.live {
+	}
+	fa.liveMu.Unlock()
+
+	var state []byte
+	evicted := make(chan bool)
+
+	go func() {
+		state, err = fa.Evict(id)
+		evicted <- true
+	}()
+
+	waitFor(t, run.evictRequested)
+
+	gate.release <- true
+	<-evicted
+
+	// the caller was told about the eviction by the time Evict returns
+	assert.Nil(t, err)
+	assert.Empty(t, fa.live)
+	assert.Equal(t, []int{200, CodeEvicted}, errHandler.codes)
+	assert.Equal(t, &EvictedError{InstanceID: id}, errHandler.errors[1])
+
+	<-errHandler.done
+
+	_, err = fa.Evict(id)
+	assert.NotNil(t, err)
+
+	// resume the instance from its state, possibly on another node
+	instance := &Instance{}
+	err = json.Unmarshal(state, instance)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusActive, instance.Status())
+
+	recorder := &testStateRecorder{}
+	resumer := NewFlowAction(provider, recorder, &ActionOptions{Record: true})
+
+	handler := newTestResultHandler()
+	err = resumer.Run(nil, "gated", &RunOptions{Op: AoResume, InitialState: instance}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// only 'b' and 'c' were left to execute
+	assert.Equal(t, 2, recorder.steps)
+	assert.Equal(t, id, recorder.instance.ID())
+	assert.Equal(t, StatusCompleted, recorder.instance.Status())
+}
@@ -1,6 +1,9 @@
 package flowinst
 
 import (
+	"sort"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
 	"github.com/TIBCOSoftware/flogo-lib/flow/support"
 	"github.com/TIBCOSoftware/flogo-lib/logger"
 )
@@ -9,6 +12,11 @@ import (
 type ExecOptions struct {
 	Patch       *support.Patch
 	Interceptor *support.Interceptor
+
+	// ActivityInputs override the inputs of activities, by task name, ie. to
+	// re-run a flow with tweaked parameters.  They are applied on top of the
+	// inputs of the Interceptor
+	ActivityInputs map[string]map[string]interface{}
 }
 
 // IDGenerator generates IDs for flow instances
@@ -34,5 +42,62 @@ func ApplyExecOptions(instance *Instance, execOptions *ExecOptions) {
 			instance.Interceptor = execOptions.Interceptor
 			instance.Interceptor.Init()
 		}
+
+		if len(execOptions.ActivityInputs) > 0 {
+			logger.Infof("Instance [%s] has activity input overrides", instance.ID())
+			instance.Interceptor = interceptActivityInputs(instance, execOptions.Interceptor, execOptions.ActivityInputs)
+			instance.Interceptor.Init()
+		}
+	}
+}
+
+// interceptActivityInputs creates an Interceptor that overrides the inputs of
+// the named tasks of the instance, on top of the specified interceptor which
+// is left untouched.  Names that don't match any task are logged
+func interceptActivityInputs(instance *Instance, interceptor *support.Interceptor, activityInputs map[string]map[string]interface{}) *support.Interceptor {
+
+	byID := make(map[int]*support.TaskInterceptor)
+	merged := &support.Interceptor{}
+
+	if interceptor != nil {
+		for _, ti := range interceptor.TaskInterceptors {
+			tiCopy := *ti
+			tiCopy.Inputs = append([]*data.Attribute(nil), ti.Inputs...)
+
+			byID[ti.ID] = &tiCopy
+			merged.TaskInterceptors = append(merged.TaskInterceptors, &tiCopy)
+		}
 	}
+
+	names := make([]string, 0, len(activityInputs))
+	for name := range activityInputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+
+		tasks := instance.Flow.GetTasksByName(name)
+
+		if len(tasks) == 0 {
+			logger.Warnf("Instance [%s] has input overrides for activity '%s', Flow [%s] has no such activity", instance.ID(), name, instance.FlowURI)
+			continue
+		}
+
+		for _, task := range tasks {
+
+			ti, exists := byID[task.ID()]
+			if !exists {
+				ti = &support.TaskInterceptor{ID: task.ID()}
+				byID[task.ID()] = ti
+				merged.TaskInterceptors = append(merged.TaskInterceptors, ti)
+			}
+
+			for attrName, value := range activityInputs[name] {
+				ti.Inputs = append(ti.Inputs, data.NewAttribute(attrName, data.ANY, value))
+			}
+		}
+	}
+
+	return merged
 }
@@ -0,0 +1,45 @@
+package flowinst
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/TIBCOSoftware/flogo-lib/flow/support"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestApplyActivityInputs
+func TestApplyActivityInputs(t *testing.T) {
+
+	def := newTestDefinition(t, mappedDefJSON)
+	instance := NewFlowInstance("1", "mapped", def)
+
+	interceptor := &support.Interceptor{TaskInterceptors: []*support.TaskInterceptor{
+		{ID: 2, Inputs: []*data.Attribute{data.NewAttribute("message", data.STRING, "intercepted")}},
+	}}
+
+	ApplyExecOptions(instance, &ExecOptions{
+		Interceptor: interceptor,
+		ActivityInputs: map[string]map[string]interface{}{
+			"echo":    {"level": "DEBUG"},
+			"missing": {"level": "WARN"},
+		},
+	})
+
+	ti := instance.Interceptor.GetTaskInterceptor(2)
+	assert.NotNil(t, ti)
+	assert.Equal(t, []*data.Attribute{
+		data.NewAttribute("message", data.STRING, "intercepted"),
+		data.NewAttribute("level", data.ANY, "DEBUG"),
+	}, ti.Inputs)
+
+	// the interceptor of the options is left untouched
+	assert.Len(t, interceptor.TaskInterceptors[0].Inputs, 1)
+
+	handler := newTestResultHandler()
+	fa := NewFlowAction(&testFlowProvider{flows: map[string]*flowdef.Definition{"mapped": def}}, nil, nil)
+	err := fa.Run(nil, "mapped", &RunOptions{ExecOptions: &ExecOptions{ActivityInputs: map[string]map[string]interface{}{"echo": {"level": "DEBUG"}}}}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+}
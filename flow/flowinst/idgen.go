@@ -0,0 +1,21 @@
+package flowinst
+
+import (
+	"github.com/TIBCOSoftware/flogo-lib/util"
+)
+
+// uuidGenerator is the IDGenerator used by default, backed by util.Generator
+type uuidGenerator struct {
+	generator *util.Generator
+}
+
+// NewFlowInstanceID implements IDGenerator.NewFlowInstanceID
+func (g *uuidGenerator) NewFlowInstanceID() string {
+	return g.generator.NextAsString()
+}
+
+// newUUIDGenerator creates the default IDGenerator, it panics if the generator
+// can't be seeded, as it would produce predictable IDs
+func newUUIDGenerator() IDGenerator {
+	return &uuidGenerator{generator: util.MustNewGenerator()}
+}
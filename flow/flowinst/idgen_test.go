@@ -0,0 +1,52 @@
+package flowinst
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sequentialIDGenerator generates sequential IDs
+type sequentialIDGenerator struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (g *sequentialIDGenerator) NewFlowInstanceID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.next++
+	return fmt.Sprintf("id-%d", g.next)
+}
+
+//TestIDGenerator
+func TestIDGenerator(t *testing.T) {
+
+	recorder := &testStateRecorder{}
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true, IDGenerator: &sequentialIDGenerator{}})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, "id-1", handler.results[0].(*IDResponse).ID)
+
+	// restarting mints a new ID as well
+	state, err := json.Marshal(recorder.instance)
+	assert.Nil(t, err)
+
+	instance := &Instance{}
+	err = json.Unmarshal(state, instance)
+	assert.Nil(t, err)
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "test", &RunOptions{Op: AoRestart, InitialState: instance}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, "id-2", handler.results[0].(*IDResponse).ID)
+}
@@ -0,0 +1,115 @@
+package flowinst
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+)
+
+// coalescedRun is an action.ResultHandler that shares the results of a single
+// run with all the callers that started it using the same idempotency key
+type coalescedRun struct {
+	lock     sync.Mutex
+	handlers []action.ResultHandler
+	results  []*runResult
+	done     bool
+	onDone   func()
+
+	// completed is the time the run completed, it is kept in the inflight
+	// runs until the RegistryTTL expires.  Guarded by the inflightMu of the
+	// FlowAction
+	completed time.Time
+}
+
+type runResult struct {
+	code int
+	data interface{}
+	err  error
+}
+
+func newCoalescedRun(onDone func()) *coalescedRun {
+	return &coalescedRun{onDone: onDone}
+}
+
+// add adds a caller to the run, any results already produced are replayed
+func (cr *coalescedRun) add(handler action.ResultHandler) {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+
+	for _, result := range cr.results {
+		handler.HandleResult(result.code, result.data, result.err)
+	}
+
+	if cr.done {
+		handler.Done()
+		return
+	}
+
+	cr.handlers = append(cr.handlers, handler)
+}
+
+// HandleResult implements action.ResultHandler.HandleResult
+func (cr *coalescedRun) HandleResult(code int, data interface{}, err error) {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+
+	cr.results = append(cr.results, &runResult{code: code, data: data, err: err})
+
+	for _, handler := range cr.handlers {
+		handler.HandleResult(code, data, err)
+	}
+}
+
+// Done implements action.ResultHandler.Done
+func (cr *coalescedRun) Done() {
+
+	if cr.onDone != nil {
+		cr.onDone()
+	}
+
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+
+	cr.done = true
+
+	for _, handler := range cr.handlers {
+		handler.Done()
+	}
+}
+
+// coalesce registers the handler for the in-flight run with the specified
+// key, returns false if there is no in-flight run and the caller should
+// start one using the returned handler.  With a RegistryTTL the completed run
+// is kept until it expires, the handlers registered meanwhile get its results
+func (fa *FlowAction) coalesce(key string, handler action.ResultHandler) (action.ResultHandler, bool) {
+	fa.inflightMu.Lock()
+	defer fa.inflightMu.Unlock()
+
+	if run, exists := fa.inflight[key]; exists {
+		run.add(handler)
+		return nil, true
+	}
+
+	var run *coalescedRun
+
+	run = newCoalescedRun(func() {
+		fa.inflightMu.Lock()
+		defer fa.inflightMu.Unlock()
+
+		if fa.actionOptions.RegistryTTL > 0 {
+			run.completed = fa.actionOptions.Clock.Now()
+			return
+		}
+
+		// the run could have been evicted and replaced by a newer one
+		if fa.inflight[key] == run {
+			delete(fa.inflight, key)
+		}
+	})
+
+	run.add(handler)
+	fa.inflight[key] = run
+
+	return run, false
+}
@@ -0,0 +1,50 @@
+package flowinst
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//TestCoalesceDuplicateStarts
+func TestCoalesceDuplicateStarts(t *testing.T) {
+
+	// the duplicates take neither a start token nor an ID
+	ids := &sequentialIDGenerator{}
+	limiter := NewStartRateLimiter(0.001, 1, StartRateLimitReject)
+
+	recorder := &blockingStateRecorder{release: make(chan bool)}
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true, IDGenerator: ids, StartRateLimiter: limiter})
+
+	handlers := make([]*testResultHandler, 10)
+
+	var wg sync.WaitGroup
+
+	for i := range handlers {
+		handlers[i] = newTestResultHandler()
+		wg.Add(1)
+
+		go func(handler *testResultHandler) {
+			defer wg.Done()
+			err := fa.Run(nil, "test", &RunOptions{IdempotencyKey: "key1"}, handler)
+			assert.Nil(t, err)
+		}(handlers[i])
+	}
+
+	wg.Wait()
+	close(recorder.release)
+
+	results := make(map[string]bool)
+
+	for _, handler := range handlers {
+		<-handler.done
+		for _, result := range handler.results {
+			results[result.(*IDResponse).ID] = true
+		}
+	}
+
+	assert.Equal(t, map[string]bool{"id-1": true}, results)
+	assert.Equal(t, 1, ids.next)
+	assert.Equal(t, 0, limiter.Rejected())
+}
@@ -0,0 +1,53 @@
+package flowinst
+
+import (
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+)
+
+// validateInputs checks the trigger attributes against the inputs declared by
+// the flow, the required inputs have to be present and the values have to be
+// convertible to the declared types
+func validateInputs(uri string, flow *flowdef.Definition, attrs []*data.Attribute) error {
+
+	inputs := flow.Inputs()
+
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	byName := make(map[string]*data.Attribute, len(attrs))
+	for _, attr := range attrs {
+		byName[attr.Name] = attr
+	}
+
+	var problems []string
+
+	for _, input := range inputs {
+
+		attr, exists := byName[input.Name]
+
+		if !exists || attr.Value == nil {
+			if input.Value == nil {
+				problems = append(problems, fmt.Sprintf("missing required input '%s'", input.Name))
+			}
+			continue
+		}
+
+		if input.Type == data.ANY {
+			continue
+		}
+
+		if _, err := data.CoerceToValue(attr.Value, input.Type); err != nil {
+			problems = append(problems, fmt.Sprintf("input '%s' is not of type %s", input.Name, input.Type.String()))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &InputValidationError{URI: uri, Problems: problems}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package flowinst
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestValidateInputs
+func TestValidateInputs(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("order").Model("budget").
+		AddInput("orderId", data.STRING, nil).
+		AddInput("quantity", data.INTEGER, nil).
+		AddInput("priority", data.INTEGER, 1).
+		AddTask(2, 2, "a", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"order": def}}
+	fa := NewFlowAction(provider, nil, &ActionOptions{ValidateInputs: true})
+
+	ctx := trigger.NewContext(context.Background(), []*data.Attribute{data.NewAttribute("quantity", data.ANY, "many")})
+
+	err = fa.Run(ctx, "order", nil, newTestResultHandler())
+	assert.EqualError(t, err, "Flow [order] has invalid inputs: missing required input 'orderId', input 'quantity' is not of type integer")
+	assert.True(t, errors.Is(err, ErrInvalidInputs))
+
+	// the optional inputs can be omitted
+	ctx = trigger.NewContext(context.Background(), []*data.Attribute{
+		data.NewAttribute("orderId", data.STRING, "order-1"),
+		data.NewAttribute("quantity", data.ANY, "2"),
+	})
+
+	handler := newTestResultHandler()
+	err = fa.Run(ctx, "order", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// validation can be requested per run
+	fa = NewFlowAction(provider, nil, nil)
+
+	err = fa.Run(nil, "order", &RunOptions{ValidateInputs: true}, newTestResultHandler())
+	assert.IsType(t, &InputValidationError{}, err)
+}
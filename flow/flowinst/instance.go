@@ -5,6 +5,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"sync"
+	"sync/atomic"
 
 	"github.com/TIBCOSoftware/flogo-lib/core/data"
 	"github.com/TIBCOSoftware/flogo-lib/flow/activity"
@@ -28,11 +29,13 @@ type Instance struct {
 	status      Status
 	state       int
 	FlowURI     string
+	flowVersion string
 	Flow        *flowdef.Definition
 	RootTaskEnv *TaskEnv
 	EhTaskEnv   *TaskEnv
 	FlowModel   *model.FlowModel
 	Attrs       map[string]*data.Attribute
+	attrLock    sync.RWMutex
 	Patch       *support.Patch
 	Interceptor *support.Interceptor
 
@@ -43,14 +46,62 @@ type Instance struct {
 
 	flowProvider flowdef.Provider
 	replyHandler support.ReplyHandler
+
+	sensitiveAttrs map[string]bool
+
+	errLock   sync.RWMutex
+	lastError error
+
+	scheduler TaskScheduler
+
+	// scheduled is the work item the TaskScheduler picked for the next step
+	scheduled *WorkItem
+
+	workUnits int64
+
+	correlationID string
+
+	recordMappedInputs bool
+
+	errorClassifier ErrorClassifier
+	maxTaskRetries  int
+	taskRetries     map[int]int
+	stepRetries     int
+
+	// loopWindow holds the IDs of the recently evaluated tasks
+	loopWindow []int
+
+	shadow        bool
+	shadowLock    sync.Mutex
+	shadowOutputs map[int]map[string]interface{}
+
+	decisionLock        sync.Mutex
+	linkDecisions       []*LinkDecision
+	recordLinkDecisions bool
+
+	timelineLock sync.Mutex
+	timeline     []TimelineEvent
+
+	panicFormatter PanicFormatter
+
+	parentID string
+	ancestry []string
+	subflows subflowRunner
+
+	// abandoned is the current step if it didn't complete within the
+	// StepTimeout
+	abandonLock sync.Mutex
+	abandoned   *abandonedStep
 }
 
 // New creates a new Flow Instance from the specified Flow
 func New(instanceID string, flowURI string, flow *flowdef.Definition, flowModel *model.FlowModel) *Instance {
 	var instance Instance
 	instance.id = instanceID
+	instance.correlationID = instanceID
 	instance.stepID = 0
 	instance.FlowURI = flowURI
+	instance.flowVersion = flow.Version()
 	instance.Flow = flow
 	instance.FlowModel = flowModel
 	instance.status = StatusNotStarted
@@ -66,6 +117,7 @@ func New(instanceID string, flowURI string, flow *flowdef.Definition, flowModel
 	taskEnv.LinkDatas = make(map[int]*LinkData)
 
 	instance.RootTaskEnv = &taskEnv
+	instance.addTimelineEvent(TimelineEvent{Type: TeCreated})
 
 	return &instance
 }
@@ -75,8 +127,10 @@ func NewFlowInstance(instanceID string, flowURI string, flow *flowdef.Definition
 
 	var instance Instance
 	instance.id = instanceID
+	instance.correlationID = instanceID
 	instance.stepID = 0
 	instance.FlowURI = flowURI
+	instance.flowVersion = flow.Version()
 	instance.Flow = flow
 	instance.FlowModel = model.Get(flow.ModelID())
 	instance.status = StatusNotStarted
@@ -92,22 +146,65 @@ func NewFlowInstance(instanceID string, flowURI string, flow *flowdef.Definition
 	taskEnv.LinkDatas = make(map[int]*LinkData)
 
 	instance.RootTaskEnv = &taskEnv
+	instance.addTimelineEvent(TimelineEvent{Type: TeCreated})
 
 	return &instance
 }
 
+// WorkUnits returns the work units accumulated by the instance
+func (pi *Instance) WorkUnits() int {
+	return int(atomic.LoadInt64(&pi.workUnits))
+}
+
+// AddWorkUnits adds to the work units accumulated by the instance
+func (pi *Instance) AddWorkUnits(units int) {
+	atomic.AddInt64(&pi.workUnits, int64(units))
+}
+
+// SetRecordMappedInputs enables tracking the inputs produced by the input
+// mappers of the tasks in the step changes, for debugging mappings
+func (pi *Instance) SetRecordMappedInputs(record bool) {
+	pi.recordMappedInputs = record
+}
+
+// SetTaskScheduler sets the TaskScheduler used to determine which of the ready
+// tasks is executed next
+func (pi *Instance) SetTaskScheduler(scheduler TaskScheduler) {
+	pi.scheduler = scheduler
+}
+
 // SetFlowProvider sets the process.Provider that the instance should use
 func (pi *Instance) SetFlowProvider(provider flowdef.Provider) {
 	pi.flowProvider = provider
 }
 
-// Restart indicates that this FlowInstance was restarted
-func (pi *Instance) Restart(id string, provider flowdef.Provider) {
+// Restart indicates that this FlowInstance was restarted, its definition is
+// looked up again with the version the instance was started with.  It fails
+// if the provider doesn't have that version anymore
+func (pi *Instance) Restart(id string, provider flowdef.Provider) error {
+
+	flow, err := flowdef.GetFlowVersion(provider, pi.FlowURI, pi.flowVersion)
+	if err != nil {
+		return err
+	}
+
+	if flow == nil {
+		return &FlowNotFoundError{URI: pi.FlowURI}
+	}
+
 	pi.id = id
 	pi.flowProvider = provider
-	pi.Flow, _ = pi.flowProvider.GetFlow(pi.FlowURI)
+	pi.Flow = flow
 	pi.FlowModel = model.Get(pi.Flow.ModelID())
 	pi.RootTaskEnv.init(pi)
+
+	return nil
+}
+
+// FlowVersion returns the version of the definition the instance was started
+// with, it is used to restart or resume the instance with the same version
+func (pi *Instance) FlowVersion() string {
+	return pi.flowVersion
 }
 
 // ID returns the ID of the Flow Instance
@@ -115,6 +212,30 @@ func (pi *Instance) ID() string {
 	return pi.id
 }
 
+// CorrelationID returns the ID that correlates all the executions of the
+// logical run of the instance, unlike the instance ID it is preserved when the
+// instance is restarted or resumed
+func (pi *Instance) CorrelationID() string {
+	return pi.correlationID
+}
+
+// SetCorrelationID sets the correlation ID of the instance
+func (pi *Instance) SetCorrelationID(correlationID string) {
+	pi.correlationID = correlationID
+}
+
+// ParentID returns the ID of the instance that started the instance as a
+// subflow, it is empty for instances that aren't subflows
+func (pi *Instance) ParentID() string {
+	return pi.parentID
+}
+
+// Ancestry returns the URIs of the flows of the ancestors of a subflow
+// instance, starting with the root
+func (pi *Instance) Ancestry() []string {
+	return pi.ancestry
+}
+
 // Name implements activity.FlowDetails.Name method
 func (pi *Instance) Name() string {
 	return pi.Flow.Name()
@@ -130,7 +251,9 @@ func (pi *Instance) SetReplyHandler(replyHandler support.ReplyHandler) {
 	pi.replyHandler = replyHandler
 }
 
-// FlowDefinition returns the Flow that the instance is of
+// FlowDefinition returns the Flow that the instance is of, it is pinned when
+// the instance is created so reloading the flow in the provider doesn't affect
+// an instance that is already running
 func (pi *Instance) FlowDefinition() *flowdef.Definition {
 	return pi.Flow
 }
@@ -147,8 +270,12 @@ func (pi *Instance) Status() Status {
 
 func (pi *Instance) setStatus(status Status) {
 
+	prevStatus := pi.status
+
 	pi.status = status
 	pi.ChangeTracker.SetStatus(status)
+
+	pi.trackStatus(prevStatus)
 }
 
 // State returns the state indicator of the Flow Instance
@@ -169,6 +296,9 @@ func (pi *Instance) UpdateAttrs(attrs []*data.Attribute) {
 
 		logger.Debugf("Updating flow attrs: %v", attrs)
 
+		pi.attrLock.Lock()
+		defer pi.attrLock.Unlock()
+
 		if pi.Attrs == nil {
 			pi.Attrs = make(map[string]*data.Attribute, len(attrs))
 		}
@@ -223,17 +353,17 @@ func (pi *Instance) DoStep() bool {
 	pi.ResetChanges()
 
 	pi.stepID++
+	pi.stepRetries = 0
 
 	if pi.status == StatusActive {
 
-		item, ok := pi.WorkItemQueue.Pop()
+		workItem, ok := pi.nextWorkItem()
 
 		if ok {
 			logger.Debug("popped item off queue")
 
-			workItem := item.(*WorkItem)
-
 			pi.ChangeTracker.trackWorkItem(&WorkItemQueueChange{ChgType: CtDel, ID: workItem.ID, WorkItem: workItem})
+			pi.trackStep(workItem)
 
 			pi.execTask(workItem)
 			hasNext = true
@@ -245,6 +375,43 @@ func (pi *Instance) DoStep() bool {
 	return hasNext
 }
 
+func (pi *Instance) setAbandonedStep(step *abandonedStep) {
+
+	pi.abandonLock.Lock()
+	defer pi.abandonLock.Unlock()
+
+	pi.abandoned = step
+}
+
+// takeAbandonedStep returns the abandoned step of the instance, if any, and
+// clears it
+func (pi *Instance) takeAbandonedStep() *abandonedStep {
+
+	pi.abandonLock.Lock()
+	defer pi.abandonLock.Unlock()
+
+	step := pi.abandoned
+	pi.abandoned = nil
+
+	return step
+}
+
+// LastError returns the most recent error produced by a step of the instance,
+// regardless of whether it was handled
+func (pi *Instance) LastError() error {
+	pi.errLock.RLock()
+	defer pi.errLock.RUnlock()
+
+	return pi.lastError
+}
+
+func (pi *Instance) setLastError(err error) {
+	pi.errLock.Lock()
+	defer pi.errLock.Unlock()
+
+	pi.lastError = err
+}
+
 // GetChanges returns the Change Tracker object
 func (pi *Instance) GetChanges() *InstanceChangeTracker {
 	return pi.ChangeTracker
@@ -278,11 +445,16 @@ func (pi *Instance) execTask(workItem *WorkItem) {
 	defer func() {
 		if r := recover(); r != nil {
 
-			err := fmt.Errorf("Unhandled Error executing task '%s' : %v\n", workItem.TaskData.task.Name(), r)
-			logger.Error(err)
+			stack := debug.Stack()
+
+			logger.Errorf("Unhandled Error executing task '%s' : %v\n", workItem.TaskData.task.Name(), r)
 
 			// todo: useful for debugging
-			logger.Debugf("StackTrace: %s", debug.Stack())
+			logger.Debugf("StackTrace: %s", stack)
+
+			err := pi.formatPanic(r, stack)
+
+			workItem.TaskData.prefetched = nil
 
 			pi.handleError(workItem.TaskData, activity.NewError(err.Error(),"", nil))
 		}
@@ -301,28 +473,47 @@ func (pi *Instance) execTask(workItem *WorkItem) {
 
 		eval := true
 
-		if taskData.HasAttrs() {
+		if taskData.prefetched != nil {
+			// the inputs were already applied, see DoParallelStep
+			eval = taskData.prefetched.eval
+		} else if taskData.HasAttrs() {
 
-			applyInputMapper(pi, taskData)
-			eval = applyInputInterceptor(pi, taskData)
+			// the task fails if its inputs can't be mapped
+			if err = applyInputMapper(pi, taskData); err == nil {
+				eval = applyInputInterceptor(pi, taskData)
+			} else {
+				eval = false
+			}
 		}
 
 		if eval {
 			done, doneCode, err = taskBehavior.Eval(taskData, workItem.EvalCode)
-		} else {
+		} else if err == nil {
 			done = true
 		}
+
+		taskData.prefetched = nil
 	} else {
 		done, doneCode, err = taskBehavior.PostEval(taskData, workItem.EvalCode, nil)
 	}
 
 	if err != nil {
+
+		if pi.shouldRetry(taskData, err) {
+			logger.Infof("Retrying task '%s' after retryable error - %s", taskData.task.Name(), err.Error())
+			pi.setLastError(err)
+			pi.scheduleEval(taskData, workItem.EvalCode)
+			return
+		}
+
 		pi.handleError(taskData, err)
 		return
 	}
 
 	if done {
 
+		delete(pi.taskRetries, taskData.task.ID())
+
 		if taskData.HasAttrs() {
 			applyOutputInterceptor(pi, taskData)
 
@@ -341,6 +532,8 @@ func (pi *Instance) execTask(workItem *WorkItem) {
 
 func (pi *Instance) handleError(taskData *TaskData, err error) {
 
+	pi.setLastError(err)
+
 	pi.AddAttr("{E.activity}", data.STRING, taskData.TaskName())
 	pi.AddAttr("{E.message}", data.STRING, err.Error())
 
@@ -447,15 +640,16 @@ func (pi *Instance) HandleError() {
 	}
 }
 
-// GetAttr implements data.Scope.GetAttr
+// GetAttr implements data.Scope.GetAttr, it is safe to call while the
+// instance is executing
 func (pi *Instance) GetAttr(attrName string) (value *data.Attribute, exists bool) {
 
-	if pi.Attrs != nil {
-		attr, found := pi.Attrs[attrName]
+	pi.attrLock.RLock()
+	attr, found := pi.Attrs[attrName]
+	pi.attrLock.RUnlock()
 
-		if found {
-			return attr, true
-		}
+	if found {
+		return attr, true
 	}
 
 	return pi.Flow.GetAttr(attrName)
@@ -463,18 +657,19 @@ func (pi *Instance) GetAttr(attrName string) (value *data.Attribute, exists bool
 
 // SetAttrValue implements api.Scope.SetAttrValue
 func (pi *Instance) SetAttrValue(attrName string, value interface{}) error {
-	if pi.Attrs == nil {
-		pi.Attrs = make(map[string]*data.Attribute)
-	}
 
 	logger.Debugf("SetAttr - name: %s, value:%v\n", attrName, value)
 
+	if isContextAttr(attrName) {
+		return fmt.Errorf("Attr [%s] is read-only", attrName)
+	}
+
 	existingAttr, exists := pi.GetAttr(attrName)
 
 	//todo: optimize, use existing attr
 	if exists {
 		attr := data.NewAttribute(attrName, existingAttr.Type, value)
-		pi.Attrs[attrName] = attr
+		pi.putAttr(attr)
 		pi.ChangeTracker.AttrChange(CtUpd, attr)
 		return nil
 	}
@@ -484,9 +679,6 @@ func (pi *Instance) SetAttrValue(attrName string, value interface{}) error {
 
 // AddAttr add a new attribute to the instance
 func (pi *Instance) AddAttr(attrName string, attrType data.Type, value interface{}) *data.Attribute {
-	if pi.Attrs == nil {
-		pi.Attrs = make(map[string]*data.Attribute)
-	}
 
 	logger.Debugf("AddAttr - name: %s, type: %s, value:%v\n", attrName, attrType, value)
 
@@ -498,13 +690,57 @@ func (pi *Instance) AddAttr(attrName string, attrType data.Type, value interface
 		attr = existingAttr
 	} else {
 		attr = data.NewAttribute(attrName, attrType, value)
-		pi.Attrs[attrName] = attr
+		pi.putAttr(attr)
 		pi.ChangeTracker.AttrChange(CtAdd, attr)
 	}
 
 	return attr
 }
 
+// SetAttr sets the attribute of the instance, replacing the existing one with
+// the same name.  It can be used to seed the state of an instance
+func (pi *Instance) SetAttr(attr *data.Attribute) {
+
+	pi.attrLock.RLock()
+	_, exists := pi.Attrs[attr.Name]
+	pi.attrLock.RUnlock()
+
+	pi.putAttr(attr)
+
+	if exists {
+		pi.ChangeTracker.AttrChange(CtUpd, attr)
+	} else {
+		pi.ChangeTracker.AttrChange(CtAdd, attr)
+	}
+}
+
+// CopyAttrs returns a copy of the attributes of the instance, it is safe to
+// call while the instance is executing
+func (pi *Instance) CopyAttrs() map[string]*data.Attribute {
+
+	pi.attrLock.RLock()
+	defer pi.attrLock.RUnlock()
+
+	attrs := make(map[string]*data.Attribute, len(pi.Attrs))
+	for name, attr := range pi.Attrs {
+		attrs[name] = attr
+	}
+
+	return attrs
+}
+
+func (pi *Instance) putAttr(attr *data.Attribute) {
+
+	pi.attrLock.Lock()
+	defer pi.attrLock.Unlock()
+
+	if pi.Attrs == nil {
+		pi.Attrs = make(map[string]*data.Attribute)
+	}
+
+	pi.Attrs[attr.Name] = attr
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////
 // Task Environment
 
@@ -624,6 +860,8 @@ type TaskData struct {
 
 	changes int
 
+	prefetched *prefetchedEval
+
 	taskID int //needed for serialization
 }
 
@@ -809,6 +1047,10 @@ func (td *TaskData) EvalLink(link *flowdef.Link) (result bool, err error) {
 		}
 	}()
 
+	defer func() {
+		td.taskEnv.Instance.addLinkDecision(link, result, err)
+	}()
+
 	mgr := td.taskEnv.Instance.Flow.GetLinkExprManager()
 
 	if mgr != nil {
@@ -835,16 +1077,26 @@ func (td *TaskData) EvalActivity() (done bool, evalErr error) {
 		if r := recover(); r != nil {
 			logger.Warnf("Unhandled Error executing activity '%s'[%s] : %v\n", td.task.Name(), td.task.ActivityType(), r)
 
+			stack := debug.Stack()
+
 			// todo: useful for debugging
-			logger.Debugf("StackTrace: %s", debug.Stack())
+			logger.Debugf("StackTrace: %s", stack)
 
 			if evalErr == nil {
-				evalErr = activity.NewError(fmt.Sprintf("%v", r),"", nil)
+				evalErr = activity.NewError(td.taskEnv.Instance.formatPanic(r, stack).Error(), "", nil)
 				done = false
 			}
 		}
 	}()
 
+	if td.prefetched != nil && td.prefetched.ran {
+		return td.usePrefetched()
+	}
+
+	if td.taskEnv.Instance.shadow {
+		return td.evalShadowActivity(act)
+	}
+
 	done, evalErr = act.Eval(td)
 
 	return done, evalErr
@@ -867,6 +1119,23 @@ func (td *TaskData) TaskName() string {
 	return td.task.Name()
 }
 
+// AddWorkUnits implements activity.WorkUnitCounter.AddWorkUnits method
+func (td *TaskData) AddWorkUnits(units int) {
+	td.taskEnv.Instance.AddWorkUnits(units)
+}
+
+// RunSubflow implements activity.SubflowRunner.RunSubflow method
+func (td *TaskData) RunSubflow(uri string, inputs map[string]interface{}) (map[string]interface{}, error) {
+
+	instance := td.taskEnv.Instance
+
+	if instance.subflows == nil {
+		return nil, fmt.Errorf("Unable to run subflow [%s], Flow [%s] is not executed by a FlowAction", uri, instance.ID())
+	}
+
+	return instance.subflows(uri, inputs)
+}
+
 // InputScope get the InputScope of the task instance
 func (td *TaskData) InputScope() data.Scope {
 
@@ -1000,3 +1269,17 @@ func NewWorkItem(id int, taskData *TaskData, execType ExecType, evalCode int) *W
 
 	return &workItem
 }
+
+// unsetOutputs returns the names of the outputs of the flow that don't have a value
+func (pi *Instance) unsetOutputs() []string {
+
+	var unset []string
+
+	for _, output := range pi.Flow.Outputs() {
+		if attr, exists := pi.GetAttr(output.Name); !exists || attr.Value == nil {
+			unset = append(unset, output.Name)
+		}
+	}
+
+	return unset
+}
@@ -37,6 +37,13 @@ type LinkDataChange struct {
 	LinkData *LinkData
 }
 
+// MappedInputsChange holds the input values the input mapper of a task
+// produced during a step
+type MappedInputsChange struct {
+	TaskID int
+	Inputs []*data.Attribute
+}
+
 // InstanceChange represents a change to the instance
 type InstanceChange struct {
 	State       int
@@ -58,6 +65,8 @@ type InstanceChangeTracker struct {
 	tdChanges map[int]*TaskDataChange
 	ldChanges map[int]*LinkDataChange
 
+	miChanges []*MappedInputsChange
+
 	instChange *InstanceChange
 }
 
@@ -132,6 +141,17 @@ func (ict *InstanceChangeTracker) trackLinkData(ldChange *LinkDataChange) {
 	ict.ldChanges[ldChange.ID] = ldChange
 }
 
+// trackMappedInputs records the inputs produced by the input mapper of a task
+func (ict *InstanceChangeTracker) trackMappedInputs(taskID int, inputs []*data.Attribute) {
+	ict.miChanges = append(ict.miChanges, &MappedInputsChange{TaskID: taskID, Inputs: inputs})
+}
+
+// MappedInputs returns the inputs produced by the input mappers of the tasks
+// executed in the current step, they are only tracked when enabled on the instance
+func (ict *InstanceChangeTracker) MappedInputs() []*MappedInputsChange {
+	return ict.miChanges
+}
+
 // ResetChanges is used to reset any tracking data stored on instance objects
 func (ict *InstanceChangeTracker) ResetChanges() {
 
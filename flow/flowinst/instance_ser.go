@@ -2,6 +2,8 @@ package flowinst
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 
 	"github.com/TIBCOSoftware/flogo-lib/core/data"
 	"github.com/TIBCOSoftware/flogo-lib/util"
@@ -10,14 +12,29 @@ import (
 ////////////////////////////////////////////////////////////////////////////////////////////////////////
 // Flow Instance Serialization
 
+// SnapshotVersion is the version of the format of the serialized instances,
+// it is incremented when the format changes incompatibly.  Snapshots without
+// a version predate versioning and are treated as version 0
+const SnapshotVersion = 1
+
 type serInstance struct {
-	ID          string            `json:"id"`
-	Status      Status            `json:"status"`
-	State       int               `json:"state"`
-	FlowURI     string            `json:"flowUri"`
-	Attrs       []*data.Attribute `json:"attrs"`
-	WorkQueue   []*WorkItem       `json:"workQueue"`
-	RootTaskEnv *TaskEnv          `json:"rootTaskEnv"`
+	Version       int               `json:"version"`
+	ID            string            `json:"id"`
+	CorrelationID string            `json:"correlationId,omitempty"`
+	Status        Status            `json:"status"`
+	State         int               `json:"state"`
+	FlowURI       string            `json:"flowUri"`
+	FlowVersion   string            `json:"flowVersion,omitempty"`
+	Attrs         []*data.Attribute `json:"attrs"`
+	WorkQueue     []*WorkItem       `json:"workQueue"`
+	RootTaskEnv   *TaskEnv          `json:"rootTaskEnv"`
+	LastError     string            `json:"lastError,omitempty"`
+	WorkUnits     int               `json:"workUnits,omitempty"`
+	LinkDecisions []*LinkDecision   `json:"linkDecisions,omitempty"`
+	StepID        int               `json:"stepId,omitempty"`
+	WorkItemCount int               `json:"workItemCount,omitempty"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Ancestry      []string          `json:"ancestry,omitempty"`
 }
 
 // MarshalJSON overrides the default MarshalJSON for FlowInstance
@@ -29,20 +46,45 @@ func (pi *Instance) MarshalJSON() ([]byte, error) {
 		queue[i], _ = e.Value.(*WorkItem)
 	}
 
-	attrs := make([]*data.Attribute, 0, len(pi.Attrs))
+	instAttrs := pi.CopyAttrs()
+	attrs := make([]*data.Attribute, 0, len(instAttrs))
 
-	for _, value := range pi.Attrs {
+	for _, value := range instAttrs {
 		attrs = append(attrs, value)
 	}
 
+	var lastError string
+
+	if err := pi.LastError(); err != nil {
+		lastError = err.Error()
+	}
+
+	var linkDecisions []*LinkDecision
+
+	if pi.recordLinkDecisions {
+		pi.decisionLock.Lock()
+		linkDecisions = pi.linkDecisions
+		pi.decisionLock.Unlock()
+	}
+
 	return json.Marshal(&serInstance{
-		ID:          pi.id,
-		Status:      pi.status,
-		State:       pi.state,
-		Attrs:       attrs,
-		FlowURI:     pi.FlowURI,
-		WorkQueue:   queue,
-		RootTaskEnv: pi.RootTaskEnv,
+		Version:       SnapshotVersion,
+		ID:            pi.id,
+		CorrelationID: pi.correlationID,
+		Status:        pi.status,
+		State:         pi.state,
+		Attrs:         attrs,
+		FlowURI:       pi.FlowURI,
+		FlowVersion:   pi.flowVersion,
+		WorkQueue:     queue,
+		RootTaskEnv:   pi.RootTaskEnv,
+		LastError:     lastError,
+		WorkUnits:     pi.WorkUnits(),
+		LinkDecisions: linkDecisions,
+		StepID:        pi.stepID,
+		WorkItemCount: pi.wiCounter,
+		ParentID:      pi.parentID,
+		Ancestry:      pi.ancestry,
 	})
 }
 
@@ -58,11 +100,33 @@ func (pi *Instance) UnmarshalJSON(d []byte) error {
 		return err
 	}
 
+	if ser.Version > SnapshotVersion {
+		return fmt.Errorf("Unsupported snapshot version %d of Flow instance [%s], the latest supported version is %d", ser.Version, ser.ID, SnapshotVersion)
+	}
+
 	pi.id = ser.ID
+	pi.stepID = ser.StepID
+	pi.wiCounter = ser.WorkItemCount
+	pi.correlationID = ser.CorrelationID
+	pi.parentID = ser.ParentID
+	pi.ancestry = ser.Ancestry
+
+	if len(pi.correlationID) == 0 {
+		// recorded before correlation IDs were introduced
+		pi.correlationID = ser.ID
+	}
 	pi.status = ser.Status
 	pi.state = ser.State
 
 	pi.FlowURI = ser.FlowURI
+	pi.flowVersion = ser.FlowVersion
+	pi.workUnits = int64(ser.WorkUnits)
+	pi.linkDecisions = ser.LinkDecisions
+
+	if len(ser.LastError) > 0 {
+		pi.lastError = errors.New(ser.LastError)
+	}
+
 	//pi.Flow = pi.flowProvider.GetFlow(pi.FlowURI)
 	//pi.FlowModel = flowmodel.Get(pi.Flow.ModelID())
 
@@ -77,9 +141,17 @@ func (pi *Instance) UnmarshalJSON(d []byte) error {
 
 	pi.WorkItemQueue = util.NewSyncQueue()
 
-	for _, workItem := range ser.WorkQueue {
+	// the queue is serialized front first and items are pushed to the front,
+	// so they are pushed back in reverse to preserve their order
+	for i := len(ser.WorkQueue) - 1; i >= 0; i-- {
+		workItem := ser.WorkQueue[i]
 		workItem.TaskData = pi.RootTaskEnv.TaskDatas[workItem.TaskID]
 		pi.WorkItemQueue.Push(workItem)
+
+		// snapshots prior to version 1 don't have the work item count
+		if workItem.ID > pi.wiCounter {
+			pi.wiCounter = workItem.ID
+		}
 	}
 
 	pi.ChangeTracker = NewInstanceChangeTracker()
@@ -273,6 +345,7 @@ func (ict *InstanceChangeTracker) MarshalJSON() ([]byte, error) {
 		WqChanges   []*WorkItemQueueChange `json:"wqChanges"`
 		TdChanges   []*TaskDataChange      `json:"tdChanges"`
 		LdChanges   []*LinkDataChange      `json:"ldChanges"`
+		MiChanges   []*MappedInputsChange  `json:"miChanges,omitempty"`
 	}{
 		Status:      ict.instChange.Status,
 		State:       ict.instChange.State,
@@ -280,5 +353,6 @@ func (ict *InstanceChangeTracker) MarshalJSON() ([]byte, error) {
 		WqChanges:   wqc,
 		TdChanges:   tdc,
 		LdChanges:   ldc,
+		MiChanges:   ict.miChanges,
 	})
 }
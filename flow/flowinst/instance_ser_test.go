@@ -1,6 +1,11 @@
 package flowinst
 
 import (
+	"encoding/json"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
 )
 
 const defJSON = `
@@ -81,3 +86,112 @@ const defJSON = `
 //		log.Debugf("Changes: %s\n", string(json))
 //	}
 //}
+
+//TestCorrelationIDSurvivesResume
+func TestCorrelationIDSurvivesResume(t *testing.T) {
+
+	provider := newTestFlowProvider(t)
+	def, _ := provider.GetFlow("test")
+
+	instance := NewFlowInstance("1", "test", def)
+	instance.Start(nil)
+	instance.DoStep()
+
+	assert.Equal(t, "1", instance.CorrelationID())
+
+	b, err := json.Marshal(instance)
+	assert.Nil(t, err)
+
+	restored := &Instance{}
+	err = json.Unmarshal(b, restored)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", restored.CorrelationID())
+
+	// restarting assigns a new instance ID, but keeps the correlation ID
+	fa := NewFlowAction(provider, nil, nil)
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "test", &RunOptions{Op: AoRestart, InitialState: restored}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.NotEqual(t, "1", restored.ID())
+	assert.Equal(t, "1", restored.CorrelationID())
+}
+
+//TestRestartPreserveID
+func TestRestartPreserveID(t *testing.T) {
+
+	provider := newTestFlowProvider(t)
+	def, _ := provider.GetFlow("test")
+
+	instance := NewFlowInstance("1", "test", def)
+	instance.Start(nil)
+	instance.DoStep()
+
+	b, err := json.Marshal(instance)
+	assert.Nil(t, err)
+
+	restored := &Instance{}
+	err = json.Unmarshal(b, restored)
+	assert.Nil(t, err)
+
+	// the snapshots of the restarted instance replace the original ones
+	recorder := NewInMemoryStateRecorder()
+	fa := NewFlowAction(provider, recorder, &ActionOptions{Record: true})
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "test", &RunOptions{Op: AoRestart, InitialState: restored, PreserveID: true}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, "1", restored.ID())
+
+	snapshot, err := recorder.LoadSnapshot("1")
+	assert.Nil(t, err)
+	assert.NotNil(t, snapshot)
+}
+
+//TestSnapshotRoundTrip
+func TestSnapshotRoundTrip(t *testing.T) {
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"budget": newTestDefinition(t, budgetDefJSON)}}
+	def, _ := provider.GetFlow("budget")
+
+	instance := NewFlowInstance("1", "budget", def)
+	instance.Start(nil)
+	instance.DoStep()
+	instance.DoStep()
+
+	b, err := json.Marshal(instance)
+	assert.Nil(t, err)
+
+	ser := map[string]interface{}{}
+	json.Unmarshal(b, &ser)
+	assert.Equal(t, float64(SnapshotVersion), ser["version"])
+
+	restored := &Instance{}
+	err = json.Unmarshal(b, restored)
+	assert.Nil(t, err)
+	restored.Restart(restored.ID(), provider)
+
+	assert.Equal(t, instance.StepID(), restored.StepID())
+
+	// the restored instance continues exactly where the original left off
+	for {
+		hasWork := instance.DoStep()
+		assert.Equal(t, hasWork, restored.DoStep())
+
+		if !hasWork {
+			break
+		}
+	}
+
+	assert.Equal(t, StatusCompleted, restored.Status())
+	assert.Equal(t, instance.StepID(), restored.StepID())
+	assert.Equal(t, instance.WorkUnits(), restored.WorkUnits())
+
+	// snapshots of a newer format are rejected
+	err = json.Unmarshal([]byte(`{"version": 99, "id": "1"}`), &Instance{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,849 @@
+package flowinst
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/activity"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/TIBCOSoftware/flogo-lib/flow/model"
+	"github.com/TIBCOSoftware/flogo-lib/flow/test"
+	"github.com/stretchr/testify/assert"
+)
+
+const ehDefJSON = `
+{
+    "type": 1,
+    "name": "eh",
+    "model": "flaky",
+    "rootTask": {
+      "id": 1,
+      "type": 1,
+      "name": "root",
+      "tasks": [
+        {
+          "id": 2,
+          "type": 2,
+          "name": "flaky"
+        }
+      ]
+    },
+    "errorHandlerTask": {
+      "id": 10,
+      "type": 1,
+      "name": "eh"
+    }
+  }
+`
+
+var flaky = &flakyTaskBehavior{SimpleTaskBehavior: &test.SimpleTaskBehavior{}}
+
+func init() {
+	m := model.New("flaky")
+	m.RegisterFlowBehavior(&test.SimpleFlowBehavior{})
+	m.RegisterTaskBehavior(1, &test.SimpleTaskBehavior{})
+	m.RegisterTaskBehavior(2, flaky)
+	registerModel(m)
+}
+
+// flakyTaskBehavior fails the first time a task is evaluated
+type flakyTaskBehavior struct {
+	*test.SimpleTaskBehavior
+	evals int
+}
+
+func (b *flakyTaskBehavior) Eval(context model.TaskContext, evalCode int) (done bool, doneCode int, err error) {
+	b.evals++
+	if b.evals == 1 {
+		return false, 0, errors.New("transient error")
+	}
+	return b.SimpleTaskBehavior.Eval(context, evalCode)
+}
+
+func newTestDefinition(t *testing.T, defJSON string) *flowdef.Definition {
+
+	defRep := &flowdef.DefinitionRep{}
+	err := json.Unmarshal([]byte(defJSON), defRep)
+	assert.Nil(t, err)
+
+	def, err := flowdef.NewDefinition(defRep)
+	assert.Nil(t, err)
+
+	return def
+}
+
+//TestLastError
+func TestLastError(t *testing.T) {
+
+	flaky.evals = 0
+
+	instance := NewFlowInstance("1", "eh", newTestDefinition(t, ehDefJSON))
+	instance.Start(nil)
+
+	assert.Nil(t, instance.LastError())
+
+	for instance.DoStep() {
+	}
+
+	// the error was handled by the error handler, but is still reported
+	assert.Equal(t, StatusCompleted, instance.Status())
+	assert.NotNil(t, instance.LastError())
+	assert.Equal(t, "transient error", instance.LastError().Error())
+
+	snapshot, err := json.Marshal(instance)
+	assert.Nil(t, err)
+
+	restored := &Instance{}
+	err = json.Unmarshal(snapshot, restored)
+	assert.Nil(t, err)
+	assert.Equal(t, "transient error", restored.LastError().Error())
+}
+
+const twoChildrenDefJSON = `
+{
+    "type": 1,
+    "name": "twoChildren",
+    "model": "test",
+    "rootTask": {
+      "id": 1,
+      "type": 1,
+      "name": "root",
+      "tasks": [
+        { "id": 2, "type": 1, "name": "a" },
+        { "id": 3, "type": 1, "name": "b" }
+      ]
+    }
+  }
+`
+
+// recordingTaskScheduler records the tasks selected by the wrapped scheduler
+type recordingTaskScheduler struct {
+	scheduler TaskScheduler
+	executed  []string
+}
+
+func (s *recordingTaskScheduler) Next(instance *Instance, ready []*WorkItem) *WorkItem {
+	workItem := s.scheduler.Next(instance, ready)
+	s.executed = append(s.executed, workItem.TaskData.TaskName())
+	return workItem
+}
+
+//TestTaskScheduler
+func TestTaskScheduler(t *testing.T) {
+
+	def := newTestDefinition(t, twoChildrenDefJSON)
+
+	fifo := &recordingTaskScheduler{scheduler: &FIFOTaskScheduler{}}
+	instance := NewFlowInstance("1", "twoChildren", def)
+	instance.SetTaskScheduler(fifo)
+	instance.Start(nil)
+
+	for instance.DoStep() {
+	}
+
+	assert.Equal(t, []string{"root", "a"}, fifo.executed)
+}
+
+//TestDefaultTaskScheduler
+func TestDefaultTaskScheduler(t *testing.T) {
+
+	def := newTestDefinition(t, twoChildrenDefJSON)
+
+	// the default order is the order in which the work item queue is popped
+	instance := NewFlowInstance("1", "twoChildren", def)
+	instance.Start(nil)
+	instance.DoStep()
+
+	popped, ok := instance.WorkItemQueue.Pop()
+	assert.True(t, ok)
+	assert.Equal(t, "b", popped.(*WorkItem).TaskData.TaskName())
+
+	// an instance without a TaskScheduler executes the task popped first
+	instance = NewFlowInstance("2", "twoChildren", def)
+	instance.Start(nil)
+
+	for instance.DoStep() {
+	}
+
+	var tasks []string
+	for _, event := range instance.Timeline() {
+		if event.Type == TeStep {
+			tasks = append(tasks, event.TaskName)
+		}
+	}
+	assert.Equal(t, []string{"root", "b"}, tasks)
+}
+
+//TestDiffInstances
+func TestDiffInstances(t *testing.T) {
+
+	instance := NewFlowInstance("1", "test", newTestDefinition(t, simpleDefJSON))
+	instance.Start(nil)
+
+	instance.DoStep()
+	snapshot1, _ := json.Marshal(instance)
+
+	instance.AddAttr("result", data.STRING, "done")
+	instance.DoStep()
+	snapshot2, _ := json.Marshal(instance)
+
+	before := &Instance{}
+	json.Unmarshal(snapshot1, before)
+	after := &Instance{}
+	json.Unmarshal(snapshot2, after)
+
+	diff := DiffInstances(before, after)
+
+	assert.True(t, diff.HasChanges())
+	assert.True(t, diff.StatusChanged)
+	assert.Equal(t, StatusActive, diff.StatusBefore)
+	assert.Equal(t, StatusCompleted, diff.StatusAfter)
+
+	assert.Equal(t, 1, len(diff.Attrs))
+	assert.Equal(t, &AttrDiff{ChgType: CtAdd, Name: "result", After: "done"}, diff.Attrs[0])
+
+	// root and 'a' completed and were released
+	assert.Equal(t, 2, len(diff.Tasks))
+	assert.Equal(t, CtDel, diff.Tasks[0].ChgType)
+	assert.Equal(t, 1, diff.Tasks[0].TaskID)
+	assert.Equal(t, CtDel, diff.Tasks[1].ChgType)
+	assert.Equal(t, 2, diff.Tasks[1].TaskID)
+
+	assert.False(t, DiffInstances(after, after).HasChanges())
+}
+
+const retryDefJSON = `
+{
+    "type": 1,
+    "name": "retry",
+    "model": "retry",
+    "rootTask": {
+      "id": 1,
+      "type": 1,
+      "name": "root",
+      "tasks": [
+        {
+          "id": 2,
+          "type": 2,
+          "name": "failing"
+        }
+      ]
+    },
+    "errorHandlerTask": {
+      "id": 10,
+      "type": 1,
+      "name": "eh"
+    }
+  }
+`
+
+var errTooManyRequests = errors.New("429 Too Many Requests")
+
+// failingTaskBehavior fails the first evaluations of a task
+type failingTaskBehavior struct {
+	*test.SimpleTaskBehavior
+	failures int
+}
+
+func (b *failingTaskBehavior) Eval(context model.TaskContext, evalCode int) (done bool, doneCode int, err error) {
+	if b.failures > 0 {
+		b.failures--
+		return false, 0, errTooManyRequests
+	}
+	return b.SimpleTaskBehavior.Eval(context, evalCode)
+}
+
+func newRetryInstance(t *testing.T, failures int) (*Instance, *failingTaskBehavior) {
+
+	behavior := &failingTaskBehavior{SimpleTaskBehavior: &test.SimpleTaskBehavior{}, failures: failures}
+
+	m := model.New("retry")
+	m.RegisterFlowBehavior(&test.SimpleFlowBehavior{})
+	m.RegisterTaskBehavior(1, &test.SimpleTaskBehavior{})
+	m.RegisterTaskBehavior(2, behavior)
+
+	instance := NewFlowInstance("1", "retry", newTestDefinition(t, retryDefJSON))
+	instance.FlowModel = m
+
+	return instance, behavior
+}
+
+//TestErrorClassifier
+func TestErrorClassifier(t *testing.T) {
+
+	classifier := func(err error) bool {
+		return err == errTooManyRequests
+	}
+
+	// the error is classified as retryable, so the task is retried
+	instance, behavior := newRetryInstance(t, 2)
+	instance.SetErrorClassifier(classifier, 3)
+	instance.Start(nil)
+
+	for instance.DoStep() {
+	}
+
+	assert.Equal(t, 0, behavior.failures)
+	assert.Equal(t, StatusCompleted, instance.Status())
+	_, handled := instance.GetAttr("{E.message}")
+	assert.False(t, handled)
+
+	// by default nothing is retryable, the error handler handles the error
+	instance, behavior = newRetryInstance(t, 2)
+	instance.SetErrorClassifier(NoRetryClassifier, 3)
+	instance.Start(nil)
+
+	for instance.DoStep() {
+	}
+
+	assert.Equal(t, 1, behavior.failures)
+	_, handled = instance.GetAttr("{E.message}")
+	assert.True(t, handled)
+}
+
+const shadowDefJSON = `
+{
+    "type": 1,
+    "name": "shadow",
+    "model": "test",
+    "rootTask": {
+      "id": 1,
+      "type": 1,
+      "name": "root",
+      "tasks": [
+        { "id": 2, "type": 1, "activityType": "charge", "name": "charge", "ouputMappings": [] }
+      ]
+    }
+  }
+`
+
+// chargeActivity has a side effect, it counts the charges made
+type chargeActivity struct {
+	charges int
+}
+
+func (a *chargeActivity) Metadata() *activity.Metadata {
+	return &activity.Metadata{ID: "charge", Outputs: map[string]*data.Attribute{
+		"receipt": data.NewAttribute("receipt", data.STRING, nil),
+	}}
+}
+
+func (a *chargeActivity) Eval(context activity.Context) (done bool, err error) {
+	a.charges++
+	context.SetOutput("receipt", "real")
+	return true, nil
+}
+
+// chargeStub is the side effect free stub of the chargeActivity
+type chargeStub struct {
+}
+
+func (s *chargeStub) Metadata() *activity.Metadata {
+	return &activity.Metadata{ID: "charge"}
+}
+
+func (s *chargeStub) Eval(context activity.Context) (done bool, err error) {
+	context.SetOutput("receipt", "stubbed")
+	return true, nil
+}
+
+var charge = &chargeActivity{}
+
+func init() {
+	registerActivity(charge)
+	RegisterShadowStub("charge", &chargeStub{})
+}
+
+//TestShadowMode
+func TestShadowMode(t *testing.T) {
+
+	instance := NewFlowInstance("1", "shadow", newTestDefinition(t, shadowDefJSON))
+	instance.SetShadow(true)
+	instance.Start(nil)
+
+	for instance.DoStep() {
+	}
+
+	assert.Equal(t, StatusCompleted, instance.Status())
+	assert.Equal(t, 0, charge.charges)
+	assert.Equal(t, map[int]map[string]interface{}{2: {"receipt": "stubbed"}}, instance.ShadowOutputs())
+}
+
+// valueLinkExprManager follows the expression links whose value is "true"
+type valueLinkExprManager struct {
+}
+
+func (m *valueLinkExprManager) EvalLinkExpr(link *flowdef.Link, scope data.Scope) bool {
+	return link.Value() == "true"
+}
+
+//TestLinkDecisions
+func TestLinkDecisions(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("branch").Model("branch").
+		AddTask(2, 2, "a", "").
+		AddTask(3, 2, "b", "").
+		AddTask(4, 2, "c", "").
+		AddExprLink(2, 3, "true").
+		AddExprLink(2, 4, "false").
+		Build()
+	assert.Nil(t, err)
+
+	def.SetLinkExprManager(&valueLinkExprManager{})
+
+	m := model.New("branch")
+	m.RegisterFlowBehavior(&test.SimpleFlowBehavior{})
+	m.RegisterTaskBehavior(1, &test.SimpleTaskBehavior{})
+	m.RegisterTaskBehavior(2, &test.SimpleTaskBehavior{})
+
+	instance := NewFlowInstance("1", "branch", def)
+	instance.FlowModel = m
+	instance.Start(nil)
+
+	for instance.DoStep() {
+	}
+
+	decisions := instance.LinkDecisions()
+	assert.Len(t, decisions, 2)
+
+	byTarget := make(map[int]LinkDecision)
+	for _, decision := range decisions {
+		assert.Equal(t, 2, decision.FromTaskID)
+		assert.Equal(t, flowdef.LtExpression, decision.LinkType)
+		byTarget[decision.ToTaskID] = decision
+	}
+
+	assert.True(t, byTarget[3].Followed)
+	assert.Equal(t, "true", byTarget[3].Condition)
+	assert.False(t, byTarget[4].Followed)
+	assert.Equal(t, "false", byTarget[4].Condition)
+
+	// only included in the snapshot when enabled
+	b, err := json.Marshal(instance)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "linkDecisions")
+
+	instance.SetRecordLinkDecisions(true)
+	b, err = json.Marshal(instance)
+	assert.Nil(t, err)
+
+	restored := &Instance{}
+	err = json.Unmarshal(b, restored)
+	assert.Nil(t, err)
+	assert.Equal(t, decisions, restored.LinkDecisions())
+}
+
+//TestTimeline
+func TestTimeline(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("built").Model("budget").
+		AddTask(2, 2, "a", "").
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	instance := NewFlowInstance("1", "built", def)
+	instance.Start(nil)
+
+	for instance.DoStep() {
+	}
+
+	var types []TimelineEventType
+	var tasks []string
+
+	for _, event := range instance.Timeline() {
+		types = append(types, event.Type)
+		if event.Type == TeStep {
+			tasks = append(tasks, event.TaskName)
+		}
+	}
+
+	assert.Equal(t, []TimelineEventType{TeCreated, TeStatus, TeStep, TeStep, TeStep, TeStatus, TeFinished}, types)
+	assert.Equal(t, []string{"root", "b", "a"}, tasks)
+
+	timeline := instance.Timeline()
+
+	assert.Equal(t, StatusNotStarted, timeline[1].PrevStatus)
+	assert.Equal(t, StatusActive, timeline[1].Status)
+	assert.Equal(t, StatusActive, timeline[5].PrevStatus)
+	assert.Equal(t, StatusCompleted, timeline[5].Status)
+	assert.Equal(t, 3, timeline[6].StepID)
+
+	for i := 1; i < len(timeline); i++ {
+		assert.False(t, timeline[i].Time.Before(timeline[i-1].Time))
+	}
+
+	b, err := json.Marshal(timeline)
+	assert.Nil(t, err)
+
+	var unmarshalled []TimelineEvent
+	err = json.Unmarshal(b, &unmarshalled)
+	assert.Nil(t, err)
+	assert.Len(t, unmarshalled, len(timeline))
+	assert.Equal(t, "b", unmarshalled[3].TaskName)
+}
+
+// panickingTaskBehavior panics when evaluating a task
+type panickingTaskBehavior struct {
+	*test.SimpleTaskBehavior
+}
+
+func (b *panickingTaskBehavior) Eval(context model.TaskContext, evalCode int) (done bool, doneCode int, err error) {
+	panic("out of cheese")
+}
+
+//TestPanicFormatter
+func TestPanicFormatter(t *testing.T) {
+
+	m := model.New("panic")
+	m.RegisterFlowBehavior(&test.SimpleFlowBehavior{})
+	m.RegisterTaskBehavior(1, &test.SimpleTaskBehavior{})
+	m.RegisterTaskBehavior(2, &panickingTaskBehavior{SimpleTaskBehavior: &test.SimpleTaskBehavior{}})
+
+	instance := NewFlowInstance("1", "retry", newTestDefinition(t, retryDefJSON))
+	instance.FlowModel = m
+
+	var stackLen int
+
+	instance.SetPanicFormatter(func(recovered interface{}, stack []byte) error {
+		stackLen = len(stack)
+		return fmt.Errorf("PANIC[%v]", recovered)
+	})
+
+	instance.Start(nil)
+
+	for instance.DoStep() {
+	}
+
+	assert.NotNil(t, instance.LastError())
+	assert.Equal(t, "PANIC[out of cheese]", instance.LastError().Error())
+
+	message, _ := instance.GetAttr("{E.message}")
+	assert.Equal(t, "PANIC[out of cheese]", message.Value)
+	assert.True(t, stackLen > 0)
+}
+
+// rendezvousActivity only completes once the specified number of evaluations
+// are running at the same time
+type rendezvousActivity struct {
+	metadata *activity.Metadata
+
+	mu      sync.Mutex
+	arrived int
+	all     chan bool
+	parties int
+}
+
+func (a *rendezvousActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+func (a *rendezvousActivity) Eval(context activity.Context) (done bool, err error) {
+
+	a.mu.Lock()
+	a.arrived++
+	if a.arrived == a.parties {
+		close(a.all)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-a.all:
+	case <-time.After(time.Second):
+		return false, errors.New("evaluations didn't run concurrently")
+	}
+
+	context.SetOutput("value", context.TaskName())
+	return true, nil
+}
+
+var rendezvous = &rendezvousActivity{
+	metadata: &activity.Metadata{ID: "rendezvous", Outputs: map[string]*data.Attribute{"value": data.NewAttribute("value", data.STRING, nil)}},
+}
+
+func init() {
+	registerActivity(rendezvous)
+}
+
+//TestDoParallelStep
+func TestDoParallelStep(t *testing.T) {
+
+	rendezvous.parties = 2
+	rendezvous.arrived = 0
+	rendezvous.all = make(chan bool)
+
+	branch := func(id int, name string) *flowdef.TaskRep {
+		mapping := &data.MappingDef{Type: data.MtAssign, Value: "value", MapTo: name + "Value"}
+		return &flowdef.TaskRep{ID: id, TypeID: 2, Name: name, ActivityType: "rendezvous", OutputMappings: []*data.MappingDef{mapping}}
+	}
+
+	builder := flowdef.NewBuilder().Name("parallel").Model("budget").
+		AddAttr("aValue", data.STRING, nil).
+		AddAttr("bValue", data.STRING, nil).
+		AddTaskRep(branch(2, "a")).
+		AddTaskRep(branch(3, "b"))
+	builder.Rep().ErrorHandlerTask = &flowdef.TaskRep{ID: 10, TypeID: 1, Name: "eh"}
+
+	def, err := builder.Build()
+	assert.Nil(t, err)
+
+	instance := NewFlowInstance("1", "parallel", def)
+	instance.Start(nil)
+
+	steps := 0
+	for instance.DoParallelStep(2) {
+		steps++
+	}
+
+	assert.Nil(t, instance.LastError())
+	assert.Equal(t, StatusCompleted, instance.Status())
+
+	// the root, then both branches together
+	assert.Equal(t, 2, steps)
+
+	a, _ := instance.GetAttr("aValue")
+	assert.Equal(t, "a", a.Value)
+	b, _ := instance.GetAttr("bValue")
+	assert.Equal(t, "b", b.Value)
+
+	var tasks []string
+	for _, event := range instance.Timeline() {
+		if event.Type == TeStep {
+			tasks = append(tasks, event.TaskName)
+		}
+	}
+	assert.Equal(t, []string{"root", "b", "a"}, tasks)
+}
+
+// declineActivity always fails
+type declineActivity struct {
+}
+
+func (a *declineActivity) Metadata() *activity.Metadata {
+	return &activity.Metadata{ID: "decline"}
+}
+
+func (a *declineActivity) Eval(context activity.Context) (done bool, err error) {
+	return false, errors.New("declined")
+}
+
+// activityErrorTaskBehavior fails the task when its activity fails
+type activityErrorTaskBehavior struct {
+	*test.SimpleTaskBehavior
+}
+
+func (b *activityErrorTaskBehavior) Eval(context model.TaskContext, evalCode int) (done bool, doneCode int, err error) {
+	done, err = context.EvalActivity()
+	return done, 0, err
+}
+
+func init() {
+	registerActivity(&declineActivity{})
+
+	m := model.New("declining")
+	m.RegisterFlowBehavior(&test.SimpleFlowBehavior{})
+	m.RegisterTaskBehavior(1, &allChildrenTaskBehavior{SimpleTaskBehavior: &test.SimpleTaskBehavior{}})
+	m.RegisterTaskBehavior(2, &activityErrorTaskBehavior{SimpleTaskBehavior: &test.SimpleTaskBehavior{}})
+	registerModel(m)
+}
+
+//TestDoParallelStepFailure
+func TestDoParallelStepFailure(t *testing.T) {
+
+	charge.charges = 0
+
+	def, err := flowdef.NewBuilder().Name("declined").Model("declining").
+		AddTask(2, 2, "charge", "charge").
+		AddTask(3, 2, "decline", "decline").
+		Build()
+	assert.Nil(t, err)
+
+	instance := NewFlowInstance("1", "declined", def)
+	instance.Start(nil)
+
+	for instance.DoParallelStep(2) {
+	}
+
+	// without an error handler the failure ends the instance before the
+	// later work items run
+	assert.Equal(t, StatusFailed, instance.Status())
+	assert.Equal(t, 0, charge.charges)
+}
+
+//TestInstanceAttrs
+func TestInstanceAttrs(t *testing.T) {
+
+	instance := NewFlowInstance("1", "test", newTestDefinition(t, simpleDefJSON))
+
+	_, exists := instance.GetAttr("total")
+	assert.False(t, exists)
+
+	instance.SetAttr(data.NewAttribute("total", data.INTEGER, 10))
+
+	attr, exists := instance.GetAttr("total")
+	assert.True(t, exists)
+	assert.Equal(t, 10, attr.Value)
+
+	// the copy isn't affected by later changes
+	attrs := instance.CopyAttrs()
+	instance.SetAttr(data.NewAttribute("total", data.INTEGER, 20))
+
+	assert.Equal(t, 10, attrs["total"].Value)
+	attr, _ = instance.GetAttr("total")
+	assert.Equal(t, 20, attr.Value)
+
+	// concurrent reads while the attributes change
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			instance.SetAttr(data.NewAttribute(fmt.Sprintf("attr%d", i), data.INTEGER, i))
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			instance.GetAttr("total")
+			instance.CopyAttrs()
+		}
+	}()
+
+	wg.Wait()
+
+	assert.Equal(t, 101, len(instance.CopyAttrs()))
+}
+
+//TestStatusIsTerminal
+func TestStatusIsTerminal(t *testing.T) {
+
+	for _, status := range []Status{StatusNotStarted, StatusActive, StatusPaused} {
+		assert.False(t, status.IsTerminal(), status.String())
+	}
+
+	for _, status := range []Status{StatusCompleted, StatusCancelled, StatusFailed, StatusAborted, StatusTimedOut} {
+		assert.True(t, status.IsTerminal(), status.String())
+	}
+
+	assert.Equal(t, "timed_out", StatusTimedOut.String())
+	assert.Equal(t, "paused", StatusPaused.String())
+}
+
+//TestDefinitionPinned
+func TestDefinitionPinned(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	reloaded, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTask(2, 2, "c", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	recorder := &testStateRecorder{}
+	fa := NewFlowAction(provider, recorder, &ActionOptions{Record: true, TaskScheduler: &FIFOTaskScheduler{}})
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "gated", nil, handler)
+	assert.Nil(t, err)
+
+	// reload the flow in the provider while 'a' is executing
+	<-gate.entered
+	provider.flows["gated"] = reloaded
+	gate.release <- true
+	<-handler.done
+
+	// the instance finished the definition it was started with
+	assert.Equal(t, StatusCompleted, recorder.instance.Status())
+	assert.True(t, def == recorder.instance.FlowDefinition())
+
+	var tasks []string
+	for _, event := range recorder.instance.Timeline() {
+		if event.Type == TeStep {
+			tasks = append(tasks, event.TaskName)
+		}
+	}
+	assert.Equal(t, []string{"root", "a", "b"}, tasks)
+}
+
+// versionedFlowProvider keeps all the versions of its flows, the last added
+// is the current one
+type versionedFlowProvider struct {
+	*testFlowProvider
+	versions map[string]*flowdef.Definition
+}
+
+func (p *versionedFlowProvider) add(t *testing.T, flowURI string, version string) {
+
+	def, err := flowdef.NewBuilder().Name(flowURI).Version(version).Model("budget").
+		AddTask(2, 2, "a", "").
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	p.flows[flowURI] = def
+	p.versions[flowURI+"@"+version] = def
+}
+
+func (p *versionedFlowProvider) GetFlowVersion(flowURI string, version string) (*flowdef.Definition, error) {
+
+	def, ok := p.versions[flowURI+"@"+version]
+	if !ok {
+		return nil, &flowdef.VersionNotFoundError{URI: flowURI, Version: version}
+	}
+
+	return def, nil
+}
+
+//TestResumeFlowVersion
+func TestResumeFlowVersion(t *testing.T) {
+
+	provider := &versionedFlowProvider{testFlowProvider: &testFlowProvider{flows: map[string]*flowdef.Definition{}}, versions: map[string]*flowdef.Definition{}}
+	provider.add(t, "versioned", "1")
+
+	def, _ := provider.GetFlow("versioned")
+	instance := NewFlowInstance("1", "versioned", def)
+	instance.Start(nil)
+	instance.DoStep()
+
+	state, err := json.Marshal(instance)
+	assert.Nil(t, err)
+
+	// a new version is deployed
+	provider.add(t, "versioned", "2")
+
+	restored := &Instance{}
+	err = json.Unmarshal(state, restored)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", restored.FlowVersion())
+
+	fa := NewFlowAction(provider, nil, nil)
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "versioned", &RunOptions{Op: AoResume, InitialState: restored}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, "1", restored.FlowDefinition().Version())
+	assert.Equal(t, StatusCompleted, restored.Status())
+
+	// the version is gone
+	delete(provider.versions, "versioned@1")
+
+	restored = &Instance{}
+	json.Unmarshal(state, restored)
+
+	err = fa.Run(nil, "versioned", &RunOptions{Op: AoResume, InitialState: restored}, newTestResultHandler())
+	assert.EqualError(t, err, "Version '1' of Flow [versioned] not found")
+}
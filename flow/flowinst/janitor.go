@@ -0,0 +1,173 @@
+package flowinst
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"github.com/TIBCOSoftware/flogo-lib/util"
+)
+
+// minJanitorInterval bounds how often the janitor sweeps the registries,
+// whatever the RegistryTTL
+const minJanitorInterval = 10 * time.Millisecond
+
+// Expirable is an in-memory store whose entries are evicted by the janitor of
+// a FlowAction, see ActionOptions.RegistryTTL.  The StateRecorder and the
+// DeadLetterSink of the FlowAction are swept if they implement it
+type Expirable interface {
+
+	// EvictExpired evicts the entries of finished instances that were
+	// stamped before the cutoff, returns the number of entries evicted
+	EvictExpired(cutoff time.Time) int
+}
+
+// clockedStore is implemented by the in-memory stores that stamp their
+// entries, the FlowAction hands them its Clock so the entries expire on it
+type clockedStore interface {
+	useClock(clock Clock)
+}
+
+// storeClock guards the Clock the entries of an in-memory store are stamped
+// with, the system clock until the store is handed another one
+type storeClock struct {
+	mu    sync.Mutex
+	clock Clock
+}
+
+func (sc *storeClock) useClock(clock Clock) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.clock = clock
+}
+
+func (sc *storeClock) now() time.Time {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.clock == nil {
+		return util.SystemClock.Now()
+	}
+
+	return sc.clock.Now()
+}
+
+// startJanitor starts sweeping the registries, the sweeps are scheduled on
+// the Clock of the FlowAction
+func (fa *FlowAction) startJanitor(ttl time.Duration) {
+
+	fa.stopJanitor = make(chan struct{})
+
+	for _, store := range []interface{}{fa.stateRecorder, fa.actionOptions.DeadLetterSink} {
+		if clocked, ok := store.(clockedStore); ok {
+			clocked.useClock(fa.actionOptions.Clock)
+		}
+	}
+
+	interval := ttl / 2
+	if interval < minJanitorInterval {
+		interval = minJanitorInterval
+	}
+
+	fa.scheduleSweep(ttl, interval)
+}
+
+// scheduleSweep schedules the next sweep of the janitor, unless the
+// FlowAction was closed
+func (fa *FlowAction) scheduleSweep(ttl, interval time.Duration) {
+
+	fa.janitorMu.Lock()
+	defer fa.janitorMu.Unlock()
+
+	select {
+	case <-fa.stopJanitor:
+		return
+	default:
+	}
+
+	fa.cancelSweep = util.AfterFunc(fa.actionOptions.Clock, interval, func() {
+		if evicted := fa.sweep(ttl); evicted > 0 {
+			logger.Debugf("Evicted %d expired registry entries", evicted)
+		}
+
+		fa.scheduleSweep(ttl, interval)
+	})
+}
+
+// sweep evicts the registry entries that expired according to the Clock of
+// the FlowAction, returns the number of entries evicted
+func (fa *FlowAction) sweep(ttl time.Duration) int {
+
+	cutoff := fa.actionOptions.Clock.Now().Add(-ttl)
+
+	evicted := fa.evictCompletedRuns(cutoff) + fa.evictStoppedRuns()
+
+	if store, ok := fa.stateRecorder.(Expirable); ok {
+		evicted += store.EvictExpired(cutoff)
+	}
+
+	if store, ok := fa.actionOptions.DeadLetterSink.(Expirable); ok {
+		evicted += store.EvictExpired(cutoff)
+	}
+
+	return evicted
+}
+
+// evictCompletedRuns evicts the coalesced runs that completed before the
+// cutoff, the runs still executing are kept so their duplicates keep joining
+// them
+func (fa *FlowAction) evictCompletedRuns(cutoff time.Time) int {
+
+	fa.inflightMu.Lock()
+	defer fa.inflightMu.Unlock()
+
+	evicted := 0
+
+	for key, run := range fa.inflight {
+		if !run.completed.IsZero() && run.completed.Before(cutoff) {
+			delete(fa.inflight, key)
+			evicted++
+		}
+	}
+
+	return evicted
+}
+
+// evictStoppedRuns evicts the live runs that stopped executing but were left
+// registered
+func (fa *FlowAction) evictStoppedRuns() int {
+
+	fa.liveMu.Lock()
+	defer fa.liveMu.Unlock()
+
+	evicted := 0
+
+	for id, run := range fa.live {
+		select {
+		case <-run.stopped:
+			delete(fa.live, id)
+			evicted++
+		default:
+		}
+	}
+
+	return evicted
+}
+
+// Close stops the background work of the FlowAction
+func (fa *FlowAction) Close() error {
+
+	fa.closeOnce.Do(func() {
+		if fa.stopJanitor != nil {
+			fa.janitorMu.Lock()
+			close(fa.stopJanitor)
+			fa.cancelSweep()
+			fa.janitorMu.Unlock()
+		}
+
+		fa.stopAsyncRecorder()
+	})
+
+	return nil
+}
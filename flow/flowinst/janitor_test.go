@@ -0,0 +1,106 @@
+package flowinst
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//TestRegistryTTL
+func TestRegistryTTL(t *testing.T) {
+
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	// the janitor doesn't sweep before the end of the test, the sweeps are
+	// done by hand
+	fa := NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{RegistryTTL: 24 * time.Hour, Clock: clock})
+	defer fa.Close()
+
+	running, _ := fa.coalesce("running", newTestResultHandler())
+	completed, _ := fa.coalesce("completed", newTestResultHandler())
+	completed.Done()
+
+	// a duplicate of the completed run joins it until it expires
+	clock.advance(30 * time.Minute)
+	assert.Equal(t, 0, fa.sweep(time.Hour))
+
+	handler := newTestResultHandler()
+	_, inflight := fa.coalesce("completed", handler)
+	assert.True(t, inflight)
+	<-handler.done
+
+	// only the completed run is evicted, however old the running one is
+	clock.advance(time.Hour)
+	assert.Equal(t, 1, fa.sweep(time.Hour))
+
+	_, exists := fa.inflight["completed"]
+	assert.False(t, exists)
+	_, exists = fa.inflight["running"]
+	assert.True(t, exists)
+
+	running.Done()
+	clock.advance(2 * time.Hour)
+	assert.Equal(t, 1, fa.sweep(time.Hour))
+	assert.Empty(t, fa.inflight)
+
+	// the interval of the janitor is bounded
+	fa = NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{RegistryTTL: time.Nanosecond})
+	fa.Close()
+	fa.Close()
+}
+
+//TestJanitorClock
+func TestJanitorClock(t *testing.T) {
+
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	sink := NewInMemoryDeadLetterSink()
+	fa := NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{RegistryTTL: time.Hour, Clock: clock, DeadLetterSink: sink})
+
+	// the letters are stamped and swept on the clock of the action
+	sink.Send(&DeadLetter{InstanceID: "1"})
+
+	clock.advance(59 * time.Minute)
+	assert.Len(t, sink.Letters(), 1)
+
+	clock.advance(31 * time.Minute)
+	assert.Empty(t, sink.Letters())
+
+	// no sweep is scheduled once the action is closed
+	fa.Close()
+	sink.Send(&DeadLetter{InstanceID: "2"})
+	clock.advance(2 * time.Hour)
+	assert.Len(t, sink.Letters(), 1)
+}
+
+//TestEvictExpired
+func TestEvictExpired(t *testing.T) {
+
+	def := newTestFlowProvider(t).flows["test"]
+
+	finished := NewFlowInstance("finished", "test", def)
+	finished.setStatus(StatusCompleted)
+	executing := NewFlowInstance("executing", "test", def)
+	executing.setStatus(StatusActive)
+
+	recorder := NewInMemoryStateRecorder()
+	recorder.RecordSnapshot(finished)
+	recorder.RecordSnapshot(executing)
+
+	assert.Equal(t, 0, recorder.EvictExpired(time.Now().Add(-time.Hour)))
+
+	// the snapshots of the executing instance are kept
+	assert.Equal(t, 1, recorder.EvictExpired(time.Now()))
+
+	_, err := recorder.GetSnapshot("finished")
+	assert.Equal(t, ErrSnapshotNotFound, err)
+	_, err = recorder.GetSnapshot("executing")
+	assert.Nil(t, err)
+
+	sink := NewInMemoryDeadLetterSink()
+	sink.Send(&DeadLetter{InstanceID: "1"})
+	sink.Send(&DeadLetter{InstanceID: "2"})
+
+	assert.Equal(t, 0, sink.EvictExpired(time.Now().Add(-time.Hour)))
+	assert.Equal(t, 2, sink.EvictExpired(time.Now()))
+	assert.Empty(t, sink.Letters())
+}
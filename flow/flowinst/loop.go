@@ -0,0 +1,91 @@
+package flowinst
+
+import (
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// DefaultLoopWindow is the number of recent task evaluations considered by a
+// LoopDetection that doesn't specify a Window
+const DefaultLoopWindow = 100
+
+// LoopDetection determines when an instance is considered to be stuck in an
+// infinite loop
+type LoopDetection struct {
+	// MaxEntries is the number of times a task can be evaluated within the
+	// Window, the instance fails when the task is entered once more.  Defaults
+	// to half the Window
+	MaxEntries int
+
+	// Window is the number of recent task evaluations considered, defaults
+	// to DefaultLoopWindow
+	Window int
+}
+
+// LoopDetectedError is the error of an instance that re-entered a task more
+// than the MaxEntries of the LoopDetection
+type LoopDetectedError struct {
+	InstanceID string
+	TaskID     int
+	Task       string
+	Entries    int
+	Window     int
+}
+
+// Error implements error.Error
+func (e *LoopDetectedError) Error() string {
+	return fmt.Sprintf("Flow [%s] possible infinite loop at task '%s' (%d), entered %d times within %d evaluations", e.InstanceID, e.Task, e.TaskID, e.Entries, e.Window)
+}
+
+// loopDetection is the StepDecorator that fails instances that evaluate the
+// same task more often than allowed by the LoopDetection.  The window of an
+// instance isn't serialized, it starts over when the instance is resumed
+func (fa *FlowAction) loopDetection(next StepFunc) StepFunc {
+	return func(instance *Instance) bool {
+
+		workItem, ok := instance.peekWorkItem()
+		if !ok || workItem.ExecType != EtEval || workItem.TaskData == nil {
+			return next(instance)
+		}
+
+		detection := fa.actionOptions.LoopDetection
+
+		window := detection.Window
+		if window < 1 {
+			window = DefaultLoopWindow
+		}
+
+		maxEntries := detection.MaxEntries
+		if maxEntries < 1 {
+			maxEntries = window / 2
+			if maxEntries < 1 {
+				maxEntries = 1
+			}
+		}
+
+		taskID := workItem.TaskData.Task().ID()
+
+		instance.loopWindow = append(instance.loopWindow, taskID)
+		if len(instance.loopWindow) > window {
+			instance.loopWindow = instance.loopWindow[len(instance.loopWindow)-window:]
+		}
+
+		entries := 0
+		for _, id := range instance.loopWindow {
+			if id == taskID {
+				entries++
+			}
+		}
+
+		if entries > maxEntries {
+			err := &LoopDetectedError{InstanceID: instance.ID(), TaskID: taskID, Task: workItem.TaskData.Task().Name(), Entries: entries, Window: window}
+			logger.Warn(err.Error())
+			instance.setLastError(err)
+			instance.setStatus(StatusFailed)
+			return false
+		}
+
+		return next(instance)
+	}
+}
@@ -0,0 +1,114 @@
+package flowinst
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/TIBCOSoftware/flogo-lib/flow/model"
+	"github.com/TIBCOSoftware/flogo-lib/flow/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// reenteringTaskBehavior enters its task again each time it is done
+type reenteringTaskBehavior struct {
+	*test.SimpleTaskBehavior
+}
+
+func (b *reenteringTaskBehavior) Done(context model.TaskContext, doneCode int) (notifyParent bool, childDoneCode int, taskEntries []*model.TaskEntry) {
+	context.SetState(test.STATE_DONE)
+	return false, 0, []*model.TaskEntry{{Task: context.Task(), EnterCode: 0}}
+}
+
+func init() {
+	m := model.New("reentering")
+	m.RegisterFlowBehavior(&test.SimpleFlowBehavior{})
+	m.RegisterTaskBehavior(1, &test.SimpleTaskBehavior{})
+	m.RegisterTaskBehavior(2, &reenteringTaskBehavior{SimpleTaskBehavior: &test.SimpleTaskBehavior{}})
+	registerModel(m)
+}
+
+//TestLoopDetection
+func TestLoopDetection(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("spin").Model("reentering").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "spin", ActivityType: "workunits", OutputMappings: []*data.MappingDef{}}).
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"spin": def}}
+	sink := NewInMemoryDeadLetterSink()
+	fa := NewFlowAction(provider, nil, &ActionOptions{LoopDetection: &LoopDetection{MaxEntries: 5, Window: 10}, DeadLetterSink: sink})
+
+	code, result, err := fa.RunSync(nil, "spin", &RunOptions{ReturnResult: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 200, code)
+
+	flowResult := result.(*FlowResult)
+	assert.Equal(t, int(StatusFailed), flowResult.Status)
+
+	letters := sink.Letters()
+	assert.Len(t, letters, 1)
+	assert.IsType(t, &LoopDetectedError{}, letters[0].Err)
+	assert.EqualError(t, letters[0].Err, "Flow ["+flowResult.ID+"] possible infinite loop at task 'spin' (2), entered 6 times within 10 evaluations")
+}
+
+//TestLoopDetectionDefaultMaxEntries
+func TestLoopDetectionDefaultMaxEntries(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("spin").Model("reentering").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "spin", ActivityType: "workunits", OutputMappings: []*data.MappingDef{}}).
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"spin": def}}
+	sink := NewInMemoryDeadLetterSink()
+	fa := NewFlowAction(provider, nil, &ActionOptions{LoopDetection: &LoopDetection{Window: 10}, DeadLetterSink: sink})
+
+	_, result, err := fa.RunSync(nil, "spin", &RunOptions{ReturnResult: true})
+	assert.Nil(t, err)
+	assert.Equal(t, int(StatusFailed), result.(*FlowResult).Status)
+
+	// the task can be entered half the window
+	letters := sink.Letters()
+	assert.Len(t, letters, 1)
+	assert.Equal(t, 6, letters[0].Err.(*LoopDetectedError).Entries)
+}
+
+// roundRobinTaskScheduler picks a different ready work item every time it is
+// asked
+type roundRobinTaskScheduler struct {
+	calls int
+}
+
+func (s *roundRobinTaskScheduler) Next(instance *Instance, ready []*WorkItem) *WorkItem {
+	s.calls++
+	return ready[s.calls%len(ready)]
+}
+
+//TestPeekedWorkItemIsExecuted
+func TestPeekedWorkItemIsExecuted(t *testing.T) {
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"budget": newTestDefinition(t, budgetDefJSON)}}
+	fa := NewFlowAction(provider, nil, &ActionOptions{TaskScheduler: &roundRobinTaskScheduler{}})
+
+	instance, err := fa.StartPaused(nil, "budget", nil)
+	assert.Nil(t, err)
+
+	// the root task schedules a, b and c
+	_, err = fa.StepOnce(instance)
+	assert.Nil(t, err)
+
+	peeked, _ := instance.peekWorkItem()
+	again, _ := instance.peekWorkItem()
+	assert.Equal(t, peeked, again)
+
+	_, err = fa.StepOnce(instance)
+	assert.Nil(t, err)
+
+	// the peeked work item is the one the step dequeued
+	for _, item := range instance.WorkItemQueue.Items() {
+		assert.NotEqual(t, peeked, item)
+	}
+	assert.Len(t, instance.WorkItemQueue.Items(), 2)
+}
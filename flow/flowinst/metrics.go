@@ -0,0 +1,291 @@
+package flowinst
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDurationBuckets are the default upper bounds, in seconds, of the
+// instance duration histogram
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// ErrorRateWindow is the number of most recently finished instances the error
+// rate is computed over
+const ErrorRateWindow = 100
+
+// MetricsCollector is a dependency free collector of the engine metrics that
+// can render them in the Prometheus text exposition format
+type MetricsCollector struct {
+	mu sync.Mutex
+
+	buckets []float64
+
+	started   map[string]float64
+	finished  map[string]float64
+	steps     map[string]float64
+	durations map[string]*histogram
+
+	recorderWaits    float64
+	recorderWaitTime float64
+	recorderDrops    float64
+	overflows        map[string]float64
+
+	// outcomes is a ring of the last finished instances, true if it failed
+	outcomes     []bool
+	nextOutcome  int
+	outcomeCount int
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewMetricsCollector creates a new MetricsCollector, if no buckets are
+// specified the DefaultDurationBuckets are used
+func NewMetricsCollector(buckets ...float64) *MetricsCollector {
+
+	if len(buckets) == 0 {
+		buckets = DefaultDurationBuckets
+	}
+
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	return &MetricsCollector{
+		buckets:   sorted,
+		started:   make(map[string]float64),
+		finished:  make(map[string]float64),
+		steps:     make(map[string]float64),
+		durations: make(map[string]*histogram),
+		overflows: make(map[string]float64),
+		outcomes:  make([]bool, ErrorRateWindow),
+	}
+}
+
+// instanceStarted records the start of an instance
+func (mc *MetricsCollector) instanceStarted(instance *Instance) {
+	mc.FlowStarted(instance.Name())
+}
+
+// instanceFinished records the end of the execution of an instance
+func (mc *MetricsCollector) instanceFinished(instance *Instance, steps int, duration time.Duration) {
+	mc.FlowFinished(instance.Name(), instance.Status(), steps, duration)
+}
+
+// FlowStarted records the start of an instance of the specified flow, it is
+// meant for the collectors that observe the instances themselves
+func (mc *MetricsCollector) FlowStarted(flow string) {
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.started[labels("flow", flow)]++
+}
+
+// FlowFinished records the end of the execution of an instance of the
+// specified flow, with its final status, the number of steps it executed and
+// how long it executed
+func (mc *MetricsCollector) FlowFinished(flow string, status Status, steps int, duration time.Duration) {
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	flowLabels := labels("flow", flow)
+
+	mc.finished[labels("flow", flow, "status", statusLabel(status))]++
+	mc.steps[flowLabels] += float64(steps)
+
+	h, ok := mc.durations[flowLabels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(mc.buckets))}
+		mc.durations[flowLabels] = h
+	}
+
+	seconds := duration.Seconds()
+
+	for i, bound := range mc.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.sum += seconds
+	h.count++
+
+	mc.outcomes[mc.nextOutcome] = isFailure(status)
+	mc.nextOutcome = (mc.nextOutcome + 1) % len(mc.outcomes)
+
+	if mc.outcomeCount < len(mc.outcomes) {
+		mc.outcomeCount++
+	}
+}
+
+// ErrorRate returns the rate of failed instances among the last
+// ErrorRateWindow finished instances, failed, aborted and timed out instances
+// count as failures
+func (mc *MetricsCollector) ErrorRate() float64 {
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.outcomeCount == 0 {
+		return 0
+	}
+
+	failures := 0
+	for i := 0; i < mc.outcomeCount; i++ {
+		if mc.outcomes[i] {
+			failures++
+		}
+	}
+
+	return float64(failures) / float64(mc.outcomeCount)
+}
+
+func isFailure(status Status) bool {
+	return status == StatusFailed || status == StatusAborted || status == StatusTimedOut
+}
+
+// recorderWaited records a recorder write that waited for the rate limit
+func (mc *MetricsCollector) recorderWaited(wait time.Duration) {
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.recorderWaits++
+	mc.recorderWaitTime += wait.Seconds()
+}
+
+// recorderDropped records a recorder write that was skipped due to the rate limit
+func (mc *MetricsCollector) recorderDropped() {
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.recorderDrops++
+}
+
+// recorderOverflowed records a record that found the AsyncRecord buffer full,
+// by the RecordOverflowPolicy applied to it
+func (mc *MetricsCollector) recorderOverflowed(policy RecordOverflowPolicy) {
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.overflows[labels("policy", policy.String())]++
+}
+
+// MetricsText renders the collected metrics in the Prometheus text exposition format
+func (mc *MetricsCollector) MetricsText() string {
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	var buf bytes.Buffer
+
+	writeCounter(&buf, "flogo_flow_instances_started_total", "Number of flow instances started.", mc.started)
+	writeCounter(&buf, "flogo_flow_instances_finished_total", "Number of flow instances that finished executing, by status.", mc.finished)
+	writeCounter(&buf, "flogo_flow_steps_total", "Number of steps executed by flow instances.", mc.steps)
+
+	writeCounter(&buf, "flogo_recorder_waits_total", "Number of recorder writes that waited for the rate limit.", map[string]float64{"": mc.recorderWaits})
+	writeCounter(&buf, "flogo_recorder_wait_seconds_total", "Time recorder writes spent waiting for the rate limit.", map[string]float64{"": mc.recorderWaitTime})
+	writeCounter(&buf, "flogo_recorder_drops_total", "Number of recorder writes skipped due to the rate limit.", map[string]float64{"": mc.recorderDrops})
+	writeCounter(&buf, "flogo_recorder_overflows_total", "Number of records that found the record buffer full, by overflow policy.", mc.overflows)
+
+	name := "flogo_flow_instance_duration_seconds"
+	fmt.Fprintf(&buf, "# HELP %s Duration of the execution of flow instances.\n", name)
+	fmt.Fprintf(&buf, "# TYPE %s histogram\n", name)
+
+	for _, key := range sortedKeys(mc.durations) {
+
+		h := mc.durations[key]
+
+		for i, bound := range mc.buckets {
+			fmt.Fprintf(&buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, key, formatFloat(bound), h.counts[i])
+		}
+
+		fmt.Fprintf(&buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, key, h.count)
+		fmt.Fprintf(&buf, "%s_sum{%s} %s\n", name, key, formatFloat(h.sum))
+		fmt.Fprintf(&buf, "%s_count{%s} %d\n", name, key, h.count)
+	}
+
+	return buf.String()
+}
+
+func writeCounter(buf *bytes.Buffer, name string, help string, values map[string]float64) {
+
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s counter\n", name)
+
+	for _, key := range sortedKeys(values) {
+		if len(key) == 0 {
+			fmt.Fprintf(buf, "%s %s\n", name, formatFloat(values[key]))
+		} else {
+			fmt.Fprintf(buf, "%s{%s} %s\n", name, key, formatFloat(values[key]))
+		}
+	}
+}
+
+// labels renders the specified name/value pairs as a Prometheus label set
+func labels(nameValues ...string) string {
+
+	pairs := make([]string, 0, len(nameValues)/2)
+
+	for i := 0; i+1 < len(nameValues); i += 2 {
+		pairs = append(pairs, nameValues[i]+"=\""+escapeLabelValue(nameValues[i+1])+"\"")
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+var labelValueEscaper = strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func statusLabel(status Status) string {
+	return status.String()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys(m interface{}) []string {
+
+	var keys []string
+
+	switch m := m.(type) {
+	case map[string]float64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*histogram:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// ErrorRate implements action.ErrorRateReporter.ErrorRate, the rate is
+// tracked by the MetricsCollector of the FlowAction, it is 0 without one
+func (fa *FlowAction) ErrorRate() float64 {
+
+	if fa.actionOptions.MetricsCollector == nil {
+		return 0
+	}
+
+	return fa.actionOptions.MetricsCollector.ErrorRate()
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowinst"
+)
+
+// Collector is a flowinst.InstanceObserver that collects the metrics of the
+// executed flows in a flowinst.MetricsCollector, labeled by flow URI, and
+// exposes them in the Prometheus text exposition format.  It is safe for
+// concurrent use
+type Collector struct {
+	*flowinst.MetricsCollector
+
+	mu      sync.Mutex
+	running map[string]*run
+}
+
+var _ flowinst.InstanceObserver = (*Collector)(nil)
+
+// run is an instance being executed
+type run struct {
+	uri   string
+	start time.Time
+	steps int
+}
+
+// NewCollector creates a new Collector, if no buckets are specified the
+// flowinst.DefaultDurationBuckets are used
+func NewCollector(buckets ...float64) *Collector {
+
+	return &Collector{
+		MetricsCollector: flowinst.NewMetricsCollector(buckets...),
+		running:          make(map[string]*run),
+	}
+}
+
+// OnStart implements flowinst.InstanceObserver.OnStart
+func (c *Collector) OnStart(id string, uri string) {
+
+	c.mu.Lock()
+	c.running[id] = &run{uri: uri, start: time.Now()}
+	c.mu.Unlock()
+
+	c.FlowStarted(uri)
+}
+
+// OnStep implements flowinst.InstanceObserver.OnStep
+func (c *Collector) OnStep(id string, step int) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r, ok := c.running[id]; ok {
+		r.steps++
+	}
+}
+
+// OnComplete implements flowinst.InstanceObserver.OnComplete
+func (c *Collector) OnComplete(id string, status flowinst.Status) {
+
+	c.mu.Lock()
+	r, ok := c.running[id]
+	delete(c.running, id)
+	c.mu.Unlock()
+
+	if ok {
+		c.FlowFinished(r.uri, status, r.steps, time.Since(r.start))
+	}
+}
+
+// ServeHTTP implements http.Handler, so the Collector can be scraped
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(c.MetricsText()))
+}
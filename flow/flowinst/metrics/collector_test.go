@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowinst"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestCollector
+func TestCollector(t *testing.T) {
+
+	c := NewCollector()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(id string) {
+			defer wg.Done()
+
+			c.OnStart(id, "res://flow:a")
+			c.OnStep(id, 1)
+			c.OnStep(id, 2)
+			c.OnComplete(id, flowinst.StatusCompleted)
+		}(string(rune('a' + i)))
+	}
+
+	wg.Wait()
+
+	c.OnStart("failing", "res://flow:b")
+	c.OnComplete("failing", flowinst.StatusFailed)
+
+	text := c.MetricsText()
+
+	assert.Contains(t, text, `flogo_flow_instances_started_total{flow="res://flow:a"} 10`)
+	assert.Contains(t, text, `flogo_flow_instances_finished_total{flow="res://flow:a",status="completed"} 10`)
+	assert.Contains(t, text, `flogo_flow_instances_finished_total{flow="res://flow:b",status="failed"} 1`)
+	assert.Contains(t, text, `flogo_flow_steps_total{flow="res://flow:a"} 20`)
+	assert.Contains(t, text, `flogo_flow_instance_duration_seconds_count{flow="res://flow:a"} 10`)
+	assert.Equal(t, 1.0/11, c.ErrorRate())
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, text, rec.Body.String())
+}
@@ -0,0 +1,58 @@
+package flowinst
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestMetricsText
+func TestMetricsText(t *testing.T) {
+
+	metrics := NewMetricsCollector()
+	fa := NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{MetricsCollector: metrics})
+
+	for i := 0; i < 3; i++ {
+		handler := newTestResultHandler()
+		err := fa.Run(nil, "test", nil, handler)
+		assert.Nil(t, err)
+		<-handler.done
+	}
+
+	text := metrics.MetricsText()
+
+	assert.Contains(t, text, "# TYPE flogo_flow_instances_started_total counter\n")
+	assert.Contains(t, text, `flogo_flow_instances_started_total{flow="simple"} 3`)
+	assert.Contains(t, text, `flogo_flow_instances_finished_total{flow="simple",status="completed"} 3`)
+	assert.Contains(t, text, "# TYPE flogo_flow_instance_duration_seconds histogram\n")
+	assert.Contains(t, text, `flogo_flow_instance_duration_seconds_bucket{flow="simple",le="+Inf"} 3`)
+	assert.Contains(t, text, `flogo_flow_instance_duration_seconds_count{flow="simple"} 3`)
+}
+
+//TestErrorRate
+func TestErrorRate(t *testing.T) {
+
+	provider := newTestFlowProvider(t)
+	provider.flows["budget"] = newTestDefinition(t, budgetDefJSON)
+
+	metrics := NewMetricsCollector()
+	fa := NewFlowAction(provider, nil, &ActionOptions{MetricsCollector: metrics, MaxStepCount: 2})
+
+	assert.Equal(t, 0.0, fa.ErrorRate())
+
+	// the budget flow is aborted at the max step count
+	for _, uri := range []string{"test", "test", "test", "budget"} {
+		handler := newTestResultHandler()
+		err := fa.Run(nil, uri, nil, handler)
+		assert.Nil(t, err)
+		<-handler.done
+	}
+
+	assert.Equal(t, 0.25, fa.ErrorRate())
+
+	var reporter action.ErrorRateReporter = fa
+	assert.Equal(t, 0.25, reporter.ErrorRate())
+
+	assert.Equal(t, 0.0, NewFlowAction(provider, nil, nil).ErrorRate())
+}
@@ -0,0 +1,40 @@
+package flowinst
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// IDNamespaceSeparator separates the namespace of an instance ID from the
+// generated part of the ID, ie. "tenant1.0d5c3bf2..."
+const IDNamespaceSeparator = "."
+
+var validIDNamespace = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateIDNamespace checks that the namespace only contains letters,
+// digits, '_' and '-'
+func ValidateIDNamespace(namespace string) error {
+
+	if !validIDNamespace.MatchString(namespace) {
+		return fmt.Errorf("Invalid ID namespace '%s' - only letters, digits, '_' and '-' are allowed", namespace)
+	}
+
+	return nil
+}
+
+// newInstanceID generates a new instance ID, prefixed with the namespace of
+// the run if it has one
+func (fa *FlowAction) newInstanceID(ro *RunOptions) (string, error) {
+
+	id := fa.idGenerator.NewFlowInstanceID()
+
+	if ro == nil || len(ro.IDNamespace) == 0 {
+		return id, nil
+	}
+
+	if err := ValidateIDNamespace(ro.IDNamespace); err != nil {
+		return "", err
+	}
+
+	return ro.IDNamespace + IDNamespaceSeparator + id, nil
+}
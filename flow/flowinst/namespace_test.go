@@ -0,0 +1,27 @@
+package flowinst
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//TestIDNamespace
+func TestIDNamespace(t *testing.T) {
+
+	recorder := &testStateRecorder{}
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", &RunOptions{IDNamespace: "tenant-1"}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	id := recorder.instance.ID()
+	assert.True(t, strings.HasPrefix(id, "tenant-1"+IDNamespaceSeparator), id)
+	assert.Equal(t, id, handler.results[0].(*IDResponse).ID)
+
+	err = fa.Run(nil, "test", &RunOptions{IDNamespace: "tenant/1"}, newTestResultHandler())
+	assert.NotNil(t, err)
+}
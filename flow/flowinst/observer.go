@@ -0,0 +1,61 @@
+package flowinst
+
+import (
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// InstanceObserver is notified of the lifecycle of the instances executed by
+// a FlowAction.  Unlike a StateRecorder it doesn't get the state of the
+// instance, it is meant for lightweight integrations such as metrics, tracing
+// and logging.  The observer is called on the goroutine executing the
+// instance, so it should return quickly
+type InstanceObserver interface {
+
+	// OnStart is called when the instance starts executing
+	OnStart(id string, uri string)
+
+	// OnStep is called after each step of the instance
+	OnStep(id string, step int)
+
+	// OnComplete is called when the instance stops executing, with its final
+	// status
+	OnComplete(id string, status Status)
+}
+
+// AddObserver registers an InstanceObserver with the FlowAction, it is
+// notified for the instances started after it was added
+func (fa *FlowAction) AddObserver(observer InstanceObserver) {
+
+	fa.observerMu.Lock()
+	defer fa.observerMu.Unlock()
+
+	observers := make([]InstanceObserver, len(fa.observers), len(fa.observers)+1)
+	copy(observers, fa.observers)
+	fa.observers = append(observers, observer)
+}
+
+// instanceObservers returns the observers registered with the FlowAction
+func (fa *FlowAction) instanceObservers() []InstanceObserver {
+
+	fa.observerMu.Lock()
+	defer fa.observerMu.Unlock()
+
+	return fa.observers
+}
+
+// notifyObservers calls notify for each of the observers, an observer that
+// panics doesn't affect the others or the instance
+func notifyObservers(observers []InstanceObserver, notify func(observer InstanceObserver)) {
+
+	for _, observer := range observers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Warnf("InstanceObserver panicked: %v", r)
+				}
+			}()
+
+			notify(observer)
+		}()
+	}
+}
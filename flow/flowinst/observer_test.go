@@ -0,0 +1,52 @@
+package flowinst
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testObserver keeps the lifecycle events of the instances
+type testObserver struct {
+	events []string
+}
+
+func (o *testObserver) OnStart(id string, uri string) {
+	o.events = append(o.events, "start:"+uri)
+}
+
+func (o *testObserver) OnStep(id string, step int) {
+	o.events = append(o.events, "step:"+strconv.Itoa(step))
+}
+
+func (o *testObserver) OnComplete(id string, status Status) {
+	o.events = append(o.events, "complete:"+statusLabel(status))
+}
+
+// panickingObserver panics on every event
+type panickingObserver struct{}
+
+func (o *panickingObserver) OnStart(id string, uri string)       { panic("tracer down") }
+func (o *panickingObserver) OnStep(id string, step int)          { panic("tracer down") }
+func (o *panickingObserver) OnComplete(id string, status Status) { panic("tracer down") }
+
+//TestInstanceObserver
+func TestInstanceObserver(t *testing.T) {
+
+	fa := NewFlowAction(newTestFlowProvider(t), nil, nil)
+
+	observer := &testObserver{}
+	fa.AddObserver(&panickingObserver{})
+	fa.AddObserver(observer)
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, []string{"start:test", "step:1", "step:2", "complete:completed"}, observer.events)
+
+	// the panicking observer didn't affect the instance
+	assert.Equal(t, []int{200}, handler.codes)
+}
@@ -0,0 +1,73 @@
+package flowinst
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// PanicFormatter converts a panic recovered while executing an instance to an
+// error, stack is the stack trace of the goroutine that panicked
+type PanicFormatter func(recovered interface{}, stack []byte) error
+
+// DefaultPanicFormatter is a PanicFormatter whose error only contains the
+// recovered value, the stack is logged at debug level
+func DefaultPanicFormatter(recovered interface{}, stack []byte) error {
+	return fmt.Errorf("%v", recovered)
+}
+
+// SetPanicFormatter sets the PanicFormatter of the instance
+func (pi *Instance) SetPanicFormatter(formatter PanicFormatter) {
+	pi.panicFormatter = formatter
+}
+
+// formatPanic converts the recovered panic to an error, whether it happened in
+// an activity, a task or the execution of the instance, by default it is a
+// PanicError
+func (pi *Instance) formatPanic(recovered interface{}, stack []byte) error {
+
+	if pi.panicFormatter != nil {
+		return pi.panicFormatter(recovered, stack)
+	}
+
+	return &PanicError{InstanceID: pi.ID(), Value: recovered, Stack: stack}
+}
+
+// PanicError is the error a panic while executing an instance is converted to
+// when the instance has no PanicFormatter
+type PanicError struct {
+	InstanceID string
+	Value      interface{}
+	Stack      []byte
+}
+
+// Error implements error.Error
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("Flow [%s] panicked: %v", e.InstanceID, e.Value)
+}
+
+// recoverRun fails the instance whose execution panicked and reports the
+// failure to the caller, the state of the instance is recorded if record is set
+func (fa *FlowAction) recoverRun(instance *Instance, handler action.ResultHandler, record bool, recovered interface{}) {
+
+	stack := debug.Stack()
+
+	err := instance.formatPanic(recovered, stack)
+
+	logger.Errorf("Flow [%s] panicked [correlation: %s] - %v", instance.ID(), instance.CorrelationID(), recovered)
+	logger.Debugf("StackTrace: %s", stack)
+
+	instance.setLastError(err)
+	instance.setStatus(StatusFailed)
+
+	if record {
+		recordErr := fa.recordState(instance, true, false)
+		if recordErr != nil {
+			logger.Warn(recordErr.Error())
+		}
+	}
+
+	handler.HandleResult(500, nil, err)
+}
@@ -0,0 +1,89 @@
+package flowinst
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/activity"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/TIBCOSoftware/flogo-lib/flow/model"
+	"github.com/TIBCOSoftware/flogo-lib/flow/test"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestPanicRecovery
+func TestPanicRecovery(t *testing.T) {
+
+	// the panic is recovered by default
+	recorder := &panickingStateRecorder{}
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true, MaxStepCount: 100})
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// the IDResponse followed by the failure
+	assert.Equal(t, []int{200, 500}, handler.codes)
+
+	panicErr, ok := handler.errors[1].(*PanicError)
+	assert.True(t, ok)
+	assert.Equal(t, "disk on fire", panicErr.Value)
+	assert.NotEmpty(t, panicErr.Stack)
+
+	// the failure is recorded
+	assert.Equal(t, StatusFailed, recorder.instance.Status())
+	assert.Equal(t, StatusFailed, recorder.snapshots[len(recorder.snapshots)-1])
+	assert.Equal(t, panicErr, recorder.instance.LastError())
+}
+
+// panickingActivity always panics
+type panickingActivity struct {
+}
+
+func (a *panickingActivity) Metadata() *activity.Metadata {
+	return &activity.Metadata{ID: "panicking"}
+}
+
+func (a *panickingActivity) Eval(context activity.Context) (done bool, err error) {
+	panic("out of cheese")
+}
+
+//TestPanicFormatting
+func TestPanicFormatting(t *testing.T) {
+
+	expected := (&PanicError{InstanceID: "1", Value: "out of cheese"}).Error()
+
+	// a panicking task
+	m := model.New("panic")
+	m.RegisterFlowBehavior(&test.SimpleFlowBehavior{})
+	m.RegisterTaskBehavior(1, &test.SimpleTaskBehavior{})
+	m.RegisterTaskBehavior(2, &panickingTaskBehavior{SimpleTaskBehavior: &test.SimpleTaskBehavior{}})
+
+	instance := NewFlowInstance("1", "retry", newTestDefinition(t, retryDefJSON))
+	instance.FlowModel = m
+	instance.Start(nil)
+
+	for instance.DoStep() {
+	}
+
+	assert.NotNil(t, instance.LastError())
+	assert.Equal(t, expected, instance.LastError().Error())
+
+	// a panicking activity
+	registerActivity(&panickingActivity{})
+
+	def, err := flowdef.NewBuilder().Name("panicking").Model("declining").
+		AddTask(2, 2, "panicking", "panicking").
+		Build()
+	assert.Nil(t, err)
+
+	instance = NewFlowInstance("1", "panicking", def)
+	instance.Start(nil)
+
+	for instance.DoStep() {
+	}
+
+	assert.Equal(t, StatusFailed, instance.Status())
+	assert.NotNil(t, instance.LastError())
+	assert.Equal(t, expected, instance.LastError().Error())
+}
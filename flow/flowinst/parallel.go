@@ -0,0 +1,195 @@
+package flowinst
+
+import (
+	"runtime/debug"
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/activity"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// prefetchedEval is the evaluation of the activity of a task done ahead of the
+// execution of its work item, see DoParallelStep
+type prefetchedEval struct {
+	eval    bool
+	ran     bool
+	done    bool
+	err     error
+	outputs []*prefetchedOutput
+}
+
+type prefetchedOutput struct {
+	name  string
+	value interface{}
+}
+
+// prefetchContext is the activity.Context used to evaluate an activity ahead
+// of time, the outputs are buffered until the work item of the task executes
+type prefetchContext struct {
+	*TaskData
+	prefetched *prefetchedEval
+}
+
+// SetOutput implements activity.Context.SetOutput
+func (c *prefetchContext) SetOutput(name string, value interface{}) {
+	c.prefetched.outputs = append(c.prefetched.outputs, &prefetchedOutput{name: name, value: value})
+}
+
+// DoParallelStep performs a 'step' that executes all the work items queued in
+// the instance.  The work items queued together are independent of each
+// other, so the activities of the tasks they evaluate are run concurrently,
+// using up to the specified number of workers, before the work items are
+// executed in order.  The outputs of the activities are only applied when the
+// work items are executed, so the results are the same as when stepping with
+// DoStep.  It relies on the task behaviors evaluating the activity of a task
+// without children when evaluating the task.
+//
+// The activities are only run ahead of time if the flow has an error handler:
+// a failing task then schedules the error handler, and the later work items
+// execute as they would with DoStep.  Without one a failing task fails the
+// instance, and the activities of the later work items must not run, so the
+// instance is stepped with DoStep.
+func (pi *Instance) DoParallelStep(workers int) bool {
+
+	if pi.Flow.ErrorHandlerTask() == nil {
+		return pi.DoStep()
+	}
+
+	pi.ResetChanges()
+
+	pi.stepID++
+	pi.stepRetries = 0
+
+	if pi.status != StatusActive {
+		return false
+	}
+
+	var batch []*WorkItem
+
+	for {
+		workItem, ok := pi.nextWorkItem()
+		if !ok {
+			break
+		}
+
+		pi.ChangeTracker.trackWorkItem(&WorkItemQueueChange{ChgType: CtDel, ID: workItem.ID, WorkItem: workItem})
+		pi.trackStep(workItem)
+
+		batch = append(batch, workItem)
+	}
+
+	if len(batch) == 0 {
+		logger.Debug("queue emtpy")
+		return false
+	}
+
+	var prefetch []*TaskData
+
+	for _, workItem := range batch {
+		if taskData := workItem.TaskData; pi.prepareEval(workItem) && taskData.prefetched.eval {
+			prefetch = append(prefetch, taskData)
+		}
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	sem := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+
+	for _, taskData := range prefetch {
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(taskData *TaskData) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			taskData.prefetchActivity()
+		}(taskData)
+	}
+
+	wg.Wait()
+
+	for _, workItem := range batch {
+		pi.execTask(workItem)
+	}
+
+	return true
+}
+
+// prepareEval applies the input mappings of a work item that evaluates an
+// activity, so the activity can be evaluated ahead of time, returns false if
+// it can't be
+func (pi *Instance) prepareEval(workItem *WorkItem) (prepared bool) {
+
+	taskData := workItem.TaskData
+
+	if workItem.ExecType != EtEval || pi.shadow || taskData.prefetched != nil {
+		return false
+	}
+
+	if len(taskData.task.ChildTasks()) > 0 || !taskData.HasActivity() {
+		return false
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			// leave it to the work item to report the error
+			taskData.prefetched = nil
+			prepared = false
+		}
+	}()
+
+	prefetched := &prefetchedEval{eval: true}
+
+	if taskData.HasAttrs() {
+		if err := applyInputMapper(pi, taskData); err != nil {
+			// leave it to the work item to report the error
+			return false
+		}
+		prefetched.eval = applyInputInterceptor(pi, taskData)
+	}
+
+	taskData.prefetched = prefetched
+
+	return true
+}
+
+// prefetchActivity evaluates the activity of the task, buffering its outputs
+func (td *TaskData) prefetchActivity() {
+
+	prefetched := td.prefetched
+	act := activity.Get(td.task.ActivityType())
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Warnf("Unhandled Error executing activity '%s'[%s] : %v\n", td.task.Name(), td.task.ActivityType(), r)
+
+			stack := debug.Stack()
+			logger.Debugf("StackTrace: %s", stack)
+
+			prefetched.done = false
+			prefetched.err = activity.NewError(td.taskEnv.Instance.formatPanic(r, stack).Error(), "", nil)
+		}
+	}()
+
+	prefetched.ran = true
+	prefetched.done, prefetched.err = act.Eval(&prefetchContext{TaskData: td, prefetched: prefetched})
+}
+
+// usePrefetched applies the outputs of the activity evaluated ahead of time
+func (td *TaskData) usePrefetched() (done bool, err error) {
+
+	prefetched := td.prefetched
+	td.prefetched = nil
+
+	for _, output := range prefetched.outputs {
+		td.SetOutput(output.name, output.value)
+	}
+
+	return prefetched.done, prefetched.err
+}
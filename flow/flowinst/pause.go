@@ -0,0 +1,209 @@
+package flowinst
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"github.com/TIBCOSoftware/flogo-lib/util"
+)
+
+// PauseInstance pauses the specified instance, the instance blocks before its
+// next step until it is resumed, cancelled or evicted.  Returns false if the
+// instance isn't being executed by the FlowAction or is already paused
+func (fa *FlowAction) PauseInstance(id string) bool {
+
+	fa.liveMu.Lock()
+	run, exists := fa.live[id]
+	fa.liveMu.Unlock()
+
+	if !exists || !run.pause() {
+		return false
+	}
+
+	logger.Infof("Flow [%s] Paused [correlation: %s]", id, run.instance.CorrelationID())
+
+	return true
+}
+
+// ResumeInstance resumes the specified paused instance.  Returns false if the
+// instance isn't being executed by the FlowAction or isn't paused
+func (fa *FlowAction) ResumeInstance(id string) bool {
+
+	fa.liveMu.Lock()
+	run, exists := fa.live[id]
+	fa.liveMu.Unlock()
+
+	if !exists || !run.resume() {
+		return false
+	}
+
+	// the time spent paused doesn't count as a stall
+	run.setProgress(fa.actionOptions.Clock.Now())
+
+	logger.Infof("Flow [%s] Resumed [correlation: %s]", id, run.instance.CorrelationID())
+
+	return true
+}
+
+// pause marks the run as paused and stops the clock of its deadline if it
+// can be paused, returns false if the run is already paused
+func (lr *liveRun) pause() bool {
+
+	lr.pauseMu.Lock()
+	defer lr.pauseMu.Unlock()
+
+	if lr.resumed != nil {
+		return false
+	}
+
+	lr.resumed = make(chan struct{})
+
+	if lr.deadline != nil {
+		lr.deadline.pause()
+	}
+
+	return true
+}
+
+// resume unblocks the paused run and restarts the clock of its deadline,
+// returns false if the run isn't paused
+func (lr *liveRun) resume() bool {
+
+	lr.pauseMu.Lock()
+	defer lr.pauseMu.Unlock()
+
+	if lr.resumed == nil {
+		return false
+	}
+
+	if lr.deadline != nil {
+		lr.deadline.resume()
+	}
+
+	close(lr.resumed)
+	lr.resumed = nil
+
+	return true
+}
+
+func (lr *liveRun) paused() bool {
+
+	lr.pauseMu.Lock()
+	defer lr.pauseMu.Unlock()
+
+	return lr.resumed != nil
+}
+
+// waitWhilePaused blocks while the run is paused, until it is resumed, the
+// context is done or its eviction is requested
+func (lr *liveRun) waitWhilePaused(ctx context.Context) {
+
+	lr.pauseMu.Lock()
+	resumed := lr.resumed
+	lr.pauseMu.Unlock()
+
+	if resumed == nil {
+		return
+	}
+
+	logger.Debugf("Flow [%s] waiting to be resumed", lr.instance.ID())
+
+	select {
+	case <-resumed:
+	case <-ctx.Done():
+	case <-lr.evict:
+	}
+}
+
+// pausableDeadline is a context that is done once it has been running for
+// its timeout, the time it is paused doesn't count towards the timeout
+type pausableDeadline struct {
+	context.Context
+	cancel context.CancelFunc
+	clock  Clock
+
+	mu        sync.Mutex
+	stopTimer func() bool
+	expires   time.Time
+	remaining time.Duration
+	err       error
+}
+
+func newPausableDeadline(clock Clock, parent context.Context, timeout time.Duration) *pausableDeadline {
+
+	ctx, cancel := context.WithCancel(parent)
+
+	pd := &pausableDeadline{Context: ctx, cancel: cancel, clock: clock, expires: clock.Now().Add(timeout)}
+	pd.stopTimer = util.AfterFunc(clock, timeout, pd.expire)
+
+	return pd
+}
+
+func (pd *pausableDeadline) expire() {
+
+	pd.mu.Lock()
+	if pd.err == nil && pd.Context.Err() == nil {
+		pd.err = context.DeadlineExceeded
+	}
+	pd.mu.Unlock()
+
+	pd.cancel()
+}
+
+func (pd *pausableDeadline) pause() {
+
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+
+	if pd.stopTimer() {
+		pd.remaining = pd.expires.Sub(pd.clock.Now())
+	}
+}
+
+func (pd *pausableDeadline) resume() {
+
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+
+	if pd.err != nil || pd.Context.Err() != nil {
+		return
+	}
+
+	pd.expires = pd.clock.Now().Add(pd.remaining)
+	pd.stopTimer = util.AfterFunc(pd.clock, pd.remaining, pd.expire)
+}
+
+// stop releases the context, it is cancelled if it isn't done yet
+func (pd *pausableDeadline) stop() {
+
+	pd.mu.Lock()
+	pd.stopTimer()
+	pd.mu.Unlock()
+
+	pd.cancel()
+}
+
+// Deadline implements context.Context.Deadline, the deadline moves when the
+// context is paused
+func (pd *pausableDeadline) Deadline() (time.Time, bool) {
+
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+
+	return pd.expires, true
+}
+
+// Err implements context.Context.Err
+func (pd *pausableDeadline) Err() error {
+
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+
+	if pd.err != nil {
+		return pd.err
+	}
+
+	return pd.Context.Err()
+}
@@ -0,0 +1,78 @@
+package flowinst
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestPauseInstance
+func TestPauseInstance(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+
+	// pauses the instance after its first step, for longer than its deadline
+	runPaused := func(fa *FlowAction, clock *testClock) *errorResultHandler {
+
+		handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+		err := fa.Run(nil, "gated", &RunOptions{Timeout: 100 * time.Millisecond}, handler)
+		assert.Nil(t, err)
+
+		<-gate.entered
+		id := handler.results[0].(*IDResponse).ID
+
+		assert.True(t, fa.PauseInstance(id))
+		assert.False(t, fa.PauseInstance(id))
+		gate.release <- true
+
+		status, exists := fa.InstanceStatus(id)
+		assert.True(t, exists)
+		assert.Equal(t, StatusPaused, status)
+
+		clock.advance(200 * time.Millisecond)
+		fa.ResumeInstance(id)
+		<-handler.done
+
+		assert.False(t, fa.ResumeInstance(id))
+
+		return handler
+	}
+
+	// the deadline of the paused instance expires
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	handler := runPaused(NewFlowAction(provider, nil, &ActionOptions{Clock: clock, TaskScheduler: &FIFOTaskScheduler{}}), clock)
+	assert.Equal(t, []int{200, CodeDeadlineExceeded}, handler.codes)
+
+	// the clock of the deadline stops while the instance is paused
+	clock = &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	handler = runPaused(NewFlowAction(provider, nil, &ActionOptions{Clock: clock, PauseExecutionTimeout: true, TaskScheduler: &FIFOTaskScheduler{}}), clock)
+	assert.Equal(t, []int{200}, handler.codes)
+
+	// a paused instance can be cancelled
+	fa := NewFlowAction(provider, nil, &ActionOptions{TaskScheduler: &FIFOTaskScheduler{}})
+
+	handler = &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(nil, "gated", nil, handler)
+	assert.Nil(t, err)
+
+	<-gate.entered
+	id := handler.results[0].(*IDResponse).ID
+
+	assert.False(t, fa.ResumeInstance(id))
+	assert.True(t, fa.PauseInstance(id))
+	gate.release <- true
+
+	assert.True(t, fa.CancelInstance(id))
+	<-handler.done
+
+	assert.Equal(t, []int{200, CodeCancelled}, handler.codes)
+}
@@ -0,0 +1,296 @@
+package flowinst
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/util"
+)
+
+// RateLimitPolicy determines what happens to a recorder write when the rate
+// limit is hit
+type RateLimitPolicy int
+
+const (
+	// RateLimitBlock blocks the stepper until the write is allowed
+	RateLimitBlock RateLimitPolicy = iota
+
+	// RateLimitBuffer skips the write and keeps the state of the instance
+	// pending, it is recorded by the next allowed write, a snapshot covers
+	// the skipped steps
+	RateLimitBuffer
+)
+
+// RecordRateLimiter is a token bucket that limits the rate of the recorder
+// writes, a single limiter can be shared by several FlowActions to limit the
+// writes globally
+type RecordRateLimiter struct {
+	Policy RateLimitPolicy
+
+	bucket *tokenBucket
+
+	mu       sync.Mutex
+	waits    int
+	waitTime time.Duration
+	drops    int
+}
+
+// NewRecordRateLimiter creates a RecordRateLimiter that allows rate writes per
+// second, with bursts of up to burst writes, a rate of zero disables the limit
+func NewRecordRateLimiter(rate float64, burst int, policy RateLimitPolicy) *RecordRateLimiter {
+	return &RecordRateLimiter{Policy: policy, bucket: newTokenBucket(rate, burst)}
+}
+
+// Wait blocks until a write is allowed, returns the time spent waiting
+func (rl *RecordRateLimiter) Wait() time.Duration {
+
+	wait := rl.bucket.reserve()
+
+	if wait > 0 {
+		rl.mu.Lock()
+		rl.waits++
+		rl.waitTime += wait
+		rl.mu.Unlock()
+
+		elapsed, _ := rl.bucket.after(wait)
+		<-elapsed
+	}
+
+	return wait
+}
+
+// TryAcquire indicates if a write is allowed now, if not the write is counted
+// as dropped
+func (rl *RecordRateLimiter) TryAcquire() bool {
+
+	if rl.bucket.tryTake() {
+		return true
+	}
+
+	rl.mu.Lock()
+	rl.drops++
+	rl.mu.Unlock()
+
+	return false
+}
+
+// Waits returns the number of writes that had to wait and the total time spent waiting
+func (rl *RecordRateLimiter) Waits() (int, time.Duration) {
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return rl.waits, rl.waitTime
+}
+
+// Drops returns the number of writes that were skipped
+func (rl *RecordRateLimiter) Drops() int {
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return rl.drops
+}
+
+// StartRateLimitPolicy determines what happens to the start of an instance
+// when the rate limit is hit
+type StartRateLimitPolicy int
+
+const (
+	// StartRateLimitBlock blocks the start until it is allowed or its context
+	// is done
+	StartRateLimitBlock StartRateLimitPolicy = iota
+
+	// StartRateLimitReject rejects the start with ErrStartRateLimited
+	StartRateLimitReject
+)
+
+// ErrStartRateLimited is the error a start is rejected with when the
+// StartRateLimiter doesn't allow it
+var ErrStartRateLimited = errors.New("flow start rate limit exceeded")
+
+// StartRateLimiter is a token bucket that limits the rate at which instances
+// are started, resumes, restarts and subflows are not limited.  A single limiter can be
+// shared by several FlowActions, ie. the ones of the handlers of a trigger,
+// to limit their starts together
+type StartRateLimiter struct {
+	Policy StartRateLimitPolicy
+
+	bucket *tokenBucket
+
+	mu       sync.Mutex
+	rejected int
+}
+
+// NewStartRateLimiter creates a StartRateLimiter that allows rate starts per
+// second, with bursts of up to burst starts, a rate of zero disables the limit
+func NewStartRateLimiter(rate float64, burst int, policy StartRateLimitPolicy) *StartRateLimiter {
+	return &StartRateLimiter{Policy: policy, bucket: newTokenBucket(rate, burst)}
+}
+
+// Rejected returns the number of starts that were rejected
+func (sl *StartRateLimiter) Rejected() int {
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	return sl.rejected
+}
+
+// acquire waits until a start is allowed or rejects it, depending on the
+// Policy
+func (sl *StartRateLimiter) acquire(ctx context.Context) error {
+
+	if sl.Policy == StartRateLimitReject {
+		if sl.bucket.tryTake() {
+			return nil
+		}
+
+		sl.mu.Lock()
+		sl.rejected++
+		sl.mu.Unlock()
+
+		return ErrStartRateLimited
+	}
+
+	wait := sl.bucket.reserve()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	elapsed, stop := sl.bucket.after(wait)
+	defer stop()
+
+	select {
+	case <-elapsed:
+		return nil
+	case <-ctx.Done():
+		sl.bucket.unreserve()
+		return ctx.Err()
+	}
+}
+
+// tokenBucket allows rate operations per second, with bursts of up to burst
+// operations, a rate of zero disables the limit.  The tokens are refilled on
+// its Clock, the system clock unless the FlowAction using it has another one
+type tokenBucket struct {
+	mu     sync.Mutex
+	clock  Clock
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		clock:  util.SystemClock,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   util.SystemClock.Now(),
+	}
+}
+
+// useClock refills the bucket on the clock from now on
+func (tb *tokenBucket) useClock(clock Clock) {
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.refill()
+
+	tb.clock = clock
+	tb.last = clock.Now()
+}
+
+// after returns a channel that is closed once the duration elapsed on the
+// clock of the bucket, and the function that stops the wait
+func (tb *tokenBucket) after(d time.Duration) (<-chan struct{}, func() bool) {
+
+	tb.mu.Lock()
+	clock := tb.clock
+	tb.mu.Unlock()
+
+	elapsed := make(chan struct{})
+
+	stop := util.AfterFunc(clock, d, func() {
+		close(elapsed)
+	})
+
+	return elapsed, stop
+}
+
+// reserve takes a token, returns the time the caller has to wait for it to be
+// refilled
+func (tb *tokenBucket) reserve() time.Duration {
+
+	if tb.rate <= 0 {
+		return 0
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.refill()
+	tb.tokens--
+
+	if tb.tokens < 0 {
+		return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+	}
+
+	return 0
+}
+
+// unreserve gives back a reserved token the caller didn't wait for
+func (tb *tokenBucket) unreserve() {
+
+	if tb.rate <= 0 {
+		return
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.tokens++
+}
+
+// tryTake takes a token if one is available now
+func (tb *tokenBucket) tryTake() bool {
+
+	if tb.rate <= 0 {
+		return true
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.refill()
+
+	if tb.tokens < 1 {
+		return false
+	}
+
+	tb.tokens--
+	return true
+}
+
+func (tb *tokenBucket) refill() {
+
+	now := tb.clock.Now()
+
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+
+	tb.last = now
+}
@@ -0,0 +1,133 @@
+package flowinst
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//TestRecordRateLimiter
+func TestRecordRateLimiter(t *testing.T) {
+
+	limiter := NewRecordRateLimiter(100, 1, RateLimitBlock)
+
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		limiter.Wait()
+	}
+	elapsed := time.Since(start)
+
+	// the first write uses the burst, the other 10 are throttled to 100/s
+	assert.True(t, elapsed >= 90*time.Millisecond, "elapsed: %v", elapsed)
+
+	waits, _ := limiter.Waits()
+	assert.Equal(t, 10, waits)
+}
+
+//TestStartRateLimiter
+func TestStartRateLimiter(t *testing.T) {
+
+	limiter := NewStartRateLimiter(0.001, 1, StartRateLimitReject)
+	fa := NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{StartRateLimiter: limiter})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	err = fa.Run(nil, "test", nil, newTestResultHandler())
+	assert.Equal(t, ErrStartRateLimited, err)
+	assert.Equal(t, 1, limiter.Rejected())
+
+	// resumes are not limited
+	def, _ := newTestFlowProvider(t).GetFlow("test")
+	instance := NewFlowInstance("1", "test", def)
+	instance.Start(nil)
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "test", &RunOptions{Op: AoResume, InitialState: instance}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// a blocked start gives up with its context
+	limiter = NewStartRateLimiter(0.001, 1, StartRateLimitBlock)
+	fa = NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{StartRateLimiter: limiter})
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = fa.Run(ctx, "test", nil, newTestResultHandler())
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+//TestRecordRateLimiterBuffer
+func TestRecordRateLimiterBuffer(t *testing.T) {
+
+	recorder := &testStateRecorder{}
+	metrics := NewMetricsCollector()
+	limiter := NewRecordRateLimiter(20, 1, RateLimitBuffer)
+
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true, RecordRateLimiter: limiter, MetricsCollector: metrics})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// only the first step was written, the final state is recorded once allowed
+	assert.Equal(t, 1, recorder.steps)
+	assert.Equal(t, StatusCompleted, recorder.snapshots[len(recorder.snapshots)-1])
+	assert.True(t, limiter.Drops() > 0)
+	assert.Contains(t, metrics.MetricsText(), "flogo_recorder_drops_total "+strconv.Itoa(limiter.Drops())+"\n")
+}
+
+//TestStartRateLimiterClock
+func TestStartRateLimiterClock(t *testing.T) {
+
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	// the tokens are refilled on the clock of the action
+	limiter := NewStartRateLimiter(1, 1, StartRateLimitReject)
+	fa := NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{StartRateLimiter: limiter, Clock: clock})
+
+	handler := newTestResultHandler()
+	assert.Nil(t, fa.Run(nil, "test", nil, handler))
+	<-handler.done
+
+	assert.Equal(t, ErrStartRateLimited, fa.Run(nil, "test", nil, newTestResultHandler()))
+
+	clock.advance(time.Second)
+
+	handler = newTestResultHandler()
+	assert.Nil(t, fa.Run(nil, "test", nil, handler))
+	<-handler.done
+
+	// a blocked start waits on the clock
+	limiter = NewStartRateLimiter(1, 1, StartRateLimitBlock)
+	fa = NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{StartRateLimiter: limiter, Clock: clock})
+
+	handler = newTestResultHandler()
+	assert.Nil(t, fa.Run(nil, "test", nil, handler))
+	<-handler.done
+
+	started := make(chan error, 1)
+	handler = newTestResultHandler()
+
+	go func() {
+		started <- fa.Run(nil, "test", nil, handler)
+	}()
+
+	waitFor(t, func() bool { return clock.pending() > 0 })
+	clock.advance(time.Second)
+
+	assert.Nil(t, <-started)
+	<-handler.done
+}
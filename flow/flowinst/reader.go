@@ -0,0 +1,143 @@
+package flowinst
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// InstanceSummary describes an instance persisted by a StateRecorder
+type InstanceSummary struct {
+	ID            string `json:"id"`
+	FlowURI       string `json:"flowUri"`
+	Status        Status `json:"status"`
+	CorrelationID string `json:"correlationId,omitempty"`
+}
+
+// InstanceFilter selects the instances listed by a StateReader, the zero
+// value selects all of them
+type InstanceFilter struct {
+	// Statuses are the statuses of the instances to list, any status if empty
+	Statuses []Status
+
+	// FlowURI is the flow of the instances to list, any flow if empty
+	FlowURI string
+
+	// Offset is the number of matching instances to skip
+	Offset int
+
+	// Limit is the maximum number of instances to list, no limit if < 1
+	Limit int
+}
+
+// Matches indicates if the instance matches the Statuses and FlowURI of the
+// filter
+func (f *InstanceFilter) Matches(summary *InstanceSummary) bool {
+
+	if f == nil {
+		return true
+	}
+
+	if len(f.FlowURI) > 0 && f.FlowURI != summary.FlowURI {
+		return false
+	}
+
+	if len(f.Statuses) == 0 {
+		return true
+	}
+
+	for _, status := range f.Statuses {
+		if status == summary.Status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// page returns the page of the matching summaries selected by the Offset and
+// Limit of the filter
+func (f *InstanceFilter) page(summaries []*InstanceSummary) []*InstanceSummary {
+
+	if f == nil {
+		return summaries
+	}
+
+	if f.Offset > 0 {
+		if f.Offset >= len(summaries) {
+			return nil
+		}
+		summaries = summaries[f.Offset:]
+	}
+
+	if f.Limit > 0 && f.Limit < len(summaries) {
+		summaries = summaries[:f.Limit]
+	}
+
+	return summaries
+}
+
+// StateReader is implemented by the StateRecorders that can query the
+// instances they persisted, ie. for dashboards or bulk resumes
+type StateReader interface {
+
+	// ListInstances returns the summaries of the instances matching the
+	// filter, ordered by ID
+	ListInstances(filter *InstanceFilter) ([]*InstanceSummary, error)
+
+	// GetSnapshot returns the latest snapshot of the instance, or
+	// ErrSnapshotNotFound
+	GetSnapshot(instanceID string) (*Instance, error)
+}
+
+func newInstanceSummary(instance *Instance) *InstanceSummary {
+	return &InstanceSummary{
+		ID:            instance.ID(),
+		FlowURI:       instance.FlowURI,
+		Status:        instance.Status(),
+		CorrelationID: instance.CorrelationID(),
+	}
+}
+
+// ListInstances implements StateReader.ListInstances
+func (sr *InMemoryStateRecorder) ListInstances(filter *InstanceFilter) ([]*InstanceSummary, error) {
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	var matching []*InstanceSummary
+
+	for _, summary := range sr.summaries {
+		if filter.Matches(summary) {
+			summaryCopy := *summary
+			matching = append(matching, &summaryCopy)
+		}
+	}
+
+	sort.Slice(matching, func(i, j int) bool { return matching[i].ID < matching[j].ID })
+
+	return filter.page(matching), nil
+}
+
+// GetSnapshot implements StateReader.GetSnapshot
+func (sr *InMemoryStateRecorder) GetSnapshot(instanceID string) (*Instance, error) {
+
+	snapshot, err := sr.LoadSnapshot(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeSnapshot(instanceID, snapshot)
+}
+
+// decodeSnapshot deserializes the snapshot of the instance
+func decodeSnapshot(instanceID string, snapshot []byte) (*Instance, error) {
+
+	instance := &Instance{}
+
+	if err := json.Unmarshal(snapshot, instance); err != nil {
+		return nil, fmt.Errorf("Invalid snapshot of Flow instance [%s] - %s", instanceID, err.Error())
+	}
+
+	return instance, nil
+}
@@ -0,0 +1,55 @@
+package flowinst
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//TestListInstances
+func TestListInstances(t *testing.T) {
+
+	def, _ := newTestFlowProvider(t).GetFlow("test")
+	recorder := NewInMemoryStateRecorder()
+
+	for i, status := range []Status{StatusActive, StatusCompleted, StatusFailed, StatusActive} {
+		uri := "test"
+		if i == 3 {
+			uri = "other"
+		}
+
+		instance := NewFlowInstance(strconv.Itoa(i), uri, def)
+		instance.setStatus(status)
+		recorder.RecordSnapshot(instance)
+	}
+
+	var reader StateReader = recorder
+
+	summaries, err := reader.ListInstances(nil)
+	assert.Nil(t, err)
+	assert.Len(t, summaries, 4)
+
+	summaries, err = reader.ListInstances(&InstanceFilter{Statuses: []Status{StatusActive}})
+	assert.Nil(t, err)
+	assert.Equal(t, []*InstanceSummary{
+		{ID: "0", FlowURI: "test", Status: StatusActive, CorrelationID: "0"},
+		{ID: "3", FlowURI: "other", Status: StatusActive, CorrelationID: "3"},
+	}, summaries)
+
+	summaries, err = reader.ListInstances(&InstanceFilter{FlowURI: "test", Offset: 1, Limit: 1})
+	assert.Nil(t, err)
+	assert.Len(t, summaries, 1)
+	assert.Equal(t, "1", summaries[0].ID)
+
+	summaries, err = reader.ListInstances(&InstanceFilter{Offset: 4})
+	assert.Nil(t, err)
+	assert.Empty(t, summaries)
+
+	instance, err := reader.GetSnapshot("2")
+	assert.Nil(t, err)
+	assert.Equal(t, StatusFailed, instance.Status())
+
+	_, err = reader.GetSnapshot("4")
+	assert.Equal(t, ErrSnapshotNotFound, err)
+}
@@ -1,5 +1,7 @@
 package flowinst
 
+import "context"
+
 // StateRecorder is the interface that describes a service that can record
 // snapshots and steps of a Flow Instance
 type StateRecorder interface {
@@ -10,3 +12,24 @@ type StateRecorder interface {
 	// RecordStep records the changes for the current Step of the Flow Instance
 	RecordStep(instance *Instance)
 }
+
+// FlushableStateRecorder is a StateRecorder that buffers records and writes
+// them asynchronously
+type FlushableStateRecorder interface {
+	StateRecorder
+
+	// Flush writes the buffered records, it should return when ctx is done
+	Flush(ctx context.Context) error
+}
+
+// RecordErrorPolicy determines what happens to an instance when its state
+// can't be recorded
+type RecordErrorPolicy int
+
+const (
+	// RecordErrorContinue logs the failure and continues executing the instance
+	RecordErrorContinue RecordErrorPolicy = iota
+
+	// RecordErrorAbort fails the instance
+	RecordErrorAbort
+)
@@ -0,0 +1,61 @@
+package flowinst
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bufferingStateRecorder buffers the recorded steps until it is flushed
+type bufferingStateRecorder struct {
+	mu       sync.Mutex
+	buffered int
+	flushed  int
+	flushes  chan bool
+}
+
+func (sr *bufferingStateRecorder) RecordSnapshot(instance *Instance) {
+}
+
+func (sr *bufferingStateRecorder) RecordStep(instance *Instance) {
+	sr.mu.Lock()
+	sr.buffered++
+	sr.mu.Unlock()
+}
+
+func (sr *bufferingStateRecorder) Flush(ctx context.Context) error {
+	sr.mu.Lock()
+	sr.flushed += sr.buffered
+	sr.buffered = 0
+	sr.mu.Unlock()
+
+	sr.flushes <- true
+	return nil
+}
+
+//TestFlushRecorder
+func TestFlushRecorder(t *testing.T) {
+
+	recorder := &bufferingStateRecorder{flushes: make(chan bool, 1)}
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true, FlushTimeout: time.Second})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	select {
+	case <-recorder.flushes:
+	case <-time.After(time.Second):
+		assert.Fail(t, "recorder was not flushed")
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	assert.Equal(t, 0, recorder.buffered)
+	assert.True(t, recorder.flushed > 0)
+}
@@ -0,0 +1,87 @@
+package flowinst
+
+import (
+	"sort"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// ActiveInstances returns the IDs of the instances currently being executed
+// by the FlowAction
+func (fa *FlowAction) ActiveInstances() []string {
+
+	fa.liveMu.Lock()
+	defer fa.liveMu.Unlock()
+
+	ids := make([]string, 0, len(fa.live))
+	for id := range fa.live {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// InstanceStatus returns the status of the specified instance, false is
+// returned if the instance isn't being executed by the FlowAction
+func (fa *FlowAction) InstanceStatus(id string) (Status, bool) {
+
+	fa.liveMu.Lock()
+	run, exists := fa.live[id]
+	fa.liveMu.Unlock()
+
+	if !exists {
+		return StatusNotStarted, false
+	}
+
+	if run.paused() {
+		return StatusPaused, true
+	}
+
+	return run.lastStatus(), true
+}
+
+// StalledInstances returns the IDs of the instances being executed by the
+// FlowAction that haven't progressed for longer than the threshold, ie. whose
+// step is blocked on a dead dependency.  The progress of an instance is the
+// completion of its last step, its start or its resume, paused instances
+// aren't stalled
+func (fa *FlowAction) StalledInstances(threshold time.Duration) []string {
+
+	now := fa.actionOptions.Clock.Now()
+
+	fa.liveMu.Lock()
+	defer fa.liveMu.Unlock()
+
+	var ids []string
+	for id, run := range fa.live {
+		if !run.paused() && now.Sub(run.progressedAt()) > threshold {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// CancelInstance cancels the specified instance, the instance stops before
+// its next step.  Returns false if the instance isn't being executed by the
+// FlowAction
+func (fa *FlowAction) CancelInstance(id string) bool {
+
+	fa.liveMu.Lock()
+	run, exists := fa.live[id]
+	fa.liveMu.Unlock()
+
+	if !exists {
+		return false
+	}
+
+	logger.Infof("Flow [%s] Cancel requested [correlation: %s]", id, run.instance.CorrelationID())
+	run.cancel()
+
+	return true
+}
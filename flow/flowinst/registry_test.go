@@ -0,0 +1,120 @@
+package flowinst
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestCancelInFlight
+func TestCancelInFlight(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	recorder := &testStateRecorder{}
+	fa := NewFlowAction(provider, recorder, &ActionOptions{CheckpointStrategy: &StatusChangeCheckpoint{}, TaskScheduler: &FIFOTaskScheduler{}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(ctx, "gated", nil, handler)
+	assert.Nil(t, err)
+
+	// the caller goes away while 'a' is executing
+	<-gate.entered
+	cancel()
+	gate.release <- true
+	<-handler.done
+
+	assert.Equal(t, []int{200, CodeCancelled}, handler.codes)
+	assert.Equal(t, context.Canceled, handler.errors[1])
+
+	// the cancelled state is recorded, 'b' never ran
+	assert.Equal(t, []Status{StatusCancelled}, recorder.snapshots)
+	assert.Equal(t, StatusCancelled, recorder.instance.Status())
+	assert.False(t, recorder.instance.WorkItemQueue.IsEmpty())
+}
+
+//TestCancelInstance
+func TestCancelInstance(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	fa := NewFlowAction(provider, nil, &ActionOptions{TaskScheduler: &FIFOTaskScheduler{}})
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(nil, "gated", nil, handler)
+	assert.Nil(t, err)
+
+	<-gate.entered
+
+	id := handler.results[0].(*IDResponse).ID
+	assert.Equal(t, []string{id}, fa.ActiveInstances())
+
+	status, exists := fa.InstanceStatus(id)
+	assert.True(t, exists)
+	assert.Equal(t, StatusActive, status)
+
+	assert.True(t, fa.CancelInstance(id))
+	gate.release <- true
+	<-handler.done
+
+	assert.Equal(t, []int{200, CodeCancelled}, handler.codes)
+
+	// the instance is no longer live
+	assert.Empty(t, fa.ActiveInstances())
+	assert.False(t, fa.CancelInstance(id))
+
+	_, exists = fa.InstanceStatus(id)
+	assert.False(t, exists)
+}
+
+//TestStalledInstances
+func TestStalledInstances(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	fa := NewFlowAction(provider, nil, &ActionOptions{Clock: clock, TaskScheduler: &FIFOTaskScheduler{}})
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(nil, "gated", nil, handler)
+	assert.Nil(t, err)
+
+	<-gate.entered
+	id := handler.results[0].(*IDResponse).ID
+
+	assert.Empty(t, fa.StalledInstances(time.Minute))
+
+	// the step executing 'a' is blocked
+	clock.advance(2 * time.Minute)
+
+	assert.Equal(t, []string{id}, fa.StalledInstances(time.Minute))
+	assert.Empty(t, fa.StalledInstances(5*time.Minute))
+
+	assert.True(t, fa.CancelInstance(id))
+	gate.release <- true
+	<-handler.done
+
+	assert.Empty(t, fa.StalledInstances(time.Minute))
+}
@@ -0,0 +1,88 @@
+package flowinst
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// ErrStepNotFound is returned by a StepLoader that has no record of the
+// requested step
+var ErrStepNotFound = errors.New("step not found")
+
+// StepLoader is the read side of a StateRecorder that keeps the state of the
+// instances after each step
+type StepLoader interface {
+
+	// LoadStep returns the state of the instance, serialized as JSON, as it
+	// was recorded by RecordStep after the specified step, or ErrStepNotFound
+	LoadStep(instanceID string, step int) ([]byte, error)
+
+	// RecordedSteps returns the IDs of the recorded steps of the instance, in
+	// ascending order
+	RecordedSteps(instanceID string) []int
+}
+
+// StepNotRecordedError is the error of a replay to a step whose previous step
+// wasn't recorded
+type StepNotRecordedError struct {
+	InstanceID string
+	Step       int
+
+	// First and Last are the range of the steps that can be replayed to, they
+	// are 0 if no step was recorded
+	First int
+	Last  int
+}
+
+// Error implements error.Error
+func (e *StepNotRecordedError) Error() string {
+
+	if e.First == 0 {
+		return fmt.Sprintf("Unable to replay Flow instance [%s] to step %d, none of its steps were recorded", e.InstanceID, e.Step)
+	}
+
+	return fmt.Sprintf("Unable to replay Flow instance [%s] to step %d, the recorded steps can be replayed to steps %d to %d", e.InstanceID, e.Step, e.First, e.Last)
+}
+
+// ReplayToStep reconstructs the instance as it was just before the specified
+// step, from the state recorded after the previous step.  The instance isn't
+// run, like with StartPaused the caller drives it with StepOnce or
+// StepToBreakpoint, or runs it with AoResume
+func (fa *FlowAction) ReplayToStep(loader StepLoader, instanceID string, step int) (*Instance, error) {
+
+	state, err := loader.LoadStep(instanceID, step-1)
+
+	if err == ErrStepNotFound {
+		notRecorded := &StepNotRecordedError{InstanceID: instanceID, Step: step}
+
+		if steps := loader.RecordedSteps(instanceID); len(steps) > 0 {
+			notRecorded.First = steps[0] + 1
+			notRecorded.Last = steps[len(steps)-1] + 1
+		}
+
+		return nil, notRecorded
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load step %d of Flow instance [%s] - %s", step-1, instanceID, err.Error())
+	}
+
+	instance := &Instance{}
+
+	if err := json.Unmarshal(state, instance); err != nil {
+		return nil, fmt.Errorf("Invalid state of step %d of Flow instance [%s] - %s", step-1, instanceID, err.Error())
+	}
+
+	if err := instance.Restart(instanceID, fa.flowProvider); err != nil {
+		return nil, err
+	}
+
+	fa.configure(instance, nil)
+
+	logger.Infof("Replayed Flow [%s] to step %d", instanceID, step)
+
+	return instance, nil
+}
@@ -0,0 +1,46 @@
+package flowinst
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestReplayToStep
+func TestReplayToStep(t *testing.T) {
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"budget": newTestDefinition(t, budgetDefJSON)}}
+
+	store := NewInMemoryStateRecorder()
+	fa := NewFlowAction(provider, store, &ActionOptions{Record: true})
+
+	_, result, err := fa.RunSync(nil, "budget", &RunOptions{ReturnResult: true})
+	assert.Nil(t, err)
+
+	id := result.(*FlowResult).ID
+
+	// b is executed by step 3
+	instance, err := fa.ReplayToStep(store, id, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, id, instance.ID())
+	assert.Equal(t, 2, instance.StepID())
+	assert.Equal(t, 3, instance.WorkUnits())
+
+	next, _ := instance.peekWorkItem()
+	assert.Equal(t, "b", next.TaskData.Task().Name())
+
+	hasWork, err := fa.StepOnce(instance)
+	assert.True(t, hasWork)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, instance.WorkUnits())
+
+	// the state before the first step isn't recorded
+	steps := store.RecordedSteps(id)
+	_, err = fa.ReplayToStep(store, id, 1)
+	assert.EqualError(t, err, fmt.Sprintf("Unable to replay Flow instance [%s] to step 1, the recorded steps can be replayed to steps 2 to %d", id, steps[len(steps)-1]+1))
+
+	_, err = fa.ReplayToStep(store, "unknown", 2)
+	assert.EqualError(t, err, "Unable to replay Flow instance [unknown] to step 2, none of its steps were recorded")
+}
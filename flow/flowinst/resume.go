@@ -0,0 +1,236 @@
+package flowinst
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// ErrSnapshotNotFound is returned by a SnapshotLoader that has no snapshot of
+// the requested instance
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// SnapshotLoader is the read side of a StateRecorder, it loads the snapshots
+// the recorder persisted
+type SnapshotLoader interface {
+
+	// LoadSnapshot returns the latest snapshot of the instance serialized as
+	// JSON, or ErrSnapshotNotFound
+	LoadSnapshot(instanceID string) ([]byte, error)
+}
+
+// InMemoryStateRecorder is a StateRecorder that keeps the snapshots of each
+// instance in memory, it is also the SnapshotLoader and StateReader of the
+// snapshots.  As it keeps every snapshot, it is meant for tests and local
+// development, see Snapshots and Steps
+type InMemoryStateRecorder struct {
+	// SkipSteps disables keeping the state of the instance after each
+	// recorded step, by default the steps are kept so they can be asserted
+	// and replayed, see Steps and FlowAction.ReplayToStep
+	SkipSteps bool
+
+	mu        sync.Mutex
+	snapshots map[string][]byte
+	summaries map[string]*InstanceSummary
+	history   map[string][][]byte
+	steps     map[string]map[int][]byte
+	recorded  map[string]time.Time
+
+	storeClock
+}
+
+// NewInMemoryStateRecorder creates a new InMemoryStateRecorder
+func NewInMemoryStateRecorder() *InMemoryStateRecorder {
+	return &InMemoryStateRecorder{snapshots: make(map[string][]byte), summaries: make(map[string]*InstanceSummary), history: make(map[string][][]byte), recorded: make(map[string]time.Time)}
+}
+
+// RecordSnapshot implements StateRecorder.RecordSnapshot
+func (sr *InMemoryStateRecorder) RecordSnapshot(instance *Instance) {
+
+	snapshot, err := json.Marshal(instance)
+	if err != nil {
+		logger.Warnf("Unable to serialize the snapshot of Flow [%s] - %s", instance.ID(), err.Error())
+		return
+	}
+
+	recorded := sr.now()
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	sr.snapshots[instance.ID()] = snapshot
+	sr.summaries[instance.ID()] = newInstanceSummary(instance)
+	sr.history[instance.ID()] = append(sr.history[instance.ID()], snapshot)
+	sr.recorded[instance.ID()] = recorded
+}
+
+// EvictExpired implements Expirable.EvictExpired, the snapshots and steps of
+// the finished instances last recorded before the cutoff are evicted
+func (sr *InMemoryStateRecorder) EvictExpired(cutoff time.Time) int {
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	evicted := 0
+
+	for id, recorded := range sr.recorded {
+		if !sr.summaries[id].Status.IsTerminal() || recorded.After(cutoff) {
+			continue
+		}
+
+		delete(sr.snapshots, id)
+		delete(sr.summaries, id)
+		delete(sr.history, id)
+		delete(sr.steps, id)
+		delete(sr.recorded, id)
+		evicted++
+	}
+
+	return evicted
+}
+
+// RecordStep implements StateRecorder.RecordStep, the steps are kept unless
+// SkipSteps is set
+func (sr *InMemoryStateRecorder) RecordStep(instance *Instance) {
+
+	if sr.SkipSteps {
+		return
+	}
+
+	state, err := json.Marshal(instance)
+	if err != nil {
+		logger.Warnf("Unable to serialize step %d of Flow [%s] - %s", instance.StepID(), instance.ID(), err.Error())
+		return
+	}
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if sr.steps == nil {
+		sr.steps = make(map[string]map[int][]byte)
+	}
+
+	if sr.steps[instance.ID()] == nil {
+		sr.steps[instance.ID()] = make(map[int][]byte)
+	}
+
+	sr.steps[instance.ID()][instance.StepID()] = state
+}
+
+// LoadStep implements StepLoader.LoadStep
+func (sr *InMemoryStateRecorder) LoadStep(instanceID string, step int) ([]byte, error) {
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	state, ok := sr.steps[instanceID][step]
+	if !ok {
+		return nil, ErrStepNotFound
+	}
+
+	return state, nil
+}
+
+// RecordedSteps implements StepLoader.RecordedSteps
+func (sr *InMemoryStateRecorder) RecordedSteps(instanceID string) []int {
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	steps := make([]int, 0, len(sr.steps[instanceID]))
+	for step := range sr.steps[instanceID] {
+		steps = append(steps, step)
+	}
+
+	sort.Ints(steps)
+
+	return steps
+}
+
+// Snapshots returns the snapshots recorded for the instance, in the order they
+// were recorded
+func (sr *InMemoryStateRecorder) Snapshots(instanceID string) []*Instance {
+
+	sr.mu.Lock()
+	history := sr.history[instanceID]
+	sr.mu.Unlock()
+
+	snapshots := make([]*Instance, 0, len(history))
+	for _, snapshot := range history {
+		if instance, err := decodeSnapshot(instanceID, snapshot); err == nil {
+			snapshots = append(snapshots, instance)
+		} else {
+			logger.Warn(err.Error())
+		}
+	}
+
+	return snapshots
+}
+
+// Steps returns the state of the instance after each of its recorded steps,
+// ordered by step, the steps aren't kept if SkipSteps is set
+func (sr *InMemoryStateRecorder) Steps(instanceID string) []*Instance {
+
+	stepIDs := sr.RecordedSteps(instanceID)
+
+	steps := make([]*Instance, 0, len(stepIDs))
+	for _, stepID := range stepIDs {
+		state, err := sr.LoadStep(instanceID, stepID)
+		if err != nil {
+			continue
+		}
+
+		if instance, err := decodeSnapshot(instanceID, state); err == nil {
+			steps = append(steps, instance)
+		} else {
+			logger.Warn(err.Error())
+		}
+	}
+
+	return steps
+}
+
+// LoadSnapshot implements SnapshotLoader.LoadSnapshot
+func (sr *InMemoryStateRecorder) LoadSnapshot(instanceID string) ([]byte, error) {
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	snapshot, ok := sr.snapshots[instanceID]
+	if !ok {
+		return nil, ErrSnapshotNotFound
+	}
+
+	return snapshot, nil
+}
+
+// ResumeByID resumes the instance from its latest snapshot, as loaded from the
+// loader, using AoResume
+func (fa *FlowAction) ResumeByID(ctx context.Context, loader SnapshotLoader, instanceID string, handler action.ResultHandler) error {
+
+	snapshot, err := loader.LoadSnapshot(instanceID)
+	if err != nil {
+		return fmt.Errorf("Unable to load the snapshot of Flow instance [%s] - %s", instanceID, err.Error())
+	}
+
+	instance := &Instance{}
+
+	if err := json.Unmarshal(snapshot, instance); err != nil {
+		return fmt.Errorf("Invalid snapshot of Flow instance [%s] - %s", instanceID, err.Error())
+	}
+
+	if instance.ID() != instanceID {
+		return fmt.Errorf("Snapshot of Flow instance [%s] is of instance [%s]", instanceID, instance.ID())
+	}
+
+	logger.Infof("Resuming Flow [%s] from its snapshot", instanceID)
+
+	return fa.Run(ctx, instance.FlowURI, &RunOptions{Op: AoResume, InitialState: instance}, handler)
+}
@@ -0,0 +1,111 @@
+package flowinst
+
+import (
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestInMemoryStateRecorder
+func TestInMemoryStateRecorder(t *testing.T) {
+
+	// the steps are recorded by default
+	recorder := NewInMemoryStateRecorder()
+
+	fa := NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	id := handler.results[0].(*IDResponse).ID
+
+	// the instance is recorded as active until it completes
+	snapshots := recorder.Snapshots(id)
+	assert.True(t, len(snapshots) > 1)
+
+	for _, snapshot := range snapshots[:len(snapshots)-1] {
+		assert.Equal(t, StatusActive, snapshot.Status())
+	}
+	assert.Equal(t, StatusCompleted, snapshots[len(snapshots)-1].Status())
+
+	var stepIDs []int
+	for _, step := range recorder.Steps(id) {
+		assert.Equal(t, id, step.ID())
+		stepIDs = append(stepIDs, step.StepID())
+	}
+	assert.Equal(t, recorder.RecordedSteps(id), stepIDs)
+	assert.NotEmpty(t, stepIDs)
+
+	assert.Empty(t, recorder.Snapshots("unknown"))
+	assert.Empty(t, recorder.Steps("unknown"))
+
+	// unless they are skipped
+	recorder = NewInMemoryStateRecorder()
+	recorder.SkipSteps = true
+
+	fa = NewFlowAction(newTestFlowProvider(t), recorder, &ActionOptions{Record: true})
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "test", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	id = handler.results[0].(*IDResponse).ID
+	assert.NotEmpty(t, recorder.Snapshots(id))
+	assert.Empty(t, recorder.Steps(id))
+}
+
+//TestResumeByID
+func TestResumeByID(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+
+	store := NewInMemoryStateRecorder()
+	fa := NewFlowAction(provider, store, &ActionOptions{Record: true, TaskScheduler: &FIFOTaskScheduler{}})
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "gated", nil, handler)
+	assert.Nil(t, err)
+
+	// the process "crashes" while 'a' is executing, the snapshot of the
+	// previous step is the latest state
+	<-gate.entered
+	id := handler.results[0].(*IDResponse).ID
+
+	crashed := NewInMemoryStateRecorder()
+	crashed.snapshots[id], err = store.LoadSnapshot(id)
+	assert.Nil(t, err)
+
+	gate.release <- true
+	<-handler.done
+
+	recorder := &testStateRecorder{}
+	resumer := NewFlowAction(provider, recorder, &ActionOptions{Record: true})
+
+	handler = newTestResultHandler()
+	err = resumer.ResumeByID(nil, crashed, id, handler)
+	assert.Nil(t, err)
+
+	<-gate.entered
+	gate.release <- true
+	<-handler.done
+
+	// 'a' and 'b' were left to execute
+	assert.Equal(t, 2, recorder.steps)
+	assert.Equal(t, id, recorder.instance.ID())
+	assert.Equal(t, StatusCompleted, recorder.instance.Status())
+
+	err = resumer.ResumeByID(nil, crashed, "unknown", newTestResultHandler())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), ErrSnapshotNotFound.Error())
+}
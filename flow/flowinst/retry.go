@@ -0,0 +1,121 @@
+package flowinst
+
+import (
+	"context"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/activity"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+	"github.com/TIBCOSoftware/flogo-lib/util"
+)
+
+// DefaultMaxTaskRetries is the default number of times a task that failed with
+// a retryable error is retried
+const DefaultMaxTaskRetries = 3
+
+// ErrorClassifier determines if an error produced by a task is retryable
+type ErrorClassifier func(err error) (retryable bool)
+
+// NoRetryClassifier is the default ErrorClassifier, it classifies no error
+// as retryable
+func NoRetryClassifier(err error) bool {
+	return false
+}
+
+// RetryableErrorClassifier classifies the errors marked using
+// activity.NewRetryableError as retryable
+func RetryableErrorClassifier(err error) bool {
+	return activity.IsRetryable(err)
+}
+
+// StepRetry configures the retry of the steps whose task failed with a
+// retryable error
+type StepRetry struct {
+
+	// MaxRetries is the maximum number of times a task is retried, defaults
+	// to ActionOptions.MaxTaskRetries
+	MaxRetries int
+
+	// Backoff is the delay before retrying a task
+	Backoff time.Duration
+
+	// BackoffFunc optionally computes the delay before the specified attempt,
+	// starting at 1, it takes precedence over Backoff
+	BackoffFunc func(attempt int) time.Duration
+}
+
+// delay returns the backoff before the specified attempt
+func (sr *StepRetry) delay(attempt int) time.Duration {
+
+	if sr.BackoffFunc != nil {
+		return sr.BackoffFunc(attempt)
+	}
+
+	return sr.Backoff
+}
+
+// backoff waits on the clock before the specified retry attempt, returns
+// false if ctx was done before
+func (sr *StepRetry) backoff(clock Clock, ctx context.Context, attempt int) bool {
+
+	delay := sr.delay(attempt)
+
+	if delay <= 0 {
+		return true
+	}
+
+	elapsed := make(chan struct{})
+
+	stop := util.AfterFunc(clock, delay, func() {
+		close(elapsed)
+	})
+	defer stop()
+
+	select {
+	case <-elapsed:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SetErrorClassifier sets the ErrorClassifier used to determine if a failed task
+// should be retried, and the maximum number of retries of a task
+func (pi *Instance) SetErrorClassifier(classifier ErrorClassifier, maxRetries int) {
+	pi.errorClassifier = classifier
+	pi.maxTaskRetries = maxRetries
+}
+
+// shouldRetry indicates if the task should be retried for the specified error,
+// it keeps track of the number of retries of the task
+func (pi *Instance) shouldRetry(taskData *TaskData, err error) bool {
+
+	if pi.errorClassifier == nil || !pi.errorClassifier(err) {
+		return false
+	}
+
+	taskID := taskData.task.ID()
+
+	if pi.taskRetries[taskID] >= pi.maxTaskRetries {
+		logger.Infof("Task '%s' exhausted its %d retries", taskData.task.Name(), pi.maxTaskRetries)
+		return false
+	}
+
+	if pi.taskRetries == nil {
+		pi.taskRetries = make(map[int]int)
+	}
+
+	pi.taskRetries[taskID]++
+
+	if pi.taskRetries[taskID] > pi.stepRetries {
+		pi.stepRetries = pi.taskRetries[taskID]
+	}
+
+	return true
+}
+
+// StepRetries returns the highest retry attempt scheduled by the last step,
+// zero if no task was retried
+func (pi *Instance) StepRetries() int {
+	return pi.stepRetries
+}
@@ -0,0 +1,95 @@
+package flowinst
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/activity"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/TIBCOSoftware/flogo-lib/flow/model"
+	"github.com/TIBCOSoftware/flogo-lib/flow/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// transientTaskBehavior fails the first evaluations of its tasks with the error
+type transientTaskBehavior struct {
+	*test.SimpleTaskBehavior
+	failures int
+	err      error
+	evals    int
+}
+
+func (b *transientTaskBehavior) Eval(context model.TaskContext, evalCode int) (done bool, doneCode int, err error) {
+	b.evals++
+	if b.failures > 0 {
+		b.failures--
+		return false, 0, b.err
+	}
+	return b.SimpleTaskBehavior.Eval(context, evalCode)
+}
+
+var transient = &transientTaskBehavior{SimpleTaskBehavior: &test.SimpleTaskBehavior{}}
+
+func init() {
+	m := model.New("transient")
+	m.RegisterFlowBehavior(&test.SimpleFlowBehavior{})
+	m.RegisterTaskBehavior(1, &allChildrenTaskBehavior{SimpleTaskBehavior: &test.SimpleTaskBehavior{}})
+	m.RegisterTaskBehavior(2, transient)
+	registerModel(m)
+}
+
+//TestStepRetry
+func TestStepRetry(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("flaky").Model("transient").
+		AddTask(2, 2, "a", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"flaky": def}}
+
+	var steps []int
+	observer := &testObserver{}
+
+	// the backoff elapses on the clock of the action
+	clock := &testClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	options := &ActionOptions{MaxStepCount: 3, StepRetry: &StepRetry{MaxRetries: 3}, Clock: clock}
+	options.StepRetry.BackoffFunc = func(attempt int) time.Duration {
+		steps = append(steps, attempt)
+		return time.Hour
+	}
+
+	fa := NewFlowAction(provider, nil, options)
+	fa.AddObserver(observer)
+
+	// the retryable failures are retried with backoff and don't use up steps
+	transient.failures, transient.evals, transient.err = 2, 0, activity.NewRetryableError(errTooManyRequests)
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(nil, "flaky", nil, handler)
+	assert.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		waitFor(t, func() bool { return clock.pending() > 0 })
+		clock.advance(time.Hour)
+	}
+	<-handler.done
+
+	assert.Equal(t, []int{200}, handler.codes)
+	assert.Equal(t, []int{1, 2}, steps)
+	assert.Equal(t, 3, transient.evals)
+	assert.Equal(t, "complete:completed", observer.events[len(observer.events)-1])
+
+	// terminal errors fail fast
+	steps = nil
+	transient.failures, transient.evals, transient.err = 2, 0, errTooManyRequests
+
+	handler = &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(nil, "flaky", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Empty(t, steps)
+	assert.Equal(t, 1, transient.evals)
+}
@@ -0,0 +1,85 @@
+package flowinst
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/action"
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// CompensationResult is a result of the compensation flow of a failed run, it
+// is reported to the caller of the failed run after the results of the run
+type CompensationResult struct {
+	FailedInstanceID string      `json:"failedInstanceId"`
+	Data             interface{} `json:"data"`
+}
+
+// compensationResultHandler reports the results of a compensation flow to the
+// handler of the run that failed
+type compensationResultHandler struct {
+	handler          action.ResultHandler
+	failedInstanceID string
+	done             chan struct{}
+}
+
+// HandleResult implements action.ResultHandler.HandleResult
+func (rh *compensationResultHandler) HandleResult(code int, data interface{}, err error) {
+	rh.handler.HandleResult(code, &CompensationResult{FailedInstanceID: rh.failedInstanceID, Data: data}, err)
+}
+
+// Done implements action.ResultHandler.Done
+func (rh *compensationResultHandler) Done() {
+	close(rh.done)
+}
+
+// compensate runs the compensation flow for the failed instance and waits for
+// it to finish.  The compensation instance is seeded with the attributes of
+// the failed instance and the trigger attribute 'failedInstanceId', it gets
+// its ID and its execution slot like the failed run did, using its
+// IDNamespace and Priority
+func (fa *FlowAction) compensate(uri string, failed *Instance, failedRO *RunOptions, handler action.ResultHandler) {
+
+	flow, _ := fa.flowProvider.GetFlow(uri)
+
+	if flow == nil {
+		err := fmt.Errorf("Compensation flow [%s] for Flow [%s] not found", uri, failed.ID())
+		logger.Error(err.Error())
+		handler.HandleResult(500, &CompensationResult{FailedInstanceID: failed.ID()}, err)
+		return
+	}
+
+	ro := &RunOptions{IDNamespace: failedRO.IDNamespace, Priority: failedRO.Priority}
+
+	instanceID, err := fa.newInstanceID(ro)
+	if err != nil {
+		logger.Errorf("Unable to compensate Flow [%s] - %s", failed.ID(), err.Error())
+		handler.HandleResult(500, &CompensationResult{FailedInstanceID: failed.ID()}, err)
+		return
+	}
+
+	logger.Infof("Compensating Flow [%s] with [%s] - Instance: %s", failed.ID(), uri, instanceID)
+
+	instance := NewFlowInstance(instanceID, uri, flow)
+	instance.SetCorrelationID(failed.CorrelationID())
+
+	instance.Attrs = make(map[string]*data.Attribute, len(failed.Attrs))
+	for name, attr := range failed.Attrs {
+		instance.Attrs[name] = data.NewAttribute(attr.Name, attr.Type, attr.Value)
+	}
+
+	// the compensation has to run even if the caller of the failed run is gone
+	ctx := trigger.NewContext(context.Background(), []*data.Attribute{data.NewAttribute("failedInstanceId", data.STRING, failed.ID())})
+
+	compHandler := &compensationResultHandler{handler: handler, failedInstanceID: failed.ID(), done: make(chan struct{})}
+
+	if err := fa.execute(ctx, AoStart, instance, ro, compHandler); err != nil {
+		logger.Errorf("Unable to compensate Flow [%s] - %s", failed.ID(), err.Error())
+		handler.HandleResult(500, &CompensationResult{FailedInstanceID: failed.ID()}, err)
+		return
+	}
+
+	<-compHandler.done
+}
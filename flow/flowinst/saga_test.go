@@ -0,0 +1,119 @@
+package flowinst
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+// uriStateRecorder keeps the last instance recorded for each flow
+type uriStateRecorder struct {
+	mu        sync.Mutex
+	instances map[string]*Instance
+}
+
+func (sr *uriStateRecorder) RecordSnapshot(instance *Instance) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.instances[instance.FlowURI] = instance
+}
+
+func (sr *uriStateRecorder) RecordStep(instance *Instance) {
+}
+
+//TestCompensation
+func TestCompensation(t *testing.T) {
+
+	ok, err := flowdef.NewBuilder().Name("ok").Model("budget").AddTask(2, 2, "a", "").Build()
+	assert.Nil(t, err)
+
+	undo, err := flowdef.NewBuilder().Name("undo").Model("budget").AddTask(2, 2, "a", "").Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{
+		"budget": newTestDefinition(t, budgetDefJSON),
+		"ok":     ok,
+		"undo":   undo,
+	}}
+
+	recorder := &uriStateRecorder{instances: make(map[string]*Instance)}
+	fa := NewFlowAction(provider, recorder, &ActionOptions{CheckpointStrategy: &StatusChangeCheckpoint{}, MaxWorkUnits: 5})
+
+	ctx := trigger.NewContext(context.Background(), []*data.Attribute{data.NewAttribute("orderId", data.STRING, "order-1")})
+
+	// the flow exceeds its budget and fails, so it is compensated
+	handler := newTestResultHandler()
+	err = fa.Run(ctx, "budget", &RunOptions{CompensationURI: "undo"}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	failed := recorder.instances["budget"]
+	assert.Equal(t, StatusFailed, failed.Status())
+
+	compensation := recorder.instances["undo"]
+	assert.NotNil(t, compensation)
+	assert.Equal(t, StatusCompleted, compensation.Status())
+
+	orderID, _ := compensation.GetAttr("{T.orderId}")
+	assert.Equal(t, "order-1", orderID.Value)
+	failedID, _ := compensation.GetAttr("{T.failedInstanceId}")
+	assert.Equal(t, failed.ID(), failedID.Value)
+
+	// the result of the run followed by the result of the compensation
+	assert.Len(t, handler.results, 2)
+	assert.Equal(t, failed.ID(), handler.results[0].(*IDResponse).ID)
+	assert.Equal(t, &CompensationResult{FailedInstanceID: failed.ID(), Data: fa.newIDResponse(compensation.ID())}, handler.results[1])
+
+	// a successful run isn't compensated
+	delete(recorder.instances, "undo")
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "ok", &RunOptions{CompensationURI: "undo"}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, StatusCompleted, recorder.instances["ok"].Status())
+	assert.Nil(t, recorder.instances["undo"])
+	assert.Len(t, handler.results, 1)
+}
+
+//TestCompensationRunOptions
+func TestCompensationRunOptions(t *testing.T) {
+
+	undo, err := flowdef.NewBuilder().Name("undo").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{
+		"budget": newTestDefinition(t, budgetDefJSON),
+		"undo":   undo,
+	}}
+
+	recorder := &uriStateRecorder{instances: make(map[string]*Instance)}
+	fa := NewFlowAction(provider, recorder, &ActionOptions{CheckpointStrategy: &StatusChangeCheckpoint{}, MaxWorkUnits: 5,
+		MaxConcurrentInstances: 1, ConcurrencyPolicy: ConcurrencyReject})
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "budget", &RunOptions{CompensationURI: "undo", IDNamespace: "tenant1"}, handler)
+	assert.Nil(t, err)
+
+	// the compensation holds the execution slot
+	<-gate.entered
+	err = fa.Run(nil, "budget", nil, newTestResultHandler())
+	assert.Equal(t, ErrTooManyInstances, err)
+
+	gate.release <- true
+	<-handler.done
+
+	// the compensation ID is in the namespace of the failed run
+	compensation := recorder.instances["undo"]
+	assert.NotNil(t, compensation)
+	assert.True(t, strings.HasPrefix(compensation.ID(), "tenant1"+IDNamespaceSeparator))
+}
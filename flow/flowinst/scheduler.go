@@ -0,0 +1,83 @@
+package flowinst
+
+// TaskScheduler determines the order in which the ready tasks of an instance
+// are executed
+type TaskScheduler interface {
+
+	// Next returns the work item that should be executed next, the ready work
+	// items are in the order they were scheduled
+	Next(instance *Instance, ready []*WorkItem) *WorkItem
+}
+
+// LIFOTaskScheduler is the default TaskScheduler, it executes the most
+// recently scheduled task first, which is the order in which the work item
+// queue is popped
+type LIFOTaskScheduler struct {
+}
+
+// Next implements TaskScheduler.Next
+func (s *LIFOTaskScheduler) Next(instance *Instance, ready []*WorkItem) *WorkItem {
+	return ready[len(ready)-1]
+}
+
+// FIFOTaskScheduler executes the tasks in the order they were scheduled
+type FIFOTaskScheduler struct {
+}
+
+// Next implements TaskScheduler.Next
+func (s *FIFOTaskScheduler) Next(instance *Instance, ready []*WorkItem) *WorkItem {
+	return ready[0]
+}
+
+var defaultTaskScheduler = &LIFOTaskScheduler{}
+
+// nextWorkItem removes the next work item to execute from the queue
+func (pi *Instance) nextWorkItem() (*WorkItem, bool) {
+
+	workItem, ok := pi.peekWorkItem()
+	pi.scheduled = nil
+
+	if !ok || !pi.WorkItemQueue.Remove(workItem) {
+		return nil, false
+	}
+
+	return workItem, true
+}
+
+// peekWorkItem returns the next work item to execute without removing it from
+// the queue.  The TaskScheduler is only asked once until the work item is
+// removed, so the work item that is peeked is the one that is executed
+func (pi *Instance) peekWorkItem() (*WorkItem, bool) {
+
+	items := pi.WorkItemQueue.Items()
+
+	if len(items) == 0 {
+		return nil, false
+	}
+
+	if pi.scheduled != nil {
+		for _, item := range items {
+			if item == pi.scheduled {
+				return pi.scheduled, true
+			}
+		}
+	}
+
+	// items are pushed to the front of the queue
+	ready := make([]*WorkItem, len(items))
+
+	for i, item := range items {
+		ready[len(items)-1-i] = item.(*WorkItem)
+	}
+
+	scheduler := pi.scheduler
+
+	if scheduler == nil {
+		scheduler = defaultTaskScheduler
+	}
+
+	workItem := scheduler.Next(pi, ready)
+	pi.scheduled = workItem
+
+	return workItem, workItem != nil
+}
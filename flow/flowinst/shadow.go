@@ -0,0 +1,90 @@
+package flowinst
+
+import (
+	"sync"
+
+	"github.com/TIBCOSoftware/flogo-lib/flow/activity"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+var (
+	shadowStubsMu sync.RWMutex
+	shadowStubs   = make(map[string]activity.Activity)
+)
+
+// RegisterShadowStub registers the side effect free stub that is evaluated in
+// place of the specified activity when an instance runs in shadow mode
+func RegisterShadowStub(activityID string, stub activity.Activity) {
+	shadowStubsMu.Lock()
+	defer shadowStubsMu.Unlock()
+
+	shadowStubs[activityID] = stub
+}
+
+func getShadowStub(activityID string) activity.Activity {
+	shadowStubsMu.RLock()
+	defer shadowStubsMu.RUnlock()
+
+	return shadowStubs[activityID]
+}
+
+// SetShadow sets the instance to run in shadow mode, in which activities are
+// never evaluated, their registered stubs are evaluated instead and activities
+// without a stub are skipped. The outputs of the tasks are kept for comparison.
+func (pi *Instance) SetShadow(shadow bool) {
+	pi.shadow = shadow
+}
+
+// Shadow indicates if the instance runs in shadow mode
+func (pi *Instance) Shadow() bool {
+	return pi.shadow
+}
+
+// ShadowOutputs returns the outputs of the tasks evaluated in shadow mode, by task ID
+func (pi *Instance) ShadowOutputs() map[int]map[string]interface{} {
+	pi.shadowLock.Lock()
+	defer pi.shadowLock.Unlock()
+
+	outputs := make(map[int]map[string]interface{}, len(pi.shadowOutputs))
+
+	for taskID, values := range pi.shadowOutputs {
+		outputs[taskID] = values
+	}
+
+	return outputs
+}
+
+// evalShadowActivity evaluates the stub of the activity, if there is one, and
+// keeps the resulting outputs
+func (td *TaskData) evalShadowActivity(act activity.Activity) (done bool, err error) {
+
+	stub := getShadowStub(act.Metadata().ID)
+
+	if stub != nil {
+		done, err = stub.Eval(td)
+	} else {
+		logger.Debugf("Shadow mode, skipping activity '%s'", act.Metadata().ID)
+		done = true
+	}
+
+	outputs := make(map[string]interface{}, len(act.Metadata().Outputs))
+
+	for name := range act.Metadata().Outputs {
+		if attr, ok := td.OutputScope().GetAttr(name); ok {
+			outputs[name] = attr.Value
+		}
+	}
+
+	pi := td.taskEnv.Instance
+
+	pi.shadowLock.Lock()
+	defer pi.shadowLock.Unlock()
+
+	if pi.shadowOutputs == nil {
+		pi.shadowOutputs = make(map[int]map[string]interface{})
+	}
+
+	pi.shadowOutputs[td.task.ID()] = outputs
+
+	return done, err
+}
@@ -0,0 +1,107 @@
+package flowinst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// ErrShuttingDown is the error runs are rejected with once the FlowAction is
+// shutting down
+var ErrShuttingDown = errors.New("flow action is shutting down")
+
+// ShutdownError is the error of a Shutdown that had to cancel instances that
+// were still executing when its context was done
+type ShutdownError struct {
+	// Stopped is the number of instances that were cancelled
+	Stopped int
+
+	// InstanceIDs are the IDs of the cancelled instances that were executing,
+	// they may still be stopping when Shutdown returns
+	InstanceIDs []string
+
+	// Err is the error of the context
+	Err error
+}
+
+// Error implements error.Error
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("Shutdown cancelled %d flow instance(s) [%s] - %s", e.Stopped, strings.Join(e.InstanceIDs, ", "), e.Err.Error())
+}
+
+// trackRun counts a run that is about to be executed, it fails with
+// ErrShuttingDown once Shutdown was called unless the run is the subflow of a
+// run that is already tracked
+func (fa *FlowAction) trackRun(subflow bool) error {
+
+	fa.runningMu.Lock()
+	defer fa.runningMu.Unlock()
+
+	if fa.shuttingDown && !subflow {
+		return ErrShuttingDown
+	}
+
+	fa.running.Add(1)
+	return nil
+}
+
+// Shutdown stops accepting new runs and waits until the instances being
+// executed are done, the subflows started by these instances are still
+// accepted.  If the context is done first, the remaining instances are
+// cancelled, they stop before their next step, the runs waiting for an
+// execution slot fail with ErrShuttingDown, and a ShutdownError listing the
+// cancelled instances is returned without waiting for them to stop.  The
+// FlowAction is closed once its instances stopped
+func (fa *FlowAction) Shutdown(ctx context.Context) error {
+
+	fa.runningMu.Lock()
+	fa.shuttingDown = true
+	fa.runningMu.Unlock()
+
+	drained := make(chan struct{})
+
+	go func() {
+		fa.running.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		logger.Info("Flow instances drained")
+		fa.Close()
+		return nil
+	case <-ctx.Done():
+	}
+
+	// the cancelled instances may still be recording their state
+	go func() {
+		<-drained
+		fa.Close()
+	}()
+
+	fa.liveMu.Lock()
+	runs := make([]*liveRun, 0, len(fa.live))
+	for _, run := range fa.live {
+		runs = append(runs, run)
+	}
+	fa.liveMu.Unlock()
+
+	ids := make([]string, 0, len(runs))
+
+	for _, run := range runs {
+		logger.Infof("Flow [%s] Cancelled by shutdown [correlation: %s]", run.instance.ID(), run.instance.CorrelationID())
+		run.cancel()
+		ids = append(ids, run.instance.ID())
+	}
+
+	stopped := len(runs)
+
+	if fa.slots != nil {
+		stopped += fa.slots.cancelWaiters()
+	}
+
+	return &ShutdownError{Stopped: stopped, InstanceIDs: ids, Err: ctx.Err()}
+}
@@ -0,0 +1,126 @@
+package flowinst
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestShutdown
+func TestShutdown(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTask(3, 2, "b", "").
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+
+	// the instance finishes before the deadline
+	fa := NewFlowAction(provider, nil, &ActionOptions{TaskScheduler: &FIFOTaskScheduler{}})
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "gated", nil, handler)
+	assert.Nil(t, err)
+	<-gate.entered
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- fa.Shutdown(context.Background())
+	}()
+
+	gate.release <- true
+	<-handler.done
+
+	assert.Nil(t, <-shutdown)
+
+	// no new runs are accepted
+	err = fa.Run(nil, "gated", nil, newTestResultHandler())
+	assert.Equal(t, ErrShuttingDown, err)
+
+	// the instance is cancelled and the run waiting for its slot rejected
+	errHandler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	fa = NewFlowAction(provider, nil, &ActionOptions{TaskScheduler: &FIFOTaskScheduler{}, MaxConcurrentInstances: 1})
+
+	err = fa.Run(nil, "gated", nil, errHandler)
+	assert.Nil(t, err)
+	<-gate.entered
+
+	blocked := make(chan error)
+	go func() {
+		blocked <- fa.Run(nil, "gated", nil, newTestResultHandler())
+	}()
+
+	waitFor(t, func() bool { return waiting(fa) == 1 })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	go func() {
+		shutdown <- fa.Shutdown(ctx)
+	}()
+
+	// the waiters are rejected after the instances were cancelled
+	assert.Equal(t, ErrShuttingDown, <-blocked)
+
+	// Shutdown doesn't wait for the cancelled instance to stop
+	err = <-shutdown
+
+	shutdownErr, ok := err.(*ShutdownError)
+	assert.True(t, ok)
+	assert.Equal(t, 2, shutdownErr.Stopped)
+	assert.Equal(t, 1, len(shutdownErr.InstanceIDs))
+	assert.Equal(t, context.Canceled, shutdownErr.Err)
+
+	gate.release <- true
+	<-errHandler.done
+
+	assert.Equal(t, CodeCancelled, errHandler.codes[len(errHandler.codes)-1])
+}
+
+//TestShutdownSubflow
+func TestShutdownSubflow(t *testing.T) {
+
+	call := newSubflowActivity("shutdownsubflow", "test")
+
+	def, err := flowdef.NewBuilder().Name("draining").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		AddTaskRep(&flowdef.TaskRep{ID: 3, TypeID: 2, Name: "call", ActivityType: "shutdownsubflow", OutputMappings: []*data.MappingDef{}}).
+		Build()
+	assert.Nil(t, err)
+
+	provider := newTestFlowProvider(t)
+	provider.flows["draining"] = def
+
+	fa := NewFlowAction(provider, nil, &ActionOptions{TaskScheduler: &FIFOTaskScheduler{}})
+
+	handler := newTestResultHandler()
+	err = fa.Run(nil, "draining", nil, handler)
+	assert.Nil(t, err)
+	<-gate.entered
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- fa.Shutdown(context.Background())
+	}()
+
+	waitFor(t, func() bool {
+		fa.runningMu.Lock()
+		defer fa.runningMu.Unlock()
+		return fa.shuttingDown
+	})
+
+	// the subflow of the draining instance is still started
+	gate.release <- true
+	<-handler.done
+
+	assert.Nil(t, <-shutdown)
+
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	assert.Nil(t, call.errs[len(call.errs)-1])
+}
@@ -1,5 +1,7 @@
 package flowinst
 
+import "strconv"
+
 // Status is value that indicates the status of a Flow Instance
 type Status int
 
@@ -10,6 +12,10 @@ const (
 	// StatusActive indicates that the FlowInstance is active
 	StatusActive Status = 100
 
+	// StatusPaused indicates that the FlowInstance is active but has been
+	// paused between steps, see FlowAction.PauseInstance
+	StatusPaused Status = 200
+
 	// StatusCompleted indicates that the FlowInstance has been completed
 	StatusCompleted Status = 500
 
@@ -18,4 +24,43 @@ const (
 
 	// StatusFailed indicates that the FlowInstance has failed
 	StatusFailed Status = 700
+
+	// StatusAborted indicates that the FlowInstance has been aborted because
+	// it exceeded the max step count
+	StatusAborted Status = 800
+
+	// StatusTimedOut indicates that the FlowInstance has been stopped because
+	// it exceeded its deadline
+	StatusTimedOut Status = 900
 )
+
+// String returns the name of the status, ie. "completed"
+func (s Status) String() string {
+
+	switch s {
+	case StatusNotStarted:
+		return "not_started"
+	case StatusActive:
+		return "active"
+	case StatusPaused:
+		return "paused"
+	case StatusCompleted:
+		return "completed"
+	case StatusCancelled:
+		return "cancelled"
+	case StatusFailed:
+		return "failed"
+	case StatusAborted:
+		return "aborted"
+	case StatusTimedOut:
+		return "timed_out"
+	}
+
+	return strconv.Itoa(int(s))
+}
+
+// IsTerminal indicates if the status is final, ie. the instance is done
+// executing because it completed, failed or was stopped
+func (s Status) IsTerminal() bool {
+	return s >= StatusCompleted
+}
@@ -0,0 +1,113 @@
+package flowinst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/core/trigger"
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
+// subflowRunner runs a subflow on behalf of an instance
+type subflowRunner func(uri string, inputs map[string]interface{}) (map[string]interface{}, error)
+
+// RecursiveSubflowError is the error of a subflow that already appears in the
+// ancestry of the instance starting it
+type RecursiveSubflowError struct {
+	InstanceID string
+	URI        string
+	Ancestry   []string
+}
+
+// Error implements error.Error
+func (e *RecursiveSubflowError) Error() string {
+	return fmt.Sprintf("Flow [%s] cannot run subflow [%s], it is already running in its ancestry: %s", e.InstanceID, e.URI, strings.Join(e.Ancestry, " -> "))
+}
+
+// runSubflow starts the flow with the specified URI as a child of the parent
+// instance and waits until it is done.  The inputs are passed to the child as
+// trigger attributes and its attributes are returned as the output.  The
+// child runs in the execution slot of the parent and is cancelled with it, in
+// which case a ParentCancelledError is returned as soon as the context of the
+// parent is done, the child stops at its next step
+func (fa *FlowAction) runSubflow(ctx context.Context, parent *Instance, uri string, inputs map[string]interface{}) (map[string]interface{}, error) {
+
+	ancestry := append(append([]string(nil), parent.ancestry...), parent.FlowURI)
+
+	for _, ancestor := range ancestry {
+		if ancestor == uri {
+			return nil, &RecursiveSubflowError{InstanceID: parent.ID(), URI: uri, Ancestry: ancestry}
+		}
+	}
+
+	attrs := make([]*data.Attribute, 0, len(inputs))
+	for name, value := range inputs {
+		attrs = append(attrs, data.NewAttribute(name, data.ANY, value))
+	}
+
+	logger.Debugf("Flow [%s] running subflow [%s]", parent.ID(), uri)
+
+	ro := &RunOptions{ReturnResult: true, CorrelationID: parent.CorrelationID(), parent: parent}
+
+	childID, err := fa.newInstanceID(ro)
+	if err != nil {
+		return nil, err
+	}
+	ro.instanceID = childID
+
+	future, err := fa.RunAsync(trigger.NewContext(ctx, attrs), uri, ro)
+	if err != nil {
+		return nil, err
+	}
+
+	code, result, err := future.Wait(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, &ParentCancelledError{InstanceID: childID, ParentID: parent.ID(), Err: ctx.Err()}
+		}
+		return nil, err
+	}
+
+	flowResult, ok := result.(*FlowResult)
+	if !ok {
+		return nil, fmt.Errorf("Subflow [%s] of Flow [%s] didn't complete, code: %d", uri, parent.ID(), code)
+	}
+
+	if Status(flowResult.Status) == StatusCancelled && ctx.Err() != nil {
+		return nil, &ParentCancelledError{InstanceID: flowResult.ID, ParentID: parent.ID(), Err: ctx.Err()}
+	}
+
+	if Status(flowResult.Status) != StatusCompleted {
+		return nil, fmt.Errorf("Subflow [%s] of Flow [%s] ended with status '%s'", flowResult.ID, parent.ID(), Status(flowResult.Status))
+	}
+
+	outputs := make(map[string]interface{}, len(flowResult.Attrs))
+	for name, attr := range flowResult.Attrs {
+		outputs[name] = attr.Value
+	}
+
+	return outputs, nil
+}
+
+// cancelCause returns the error the run of the instance is cancelled with, a
+// subflow whose parent is done is cancelled with a ParentCancelledError
+func cancelCause(ctx context.Context, instance *Instance, err error) error {
+
+	if len(instance.ParentID()) > 0 && ctx.Err() != nil {
+		return &ParentCancelledError{InstanceID: instance.ID(), ParentID: instance.ParentID(), Err: ctx.Err()}
+	}
+
+	return err
+}
+
+// subflowCancelled indicates if the instance failed because one of its
+// subflows was cancelled with it
+func subflowCancelled(instance *Instance) bool {
+
+	var parentErr *ParentCancelledError
+
+	return errors.As(instance.LastError(), &parentErr) && parentErr.ParentID == instance.ID()
+}
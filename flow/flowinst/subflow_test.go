@@ -0,0 +1,284 @@
+package flowinst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/activity"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+const subflowDefJSON = `
+{
+    "type": 1,
+    "name": "%s",
+    "model": "budget",
+    "rootTask": {
+      "id": 1,
+      "type": 1,
+      "name": "root",
+      "tasks": [
+        { "id": 2, "type": 2, "activityType": "%s", "name": "call", "ouputMappings": [] }
+      ]
+    }
+  }
+`
+
+// subflowActivity runs the flow with the configured URI as a subflow
+type subflowActivity struct {
+	metadata *activity.Metadata
+	uri      string
+
+	mu      sync.Mutex
+	outputs []map[string]interface{}
+	errs    []error
+}
+
+func (a *subflowActivity) Metadata() *activity.Metadata {
+	return a.metadata
+}
+
+func (a *subflowActivity) Eval(context activity.Context) (done bool, err error) {
+
+	outputs, err := activity.RunSubflow(context, a.uri, map[string]interface{}{"orderId": "order-1"})
+
+	a.mu.Lock()
+	a.outputs = append(a.outputs, outputs)
+	a.errs = append(a.errs, err)
+	a.mu.Unlock()
+
+	return true, nil
+}
+
+// newSubflowActivity registers a subflowActivity that runs the flow with the
+// URI, the activity registered by a previous run of the tests is reset and
+// reused
+func newSubflowActivity(id string, uri string) *subflowActivity {
+
+	if act, ok := activity.Get(id).(*subflowActivity); ok {
+		act.mu.Lock()
+		act.uri = uri
+		act.outputs = nil
+		act.errs = nil
+		act.mu.Unlock()
+
+		return act
+	}
+
+	act := &subflowActivity{metadata: &activity.Metadata{ID: id}, uri: uri}
+	activity.Register(act)
+
+	return act
+}
+
+// parentRecorder records the parent of the recorded instances
+type parentRecorder struct {
+	mu      sync.Mutex
+	parents map[string]string
+}
+
+func (sr *parentRecorder) RecordSnapshot(instance *Instance) {
+	sr.mu.Lock()
+	sr.parents[instance.ID()] = instance.ParentID()
+	sr.mu.Unlock()
+}
+
+func (sr *parentRecorder) RecordStep(instance *Instance) {
+}
+
+//TestSubflow
+func TestSubflow(t *testing.T) {
+
+	call := newSubflowActivity("subflowcall", "test")
+	loop := newSubflowActivity("subflowloop", "parent")
+
+	provider := newTestFlowProvider(t)
+	provider.flows["parent"] = newTestDefinition(t, fmt.Sprintf(subflowDefJSON, "parent", "subflowcall"))
+	provider.flows["child"] = newTestDefinition(t, fmt.Sprintf(subflowDefJSON, "child", "subflowloop"))
+
+	recorder := &parentRecorder{parents: make(map[string]string)}
+	fa := NewFlowAction(provider, recorder, &ActionOptions{Record: true})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "parent", &RunOptions{ReturnID: true}, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	parentID := handler.results[0].(*IDResponse).ID
+
+	// the output of the subflow is the output of the step
+	assert.Equal(t, 1, len(call.outputs))
+	assert.Nil(t, call.errs[0])
+	assert.Equal(t, "order-1", call.outputs[0]["{T.orderId}"])
+
+	assert.Equal(t, 2, len(recorder.parents))
+	assert.Equal(t, "", recorder.parents[parentID])
+	for id, parent := range recorder.parents {
+		if id != parentID {
+			assert.Equal(t, parentID, parent)
+		}
+	}
+
+	// parent -> child -> parent is recursive
+	call.uri = "child"
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "parent", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Nil(t, call.errs[1])
+	assert.Equal(t, 1, len(loop.errs))
+
+	recursiveErr, ok := loop.errs[0].(*RecursiveSubflowError)
+	assert.True(t, ok)
+	assert.Equal(t, "parent", recursiveErr.URI)
+	assert.Equal(t, []string{"parent", "child"}, recursiveErr.Ancestry)
+}
+
+//TestSubflowCancellation
+func TestSubflowCancellation(t *testing.T) {
+
+	mid := newSubflowActivity("subflowmid", "mid")
+	leaf := newSubflowActivity("subflowleaf", "leaf")
+
+	// root -> mid -> leaf, each one has work left after its first task
+	provider := &testFlowProvider{flows: make(map[string]*flowdef.Definition)}
+
+	for _, flow := range [][]string{{"root", "subflowmid"}, {"mid", "subflowleaf"}, {"leaf", "gate"}} {
+		def, err := flowdef.NewBuilder().Name(flow[0]).Model("budget").
+			AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: flow[1], OutputMappings: []*data.MappingDef{}}).
+			AddTask(3, 2, "b", "").
+			Build()
+		assert.Nil(t, err)
+
+		provider.flows[flow[0]] = def
+	}
+
+	recorder := NewInMemoryStateRecorder()
+	sink := NewInMemoryDeadLetterSink()
+	fa := NewFlowAction(provider, recorder, &ActionOptions{Record: true, DeadLetterSink: sink, TaskScheduler: &FIFOTaskScheduler{}})
+
+	handler := &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err := fa.Run(nil, "root", nil, handler)
+	assert.Nil(t, err)
+
+	// the grandchild is executing 'a'
+	<-gate.entered
+	rootID := handler.results[0].(*IDResponse).ID
+
+	assert.True(t, fa.CancelInstance(rootID))
+	<-handler.done
+
+	assert.Equal(t, []int{200, CodeCancelled}, handler.codes)
+
+	// the root doesn't wait for the grandchild, which stops after its step
+	gate.release <- true
+	assert.Nil(t, fa.Shutdown(context.Background()))
+	assert.Empty(t, fa.ActiveInstances())
+
+	summaries, err := recorder.ListInstances(&InstanceFilter{FlowURI: "leaf"})
+	assert.Nil(t, err)
+	assert.Len(t, summaries, 1)
+	assert.Equal(t, StatusCancelled, summaries[0].Status)
+
+	snapshot, err := recorder.GetSnapshot(summaries[0].ID)
+	assert.Nil(t, err)
+	assert.Contains(t, snapshot.LastError().Error(), "cancelled with its parent")
+
+	var parentErr *ParentCancelledError
+	assert.True(t, errors.As(leaf.errs[0], &parentErr))
+	assert.Equal(t, summaries[0].ID, parentErr.InstanceID)
+	assert.Equal(t, snapshot.ParentID(), parentErr.ParentID)
+	assert.Equal(t, context.Canceled, parentErr.Err)
+
+	assert.True(t, errors.As(mid.errs[0], &parentErr))
+	assert.Equal(t, rootID, parentErr.ParentID)
+
+	// only the root is sent to the dead-letter sink when it times out
+	fa = NewFlowAction(provider, recorder, &ActionOptions{Record: true, DeadLetterSink: sink, ExecutionTimeout: 20 * time.Millisecond, TaskScheduler: &FIFOTaskScheduler{}})
+
+	handler = &errorResultHandler{testResultHandler: newTestResultHandler()}
+	err = fa.Run(nil, "root", nil, handler)
+	assert.Nil(t, err)
+
+	<-gate.entered
+	<-handler.done
+	gate.release <- true
+	assert.Nil(t, fa.Shutdown(context.Background()))
+
+	assert.Equal(t, []int{200, CodeDeadlineExceeded}, handler.codes)
+
+	letters := sink.Letters()
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "root", letters[0].FlowURI)
+	assert.Equal(t, DeadLetterTimedOut, letters[0].Reason)
+
+	summaries, err = recorder.ListInstances(&InstanceFilter{FlowURI: "mid", Statuses: []Status{StatusCancelled}})
+	assert.Nil(t, err)
+	assert.Len(t, summaries, 2)
+}
+
+//TestSubflowSharesSlot
+func TestSubflowSharesSlot(t *testing.T) {
+
+	call := newSubflowActivity("subflowslot", "test")
+
+	provider := newTestFlowProvider(t)
+	provider.flows["slot"] = newTestDefinition(t, fmt.Sprintf(subflowDefJSON, "slot", "subflowslot"))
+
+	// the subflow doesn't wait for the slot held by its parent
+	fa := NewFlowAction(provider, nil, &ActionOptions{MaxConcurrentInstances: 1})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "slot", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, 1, len(call.errs))
+	assert.Nil(t, call.errs[0])
+	assert.Equal(t, "order-1", call.outputs[0]["{T.orderId}"])
+}
+
+//TestSubflowBypassesStartLimits
+func TestSubflowBypassesStartLimits(t *testing.T) {
+
+	call := newSubflowActivity("subflowlimited", "test")
+
+	provider := newTestFlowProvider(t)
+	provider.flows["limited"] = newTestDefinition(t, fmt.Sprintf(subflowDefJSON, "limited", "subflowlimited"))
+
+	// a single start is allowed, and only the one of the parent is admitted
+	var admitted []string
+	limiter := NewStartRateLimiter(0.001, 1, StartRateLimitReject)
+	fa := NewFlowAction(provider, nil, &ActionOptions{
+		StartRateLimiter: limiter,
+		ValidateInputs:   true,
+		AdmitRun: func(uri string, res flowdef.ResourceTags) error {
+			admitted = append(admitted, uri)
+			if uri != "limited" {
+				return errors.New("not admitted")
+			}
+			return nil
+		},
+	})
+
+	handler := newTestResultHandler()
+	err := fa.Run(nil, "limited", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	assert.Equal(t, 1, len(call.errs))
+	assert.Nil(t, call.errs[0])
+	assert.Equal(t, []string{"limited"}, admitted)
+	assert.Equal(t, 0, limiter.Rejected())
+
+	// the trigger starts are still limited
+	assert.Equal(t, ErrStartRateLimited, fa.Run(nil, "limited", nil, newTestResultHandler()))
+}
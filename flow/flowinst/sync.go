@@ -0,0 +1,118 @@
+package flowinst
+
+import (
+	"context"
+	"sync"
+)
+
+// syncResultHandler is an action.ResultHandler that keeps the last result of
+// a run and signals when the run is done
+type syncResultHandler struct {
+	mu   sync.Mutex
+	code int
+	data interface{}
+	err  error
+
+	done chan struct{}
+}
+
+// HandleResult implements action.ResultHandler.HandleResult
+func (rh *syncResultHandler) HandleResult(code int, data interface{}, err error) {
+
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	rh.code = code
+	rh.data = data
+	rh.err = err
+}
+
+// Done implements action.ResultHandler.Done
+func (rh *syncResultHandler) Done() {
+	close(rh.done)
+}
+
+func (rh *syncResultHandler) result() (int, interface{}, error) {
+
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	return rh.code, rh.data, rh.err
+}
+
+// FlowFuture is the pending result of a run started with RunAsync
+type FlowFuture struct {
+	handler *syncResultHandler
+	cancel  context.CancelFunc
+}
+
+// Done returns a channel that is closed when the run is done
+func (f *FlowFuture) Done() <-chan struct{} {
+	return f.handler.done
+}
+
+// Wait blocks until the run is done and returns the last result it reported.
+// If the context is done first, the error of the context is returned and the
+// run continues, see Cancel
+func (f *FlowFuture) Wait(ctx context.Context) (code int, data interface{}, err error) {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	select {
+	case <-f.handler.done:
+		return f.handler.result()
+	case <-ctx.Done():
+		code := CodeCancelled
+		if ctx.Err() == context.DeadlineExceeded {
+			code = CodeDeadlineExceeded
+		}
+		return code, nil, ctx.Err()
+	}
+}
+
+// Cancel cancels the run
+func (f *FlowFuture) Cancel() {
+	f.cancel()
+}
+
+// RunAsync runs the flow and returns a FlowFuture for the last result reported
+// by the run, the run is cancelled with the context
+func (fa *FlowAction) RunAsync(ctx context.Context, uri string, options interface{}) (*FlowFuture, error) {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+
+	future := &FlowFuture{handler: &syncResultHandler{done: make(chan struct{})}, cancel: cancel}
+
+	if err := fa.Run(runCtx, uri, options, future.handler); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go func() {
+		// release the resources of the context once the run is done
+		<-future.handler.done
+		cancel()
+	}()
+
+	return future, nil
+}
+
+// RunSync runs the flow and blocks until it is done, the last result reported
+// by the run is returned. If the context is done before the run, the run is
+// cancelled and the error of the context is returned
+func (fa *FlowAction) RunSync(ctx context.Context, uri string, options interface{}) (code int, data interface{}, err error) {
+
+	future, err := fa.RunAsync(ctx, uri, options)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// the run is cancelled with ctx
+	return future.Wait(ctx)
+}
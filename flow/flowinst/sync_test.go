@@ -0,0 +1,72 @@
+package flowinst
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TIBCOSoftware/flogo-lib/core/data"
+	"github.com/TIBCOSoftware/flogo-lib/flow/flowdef"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestRunSync
+func TestRunSync(t *testing.T) {
+
+	fa := NewFlowAction(newTestFlowProvider(t), nil, nil)
+
+	code, result, err := fa.RunSync(nil, "test", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, code)
+	assert.IsType(t, &IDResponse{}, result)
+
+	// the result of a run that didn't finish
+	fa = NewFlowAction(newTestFlowProvider(t), nil, &ActionOptions{MaxStepCount: 1})
+
+	code, _, err = fa.RunSync(nil, "test", nil)
+	assert.Equal(t, CodeMaxStepCountExceeded, code)
+	assert.IsType(t, &MaxStepCountError{}, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	code, _, err = fa.RunSync(ctx, "test", nil)
+	assert.Equal(t, CodeCancelled, code)
+	assert.Equal(t, context.Canceled, err)
+}
+
+//TestRunAsync
+func TestRunAsync(t *testing.T) {
+
+	def, err := flowdef.NewBuilder().Name("gated").Model("budget").
+		AddTaskRep(&flowdef.TaskRep{ID: 2, TypeID: 2, Name: "a", ActivityType: "gate", OutputMappings: []*data.MappingDef{}}).
+		Build()
+	assert.Nil(t, err)
+
+	provider := &testFlowProvider{flows: map[string]*flowdef.Definition{"gated": def}}
+	fa := NewFlowAction(provider, nil, nil)
+
+	future, err := fa.RunAsync(nil, "gated", &RunOptions{ReturnResult: true})
+	assert.Nil(t, err)
+
+	<-gate.entered
+
+	// the wait can time out without affecting the run
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	code, _, err := future.Wait(ctx)
+	assert.Equal(t, CodeDeadlineExceeded, code)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	gate.release <- true
+	<-future.Done()
+
+	code, result, err := future.Wait(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, int(StatusCompleted), result.(*FlowResult).Status)
+
+	_, err = fa.RunAsync(nil, "unknown", nil)
+	assert.IsType(t, &FlowNotFoundError{}, err)
+}
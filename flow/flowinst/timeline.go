@@ -0,0 +1,85 @@
+package flowinst
+
+import (
+	"time"
+)
+
+// TimelineEventType is the type of a TimelineEvent
+type TimelineEventType string
+
+const (
+	// TeCreated is the creation of the instance
+	TeCreated TimelineEventType = "created"
+
+	// TeStep is the execution of a step of the instance
+	TeStep TimelineEventType = "step"
+
+	// TeStatus is a change of the status of the instance
+	TeStatus TimelineEventType = "status"
+
+	// TeFinished is the instance reaching a final status
+	TeFinished TimelineEventType = "finished"
+)
+
+// TimelineEvent is an event in the lifecycle of an instance
+type TimelineEvent struct {
+	Type       TimelineEventType `json:"type"`
+	Time       time.Time         `json:"time"`
+	StepID     int               `json:"stepId"`
+	TaskID     int               `json:"taskId,omitempty"`
+	TaskName   string            `json:"taskName,omitempty"`
+	ExecType   ExecType          `json:"execType,omitempty"`
+	Status     Status            `json:"status"`
+	PrevStatus Status            `json:"prevStatus,omitempty"`
+}
+
+// Timeline returns the events in the lifecycle of the instance, in the order
+// they happened
+func (pi *Instance) Timeline() []TimelineEvent {
+
+	pi.timelineLock.Lock()
+	defer pi.timelineLock.Unlock()
+
+	timeline := make([]TimelineEvent, len(pi.timeline))
+	copy(timeline, pi.timeline)
+
+	return timeline
+}
+
+func (pi *Instance) addTimelineEvent(event TimelineEvent) {
+
+	event.Time = time.Now()
+	event.StepID = pi.stepID
+	event.Status = pi.status
+
+	pi.timelineLock.Lock()
+	defer pi.timelineLock.Unlock()
+
+	pi.timeline = append(pi.timeline, event)
+}
+
+// trackStep adds the execution of the specified work item to the timeline
+func (pi *Instance) trackStep(workItem *WorkItem) {
+
+	event := TimelineEvent{Type: TeStep, TaskID: workItem.TaskID, ExecType: workItem.ExecType}
+
+	if task := pi.Flow.GetTask(workItem.TaskID); task != nil {
+		event.TaskName = task.Name()
+	}
+
+	pi.addTimelineEvent(event)
+}
+
+// trackStatus adds a change of status to the timeline
+func (pi *Instance) trackStatus(prevStatus Status) {
+
+	if prevStatus == pi.status {
+		return
+	}
+
+	pi.addTimelineEvent(TimelineEvent{Type: TeStatus, PrevStatus: prevStatus})
+
+	if pi.status.IsTerminal() {
+		pi.addTimelineEvent(TimelineEvent{Type: TeFinished})
+	}
+}
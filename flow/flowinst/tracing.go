@@ -0,0 +1,99 @@
+package flowinst
+
+import (
+	"context"
+	"fmt"
+)
+
+// Tags of the spans created for instances and their steps
+const (
+	TagInstanceID = "flow.instance_id"
+	TagFlowURI    = "flow.uri"
+	TagStep       = "flow.step"
+	TagStatus     = "flow.status"
+)
+
+// Tracer creates the spans that trace the execution of instances, it can be
+// backed by OpenTracing or OpenTelemetry
+type Tracer interface {
+
+	// StartSpan starts a span that is a child of the span in the context, if
+	// any, and returns a context carrying the new span
+	StartSpan(ctx context.Context, operation string) (context.Context, Span)
+}
+
+// Span is a traced operation
+type Span interface {
+
+	// SetTag sets a tag of the span
+	SetTag(key string, value interface{})
+
+	// SetError marks the span as failed with the specified error
+	SetError(err error)
+
+	// Finish ends the span
+	Finish()
+}
+
+// startInstanceSpan starts the span of the execution of the instance, it is
+// a child of the span of the context the instance is run with
+func (fa *FlowAction) startInstanceSpan(ctx context.Context, instance *Instance) (context.Context, Span) {
+
+	spanCtx, span := fa.actionOptions.Tracer.StartSpan(ctx, "flow:"+instance.FlowURI)
+
+	span.SetTag(TagInstanceID, instance.ID())
+	span.SetTag(TagFlowURI, instance.FlowURI)
+
+	return spanCtx, span
+}
+
+// finishInstanceSpan tags the span with the status of the instance and marks
+// it with an error if the instance didn't complete
+func finishInstanceSpan(ctx context.Context, span Span, instance *Instance) {
+
+	status := instance.Status()
+	span.SetTag(TagStatus, status.String())
+
+	if status > StatusCompleted {
+		err := instance.LastError()
+
+		if err == nil {
+			err = ctx.Err()
+		}
+
+		if err == nil {
+			err = fmt.Errorf("Flow [%s] ended with status '%s'", instance.ID(), status)
+		}
+
+		span.SetError(err)
+	}
+
+	span.Finish()
+}
+
+// startStepSpan starts the span of a step of the instance
+func (fa *FlowAction) startStepSpan(ctx context.Context, instance *Instance, step int) Span {
+
+	_, span := fa.actionOptions.Tracer.StartSpan(ctx, "flow-step")
+
+	span.SetTag(TagInstanceID, instance.ID())
+	span.SetTag(TagFlowURI, instance.FlowURI)
+	span.SetTag(TagStep, step)
+
+	return span
+}
+
+// finishStepSpan marks the span with the error of the instance if the step
+// failed it
+func finishStepSpan(span Span, instance *Instance) {
+
+	if instance.Status() == StatusFailed {
+		if err := instance.LastError(); err != nil {
+			span.SetError(err)
+		} else {
+			span.SetError(fmt.Errorf("Flow [%s] failed", instance.ID()))
+		}
+	}
+
+	span.Finish()
+}
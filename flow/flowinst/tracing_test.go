@@ -0,0 +1,127 @@
+package flowinst
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSpanKey struct{}
+
+// testSpan is a span recorded by the testTracer
+type testSpan struct {
+	operation string
+	parent    *testSpan
+	tags      map[string]interface{}
+	err       error
+	finished  bool
+}
+
+func (s *testSpan) SetTag(key string, value interface{}) {
+	s.tags[key] = value
+}
+
+func (s *testSpan) SetError(err error) {
+	s.err = err
+}
+
+func (s *testSpan) Finish() {
+	s.finished = true
+}
+
+// testTracer records the started spans, the parent of a span is the span of
+// the context it is started with
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (tr *testTracer) StartSpan(ctx context.Context, operation string) (context.Context, Span) {
+
+	parent, _ := ctx.Value(testSpanKey{}).(*testSpan)
+	span := &testSpan{operation: operation, parent: parent, tags: make(map[string]interface{})}
+
+	tr.mu.Lock()
+	tr.spans = append(tr.spans, span)
+	tr.mu.Unlock()
+
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func (tr *testTracer) started(operation string) []*testSpan {
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	var spans []*testSpan
+	for _, span := range tr.spans {
+		if span.operation == operation {
+			spans = append(spans, span)
+		}
+	}
+
+	return spans
+}
+
+//TestTracer
+func TestTracer(t *testing.T) {
+
+	newSubflowActivity("tracedcall", "test")
+
+	provider := newTestFlowProvider(t)
+	provider.flows["traced"] = newTestDefinition(t, fmt.Sprintf(subflowDefJSON, "traced", "tracedcall"))
+	provider.flows["budget"] = newTestDefinition(t, budgetDefJSON)
+
+	tracer := &testTracer{}
+	fa := NewFlowAction(provider, nil, &ActionOptions{Tracer: tracer})
+
+	ctx, triggerSpan := tracer.StartSpan(context.Background(), "trigger")
+
+	handler := newTestResultHandler()
+	err := fa.Run(ctx, "traced", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	// the span of the instance is a child of the span of the trigger
+	instanceSpans := tracer.started("flow:traced")
+	assert.Equal(t, 1, len(instanceSpans))
+
+	instanceSpan := instanceSpans[0]
+	assert.Equal(t, triggerSpan, instanceSpan.parent)
+	assert.Equal(t, "traced", instanceSpan.tags[TagFlowURI])
+	assert.Equal(t, "completed", instanceSpan.tags[TagStatus])
+	assert.True(t, instanceSpan.finished)
+	assert.Nil(t, instanceSpan.err)
+
+	// the steps and the subflow are children of the instance
+	subflowSpans := tracer.started("flow:test")
+	assert.Equal(t, 1, len(subflowSpans))
+	assert.Equal(t, instanceSpan, subflowSpans[0].parent)
+
+	steps := 0
+	for _, span := range tracer.started("flow-step") {
+		if span.parent == instanceSpan {
+			steps++
+			assert.Equal(t, steps, span.tags[TagStep])
+			assert.Equal(t, instanceSpan.tags[TagInstanceID], span.tags[TagInstanceID])
+			assert.True(t, span.finished)
+		}
+	}
+	assert.True(t, steps > 0)
+
+	// an aborted instance is marked with an error
+	fa = NewFlowAction(provider, nil, &ActionOptions{Tracer: tracer, MaxStepCount: 2})
+
+	handler = newTestResultHandler()
+	err = fa.Run(nil, "budget", nil, handler)
+	assert.Nil(t, err)
+	<-handler.done
+
+	budgetSpans := tracer.started("flow:budget")
+	assert.Equal(t, 1, len(budgetSpans))
+	assert.Equal(t, "aborted", budgetSpans[0].tags[TagStatus])
+	assert.NotNil(t, budgetSpans[0].err)
+}
@@ -1,8 +1,43 @@
 package support
 
+import (
+	"github.com/TIBCOSoftware/flogo-lib/logger"
+)
+
 // ReplyHandler is used to reply back to whoever started the flow instance
 type ReplyHandler interface {
 
 	// Reply is used to reply with the results of the instance execution
 	Reply(replyCode int, replyData interface{}, err error)
 }
+
+// MultiReplyHandler is a ReplyHandler that fans the replies out to several
+// ReplyHandlers, in order
+type MultiReplyHandler struct {
+	handlers []ReplyHandler
+}
+
+// NewMultiReplyHandler creates a new MultiReplyHandler for the specified handlers
+func NewMultiReplyHandler(handlers ...ReplyHandler) *MultiReplyHandler {
+	return &MultiReplyHandler{handlers: handlers}
+}
+
+// Reply implements ReplyHandler.Reply, a handler that panics doesn't keep
+// the reply from the handlers after it
+func (rh *MultiReplyHandler) Reply(replyCode int, replyData interface{}, err error) {
+
+	for _, handler := range rh.handlers {
+		reply(handler, replyCode, replyData, err)
+	}
+}
+
+func reply(handler ReplyHandler, replyCode int, replyData interface{}, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("ReplyHandler panicked: %v", r)
+		}
+	}()
+
+	handler.Reply(replyCode, replyData, err)
+}
@@ -36,6 +36,15 @@ func Errorf(format string, args ...interface{}) {
 	GetDefaultLogger().Errorf(format, args...)
 }
 
+// IsEnabledFor checks if the level is enabled for the default logger, loggers
+// that don't implement LevelEnabler are considered enabled for every level
+func IsEnabledFor(level Level) bool {
+	if enabler, ok := GetDefaultLogger().(LevelEnabler); ok {
+		return enabler.IsEnabledFor(level)
+	}
+	return true
+}
+
 func SetLogLevel(level Level) {
 	GetDefaultLogger().SetLogLevel(level)
 }
@@ -111,6 +111,20 @@ func (logger *DefaultLogger) Errorf(format string, args ...interface{}) {
 	logger.loggerImpl.Errorf(format, args...)
 }
 
+// IsEnabledFor checks if the level is enabled.
+func (logger *DefaultLogger) IsEnabledFor(level Level) bool {
+	switch level {
+	case DebugLevel:
+		return logger.DebugEnabled()
+	case InfoLevel:
+		return logger.InfoEnabled()
+	case WarnLevel:
+		return logger.WarnEnabled()
+	default:
+		return logger.ErrorEnabled()
+	}
+}
+
 //SetLog Level
 func (logger *DefaultLogger) SetLogLevel(logLevel Level) {
 	switch logLevel {
@@ -33,3 +33,19 @@ func TestConcurrentGetLoggerOk(t *testing.T) {
 	w.Wait()
 	assert.NotNil(t, f, "Recovered not nil, some problem getting logger")
 }
+
+// TestIsEnabledFor tests that the levels below the log level are disabled
+func TestIsEnabledFor(t *testing.T) {
+	f := &DefaultLoggerFactory{}
+	l := f.GetLogger("levelTest")
+	defer l.SetLogLevel(InfoLevel)
+
+	l.SetLogLevel(WarnLevel)
+
+	enabler, ok := l.(LevelEnabler)
+	assert.True(t, ok)
+	assert.False(t, enabler.IsEnabledFor(DebugLevel))
+	assert.False(t, enabler.IsEnabledFor(InfoLevel))
+	assert.True(t, enabler.IsEnabledFor(WarnLevel))
+	assert.True(t, enabler.IsEnabledFor(ErrorLevel))
+}
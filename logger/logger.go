@@ -16,6 +16,12 @@ type Logger interface {
 	SetLogLevel(Level)
 }
 
+// LevelEnabler is implemented by Loggers that can report if a level is
+// enabled, it is used to skip building expensive log messages
+type LevelEnabler interface {
+	IsEnabledFor(level Level) bool
+}
+
 type LoggerFactory interface {
 	GetLogger(name string) Logger
 }
@@ -0,0 +1,38 @@
+package util
+
+import (
+	"time"
+)
+
+// Clock is a source of the current time, it can be replaced to control the
+// passing of time in tests
+type Clock interface {
+	Now() time.Time
+}
+
+// TimerClock is implemented by Clocks that also schedule timers, the system
+// timers are used for the Clocks that don't implement it
+type TimerClock interface {
+	Clock
+
+	// AfterFunc calls f once the duration elapsed on the clock, the returned
+	// function cancels the call, it returns false if f was already called
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// SystemClock is the Clock of the system
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// AfterFunc calls f once the duration elapsed on the clock
+func AfterFunc(clock Clock, d time.Duration, f func()) (stop func() bool) {
+
+	if timerClock, ok := clock.(TimerClock); ok {
+		return timerClock.AfterFunc(d, f)
+	}
+
+	return time.AfterFunc(d, f).Stop
+}
@@ -52,6 +52,11 @@ import (
 // Generator represents a UUID generator that
 // generates UUIDs in sequence from a random starting
 // point.
+//
+// The UUIDs of a Generator never collide, the counter
+// only repeats after 2^64 UUIDs, and the UUIDs of
+// different Generators are unlikely to collide as each
+// one starts from its own 192 bit random seed.
 type Generator struct {
 	seed    [24]byte
 	counter uint64
@@ -68,6 +73,18 @@ func NewGenerator() (*Generator, error) {
 	return &g, nil
 }
 
+// MustNewGenerator returns a new Generator, it panics
+// if the random seed cannot be read rather than
+// returning a Generator that would produce predictable
+// UUIDs.
+func MustNewGenerator() *Generator {
+	g, err := NewGenerator()
+	if err != nil {
+		panic("util.MustNewGenerator: " + err.Error())
+	}
+	return g
+}
+
 // Next returns the next UUID from the generator.
 // Only the first 8 bytes can differ from the previous
 // UUID, so taking a slice of the first 16 bytes
@@ -87,15 +104,10 @@ func (g *Generator) Next() [24]byte {
 }
 
 // NextAsString returns the next UUID from the generator as a string.
+//
+// It is OK to call this method concurrently.
 func (g *Generator) NextAsString() string {
-	x := atomic.AddUint64(&g.counter, 1)
-	var counterBytes [8]byte
-	binary.LittleEndian.PutUint64(counterBytes[:], x)
-
-	uuid := g.seed
-	for i, b := range counterBytes {
-		uuid[i] ^= b
-	}
+	uuid := g.Next()
 
 	buf := make([]byte, 32)
 	hex.Encode(buf[0:], uuid[0:16]) //truncate to 128bit UUID
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//TestNextAsStringConcurrent
+func TestNextAsStringConcurrent(t *testing.T) {
+
+	g := MustNewGenerator()
+
+	const goroutines = 50
+	const perGoroutine = 1000
+
+	ids := make([][]string, goroutines)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			ids[i] = make([]string, perGoroutine)
+			for j := range ids[i] {
+				ids[i][j] = g.NextAsString()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	seen := make(map[string]bool, goroutines*perGoroutine)
+
+	for _, batch := range ids {
+		for _, id := range batch {
+			assert.Len(t, id, 32)
+			seen[id] = true
+		}
+	}
+
+	// no duplicates
+	assert.Len(t, seen, goroutines*perGoroutine)
+}
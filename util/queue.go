@@ -54,3 +54,34 @@ func (sq *SyncQueue) IsEmpty() bool {
 
 	return (sq.List.Len() == 0)
 }
+
+// Items returns the items in the queue, starting with the item that would be
+// popped next
+func (sq *SyncQueue) Items() []interface{} {
+	sq.lock.Lock()
+	defer sq.lock.Unlock()
+
+	items := make([]interface{}, 0, sq.List.Len())
+
+	for e := sq.List.Front(); e != nil; e = e.Next() {
+		items = append(items, e.Value)
+	}
+
+	return items
+}
+
+// Remove removes the specified item from the queue, returns false if the
+// item wasn't found
+func (sq *SyncQueue) Remove(item interface{}) bool {
+	sq.lock.Lock()
+	defer sq.lock.Unlock()
+
+	for e := sq.List.Front(); e != nil; e = e.Next() {
+		if e.Value == item {
+			sq.List.Remove(e)
+			return true
+		}
+	}
+
+	return false
+}